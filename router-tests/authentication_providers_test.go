@@ -0,0 +1,139 @@
+package integration_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+)
+
+func TestAuthenticationProviders(t *testing.T) {
+	t.Parallel()
+
+	t.Run("static JWT secret", func(t *testing.T) {
+		t.Parallel()
+
+		const secret = "0123456789abcdef0123456789abcdef"
+		authenticator, err := authentication.NewJWTAuthenticator(authentication.JWTAuthenticatorOptions{
+			Name:   "static",
+			Secret: secret,
+		})
+		require.NoError(t, err)
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithAccessController(core.NewAccessController([]authentication.Authenticator{authenticator}, true)),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-1"}).SignedString([]byte(secret))
+			require.NoError(t, err)
+
+			res, err := xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{
+				"Authorization": []string{"Bearer " + token},
+			}, strings.NewReader(employeesQuery))
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			require.Equal(t, "static", res.Header.Get(xAuthenticatedByHeader))
+			data, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.Equal(t, employeesExpectedData, string(data))
+
+			res, err = xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{
+				"Authorization": []string{"Bearer invalid"},
+			}, strings.NewReader(employeesQuery))
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		})
+	})
+
+	t.Run("token introspection", func(t *testing.T) {
+		t.Parallel()
+
+		introspection := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = r.ParseForm()
+			response := map[string]any{"active": false}
+			if r.PostForm.Get("token") == "opaque-token" {
+				response = map[string]any{"active": true, "sub": "user-1", "scope": "read:employee read:private"}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(response)
+		}))
+		t.Cleanup(introspection.Close)
+
+		authenticator, err := authentication.NewIntrospectionAuthenticator(authentication.IntrospectionAuthenticatorOptions{
+			Name: "introspection",
+			URL:  introspection.URL,
+		})
+		require.NoError(t, err)
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithAccessController(core.NewAccessController([]authentication.Authenticator{authenticator}, true)),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res, err := xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{
+				"Authorization": []string{"Bearer opaque-token"},
+			}, strings.NewReader(employeesQueryRequiringClaims))
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			require.Equal(t, "introspection", res.Header.Get(xAuthenticatedByHeader))
+
+			res, err = xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{
+				"Authorization": []string{"Bearer revoked-token"},
+			}, strings.NewReader(employeesQuery))
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		})
+	})
+
+	t.Run("API keys", func(t *testing.T) {
+		t.Parallel()
+
+		hash := sha256.Sum256([]byte("my-api-key"))
+		keyFile := filepath.Join(t.TempDir(), "api_keys.yaml")
+		require.NoError(t, os.WriteFile(keyFile, []byte("keys:\n  - name: ci\n    hash: sha256:"+hex.EncodeToString(hash[:])+"\n"), 0o600))
+
+		authenticator, err := authentication.NewAPIKeyAuthenticator(authentication.APIKeyAuthenticatorOptions{
+			Name:    "api-key",
+			KeyFile: keyFile,
+		})
+		require.NoError(t, err)
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithAccessController(core.NewAccessController([]authentication.Authenticator{authenticator}, true)),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res, err := xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{
+				"X-API-Key": []string{"my-api-key"},
+			}, strings.NewReader(employeesQuery))
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			require.Equal(t, "api-key", res.Header.Get(xAuthenticatedByHeader))
+
+			res, err = xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{
+				"X-API-Key": []string{"wrong-key"},
+			}, strings.NewReader(employeesQuery))
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		})
+	})
+}
@@ -0,0 +1,87 @@
+package integration_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/jwks"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestAuthenticationValidation(t *testing.T) {
+	t.Parallel()
+
+	authServer, err := jwks.NewServer(t)
+	require.NoError(t, err)
+	t.Cleanup(authServer.Close)
+
+	authenticator, err := authentication.NewJWKSAuthenticator(authentication.JWKSAuthenticatorOptions{
+		Name: jwksName,
+		URL:  authServer.JWKSURL(),
+		Validation: authentication.TokenValidationOptions{
+			Audiences:      []string{"router"},
+			RequiredClaims: []string{"sub"},
+		},
+	})
+	require.NoError(t, err)
+
+	metricReader := metric.NewManualReader()
+
+	testenv.Run(t, &testenv.Config{
+		MetricReader: metricReader,
+		RouterOptions: []core.Option{
+			core.WithAccessController(core.NewAccessController([]authentication.Authenticator{authenticator}, true)),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		token, err := authServer.Token(map[string]any{"sub": "user-1", "aud": "router"})
+		require.NoError(t, err)
+
+		res, err := xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{
+			"Authorization": []string{"Bearer " + token},
+		}, strings.NewReader(employeesQuery))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		token, err = authServer.Token(map[string]any{"sub": "user-1", "aud": "another-service"})
+		require.NoError(t, err)
+
+		res, err = xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{
+			"Authorization": []string{"Bearer " + token},
+		}, strings.NewReader(employeesQuery))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, metricReader.Collect(context.Background(), &rm))
+
+		var failures *metricdata.Sum[int64]
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name == "router.authentication.failures" {
+					sum := m.Data.(metricdata.Sum[int64])
+					failures = &sum
+				}
+			}
+		}
+		require.NotNil(t, failures)
+		require.Len(t, failures.DataPoints, 1)
+		require.Equal(t, int64(1), failures.DataPoints[0].Value)
+
+		reason, ok := failures.DataPoints[0].Attributes.Value(attribute.Key("wg.authentication.failure_reason"))
+		require.True(t, ok)
+		require.Equal(t, "invalid_audience", reason.AsString())
+		provider, ok := failures.DataPoints[0].Attributes.Value(attribute.Key("wg.authentication.provider"))
+		require.True(t, ok)
+		require.Equal(t, jwksName, provider.AsString())
+	})
+}
@@ -0,0 +1,171 @@
+package integration_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/authz"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/authorization"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestExternalAuthorization(t *testing.T) {
+	t.Parallel()
+
+	// Admins can load all employees, everybody else only their own one
+	decide := func(request *authorization.Request) *authorization.Decision {
+		if request.Claims["role"] == "admin" {
+			return &authorization.Decision{Allow: true}
+		}
+		for _, args := range request.Arguments {
+			if args["id"] != request.Claims["employee_id"] {
+				return &authorization.Decision{Reason: "not your employee"}
+			}
+		}
+		return &authorization.Decision{Allow: len(request.Arguments) > 0}
+	}
+
+	for _, protocol := range []string{authorization.ProtocolHTTP, authorization.ProtocolGRPC} {
+		protocol := protocol
+
+		t.Run(protocol, func(t *testing.T) {
+			t.Parallel()
+
+			authzServer := authz.NewServer(t, decide)
+			url := authzServer.HTTPURL()
+			if protocol == authorization.ProtocolGRPC {
+				url = authzServer.GRPCURL()
+			}
+
+			authenticators, authServer := configureAuth(t)
+
+			testenv.Run(t, &testenv.Config{
+				RouterOptions: []core.Option{
+					core.WithAccessController(core.NewAccessController(authenticators, false)),
+					core.WithAuthorizationConfig(&config.AuthorizationConfiguration{
+						External: config.ExternalAuthorizationConfiguration{
+							Enabled:  true,
+							Protocol: protocol,
+							URL:      url,
+							Timeout:  time.Second,
+							Fields:   []string{"Query.employees", "Query.employee"},
+						},
+					}),
+				},
+			}, func(t *testing.T, xEnv *testenv.Environment) {
+				request := func(t *testing.T, claims map[string]any, query string) string {
+					token, err := authServer.Token(claims)
+					require.NoError(t, err)
+					res, err := xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{
+						"Authorization": []string{"Bearer " + token},
+					}, strings.NewReader(query))
+					require.NoError(t, err)
+					defer res.Body.Close()
+					require.Equal(t, http.StatusOK, res.StatusCode)
+					data, err := io.ReadAll(res.Body)
+					require.NoError(t, err)
+					return string(data)
+				}
+
+				require.Equal(t, employeesExpectedData, request(t, map[string]any{"role": "admin"}, employeesQuery))
+
+				data := request(t, map[string]any{"role": "viewer"}, employeesQuery)
+				require.Contains(t, data, `"message":"Unauthorized to load field 'Query.employees', Reason: denied by external authorization."`)
+
+				data = request(t, map[string]any{"employee_id": 1}, `{"query":"query Employee($id: Int!) { employee(id: $id) { id } }","variables":{"id":1}}`)
+				require.Equal(t, `{"data":{"employee":{"id":1}}}`, data)
+
+				data = request(t, map[string]any{"employee_id": 1}, `{"query":"{ employee(id: 2) { id } }"}`)
+				require.Contains(t, data, "Reason: not your employee.")
+
+				requests := authzServer.Requests()
+				require.Len(t, requests, 4)
+				require.Equal(t, "Employee", requests[2].Operation.Name)
+				require.Equal(t, "query", requests[2].Operation.Type)
+				require.Equal(t, authorization.Coordinate{TypeName: "Query", FieldName: "employee"}, requests[2].Coordinate)
+				require.Equal(t, []map[string]any{{"id": float64(1)}}, requests[2].Arguments)
+				require.Equal(t, float64(1), requests[2].Claims["employee_id"])
+
+				// Decisions are cached
+				data = request(t, map[string]any{"role": "viewer"}, employeesQuery)
+				require.Contains(t, data, "denied by external authorization")
+				require.Len(t, authzServer.Requests(), 4)
+			})
+		})
+	}
+
+	t.Run("fail closed", func(t *testing.T) {
+		t.Parallel()
+
+		authzServer := authz.NewServer(t, decide)
+		authzServer.Close()
+
+		authenticators, authServer := configureAuth(t)
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithAccessController(core.NewAccessController(authenticators, false)),
+				core.WithAuthorizationConfig(&config.AuthorizationConfiguration{
+					External: config.ExternalAuthorizationConfiguration{
+						Enabled: true,
+						URL:     authzServer.HTTPURL(),
+						Timeout: time.Second,
+						Fields:  []string{"Query.employees"},
+					},
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			token, err := authServer.Token(map[string]any{"role": "admin"})
+			require.NoError(t, err)
+			res, err := xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{
+				"Authorization": []string{"Bearer " + token},
+			}, strings.NewReader(employeesQuery))
+			require.NoError(t, err)
+			defer res.Body.Close()
+			data, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(data), "Reason: external authorization failed.")
+		})
+	})
+
+	t.Run("fail open", func(t *testing.T) {
+		t.Parallel()
+
+		authzServer := authz.NewServer(t, decide)
+		authzServer.Close()
+
+		authenticators, authServer := configureAuth(t)
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithAccessController(core.NewAccessController(authenticators, false)),
+				core.WithAuthorizationConfig(&config.AuthorizationConfiguration{
+					External: config.ExternalAuthorizationConfiguration{
+						Enabled:  true,
+						URL:      authzServer.HTTPURL(),
+						Timeout:  time.Second,
+						FailOpen: true,
+						Fields:   []string{"Query.employees"},
+					},
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			token, err := authServer.Token(map[string]any{"role": "viewer"})
+			require.NoError(t, err)
+			res, err := xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{
+				"Authorization": []string{"Bearer " + token},
+			}, strings.NewReader(employeesQuery))
+			require.NoError(t, err)
+			defer res.Body.Close()
+			data, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.Equal(t, employeesExpectedData, string(data))
+		})
+	})
+}
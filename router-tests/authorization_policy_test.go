@@ -0,0 +1,76 @@
+package integration_test
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestAuthorizationPolicies(t *testing.T) {
+	t.Parallel()
+
+	policyFile := filepath.Join(t.TempDir(), "policies.yaml")
+	require.NoError(t, os.WriteFile(policyFile, []byte(`policies:
+  - field: Query.employees
+    rule: claims.roles contains "admin"
+  - field: Query.employee
+    rule: claims.employee_id == args.id || claims.roles contains "admin"
+`), 0o600))
+
+	authenticators, authServer := configureAuth(t)
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithAccessController(core.NewAccessController(authenticators, false)),
+			core.WithAuthorizationConfig(&config.AuthorizationConfiguration{
+				PolicyFile: policyFile,
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		request := func(t *testing.T, claims map[string]any, query string) string {
+			header := http.Header{}
+			if claims != nil {
+				token, err := authServer.Token(claims)
+				require.NoError(t, err)
+				header.Set("Authorization", "Bearer "+token)
+			}
+			res, err := xEnv.MakeRequest(http.MethodPost, "/graphql", header, strings.NewReader(query))
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			data, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			return string(data)
+		}
+
+		t.Run("claim contains role", func(t *testing.T) {
+			require.Equal(t, employeesExpectedData, request(t, map[string]any{"roles": []string{"admin"}}, employeesQuery))
+
+			data := request(t, map[string]any{"roles": []string{"viewer"}}, employeesQuery)
+			require.Contains(t, data, `"message":"Unauthorized to load field 'Query.employees', Reason: authorization policy not satisfied."`)
+
+			data = request(t, nil, employeesQuery)
+			require.Contains(t, data, `"message":"Unauthorized to load field 'Query.employees', Reason: not authenticated."`)
+		})
+
+		t.Run("claim matches argument", func(t *testing.T) {
+			data := request(t, map[string]any{"employee_id": 1}, `{"query":"{ employee(id: 1) { id } }"}`)
+			require.Equal(t, `{"data":{"employee":{"id":1}}}`, data)
+
+			data = request(t, map[string]any{"employee_id": 1}, `{"query":"query($id: Int!) { employee(id: $id) { id } }","variables":{"id":2}}`)
+			require.Contains(t, data, "authorization policy not satisfied")
+
+			// Every selection of the field must satisfy the rule
+			data = request(t, map[string]any{"employee_id": 1}, `{"query":"{ a: employee(id: 1) { id } b: employee(id: 2) { id } }"}`)
+			require.Contains(t, data, "authorization policy not satisfied")
+		})
+	})
+}
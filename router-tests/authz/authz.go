@@ -0,0 +1,100 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/wundergraph/cosmo/router/pkg/authorization"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const httpPath = "/authorize"
+
+// DecideFunc returns the decision of the stub for a request
+type DecideFunc func(request *authorization.Request) *authorization.Decision
+
+// Server is a stub external authorization service. It serves the HTTP endpoint and the gRPC
+// procedure on the same port.
+type Server struct {
+	httpServer *httptest.Server
+	decide     DecideFunc
+
+	mu       sync.Mutex
+	requests []authorization.Request
+}
+
+func NewServer(t *testing.T, decide DecideFunc) *Server {
+	s := &Server{decide: decide}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(httpPath, s.serveHTTP)
+	mux.Handle(authorization.GRPCProcedure, connect.NewUnaryHandler(authorization.GRPCProcedure, s.serveGRPC))
+
+	// gRPC requires HTTP/2, the router connects without TLS
+	s.httpServer = httptest.NewServer(h2c.NewHandler(mux, &http2.Server{}))
+	t.Cleanup(s.httpServer.Close)
+
+	return s
+}
+
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// HTTPURL is the URL of the HTTP endpoint
+func (s *Server) HTTPURL() string {
+	return s.httpServer.URL + httpPath
+}
+
+// GRPCURL is the base URL of the gRPC service
+func (s *Server) GRPCURL() string {
+	return s.httpServer.URL
+}
+
+// Requests returns all requests received by the server
+func (s *Server) Requests() []authorization.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]authorization.Request(nil), s.requests...)
+}
+
+func (s *Server) handle(request *authorization.Request) *authorization.Decision {
+	s.mu.Lock()
+	s.requests = append(s.requests, *request)
+	s.mu.Unlock()
+	return s.decide(request)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var request authorization.Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.handle(&request))
+}
+
+func (s *Server) serveGRPC(ctx context.Context, req *connect.Request[structpb.Struct]) (*connect.Response[structpb.Struct], error) {
+	data, err := req.Msg.MarshalJSON()
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	var request authorization.Request
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	decision := s.handle(&request)
+	response, err := structpb.NewStruct(map[string]any{"allow": decision.Allow, "reason": decision.Reason})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(response), nil
+}
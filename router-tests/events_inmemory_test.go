@@ -0,0 +1,211 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/pubsub"
+)
+
+// inMemoryEvents replaces the NATS sources of the test environment with in-memory sources on bus
+func inMemoryEvents(bus *pubsub.Bus, subscriptions ...config.EventSubscriptionConfiguration) []core.Option {
+	return []core.Option{
+		core.WithEvents(config.EventsConfiguration{
+			Sources: map[string]config.EventSource{
+				"default": {Provider: "INMEMORY"},
+				"my-nats": {Provider: "INMEMORY"},
+			},
+			Subscriptions: subscriptions,
+		}),
+		core.WithEventBus("default", bus),
+		core.WithEventBus("my-nats", bus),
+	}
+}
+
+func TestEventsInMemory(t *testing.T) {
+	t.Parallel()
+
+	t.Run("subscribe", func(t *testing.T) {
+		t.Parallel()
+
+		bus := pubsub.NewBus()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: inMemoryEvents(bus),
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			var subscription struct {
+				employeeUpdated struct {
+					ID      float64 `graphql:"id"`
+					Details struct {
+						Forename string `graphql:"forename"`
+					} `graphql:"details"`
+				} `graphql:"employeeUpdated(employeeID: 3)"`
+			}
+
+			client := graphql.NewSubscriptionClient(xEnv.GraphQLSubscriptionURL())
+			t.Cleanup(func() {
+				_ = client.Close()
+			})
+
+			wg := &sync.WaitGroup{}
+			wg.Add(1)
+
+			subscriptionID, err := client.Subscribe(&subscription, nil, func(dataValue []byte, errValue error) error {
+				defer wg.Done()
+				require.NoError(t, errValue)
+				require.JSONEq(t, `{"employeeUpdated":{"id":3,"details":{"forename":"Stefan"}}}`, string(dataValue))
+				return nil
+			})
+			require.NoError(t, err)
+			require.NotEqual(t, "", subscriptionID)
+
+			go func() {
+				clientErr := client.Run()
+				require.NoError(t, clientErr)
+			}()
+
+			go func() {
+				wg.Wait()
+				unsubscribeErr := client.Unsubscribe(subscriptionID)
+				require.NoError(t, unsubscribeErr)
+				clientCloseErr := client.Close()
+				require.NoError(t, clientCloseErr)
+			}()
+
+			xEnv.WaitForSubscriptionCount(1, time.Second*5)
+
+			// Trigger the subscription on the in-memory bus
+			require.NoError(t, bus.Publish("employeeUpdated.3", []byte(`{"id":3,"__typename": "Employee"}`)))
+
+			xEnv.WaitForMessagesSent(1, time.Second*10)
+			xEnv.WaitForSubscriptionCount(0, time.Second*10)
+			xEnv.WaitForConnectionCount(0, time.Second*10)
+		})
+	})
+
+	t.Run("subscribe with transform and filter", func(t *testing.T) {
+		t.Parallel()
+
+		bus := pubsub.NewBus()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: inMemoryEvents(bus, config.EventSubscriptionConfiguration{
+				Field: "Subscription.employeeUpdated",
+				Transform: &config.EventTransformConfiguration{
+					Fields:   map[string]string{"id": "employee.id"},
+					TypeName: "Employee",
+				},
+				Filter: []config.EventFilterCondition{{Path: "id", Argument: "employeeID"}},
+			}),
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			var subscription struct {
+				employeeUpdated struct {
+					ID      float64 `graphql:"id"`
+					Details struct {
+						Forename string `graphql:"forename"`
+					} `graphql:"details"`
+				} `graphql:"employeeUpdated(employeeID: 3)"`
+			}
+
+			client := graphql.NewSubscriptionClient(xEnv.GraphQLSubscriptionURL())
+			t.Cleanup(func() {
+				_ = client.Close()
+			})
+
+			updates := make(chan string, 2)
+			subscriptionID, err := client.Subscribe(&subscription, nil, func(dataValue []byte, errValue error) error {
+				require.NoError(t, errValue)
+				updates <- string(dataValue)
+				return nil
+			})
+			require.NoError(t, err)
+			require.NotEqual(t, "", subscriptionID)
+
+			go func() {
+				_ = client.Run()
+			}()
+
+			xEnv.WaitForSubscriptionCount(1, time.Second*5)
+
+			// The event of another employee is filtered, the broker payload is mapped to the Employee entity
+			require.NoError(t, bus.Publish("employeeUpdated.3", []byte(`{"employee":{"id":4}}`)))
+			require.NoError(t, bus.Publish("employeeUpdated.3", []byte(`{"employee":{"id":3}}`)))
+
+			select {
+			case update := <-updates:
+				require.JSONEq(t, `{"employeeUpdated":{"id":3,"details":{"forename":"Stefan"}}}`, update)
+			case <-time.After(10 * time.Second):
+				t.Fatal("timed out waiting for the subscription update")
+			}
+			select {
+			case update := <-updates:
+				t.Fatalf("unexpected update %s", update)
+			case <-time.After(100 * time.Millisecond):
+			}
+
+			require.NoError(t, client.Unsubscribe(subscriptionID))
+			xEnv.WaitForSubscriptionCount(0, time.Second*10)
+		})
+	})
+
+	t.Run("request", func(t *testing.T) {
+		t.Parallel()
+
+		bus := pubsub.NewBus()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: inMemoryEvents(bus),
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			unsubscribe, err := bus.Subscribe("getEmployee.*", func(msg *pubsub.Msg) {
+				require.NoError(t, msg.Respond([]byte(`{"id": 3, "__typename": "Employee"}`)))
+			})
+			require.NoError(t, err)
+			t.Cleanup(unsubscribe)
+
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `query { employeeFromEvent(id: 3) { id details { forename } }}`,
+			})
+			require.JSONEq(t, `{"data":{"employeeFromEvent": {"id": 3, "details": {"forename": "Stefan"}}}}`, res.Body)
+		})
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		t.Parallel()
+
+		bus := pubsub.NewBus()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: inMemoryEvents(bus),
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			messages := make(chan *pubsub.Msg, 1)
+			unsubscribe, err := bus.Subscribe("updateEmployee.>", func(msg *pubsub.Msg) {
+				messages <- msg
+			})
+			require.NoError(t, err)
+			t.Cleanup(unsubscribe)
+
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `mutation UpdateEmployee($update: UpdateEmployeeInput!) {
+							updateEmployee(id: 3, update: $update) {success}
+						}`,
+				Variables: json.RawMessage(`{"update":{"name":"Stefan Avramovic","email":"avramovic@wundergraph.com"}}`),
+			})
+			require.JSONEq(t, `{"data":{"updateEmployee": {"success": true}}}`, res.Body)
+
+			select {
+			case msg := <-messages:
+				require.Equal(t, "updateEmployee.3", msg.Subject)
+				require.Equal(t, `{"id":3,"update":{"name":"Stefan Avramovic","email":"avramovic@wundergraph.com"}}`, string(msg.Data))
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the published event")
+			}
+		})
+	})
+}
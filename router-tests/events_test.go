@@ -3,18 +3,23 @@ package integration_test
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/wundergraph/cosmo/router/pkg/config"
 	"net/http"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/hasura/go-graphql-client"
 	"github.com/stretchr/testify/require"
 	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
 )
 
 func TestEventsNew(t *testing.T) {
@@ -619,6 +624,104 @@ func TestEventsNew(t *testing.T) {
 		})
 	})
 
+	t.Run("subscribe with stream and ephemeral consumers", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			ModifyEngineExecutionConfiguration: func(engineExecutionConfiguration *config.EngineExecutionConfiguration) {
+				engineExecutionConfiguration.WebSocketReadTimeout = time.Millisecond * 10
+			},
+			ModifyEventsConfiguration: func(eventsConfiguration *config.EventsConfiguration) {
+				source := eventsConfiguration.Sources["default"]
+				source.JetStream = &config.NatsJetStreamConfiguration{
+					EphemeralConsumers: true,
+					MaxDeliver:         3,
+				}
+				eventsConfiguration.Sources["default"] = source
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			type subscriptionPayload struct {
+				Data struct {
+					EmployeeUpdatedStream struct {
+						ID float64 `graphql:"id"`
+					} `graphql:"employeeUpdatedStream(id: 12)"`
+				} `json:"data"`
+			}
+
+			js, err := jetstream.New(xEnv.NatsConnectionDefault)
+			require.NoError(t, err)
+
+			stream, err := js.CreateOrUpdateStream(xEnv.Context, jetstream.StreamConfig{
+				Name:     "streamName",
+				Subjects: []string{"employeeUpdated.>"},
+				Storage:  jetstream.MemoryStorage,
+			})
+			require.NoError(t, err)
+
+			// Published before the subscription started, it is not delivered to the ephemeral consumer
+			err = xEnv.NatsConnectionDefault.Publish("employeeUpdated.12", []byte(`{"id":12,"__typename":"Employee"}`))
+			require.NoError(t, err)
+
+			conn := xEnv.InitGraphQLWebSocketConnection(nil, nil)
+			err = conn.WriteJSON(&testenv.WebSocketMessage{
+				ID:      "1",
+				Type:    "subscribe",
+				Payload: []byte(`{"query":"subscription { employeeUpdatedStream(id: 12) { id }}"}`),
+			})
+			require.NoError(t, err)
+
+			xEnv.WaitForSubscriptionCount(1, time.Second*5)
+
+			consumers := func() []string {
+				var names []string
+				lister := stream.ListConsumers(xEnv.Context)
+				for info := range lister.Info() {
+					names = append(names, info.Name)
+				}
+				require.NoError(t, lister.Err())
+				return names
+			}
+			names := consumers()
+			require.Len(t, names, 1)
+			require.True(t, strings.HasPrefix(names[0], "consumerName-"))
+
+			err = xEnv.NatsConnectionDefault.Publish("employeeUpdated.12", []byte(`{"id":13,"__typename":"Employee"}`))
+			require.NoError(t, err)
+
+			var msg testenv.WebSocketMessage
+			var payload subscriptionPayload
+			err = conn.ReadJSON(&msg)
+			require.NoError(t, err)
+			require.Equal(t, "1", msg.ID)
+			require.Equal(t, "next", msg.Type)
+			err = json.Unmarshal(msg.Payload, &payload)
+			require.NoError(t, err)
+			require.Equal(t, float64(13), payload.Data.EmployeeUpdatedStream.ID)
+
+			// The message was acknowledged when it was handed off to the subscription
+			require.Eventually(t, func() bool {
+				info, err := js.Consumer(xEnv.Context, "streamName", names[0])
+				if err != nil {
+					return false
+				}
+				cached := info.CachedInfo()
+				return cached.NumAckPending == 0 && cached.AckFloor.Consumer == 1
+			}, time.Second*5, time.Millisecond*100)
+
+			// The ephemeral consumer is deleted when the subscription ends
+			err = conn.WriteJSON(&testenv.WebSocketMessage{
+				ID:   "1",
+				Type: "complete",
+			})
+			require.NoError(t, err)
+			xEnv.WaitForSubscriptionCount(0, time.Second*10)
+
+			require.Eventually(t, func() bool {
+				return len(consumers()) == 0
+			}, time.Second*5, time.Millisecond*100)
+		})
+	})
+
 	t.Run("subscribing to a non-existent stream returns an error", func(t *testing.T) {
 		testenv.Run(t, &testenv.Config{}, func(t *testing.T, xEnv *testenv.Environment) {
 			var subscription struct {
@@ -658,4 +761,56 @@ func TestEventsNew(t *testing.T) {
 			wg.Wait()
 		})
 	})
+
+	t.Run("connections are closed on shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{}, func(t *testing.T, xEnv *testenv.Environment) {
+			// NatsConnectionDefault and NatsConnectionMyNats of the test environment stay connected
+			testConnections := 2
+			require.Greater(t, xEnv.NatsServer.NumClients(), testConnections)
+
+			xEnv.Shutdown()
+			require.Eventually(t, func() bool {
+				return xEnv.NatsServer.NumClients() == testConnections
+			}, time.Second*5, time.Millisecond*100)
+		})
+	})
+	t.Run("connections are closed when a config update fails", func(t *testing.T) {
+		t.Parallel()
+
+		redis := miniredis.RunT(t)
+		var routerConfig *nodev1.RouterConfig
+
+		testenv.Run(t, &testenv.Config{
+			ModifyRouterConfig: func(cfg *nodev1.RouterConfig) {
+				routerConfig = cfg
+			},
+			RouterOptions: []core.Option{
+				core.WithRateLimitConfig(&config.RateLimitConfiguration{
+					Enabled:  true,
+					Strategy: "simple",
+					SimpleStrategy: config.RateLimitSimpleStrategy{
+						Rate:   1000,
+						Burst:  1000,
+						Period: time.Second,
+					},
+					Storage: config.RedisConfiguration{
+						Url: "redis://" + redis.Addr(),
+					},
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			connections := xEnv.NatsServer.NumClients()
+
+			// The new server connects to the event sources before it fails to connect to redis
+			redis.Close()
+			_, err := xEnv.Router.UpdateServer(context.Background(), routerConfig)
+			require.ErrorContains(t, err, "failed to connect to redis")
+
+			require.Eventually(t, func() bool {
+				return xEnv.NatsServer.NumClients() == connections
+			}, time.Second*5, time.Millisecond*100)
+		})
+	})
 }
@@ -0,0 +1,144 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestForwardClaims(t *testing.T) {
+	t.Parallel()
+
+	t.Run("forward claims as headers", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			mu      sync.Mutex
+			headers []http.Header
+		)
+
+		authenticators, authServer := configureAuth(t)
+		testenv.Run(t, &testenv.Config{
+			Subgraphs: testenv.SubgraphsConfig{
+				Employees: testenv.SubgraphConfig{
+					Middleware: func(next http.Handler) http.Handler {
+						return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+							mu.Lock()
+							headers = append(headers, r.Header.Clone())
+							mu.Unlock()
+							next.ServeHTTP(w, r)
+						})
+					},
+				},
+			},
+			RouterOptions: []core.Option{
+				core.WithAccessController(core.NewAccessController(authenticators, false)),
+				core.WithForwardClaims(config.ForwardClaimsConfiguration{
+					Enabled:      true,
+					Mode:         config.ForwardClaimsModeHeaders,
+					Claims:       []string{"sub", "org.id"},
+					HeaderPrefix: "X-Claim-",
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			token, err := authServer.Token(map[string]any{
+				"sub": "user-1",
+				"org": map[string]any{"id": "org-1"},
+			})
+			require.NoError(t, err)
+
+			res, err := xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{
+				"Authorization": []string{"Bearer " + token},
+				"X-Claim-Sub":   []string{"spoofed"},
+			}, strings.NewReader(employeesQuery))
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			require.Len(t, headers, 1)
+			require.Equal(t, "user-1", headers[0].Get("X-Claim-Sub"))
+			require.Equal(t, "org-1", headers[0].Get("X-Claim-Org-Id"))
+		})
+	})
+
+	t.Run("forward claims as signed token and serve the key set", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			mu     sync.Mutex
+			tokens []string
+		)
+
+		authenticators, authServer := configureAuth(t)
+		testenv.Run(t, &testenv.Config{
+			Subgraphs: testenv.SubgraphsConfig{
+				Employees: testenv.SubgraphConfig{
+					Middleware: func(next http.Handler) http.Handler {
+						return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+							mu.Lock()
+							tokens = append(tokens, r.Header.Get("X-Router-Claims"))
+							mu.Unlock()
+							next.ServeHTTP(w, r)
+						})
+					},
+				},
+			},
+			RouterOptions: []core.Option{
+				core.WithAccessController(core.NewAccessController(authenticators, false)),
+				core.WithForwardClaims(config.ForwardClaimsConfiguration{
+					Enabled: true,
+					Mode:    config.ForwardClaimsModeJWT,
+					Claims:  []string{"sub"},
+					JWT: config.ForwardClaimsJWTOptions{
+						HeaderName: "X-Router-Claims",
+						KeyID:      "router-key",
+						Issuer:     "cosmo-router",
+						JWKSPath:   "/.well-known/jwks.json",
+					},
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			token, err := authServer.Token(map[string]any{"sub": "user-1"})
+			require.NoError(t, err)
+
+			res, err := xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{
+				"Authorization": []string{"Bearer " + token},
+			}, strings.NewReader(employeesQuery))
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+
+			mu.Lock()
+			require.Len(t, tokens, 1)
+			require.NotEmpty(t, tokens[0])
+			mu.Unlock()
+
+			jwksRes, err := xEnv.MakeRequest(http.MethodGet, "/.well-known/jwks.json", nil, nil)
+			require.NoError(t, err)
+			defer jwksRes.Body.Close()
+			require.Equal(t, http.StatusOK, jwksRes.StatusCode)
+
+			data, err := io.ReadAll(jwksRes.Body)
+			require.NoError(t, err)
+
+			var jwks struct {
+				Keys []map[string]string `json:"keys"`
+			}
+			require.NoError(t, json.Unmarshal(data, &jwks))
+			require.Len(t, jwks.Keys, 1)
+			require.Equal(t, "router-key", jwks.Keys[0]["kid"])
+			require.Equal(t, "ES256", jwks.Keys[0]["alg"])
+		})
+	})
+}
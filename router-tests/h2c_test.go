@@ -0,0 +1,159 @@
+package integration_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+const h2cEmployeesIDData = `{"data":{"employees":[{"id":1},{"id":2},{"id":3},{"id":4},{"id":5},{"id":7},{"id":8},{"id":10},{"id":11},{"id":12}]}}`
+
+// h2cClient speaks HTTP/2 over cleartext with prior knowledge
+func h2cClient() *http.Client {
+	return &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+	}
+}
+
+// protocolRecorder records the protocol of the subgraph requests and serves h2c
+type protocolRecorder struct {
+	mu     sync.Mutex
+	protos []string
+}
+
+func (p *protocolRecorder) middleware(next http.Handler) http.Handler {
+	return h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.protos = append(p.protos, r.Proto)
+		p.mu.Unlock()
+		next.ServeHTTP(w, r)
+	}), &http2.Server{})
+}
+
+func (p *protocolRecorder) recorded() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.protos...)
+}
+
+func TestH2C(t *testing.T) {
+	t.Parallel()
+
+	t.Run("router accepts h2c connections when enabled", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithH2C(true),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res, err := h2cClient().Post(xEnv.GraphQLRequestURL(), "application/json", strings.NewReader(`{"query":"{ employees { id } }"}`))
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, "HTTP/2.0", res.Proto)
+
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.JSONEq(t, h2cEmployeesIDData, string(body))
+
+			// HTTP/1.1 is still served
+			http1Res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `query { employees { id } }`,
+			})
+			require.Equal(t, "HTTP/1.1", http1Res.Proto)
+			require.JSONEq(t, h2cEmployeesIDData, http1Res.Body)
+		})
+	})
+
+	t.Run("router rejects h2c connections by default", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{}, func(t *testing.T, xEnv *testenv.Environment) {
+			_, err := h2cClient().Post(xEnv.GraphQLRequestURL(), "application/json", strings.NewReader(`{"query":"{ employees { id } }"}`))
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("all subgraphs are called with h2c when enabled", func(t *testing.T) {
+		t.Parallel()
+
+		recorder := &protocolRecorder{}
+
+		transportOptions := core.DefaultSubgraphTransportOptions()
+		transportOptions.H2C = true
+
+		testenv.Run(t, &testenv.Config{
+			Subgraphs: testenv.SubgraphsConfig{
+				GlobalMiddleware: recorder.middleware,
+			},
+			RouterOptions: []core.Option{
+				core.WithSubgraphTransportOptions(transportOptions),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `query { employees { id } }`,
+			})
+			require.JSONEq(t, h2cEmployeesIDData, res.Body)
+
+			protos := recorder.recorded()
+			require.NotEmpty(t, protos)
+			for _, proto := range protos {
+				require.Equal(t, "HTTP/2.0", proto)
+			}
+		})
+	})
+
+	t.Run("only the configured subgraph is called with h2c", func(t *testing.T) {
+		t.Parallel()
+
+		employees := &protocolRecorder{}
+		others := &protocolRecorder{}
+
+		testenv.Run(t, &testenv.Config{
+			Subgraphs: testenv.SubgraphsConfig{
+				Employees: testenv.SubgraphConfig{
+					Middleware: employees.middleware,
+				},
+				Hobbies: testenv.SubgraphConfig{
+					Middleware: others.middleware,
+				},
+			},
+			RouterOptions: []core.Option{
+				core.WithH2CSubgraphs("employees"),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `query { employees { id hobbies { __typename } } }`,
+			})
+			require.Equal(t, http.StatusOK, res.Response.StatusCode)
+
+			require.NotEmpty(t, employees.recorded())
+			for _, proto := range employees.recorded() {
+				require.Equal(t, "HTTP/2.0", proto)
+			}
+
+			require.NotEmpty(t, others.recorded())
+			for _, proto := range others.recorded() {
+				require.Equal(t, "HTTP/1.1", proto)
+			}
+		})
+	})
+}
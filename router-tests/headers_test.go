@@ -455,3 +455,188 @@ func TestForwardRenamedHeaders(t *testing.T) {
 		})
 	})
 }
+
+func TestHeaderRuleOperations(t *testing.T) {
+	t.Setenv("TEST_HEADER_RULE_SECRET", "secret")
+
+	headerRules := config.HeaderRules{
+		All: config.GlobalHeaderRule{
+			Request: []config.RequestHeaderRule{
+				{
+					Operation: config.HeaderRuleOperationPropagate,
+					Matching:  "(?i)^X-Custom-.*",
+				},
+				{
+					Operation: config.HeaderRuleOperationRemove,
+					Named:     "X-Custom-Internal",
+				},
+				{
+					Operation:     config.HeaderRuleOperationSet,
+					Name:          "X-Client",
+					ValueTemplate: "{{ client.name }}/{{ client.version }}",
+				},
+				{
+					Operation:     config.HeaderRuleOperationSet,
+					Name:          "X-Operation",
+					ValueTemplate: "{{ operation.type }} {{ operation.name }}",
+				},
+			},
+		},
+		Subgraphs: map[string]config.GlobalHeaderRule{
+			"test1": {
+				Request: []config.RequestHeaderRule{
+					{
+						Operation:    config.HeaderRuleOperationSet,
+						Name:         "X-Secret",
+						ValueFromEnv: "TEST_HEADER_RULE_SECRET",
+					},
+				},
+			},
+		},
+	}
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithHeaderRules(headerRules),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		header := http.Header{
+			"X-Custom-Public":        []string{"public"},
+			"X-Custom-Internal":      []string{"internal"},
+			"Graphql-Client-Name":    []string{"web"},
+			"Graphql-Client-Version": []string{"1.0.0"},
+		}
+
+		cases := map[string]string{
+			"X-Custom-Public":   "public",
+			"X-Custom-Internal": "",
+			"X-Client":          "web/1.0.0",
+			"X-Operation":       "query HeaderValue",
+			"X-Secret":          "secret",
+		}
+
+		for name, expected := range cases {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Header:        header,
+				OperationName: []byte(`"HeaderValue"`),
+				Query:         `query HeaderValue { headerValue(name:"` + name + `") }`,
+			})
+			require.Equal(t, `{"data":{"headerValue":"`+expected+`"}}`, res.Body, name)
+		}
+	})
+}
+
+// withResponseHeaders is a subgraph middleware that adds the headers to the subgraph responses
+func withResponseHeaders(header http.Header) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for name, values := range header {
+				w.Header()[name] = values
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestPropagateResponseHeaders(t *testing.T) {
+	t.Parallel()
+
+	testenv.Run(t, &testenv.Config{
+		Subgraphs: testenv.SubgraphsConfig{
+			Employees: testenv.SubgraphConfig{
+				Middleware: withResponseHeaders(http.Header{
+					"Set-Cookie":      []string{"employees=1"},
+					"Cache-Control":   []string{"max-age=60, s-maxage=120"},
+					"X-Custom-Source": []string{"employees"},
+				}),
+			},
+			Hobbies: testenv.SubgraphConfig{
+				Middleware: withResponseHeaders(http.Header{
+					"Set-Cookie":    []string{"hobbies=1"},
+					"Cache-Control": []string{"max-age=30"},
+				}),
+			},
+		},
+		RouterOptions: []core.Option{
+			core.WithHeaderRules(config.HeaderRules{
+				All: config.GlobalHeaderRule{
+					Response: []config.ResponseHeaderRule{
+						{
+							Operation: config.HeaderRuleOperationPropagate,
+							Named:     "Set-Cookie",
+							Algorithm: config.ResponseHeaderRuleAlgorithmAppend,
+						},
+						{
+							Operation: config.HeaderRuleOperationPropagate,
+							Named:     "Cache-Control",
+							Algorithm: config.ResponseHeaderRuleAlgorithmMostRestrictiveCacheControl,
+						},
+						{
+							Operation: config.HeaderRuleOperationPropagate,
+							Matching:  "(?i)^X-Custom-.*",
+							Algorithm: config.ResponseHeaderRuleAlgorithmFirstWrite,
+						},
+					},
+				},
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `query { employees { id hobbies { __typename } } }`,
+		})
+
+		require.ElementsMatch(t, []string{"employees=1", "hobbies=1"}, res.Response.Header.Values("Set-Cookie"))
+		require.Equal(t, "max-age=30, s-maxage=120", res.Response.Header.Get("Cache-Control"))
+		require.Equal(t, "employees", res.Response.Header.Get("X-Custom-Source"))
+	})
+}
+
+func TestPropagateMostRestrictiveCacheControl(t *testing.T) {
+	t.Parallel()
+
+	run := func(t *testing.T, rule config.ResponseHeaderRule, expected string) {
+		testenv.Run(t, &testenv.Config{
+			Subgraphs: testenv.SubgraphsConfig{
+				Employees: testenv.SubgraphConfig{
+					Middleware: withResponseHeaders(http.Header{
+						"Cache-Control": []string{"max-age=60"},
+					}),
+				},
+			},
+			RouterOptions: []core.Option{
+				core.WithHeaderRules(config.HeaderRules{
+					All: config.GlobalHeaderRule{
+						Response: []config.ResponseHeaderRule{rule},
+					},
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			// The hobbies subgraph responds without a Cache-Control header
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `query { employees { id hobbies { __typename } } }`,
+			})
+			require.Equal(t, expected, res.Response.Header.Get("Cache-Control"))
+		})
+	}
+
+	t.Run("a subgraph response without the header is not cacheable", func(t *testing.T) {
+		t.Parallel()
+
+		run(t, config.ResponseHeaderRule{
+			Operation: config.HeaderRuleOperationPropagate,
+			Named:     "Cache-Control",
+			Algorithm: config.ResponseHeaderRuleAlgorithmMostRestrictiveCacheControl,
+		}, "no-store")
+	})
+
+	t.Run("a subgraph response without the header has the default policy", func(t *testing.T) {
+		t.Parallel()
+
+		run(t, config.ResponseHeaderRule{
+			Operation: config.HeaderRuleOperationPropagate,
+			Named:     "Cache-Control",
+			Default:   "max-age=10",
+			Algorithm: config.ResponseHeaderRuleAlgorithmMostRestrictiveCacheControl,
+		}, "max-age=10")
+	})
+}
@@ -0,0 +1,92 @@
+package integration_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+)
+
+func TestHTTP3(t *testing.T) {
+	t.Parallel()
+
+	tlsConfig := &core.TlsConfig{
+		Enabled:  true,
+		CertFile: "testdata/tls/cert.pem",
+		KeyFile:  "testdata/tls/key.pem",
+	}
+
+	t.Run("router serves http3 and announces it with alt-svc", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			TLSConfig: tlsConfig,
+			RouterOptions: []core.Option{
+				core.WithHTTP3(true),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			routerURL, err := url.Parse(xEnv.RouterURL)
+			require.NoError(t, err)
+
+			// The HTTP/3 server is announced as soon as it listens
+			require.Eventually(t, func() bool {
+				res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+					Query: `query { employees { id } }`,
+				})
+				return res.Response.Header.Get("Alt-Svc") == fmt.Sprintf(`h3=":%s"; ma=2592000`, routerURL.Port())
+			}, 5*time.Second, 50*time.Millisecond)
+
+			caCert, err := os.ReadFile(tlsConfig.CertFile)
+			require.NoError(t, err)
+			caCertPool := x509.NewCertPool()
+			require.True(t, caCertPool.AppendCertsFromPEM(caCert))
+
+			transport := &http3.RoundTripper{
+				TLSClientConfig: &tls.Config{RootCAs: caCertPool},
+			}
+			defer transport.Close()
+
+			client := &http.Client{Transport: transport}
+			res, err := client.Post(xEnv.GraphQLRequestURL(), "application/json", strings.NewReader(`{"query":"{ employees { id } }"}`))
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, "HTTP/3.0", res.Proto)
+
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.JSONEq(t, h2cEmployeesIDData, string(body))
+		})
+	})
+
+	t.Run("router does not announce http3 by default", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			TLSConfig: tlsConfig,
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `query { employees { id } }`,
+			})
+			require.Empty(t, res.Response.Header.Get("Alt-Svc"))
+		})
+	})
+
+	t.Run("http3 requires tls", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := core.NewRouter(core.WithHTTP3(true))
+		require.ErrorContains(t, err, "http3 requires tls to be enabled")
+	})
+}
@@ -0,0 +1,115 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestMultipartSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	// subscribe starts a subscription with the Accept header of Apollo clients
+	subscribe := func(t *testing.T, xEnv *testenv.Environment, query string) *multipart.Reader {
+		body, err := json.Marshal(testenv.GraphQLRequest{Query: query})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, xEnv.GraphQLRequestURL(), bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", `multipart/mixed;subscriptionSpec="1.0", application/json`)
+
+		client := http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = resp.Body.Close()
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		require.Equal(t, "multipart/mixed", mediaType)
+		require.Equal(t, "graphql", params["boundary"])
+		require.Equal(t, "1.0", params["subscriptionspec"])
+		return multipart.NewReader(resp.Body, params["boundary"])
+	}
+
+	nextPart := func(t *testing.T, reader *multipart.Reader) string {
+		part, err := reader.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, "application/json", part.Header.Get("Content-Type"))
+		data, err := io.ReadAll(part)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("updates are delivered as parts", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{}, func(t *testing.T, xEnv *testenv.Environment) {
+			reader := subscribe(t, xEnv, `subscription { currentTime { unixTime }}`)
+
+			for updates := 0; updates < 2; {
+				data := nextPart(t, reader)
+				if data == "{}" {
+					continue
+				}
+				var part struct {
+					Payload struct {
+						Data struct {
+							CurrentTime struct {
+								UnixTime float64 `json:"unixTime"`
+							} `json:"currentTime"`
+						} `json:"data"`
+					} `json:"payload"`
+				}
+				require.NoError(t, json.Unmarshal([]byte(data), &part))
+				require.NotZero(t, part.Payload.Data.CurrentTime.UnixTime)
+				updates++
+			}
+		})
+	})
+
+	t.Run("heartbeats keep idle subscriptions open", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			ModifyEngineExecutionConfiguration: func(cfg *config.EngineExecutionConfiguration) {
+				cfg.SubscriptionMultipartHeartbeatInterval = 100 * time.Millisecond
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			// No events are published, so the subscription only receives heartbeats
+			reader := subscribe(t, xEnv, `subscription { employeeUpdated(employeeID: 3) { id } }`)
+
+			require.Equal(t, "{}", nextPart(t, reader))
+			require.Equal(t, "{}", nextPart(t, reader))
+		})
+	})
+
+	t.Run("query parameters take precedence", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{}, func(t *testing.T, xEnv *testenv.Environment) {
+			req, err := http.NewRequest(http.MethodPost, xEnv.GraphQLServeSentEventsURL(), bytes.NewReader([]byte(`{"query":"subscription { currentTime { unixTime }}"}`)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "multipart/mixed")
+
+			client := http.Client{Timeout: 10 * time.Second}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		})
+	})
+}
@@ -95,6 +95,8 @@ type Config struct {
 	ModifySecurityConfiguration        func(securityConfiguration *config.SecurityConfiguration)
 	ModifySubgraphErrorPropagation     func(subgraphErrorPropagation *config.SubgraphErrorPropagationConfiguration)
 	ModifyCDNConfig                    func(cdnConfig *config.CDNConfiguration)
+	ModifyEventsConfiguration          func(eventsConfiguration *config.EventsConfiguration)
+	ModifyWebSocketConfiguration       func(webSocketConfiguration *config.WebSocketConfiguration)
 	DisableWebSockets                  bool
 	TLSConfig                          *core.TlsConfig
 	TraceExporter                      trace.SpanExporter
@@ -379,6 +381,14 @@ func createTestEnv(t testing.TB, cfg *Config) (*Environment, error) {
 		}
 	}()
 
+	if h3 := svr.HTTP3Server(); h3 != nil {
+		go func() {
+			if err := h3.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				t.Errorf("could not start http3 router: %s", err)
+			}
+		}()
+	}
+
 	graphQLPath := "/graphql"
 	if cfg.OverrideGraphQLPath != "" {
 		graphQLPath = cfg.OverrideGraphQLPath
@@ -499,6 +509,7 @@ func configureRouter(listenerAddr string, testConfig *Config, routerConfig *node
 		WebSocketReadTimeout:      time.Millisecond * 100,
 		MaxConcurrentResolvers:    128,
 		ExecutionPlanCacheSize:    1024,
+		SubscriptionDeduplication: true,
 	}
 	if testConfig.ModifyEngineExecutionConfiguration != nil {
 		testConfig.ModifyEngineExecutionConfiguration(&engineExecutionConfig)
@@ -519,6 +530,12 @@ func configureRouter(listenerAddr string, testConfig *Config, routerConfig *node
 			URL:      natsServer.ClientURL(),
 		}
 	}
+	eventsConfiguration := config.EventsConfiguration{
+		Sources: eventSourceBySourceName,
+	}
+	if testConfig.ModifyEventsConfiguration != nil {
+		testConfig.ModifyEventsConfiguration(&eventsConfiguration)
+	}
 	routerOpts := []core.Option{
 		core.WithStaticRouterConfig(routerConfig),
 		core.WithLogger(zapLogger),
@@ -532,9 +549,7 @@ func configureRouter(listenerAddr string, testConfig *Config, routerConfig *node
 		core.WithWithSubgraphErrorPropagation(cfg.SubgraphErrorPropagation),
 		core.WithTLSConfig(testConfig.TLSConfig),
 		core.WithInstanceID("test-instance"),
-		core.WithEvents(config.EventsConfiguration{
-			Sources: eventSourceBySourceName,
-		}),
+		core.WithEvents(eventsConfiguration),
 	}
 	routerOpts = append(routerOpts, testConfig.RouterOptions...)
 
@@ -578,7 +593,7 @@ func configureRouter(listenerAddr string, testConfig *Config, routerConfig *node
 	}
 
 	if !testConfig.DisableWebSockets {
-		routerOpts = append(routerOpts, core.WithWebSocketConfiguration(&config.WebSocketConfiguration{
+		wsConfig := &config.WebSocketConfiguration{
 			Enabled: true,
 			AbsintheProtocol: config.AbsintheProtocolConfiguration{
 				Enabled:     true,
@@ -587,7 +602,11 @@ func configureRouter(listenerAddr string, testConfig *Config, routerConfig *node
 			ForwardUpgradeHeaders:     true,
 			ForwardUpgradeQueryParams: true,
 			ForwardInitialPayload:     true,
-		}))
+		}
+		if testConfig.ModifyWebSocketConfiguration != nil {
+			testConfig.ModifyWebSocketConfiguration(wsConfig)
+		}
+		routerOpts = append(routerOpts, core.WithWebSocketConfiguration(wsConfig))
 	}
 	return core.NewRouter(routerOpts...)
 }
@@ -0,0 +1,116 @@
+package integration_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/trace/tracetest"
+)
+
+// serveOnUnixSocket proxies the subgraph URL on a Unix domain socket and returns the socket path
+func serveOnUnixSocket(t *testing.T, subgraphURL string) string {
+	t.Helper()
+
+	// The path of a socket is limited to about 100 bytes, t.TempDir() can exceed it
+	dir, err := os.MkdirTemp("", "sock")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "subgraph.sock")
+
+	ln, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	target, err := url.Parse(subgraphURL)
+	require.NoError(t, err)
+
+	server := &http.Server{Handler: httputil.NewSingleHostReverseProxy(&url.URL{Scheme: target.Scheme, Host: target.Host})}
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	return socket
+}
+
+func TestUnixSocketSubgraph(t *testing.T) {
+	t.Parallel()
+
+	exporter := tracetest.NewInMemoryExporter(t)
+
+	var (
+		mu      sync.Mutex
+		headers []http.Header
+	)
+
+	testenv.Run(t, &testenv.Config{
+		TraceExporter: exporter,
+		Subgraphs: testenv.SubgraphsConfig{
+			Employees: testenv.SubgraphConfig{
+				Middleware: func(next http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						mu.Lock()
+						headers = append(headers, r.Header.Clone())
+						mu.Unlock()
+						next.ServeHTTP(w, r)
+					})
+				},
+			},
+		},
+		ModifyRouterConfig: func(routerConfig *nodev1.RouterConfig) {
+			for _, sg := range routerConfig.Subgraphs {
+				if sg.Name != "employees" {
+					continue
+				}
+				socket := serveOnUnixSocket(t, sg.RoutingUrl)
+				socketURL := "unix://" + socket + ":/graphql"
+
+				for _, ds := range routerConfig.EngineConfig.DatasourceConfigurations {
+					if ds.Id == sg.Id {
+						ds.CustomGraphql.Fetch.Url.StaticVariableContent = socketURL
+					}
+				}
+				sg.RoutingUrl = socketURL
+			}
+		},
+		RouterOptions: []core.Option{
+			core.WithHeaderRules(config.HeaderRules{
+				Subgraphs: map[string]config.GlobalHeaderRule{
+					"employees": {
+						Request: []config.RequestHeaderRule{
+							{
+								Operation: config.HeaderRuleOperationPropagate,
+								Named:     "X-Custom",
+							},
+						},
+					},
+				},
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `query { employees { id } }`,
+			Header: http.Header{
+				"X-Custom": []string{"propagated"},
+			},
+		})
+		require.JSONEq(t, h2cEmployeesIDData, res.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		require.Len(t, headers, 1)
+		require.Equal(t, "propagated", headers[0].Get("X-Custom"))
+		require.NotEmpty(t, headers[0].Get("Traceparent"))
+		require.NotEmpty(t, exporter.GetSpans().Snapshots())
+	})
+}
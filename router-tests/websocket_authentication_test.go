@@ -0,0 +1,193 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestWebSocketInitialPayloadAuthentication(t *testing.T) {
+	t.Parallel()
+
+	enableInitialPayloadAuthentication := func(cfg *config.WebSocketConfiguration) {
+		cfg.Authentication.FromInitialPayload = config.InitialPayloadAuthenticationConfiguration{
+			Enabled:    true,
+			Key:        "Authorization",
+			HeaderName: "Authorization",
+		}
+	}
+
+	// initConnection sends the connection_init message and returns the first message of the router
+	initConnection := func(t *testing.T, xEnv *testenv.Environment, payload string) (*websocket.Conn, testenv.WebSocketMessage, error) {
+		conn, _, err := xEnv.GraphQLWebsocketDialWithRetry(nil)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = conn.Close()
+		})
+		err = conn.WriteJSON(testenv.WebSocketMessage{
+			Type:    "connection_init",
+			Payload: json.RawMessage(payload),
+		})
+		require.NoError(t, err)
+		var msg testenv.WebSocketMessage
+		err = conn.ReadJSON(&msg)
+		return conn, msg, err
+	}
+
+	requireForbidden := func(t *testing.T, err error) {
+		var closeErr *websocket.CloseError
+		require.True(t, errors.As(err, &closeErr), "expected close error, got %v", err)
+		require.Equal(t, 4403, closeErr.Code)
+	}
+
+	t.Run("token in initial payload", func(t *testing.T) {
+		t.Parallel()
+
+		authenticators, authServer := configureAuth(t)
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithAccessController(core.NewAccessController(authenticators, true)),
+			},
+			ModifyWebSocketConfiguration: enableInitialPayloadAuthentication,
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			token, err := authServer.Token(map[string]any{"scope": "read:employee read:private"})
+			require.NoError(t, err)
+			conn, ack, err := initConnection(t, xEnv, `{"Authorization":"Bearer `+token+`"}`)
+			require.NoError(t, err)
+			require.Equal(t, "connection_ack", ack.Type)
+
+			err = conn.WriteJSON(testenv.WebSocketMessage{
+				ID:      "1",
+				Type:    "subscribe",
+				Payload: []byte(`{"query":"{ employees { id } }"}`),
+			})
+			require.NoError(t, err)
+			var res testenv.WebSocketMessage
+			err = conn.ReadJSON(&res)
+			require.NoError(t, err)
+			require.Equal(t, "next", res.Type)
+			require.Equal(t, employeesExpectedData, string(res.Payload))
+		})
+	})
+
+	t.Run("claims of the initial payload are used for authorization", func(t *testing.T) {
+		t.Parallel()
+
+		authenticators, authServer := configureAuth(t)
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithAccessController(core.NewAccessController(authenticators, false)),
+			},
+			ModifyWebSocketConfiguration: enableInitialPayloadAuthentication,
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			token, err := authServer.Token(map[string]any{"scope": "read:employee read:private"})
+			require.NoError(t, err)
+			conn, ack, err := initConnection(t, xEnv, `{"Authorization":"Bearer `+token+`"}`)
+			require.NoError(t, err)
+			require.Equal(t, "connection_ack", ack.Type)
+
+			err = conn.WriteJSON(testenv.WebSocketMessage{
+				ID:      "1",
+				Type:    "subscribe",
+				Payload: []byte(`{"query":"{ employee(id: 1) { id startDate } }"}`),
+			})
+			require.NoError(t, err)
+			var res testenv.WebSocketMessage
+			err = conn.ReadJSON(&res)
+			require.NoError(t, err)
+			require.Equal(t, "next", res.Type)
+			require.Equal(t, `{"data":{"employee":{"id":1,"startDate":"January 2020"}}}`, string(res.Payload))
+		})
+	})
+
+	t.Run("invalid token in initial payload", func(t *testing.T) {
+		t.Parallel()
+
+		authenticators, _ := configureAuth(t)
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithAccessController(core.NewAccessController(authenticators, false)),
+			},
+			ModifyWebSocketConfiguration: enableInitialPayloadAuthentication,
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			_, _, err := initConnection(t, xEnv, `{"Authorization":"Bearer invalid"}`)
+			requireForbidden(t, err)
+		})
+	})
+
+	t.Run("missing token when authentication is required", func(t *testing.T) {
+		t.Parallel()
+
+		authenticators, _ := configureAuth(t)
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithAccessController(core.NewAccessController(authenticators, true)),
+			},
+			ModifyWebSocketConfiguration: enableInitialPayloadAuthentication,
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			_, _, err := initConnection(t, xEnv, `{"foo":"bar"}`)
+			requireForbidden(t, err)
+		})
+	})
+
+	t.Run("upgrade request is rejected when disabled", func(t *testing.T) {
+		t.Parallel()
+
+		authenticators, _ := configureAuth(t)
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithAccessController(core.NewAccessController(authenticators, true)),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			_, res, err := xEnv.GraphQLWebsocketDialWithRetry(nil)
+			require.ErrorIs(t, err, websocket.ErrBadHandshake)
+			require.Equal(t, 401, res.StatusCode)
+		})
+	})
+
+	t.Run("connection is closed when the token expires", func(t *testing.T) {
+		t.Parallel()
+
+		authenticators, authServer := configureAuth(t)
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithAccessController(core.NewAccessController(authenticators, true)),
+			},
+			ModifyWebSocketConfiguration: enableInitialPayloadAuthentication,
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			token, err := authServer.Token(map[string]any{"exp": time.Now().Add(time.Second * 2).Unix()})
+			require.NoError(t, err)
+			conn, ack, err := initConnection(t, xEnv, `{"Authorization":"Bearer `+token+`"}`)
+			require.NoError(t, err)
+			require.Equal(t, "connection_ack", ack.Type)
+
+			err = conn.WriteJSON(testenv.WebSocketMessage{
+				ID:      "1",
+				Type:    "subscribe",
+				Payload: []byte(`{"query":"subscription { employeeUpdated(employeeID: 3) { id } }"}`),
+			})
+			require.NoError(t, err)
+			xEnv.WaitForSubscriptionCount(1, time.Second*5)
+
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second*10)))
+			var msg testenv.WebSocketMessage
+			err = conn.ReadJSON(&msg)
+			requireForbidden(t, err)
+			xEnv.WaitForSubscriptionCount(0, time.Second*5)
+		})
+	})
+}
@@ -0,0 +1,132 @@
+package integration_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestWebSocketKeepAlive(t *testing.T) {
+	t.Parallel()
+
+	// readUntilClose reads the messages of the router until it closes the connection
+	readUntilClose := func(t *testing.T, conn *websocket.Conn) (*websocket.CloseError, []testenv.WebSocketMessage) {
+		var messages []testenv.WebSocketMessage
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		for {
+			var msg testenv.WebSocketMessage
+			err := conn.ReadJSON(&msg)
+			if err != nil {
+				var closeErr *websocket.CloseError
+				require.True(t, errors.As(err, &closeErr), "expected close error, got %v", err)
+				return closeErr, messages
+			}
+			messages = append(messages, msg)
+		}
+	}
+
+	t.Run("initialization timeout", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			ModifyWebSocketConfiguration: func(cfg *config.WebSocketConfiguration) {
+				cfg.InitializationTimeout = 100 * time.Millisecond
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			conn, _, err := xEnv.GraphQLWebsocketDialWithRetry(nil)
+			require.NoError(t, err)
+			defer conn.Close()
+
+			closeErr, messages := readUntilClose(t, conn)
+			require.Empty(t, messages)
+			require.Equal(t, 4408, closeErr.Code)
+			require.Equal(t, "Connection initialisation timeout", closeErr.Text)
+		})
+	})
+
+	t.Run("too many initialisation requests", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{}, func(t *testing.T, xEnv *testenv.Environment) {
+			conn := xEnv.InitGraphQLWebSocketConnection(nil, nil)
+			err := conn.WriteJSON(testenv.WebSocketMessage{Type: "connection_init"})
+			require.NoError(t, err)
+
+			closeErr, _ := readUntilClose(t, conn)
+			require.Equal(t, 4429, closeErr.Code)
+		})
+	})
+
+	t.Run("subscriber already exists", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{}, func(t *testing.T, xEnv *testenv.Environment) {
+			conn := xEnv.InitGraphQLWebSocketConnection(nil, nil)
+			for i := 0; i < 2; i++ {
+				err := conn.WriteJSON(&testenv.WebSocketMessage{
+					ID:      "1",
+					Type:    "subscribe",
+					Payload: []byte(`{"query":"subscription { currentTime { unixTime }}"}`),
+				})
+				require.NoError(t, err)
+			}
+
+			closeErr, _ := readUntilClose(t, conn)
+			require.Equal(t, 4409, closeErr.Code)
+			require.Equal(t, "Subscriber for 1 already exists", closeErr.Text)
+		})
+	})
+
+	t.Run("ping and pong", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			ModifyWebSocketConfiguration: func(cfg *config.WebSocketConfiguration) {
+				cfg.PingInterval = 100 * time.Millisecond
+				cfg.PongTimeout = 300 * time.Millisecond
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			conn := xEnv.InitGraphQLWebSocketConnection(nil, nil)
+
+			// Answering the pings keeps the connection open past the pong timeout
+			deadline := time.Now().Add(time.Second)
+			for time.Now().Before(deadline) {
+				var msg testenv.WebSocketMessage
+				require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+				require.NoError(t, conn.ReadJSON(&msg))
+				require.Equal(t, "ping", msg.Type)
+				require.NoError(t, conn.WriteJSON(testenv.WebSocketMessage{Type: "pong"}))
+			}
+
+			// Without pongs the router gives up on the client
+			closeErr, messages := readUntilClose(t, conn)
+			require.NotEmpty(t, messages)
+			require.Equal(t, websocket.CloseGoingAway, closeErr.Code)
+			require.Equal(t, "Pong timeout", closeErr.Text)
+		})
+	})
+
+	t.Run("max connection lifetime", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			ModifyWebSocketConfiguration: func(cfg *config.WebSocketConfiguration) {
+				cfg.MaxConnectionLifetime = 200 * time.Millisecond
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			conn := xEnv.InitGraphQLWebSocketConnection(nil, nil)
+
+			start := time.Now()
+			closeErr, _ := readUntilClose(t, conn)
+			require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+			require.Equal(t, websocket.CloseGoingAway, closeErr.Code)
+			require.Equal(t, "Connection lifetime exceeded", closeErr.Text)
+		})
+	})
+}
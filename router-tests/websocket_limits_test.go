@@ -0,0 +1,185 @@
+package integration_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	io_prometheus_client "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestWebSocketLimits(t *testing.T) {
+	t.Parallel()
+
+	// readClose reads the messages of the router until it closes the connection
+	readClose := func(t *testing.T, conn *websocket.Conn) *websocket.CloseError {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		for {
+			var msg testenv.WebSocketMessage
+			err := conn.ReadJSON(&msg)
+			if err != nil {
+				var closeErr *websocket.CloseError
+				require.True(t, errors.As(err, &closeErr), "expected close error, got %v", err)
+				return closeErr
+			}
+		}
+	}
+
+	subscribe := func(t *testing.T, conn *websocket.Conn, id string) {
+		err := conn.WriteJSON(&testenv.WebSocketMessage{
+			ID:      id,
+			Type:    "subscribe",
+			Payload: []byte(`{"query":"subscription { currentTime { unixTime }}"}`),
+		})
+		require.NoError(t, err)
+	}
+
+	// awaitNext waits for the next update of the subscription
+	awaitNext := func(t *testing.T, conn *websocket.Conn, id string) {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		for {
+			var msg testenv.WebSocketMessage
+			require.NoError(t, conn.ReadJSON(&msg))
+			if msg.ID == id && msg.Type == "next" {
+				return
+			}
+		}
+	}
+
+	t.Run("max connections", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			ModifyWebSocketConfiguration: func(cfg *config.WebSocketConfiguration) {
+				cfg.Limits.MaxConnections = 1
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			first := xEnv.InitGraphQLWebSocketConnection(nil, nil)
+
+			conn, _, err := xEnv.GraphQLWebsocketDialWithRetry(nil)
+			require.NoError(t, err)
+			defer conn.Close()
+			closeErr := readClose(t, conn)
+			require.Equal(t, websocket.CloseTryAgainLater, closeErr.Code)
+			require.Equal(t, "Too many connections", closeErr.Text)
+
+			// Closing the first connection makes room for a new one
+			require.NoError(t, first.Close())
+			require.Eventually(t, func() bool {
+				conn, _, err := xEnv.GraphQLWebsocketDialWithRetry(nil)
+				if err != nil {
+					return false
+				}
+				defer conn.Close()
+				if err := conn.WriteJSON(testenv.WebSocketMessage{Type: "connection_init"}); err != nil {
+					return false
+				}
+				var ack testenv.WebSocketMessage
+				return conn.ReadJSON(&ack) == nil && ack.Type == "connection_ack"
+			}, 5*time.Second, 50*time.Millisecond)
+		})
+	})
+
+	t.Run("max connections per client", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			ModifyWebSocketConfiguration: func(cfg *config.WebSocketConfiguration) {
+				cfg.Limits.MaxConnectionsPerClient = 1
+				cfg.Limits.ClientIdentifier = config.WebSocketClientIdentifierClientName
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			xEnv.InitGraphQLWebSocketConnection(http.Header{"Graphql-Client-Name": []string{"a"}}, nil)
+
+			conn, _, err := xEnv.GraphQLWebsocketDialWithRetry(http.Header{"Graphql-Client-Name": []string{"a"}})
+			require.NoError(t, err)
+			defer conn.Close()
+			closeErr := readClose(t, conn)
+			require.Equal(t, websocket.ClosePolicyViolation, closeErr.Code)
+			require.Equal(t, "Too many connections of the client", closeErr.Text)
+
+			// Other clients are not affected
+			xEnv.InitGraphQLWebSocketConnection(http.Header{"Graphql-Client-Name": []string{"b"}}, nil)
+		})
+	})
+
+	t.Run("max subscriptions per connection", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			ModifyWebSocketConfiguration: func(cfg *config.WebSocketConfiguration) {
+				cfg.Limits.MaxSubscriptionsPerConnection = 1
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			conn := xEnv.InitGraphQLWebSocketConnection(nil, nil)
+
+			subscribe(t, conn, "1")
+			awaitNext(t, conn, "1")
+
+			// A completed subscription doesn't count anymore
+			require.NoError(t, conn.WriteJSON(&testenv.WebSocketMessage{ID: "1", Type: "complete"}))
+			subscribe(t, conn, "2")
+			awaitNext(t, conn, "2")
+
+			subscribe(t, conn, "3")
+			closeErr := readClose(t, conn)
+			require.Equal(t, websocket.ClosePolicyViolation, closeErr.Code)
+			require.Equal(t, "Too many subscriptions", closeErr.Text)
+		})
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		t.Parallel()
+
+		metricReader := metric.NewManualReader()
+		promRegistry := prometheus.NewRegistry()
+
+		testenv.Run(t, &testenv.Config{
+			MetricReader:       metricReader,
+			PrometheusRegistry: promRegistry,
+			ModifyWebSocketConfiguration: func(cfg *config.WebSocketConfiguration) {
+				cfg.Limits.MaxConnections = 1
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			conn := xEnv.InitGraphQLWebSocketConnection(nil, nil)
+			subscribe(t, conn, "1")
+			awaitNext(t, conn, "1")
+
+			rejected, _, err := xEnv.GraphQLWebsocketDialWithRetry(nil)
+			require.NoError(t, err)
+			defer rejected.Close()
+			require.Equal(t, websocket.CloseTryAgainLater, readClose(t, rejected).Code)
+
+			mf, err := promRegistry.Gather()
+			require.NoError(t, err)
+
+			value := func(name string) float64 {
+				for _, family := range mf {
+					if family.GetName() != name {
+						continue
+					}
+					require.Len(t, family.GetMetric(), 1)
+					m := family.GetMetric()[0]
+					if family.GetType() == io_prometheus_client.MetricType_COUNTER {
+						return m.GetCounter().GetValue()
+					}
+					return m.GetGauge().GetValue()
+				}
+				t.Fatalf("metric %s not found", name)
+				return 0
+			}
+
+			require.Equal(t, float64(1), value("router_websocket_connections"))
+			require.Equal(t, float64(1), value("router_websocket_subscriptions"))
+			require.Equal(t, float64(1), value("router_websocket_rejections_total"))
+		})
+	})
+}
@@ -81,6 +81,7 @@ func NewRouter(params Params, additionalOptions ...core.Option) (*core.Router, e
 				HeaderNames:         auth.JWKS.HeaderNames,
 				HeaderValuePrefixes: auth.JWKS.HeaderValuePrefixes,
 				RefreshInterval:     auth.JWKS.RefreshInterval,
+				Validation:          tokenValidationOptions(auth.JWKS.Validation),
 			}
 			authenticator, err := authentication.NewJWKSAuthenticator(opts)
 			if err != nil {
@@ -88,11 +89,73 @@ func NewRouter(params Params, additionalOptions ...core.Option) (*core.Router, e
 			}
 			authenticators = append(authenticators, authenticator)
 		}
+		if auth.JWT != nil {
+			name := auth.Name
+			if name == "" {
+				name = fmt.Sprintf("jwt-#%d", i)
+			}
+			opts := authentication.JWTAuthenticatorOptions{
+				Name:                name,
+				Secret:              auth.JWT.Secret,
+				SecretFile:          auth.JWT.SecretFile,
+				PublicKey:           auth.JWT.PublicKey,
+				PublicKeyFile:       auth.JWT.PublicKeyFile,
+				HeaderNames:         auth.JWT.HeaderNames,
+				HeaderValuePrefixes: auth.JWT.HeaderValuePrefixes,
+				Validation:          tokenValidationOptions(auth.JWT.Validation),
+			}
+			authenticator, err := authentication.NewJWTAuthenticator(opts)
+			if err != nil {
+				logger.Fatal("Could not create JWT authenticator", zap.Error(err), zap.String("name", name))
+			}
+			authenticators = append(authenticators, authenticator)
+		}
+		if auth.Introspection != nil {
+			name := auth.Name
+			if name == "" {
+				name = fmt.Sprintf("introspection-#%d", i)
+			}
+			opts := authentication.IntrospectionAuthenticatorOptions{
+				Name:                name,
+				URL:                 auth.Introspection.URL,
+				ClientID:            auth.Introspection.ClientID,
+				ClientSecret:        auth.Introspection.ClientSecret,
+				TokenTypeHint:       auth.Introspection.TokenTypeHint,
+				HeaderNames:         auth.Introspection.HeaderNames,
+				HeaderValuePrefixes: auth.Introspection.HeaderValuePrefixes,
+				CacheTTL:            auth.Introspection.CacheTTL,
+				CacheSize:           auth.Introspection.CacheSize,
+				Timeout:             auth.Introspection.Timeout,
+			}
+			authenticator, err := authentication.NewIntrospectionAuthenticator(opts)
+			if err != nil {
+				logger.Fatal("Could not create introspection authenticator", zap.Error(err), zap.String("name", name))
+			}
+			authenticators = append(authenticators, authenticator)
+		}
+		if auth.APIKey != nil {
+			name := auth.Name
+			if name == "" {
+				name = fmt.Sprintf("api-key-#%d", i)
+			}
+			opts := authentication.APIKeyAuthenticatorOptions{
+				Name:                name,
+				KeyFile:             auth.APIKey.KeyFile,
+				HeaderNames:         auth.APIKey.HeaderNames,
+				HeaderValuePrefixes: auth.APIKey.HeaderValuePrefixes,
+			}
+			authenticator, err := authentication.NewAPIKeyAuthenticator(opts)
+			if err != nil {
+				logger.Fatal("Could not create API key authenticator", zap.Error(err), zap.String("name", name))
+			}
+			authenticators = append(authenticators, authenticator)
+		}
 	}
 
 	options := []core.Option{
 		core.WithListenerAddr(cfg.ListenAddr),
 		core.WithOverrideRoutingURL(cfg.OverrideRoutingURL),
+		core.WithSubgraphPools(cfg.SubgraphPools),
 		core.WithLogger(logger),
 		core.WithConfigPoller(configPoller),
 		core.WithSelfRegistration(selfRegister),
@@ -127,13 +190,19 @@ func NewRouter(params Params, additionalOptions ...core.Option) (*core.Router, e
 			DialTimeout:            cfg.TrafficShaping.All.DialTimeout,
 			TLSHandshakeTimeout:    cfg.TrafficShaping.All.TLSHandshakeTimeout,
 			KeepAliveProbeInterval: cfg.TrafficShaping.All.KeepAliveProbeInterval,
+			H2C:                    cfg.TrafficShaping.All.H2C,
 		}),
+		core.WithH2CSubgraphs(h2cSubgraphs(&cfg.TrafficShaping)...),
+		core.WithH2C(cfg.H2C.Enabled),
+		core.WithHTTP3(cfg.HTTP3.Enabled),
 		core.WithSubgraphRetryOptions(
 			cfg.TrafficShaping.All.BackoffJitterRetry.Enabled,
 			cfg.TrafficShaping.All.BackoffJitterRetry.MaxAttempts,
 			cfg.TrafficShaping.All.BackoffJitterRetry.MaxDuration,
 			cfg.TrafficShaping.All.BackoffJitterRetry.Interval,
 		),
+		core.WithSubgraphRetryPolicy(subgraphRetryPolicy(&cfg.TrafficShaping)),
+		core.WithSubgraphHedgingPolicies(subgraphHedgingPolicies(&cfg.TrafficShaping)),
 		core.WithCors(&cors.Config{
 			AllowOrigins:     cfg.CORS.AllowOrigins,
 			AllowMethods:     cfg.CORS.AllowMethods,
@@ -142,14 +211,16 @@ func NewRouter(params Params, additionalOptions ...core.Option) (*core.Router, e
 			MaxAge:           cfg.CORS.MaxAge,
 		}),
 		core.WithTLSConfig(&core.TlsConfig{
-			Enabled:  cfg.TLS.Server.Enabled,
-			CertFile: cfg.TLS.Server.CertFile,
-			KeyFile:  cfg.TLS.Server.KeyFile,
+			Enabled:      cfg.TLS.Server.Enabled,
+			CertFile:     cfg.TLS.Server.CertFile,
+			KeyFile:      cfg.TLS.Server.KeyFile,
+			Certificates: tlsCertificates(cfg.TLS.Server.Certificates),
 			ClientAuth: &core.TlsClientAuthConfig{
 				CertFile: cfg.TLS.Server.ClientAuth.CertFile,
 				Required: cfg.TLS.Server.ClientAuth.Required,
 			},
 		}),
+		core.WithSubgraphTLSConfig(subgraphTLSConfig(&cfg.TLS.Client)),
 		core.WithDevelopmentMode(cfg.DevelopmentMode),
 		core.WithTracing(traceConfig(&cfg.Telemetry)),
 		core.WithMetrics(metricsConfig(&cfg.Telemetry)),
@@ -157,6 +228,7 @@ func NewRouter(params Params, additionalOptions ...core.Option) (*core.Router, e
 		core.WithSecurityConfig(cfg.SecurityConfiguration),
 		core.WithAuthorizationConfig(&cfg.Authorization),
 		core.WithAccessController(core.NewAccessController(authenticators, cfg.Authorization.RequireAuthentication)),
+		core.WithForwardClaims(cfg.Authentication.ForwardClaims),
 		core.WithWebSocketConfiguration(&cfg.WebSocket),
 		core.WithWithSubgraphErrorPropagation(cfg.SubgraphErrorPropagation),
 		core.WithLocalhostFallbackInsideDocker(cfg.LocalhostFallbackInsideDocker),
@@ -170,6 +242,97 @@ func NewRouter(params Params, additionalOptions ...core.Option) (*core.Router, e
 	return core.NewRouter(options...)
 }
 
+func subgraphRetryPolicy(cfg *config.TrafficShapingRules) *core.SubgraphRetryPolicy {
+	retry := cfg.All.BackoffJitterRetry
+
+	policy := &core.SubgraphRetryPolicy{
+		RespectRetryAfter:   retry.RespectRetryAfter,
+		StatusCodes:         retry.StatusCodes,
+		SubgraphStatusCodes: map[string][]int{},
+	}
+
+	for name, rule := range cfg.Subgraphs {
+		if len(rule.Retry.StatusCodes) > 0 {
+			policy.SubgraphStatusCodes[name] = rule.Retry.StatusCodes
+		}
+	}
+
+	if retry.Budget.Enabled {
+		policy.Budget = &core.SubgraphRetryBudget{
+			Ratio:      retry.Budget.Ratio,
+			MinRetries: retry.Budget.MinRetries,
+			Window:     retry.Budget.Window,
+		}
+	}
+
+	return policy
+}
+
+func subgraphHedgingPolicies(cfg *config.TrafficShapingRules) map[string]*core.SubgraphHedgingPolicy {
+	policies := map[string]*core.SubgraphHedgingPolicy{}
+
+	for name, rule := range cfg.Subgraphs {
+		if !rule.Hedging.Enabled {
+			continue
+		}
+		percentile := rule.Hedging.Percentile
+		if percentile == 0 {
+			percentile = 95
+		}
+		policies[name] = &core.SubgraphHedgingPolicy{
+			Percentile: percentile,
+			MinDelay:   rule.Hedging.MinDelay,
+			MaxDelay:   rule.Hedging.MaxDelay,
+		}
+	}
+
+	return policies
+}
+
+func tlsCertificates(certificates []config.TLSCertificateConfiguration) []core.TlsCertificate {
+	result := make([]core.TlsCertificate, 0, len(certificates))
+	for _, c := range certificates {
+		result = append(result, core.TlsCertificate{
+			CertFile: c.CertFile,
+			KeyFile:  c.KeyFile,
+		})
+	}
+	return result
+}
+
+func subgraphTLSConfig(cfg *config.TLSClientConfiguration) *core.SubgraphTlsConfig {
+	tlsClientConfig := func(c config.TLSClientCertConfiguration) *core.TlsClientConfig {
+		return &core.TlsClientConfig{
+			CAFile:             c.CAFile,
+			CertFile:           c.CertFile,
+			KeyFile:            c.KeyFile,
+			ServerName:         c.ServerName,
+			MinVersion:         c.MinVersion,
+			InsecureSkipVerify: c.InsecureSkipVerify,
+		}
+	}
+
+	subgraphs := make(map[string]*core.TlsClientConfig, len(cfg.Subgraphs))
+	for name, c := range cfg.Subgraphs {
+		subgraphs[name] = tlsClientConfig(c)
+	}
+
+	return &core.SubgraphTlsConfig{
+		All:       tlsClientConfig(cfg.All),
+		Subgraphs: subgraphs,
+	}
+}
+
+func h2cSubgraphs(cfg *config.TrafficShapingRules) []string {
+	var subgraphs []string
+	for name, rule := range cfg.Subgraphs {
+		if rule.H2C {
+			subgraphs = append(subgraphs, name)
+		}
+	}
+	return subgraphs
+}
+
 func traceConfig(cfg *config.Telemetry) *trace.Config {
 	var exporters []*trace.ExporterConfig
 	for _, exp := range cfg.Tracing.Exporters {
@@ -242,3 +405,14 @@ func metricsConfig(cfg *config.Telemetry) *metric.Config {
 		},
 	}
 }
+
+func tokenValidationOptions(cfg config.AuthenticationTokenValidation) authentication.TokenValidationOptions {
+	return authentication.TokenValidationOptions{
+		Audiences:        cfg.Audiences,
+		Issuers:          cfg.Issuers,
+		ClockSkew:        cfg.ClockSkew,
+		ValidateIssuedAt: cfg.ValidateIssuedAt,
+		RequiredClaims:   cfg.RequiredClaims,
+		Algorithms:       cfg.Algorithms,
+	}
+}
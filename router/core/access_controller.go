@@ -1,10 +1,15 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
+	"go.uber.org/zap"
+
 	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/metric"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
 )
 
 var (
@@ -39,7 +44,7 @@ func DefaultAccessController() *AccessController {
 func (a *AccessController) Access(w http.ResponseWriter, r *http.Request) (*http.Request, error) {
 	auth, err := authentication.AuthenticateHTTPRequest(r.Context(), a.authenticators, r)
 	if err != nil {
-		return nil, ErrUnauthorized
+		return nil, &authenticationError{cause: err}
 	}
 	if auth != nil {
 		w.Header().Set("X-Authenticated-By", auth.Authenticator())
@@ -50,3 +55,59 @@ func (a *AccessController) Access(w http.ResponseWriter, r *http.Request) (*http
 	}
 	return r, nil
 }
+
+// AccessProvider is the same as Access for authentication information that isn't part of an
+// *http.Request, e.g. the initial payload of a WebSocket connection. If it succeeds, ctx is
+// returned with the authentication.
+func (a *AccessController) AccessProvider(ctx context.Context, provider authentication.Provider) (context.Context, error) {
+	auth, err := authentication.Authenticate(ctx, a.authenticators, provider)
+	if err != nil {
+		return nil, &authenticationError{cause: err}
+	}
+	if auth != nil {
+		return authentication.NewContext(ctx, auth), nil
+	}
+	if a.authenticationRequired {
+		return nil, ErrUnauthorized
+	}
+	return ctx, nil
+}
+
+// authenticationError is returned by Access when the authentication information is invalid. It is
+// reported as ErrUnauthorized to the client, the cause is only logged and measured.
+type authenticationError struct {
+	cause error
+}
+
+func (e *authenticationError) Error() string {
+	return ErrUnauthorized.Error()
+}
+
+func (e *authenticationError) Is(target error) bool {
+	return target == ErrUnauthorized
+}
+
+func (e *authenticationError) Unwrap() error {
+	return e.cause
+}
+
+// reportAuthenticationFailure logs the reasons of a failed authentication at debug level and
+// measures them by provider and reason
+func reportAuthenticationFailure(ctx context.Context, err error, logger *zap.Logger, metricStore metric.Store) {
+	var authErr *authenticationError
+	if !errors.As(err, &authErr) {
+		return
+	}
+
+	for _, failure := range authentication.Failures(authErr.cause) {
+		logger.Debug("Authentication failed",
+			zap.String("provider", failure.Authenticator),
+			zap.String("reason", string(failure.Reason)),
+			zap.Error(failure.Err),
+		)
+		metricStore.MeasureAuthenticationFailure(ctx,
+			otel.WgAuthenticationProvider.String(failure.Authenticator),
+			otel.WgAuthenticationFailureReason.String(string(failure.Reason)),
+		)
+	}
+}
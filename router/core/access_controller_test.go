@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/metric"
+)
+
+type authenticationFailureRecorder struct {
+	metric.NoopMetrics
+	failures [][]attribute.KeyValue
+}
+
+func (r *authenticationFailureRecorder) MeasureAuthenticationFailure(ctx context.Context, attr ...attribute.KeyValue) {
+	r.failures = append(r.failures, attr)
+}
+
+func TestReportAuthenticationFailure(t *testing.T) {
+	authenticator, err := authentication.NewJWTAuthenticator(authentication.JWTAuthenticatorOptions{
+		Name:   "static",
+		Secret: "0123456789abcdef0123456789abcdef",
+	})
+	require.NoError(t, err)
+
+	accessController := NewAccessController([]authentication.Authenticator{authenticator}, true)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.Header.Set("Authorization", "Bearer not-a-token")
+
+	_, err = accessController.Access(httptest.NewRecorder(), req)
+	require.ErrorIs(t, err, ErrUnauthorized)
+	// The cause is never exposed to the client
+	require.Equal(t, "unauthorized", err.Error())
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	recorder := &authenticationFailureRecorder{}
+	reportAuthenticationFailure(context.Background(), err, zap.New(core), recorder)
+
+	entries := logs.FilterMessage("Authentication failed").All()
+	require.Len(t, entries, 1)
+	require.Equal(t, "static", entries[0].ContextMap()["provider"])
+	require.Equal(t, "malformed", entries[0].ContextMap()["reason"])
+
+	require.Equal(t, [][]attribute.KeyValue{{
+		attribute.String("wg.authentication.provider", "static"),
+		attribute.String("wg.authentication.failure_reason", "malformed"),
+	}}, recorder.failures)
+}
+
+func TestAccessProvider(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	authenticator, err := authentication.NewJWTAuthenticator(authentication.JWTAuthenticatorOptions{
+		Name:   "static",
+		Secret: secret,
+	})
+	require.NoError(t, err)
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-1", "exp": expiresAt.Unix()}).SignedString([]byte(secret))
+	require.NoError(t, err)
+
+	accessController := NewAccessController([]authentication.Authenticator{authenticator}, true)
+
+	ctx, err := accessController.AccessProvider(context.Background(), authenticationHeaders{"Authorization": []string{"Bearer " + token}})
+	require.NoError(t, err)
+	auth := authentication.FromContext(ctx)
+	require.NotNil(t, auth)
+	require.Equal(t, "user-1", auth.Claims()["sub"])
+
+	expiry, ok := authenticationExpiry(auth)
+	require.True(t, ok)
+	require.Equal(t, expiresAt, expiry)
+
+	_, err = accessController.AccessProvider(context.Background(), authenticationHeaders{})
+	require.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = accessController.AccessProvider(context.Background(), authenticationHeaders{"Authorization": []string{"Bearer not-a-token"}})
+	var authErr *authenticationError
+	require.ErrorAs(t, err, &authErr)
+}
@@ -0,0 +1,413 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// authorizationExpression is a compiled rule of the authorization policy file, e.g.
+// claims.tenant == args.tenantId && claims.roles contains "admin"
+//
+// Operands are claims.<path>, args.<path>, string, number and boolean literals. Nested values are
+// addressed with dots, e.g. claims.org.id. The operators are ==, !=, <, <=, >, >=, contains, in,
+// !, && and ||, expressions can be grouped with parentheses. contains and in match the items of lists
+// and the whitespace separated tokens of strings.
+// Comparisons with a missing value are always false, so a rule can't be satisfied by omitting a claim.
+type authorizationExpression struct {
+	root expressionNode
+	// usesArgs is true when the expression references field arguments
+	usesArgs bool
+}
+
+// expressionInput are the values an expression is evaluated against
+type expressionInput struct {
+	claims map[string]any
+	args   map[string]any
+}
+
+func (e *authorizationExpression) Evaluate(input expressionInput) bool {
+	result, ok := e.root.evaluate(input).(bool)
+	return ok && result
+}
+
+func compileAuthorizationExpression(expression string) (*authorizationExpression, error) {
+	tokens, err := tokenizeExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &expressionParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected '%s' at position %d", p.peek().text, p.peek().pos)
+	}
+	return &authorizationExpression{root: root, usesArgs: p.usesArgs}, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPath
+	tokenString
+	tokenNumber
+	tokenBool
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+)
+
+type expressionToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenizeExpression(expression string) ([]expressionToken, error) {
+	var tokens []expressionToken
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, expressionToken{kind: tokenLeftParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, expressionToken{kind: tokenRightParen, text: ")", pos: i})
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(expression) && expression[end] != '"'; end++ {
+				if expression[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(expression) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			value, err := strconv.Unquote(expression[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d: %w", i, err)
+			}
+			tokens = append(tokens, expressionToken{kind: tokenString, text: value, pos: i})
+			i = end + 1
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(expression) && (expression[end] == '.' || (expression[end] >= '0' && expression[end] <= '9')) {
+				end++
+			}
+			tokens = append(tokens, expressionToken{kind: tokenNumber, text: expression[i:end], pos: i})
+			i = end
+		case strings.ContainsRune("=!<>&|", rune(c)):
+			operator := expression[i : i+1]
+			if i+1 < len(expression) {
+				switch two := expression[i : i+2]; two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					operator = two
+				}
+			}
+			if operator == "=" || operator == "&" || operator == "|" {
+				return nil, fmt.Errorf("unknown operator '%s' at position %d", operator, i)
+			}
+			tokens = append(tokens, expressionToken{kind: tokenOperator, text: operator, pos: i})
+			i += len(operator)
+		case c == '_' || unicode.IsLetter(rune(c)):
+			end := i + 1
+			for end < len(expression) && (expression[end] == '_' || expression[end] == '.' ||
+				unicode.IsLetter(rune(expression[end])) || unicode.IsDigit(rune(expression[end]))) {
+				end++
+			}
+			word := expression[i:end]
+			switch word {
+			case "contains", "in":
+				tokens = append(tokens, expressionToken{kind: tokenOperator, text: word, pos: i})
+			case "true", "false":
+				tokens = append(tokens, expressionToken{kind: tokenBool, text: word, pos: i})
+			default:
+				tokens = append(tokens, expressionToken{kind: tokenPath, text: word, pos: i})
+			}
+			i = end
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' at position %d", c, i)
+		}
+	}
+	return append(tokens, expressionToken{kind: tokenEOF, text: "end of expression", pos: len(expression)}), nil
+}
+
+type expressionParser struct {
+	tokens   []expressionToken
+	pos      int
+	usesArgs bool
+}
+
+func (p *expressionParser) peek() expressionToken {
+	return p.tokens[p.pos]
+}
+
+func (p *expressionParser) next() expressionToken {
+	token := p.tokens[p.pos]
+	if token.kind != tokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+func (p *expressionParser) parseOr() (expressionNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOperator && p.peek().text == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{or: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseAnd() (expressionNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOperator && p.peek().text == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseUnary() (expressionNode, error) {
+	if p.peek().kind == tokenOperator && p.peek().text == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *expressionParser) parseComparison() (expressionNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	token := p.peek()
+	if token.kind != tokenOperator {
+		return left, nil
+	}
+	switch token.text {
+	case "==", "!=", "<", "<=", ">", ">=", "contains", "in":
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return &comparisonNode{operator: token.text, left: left, right: right}, nil
+}
+
+func (p *expressionParser) parseOperand() (expressionNode, error) {
+	token := p.next()
+	switch token.kind {
+	case tokenLeftParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRightParen {
+			return nil, fmt.Errorf("expected ')' at position %d", closing.pos)
+		}
+		return node, nil
+	case tokenString:
+		return &literalNode{value: token.text}, nil
+	case tokenBool:
+		return &literalNode{value: token.text == "true"}, nil
+	case tokenNumber:
+		value, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s' at position %d", token.text, token.pos)
+		}
+		return &literalNode{value: value}, nil
+	case tokenPath:
+		root, path, _ := strings.Cut(token.text, ".")
+		if path == "" || strings.Contains(path, "..") || strings.HasSuffix(path, ".") {
+			return nil, fmt.Errorf("invalid path '%s' at position %d", token.text, token.pos)
+		}
+		switch root {
+		case "claims":
+			return &pathNode{path: path}, nil
+		case "args":
+			p.usesArgs = true
+			return &pathNode{args: true, path: path}, nil
+		}
+		return nil, fmt.Errorf("unknown variable '%s' at position %d, expected claims.<path> or args.<path>", token.text, token.pos)
+	}
+	return nil, fmt.Errorf("unexpected '%s' at position %d", token.text, token.pos)
+}
+
+type expressionNode interface {
+	evaluate(input expressionInput) any
+}
+
+type literalNode struct {
+	value any
+}
+
+func (n *literalNode) evaluate(expressionInput) any {
+	return n.value
+}
+
+type pathNode struct {
+	args bool
+	path string
+}
+
+func (n *pathNode) evaluate(input expressionInput) any {
+	if n.args {
+		return claimByPath(input.args, n.path)
+	}
+	return claimByPath(input.claims, n.path)
+}
+
+type notNode struct {
+	operand expressionNode
+}
+
+func (n *notNode) evaluate(input expressionInput) any {
+	value, ok := n.operand.evaluate(input).(bool)
+	return ok && !value
+}
+
+type logicalNode struct {
+	or          bool
+	left, right expressionNode
+}
+
+func (n *logicalNode) evaluate(input expressionInput) any {
+	left, _ := n.left.evaluate(input).(bool)
+	if n.or && left {
+		return true
+	}
+	if !n.or && !left {
+		return false
+	}
+	right, _ := n.right.evaluate(input).(bool)
+	return right
+}
+
+type comparisonNode struct {
+	operator    string
+	left, right expressionNode
+}
+
+func (n *comparisonNode) evaluate(input expressionInput) any {
+	left, right := normalizeExpressionValue(n.left.evaluate(input)), normalizeExpressionValue(n.right.evaluate(input))
+	if left == nil || right == nil {
+		return false
+	}
+	switch n.operator {
+	case "==":
+		return expressionValuesEqual(left, right)
+	case "!=":
+		return !expressionValuesEqual(left, right)
+	case "contains":
+		return expressionContains(left, right)
+	case "in":
+		return expressionContains(right, left)
+	}
+	if l, ok := left.(float64); ok {
+		if r, ok := right.(float64); ok {
+			return compareOrdered(n.operator, l, r)
+		}
+	}
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			return compareOrdered(n.operator, l, r)
+		}
+	}
+	return false
+}
+
+func compareOrdered[T float64 | string](operator string, left, right T) bool {
+	switch operator {
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	case ">=":
+		return left >= right
+	}
+	return false
+}
+
+// normalizeExpressionValue converts all numbers to float64 so claims and arguments decoded in
+// different ways compare equal
+func normalizeExpressionValue(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+	return value
+}
+
+func expressionValuesEqual(left, right any) bool {
+	return reflect.DeepEqual(normalizeExpressionValue(left), normalizeExpressionValue(right))
+}
+
+// expressionContains returns true if the list contains the value. Strings are lists of whitespace
+// separated tokens like the OAuth scope claim, so "admin" is not contained in "superadmin admin:read".
+func expressionContains(container, value any) bool {
+	switch c := container.(type) {
+	case []any:
+		for _, item := range c {
+			if expressionValuesEqual(item, value) {
+				return true
+			}
+		}
+	case []string:
+		for _, item := range c {
+			if expressionValuesEqual(item, value) {
+				return true
+			}
+		}
+	case string:
+		if s, ok := value.(string); ok {
+			for _, token := range strings.Fields(c) {
+				if token == s {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,250 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astvisitor"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/operationreport"
+)
+
+// AuthorizationPolicyFile is the file of the claim based authorization rules. A field is only
+// resolved when all of its rules evaluate to true:
+//
+//	policies:
+//	  - field: Query.employees
+//	    rule: claims.roles contains "admin"
+//	  - field: Mutation.updateEmployeeTag
+//	    rule: claims.tenant == args.tenantId
+//
+// See authorizationExpression for the syntax of the rules.
+type AuthorizationPolicyFile struct {
+	Policies []AuthorizationPolicy `yaml:"policies"`
+}
+
+type AuthorizationPolicy struct {
+	// Field is the coordinate of the field in the format Type.field
+	Field string `yaml:"field"`
+	Rule  string `yaml:"rule"`
+}
+
+// AuthorizationPolicies are the compiled rules of an AuthorizationPolicyFile
+type AuthorizationPolicies struct {
+	rules map[resolve.GraphCoordinate][]*authorizationExpression
+	// argumentCoordinates are the fields with rules referencing the field arguments
+	argumentCoordinates map[resolve.GraphCoordinate]struct{}
+}
+
+// LoadAuthorizationPolicies reads and compiles the rules of an AuthorizationPolicyFile
+func LoadAuthorizationPolicies(path string) (*AuthorizationPolicies, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read authorization policy file: %w", err)
+	}
+
+	var file AuthorizationPolicyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("could not parse authorization policy file: %w", err)
+	}
+
+	policies := &AuthorizationPolicies{
+		rules:               make(map[resolve.GraphCoordinate][]*authorizationExpression, len(file.Policies)),
+		argumentCoordinates: make(map[resolve.GraphCoordinate]struct{}),
+	}
+	for i, policy := range file.Policies {
+		coordinate, err := parseGraphCoordinate(policy.Field)
+		if err != nil {
+			return nil, fmt.Errorf("authorization policy #%d: %w", i, err)
+		}
+		expression, err := compileAuthorizationExpression(policy.Rule)
+		if err != nil {
+			return nil, fmt.Errorf("authorization policy #%d for %s: invalid rule: %w", i, policy.Field, err)
+		}
+		policies.rules[coordinate] = append(policies.rules[coordinate], expression)
+		if expression.usesArgs {
+			policies.argumentCoordinates[coordinate] = struct{}{}
+		}
+	}
+
+	return policies, nil
+}
+
+// parseGraphCoordinate parses a field coordinate in the format Type.field
+func parseGraphCoordinate(field string) (resolve.GraphCoordinate, error) {
+	typeName, fieldName, ok := strings.Cut(field, ".")
+	if !ok || typeName == "" || fieldName == "" || strings.Contains(fieldName, ".") {
+		return resolve.GraphCoordinate{}, fmt.Errorf("field '%s' must have the format Type.field", field)
+	}
+	return resolve.GraphCoordinate{TypeName: typeName, FieldName: fieldName}, nil
+}
+
+// Has returns true if at least one rule applies to the field
+func (p *AuthorizationPolicies) Has(typeName, fieldName string) bool {
+	if p == nil {
+		return false
+	}
+	_, ok := p.rules[resolve.GraphCoordinate{TypeName: typeName, FieldName: fieldName}]
+	return ok
+}
+
+// Coordinates returns the coordinates of all fields with rules
+func (p *AuthorizationPolicies) Coordinates() []resolve.GraphCoordinate {
+	if p == nil {
+		return nil
+	}
+	coordinates := make([]resolve.GraphCoordinate, 0, len(p.rules))
+	for coordinate := range p.rules {
+		coordinates = append(coordinates, coordinate)
+	}
+	return coordinates
+}
+
+// Authorize evaluates the rules of the field. The arguments of all selections of the field in the
+// operation are checked, so aliasing a field can't be used to bypass a rule.
+func (p *AuthorizationPolicies) Authorize(ctx *resolve.Context, coordinate resolve.GraphCoordinate, claims map[string]any) (bool, error) {
+	if p == nil {
+		return true, nil
+	}
+	rules := p.rules[coordinate]
+	if len(rules) == 0 {
+		return true, nil
+	}
+
+	inputs := []expressionInput{{claims: claims}}
+	if _, ok := p.argumentCoordinates[coordinate]; ok {
+		arguments, err := operationFieldArguments(ctx, coordinate)
+		if err != nil {
+			return false, err
+		}
+		if len(arguments) > 0 {
+			inputs = inputs[:0]
+			for _, args := range arguments {
+				inputs = append(inputs, expressionInput{claims: claims, args: args})
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		for _, input := range inputs {
+			if !rule.Evaluate(input) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// operationFieldArguments returns the arguments of every selection of the field in the operation.
+// The arguments of all fields are collected by a single walk of the operation, which is cached
+// on the operation context.
+func operationFieldArguments(ctx *resolve.Context, coordinate resolve.GraphCoordinate) ([]map[string]any, error) {
+	operation := authorizationOperationContext(ctx.Context())
+	if operation == nil || operation.preparedPlan == nil || operation.preparedPlan.operationDocument == nil {
+		return nil, nil
+	}
+
+	cache := &operation.authorizationArguments
+	cache.once.Do(func() {
+		cache.arguments, cache.err = collectFieldArguments(operation.preparedPlan, ctx.Variables)
+	})
+	return cache.arguments[coordinate], cache.err
+}
+
+// fieldArgumentsCache holds the arguments of the fields of an operation by coordinate
+type fieldArgumentsCache struct {
+	once      sync.Once
+	arguments map[resolve.GraphCoordinate][]map[string]any
+	err       error
+}
+
+// collectFieldArguments returns the arguments of every selection of the fields in the operation
+func collectFieldArguments(plan *planWithMetaData, variablesJSON []byte) (map[resolve.GraphCoordinate][]map[string]any, error) {
+	var variables map[string]any
+	if len(variablesJSON) > 0 {
+		if err := json.Unmarshal(variablesJSON, &variables); err != nil {
+			return nil, fmt.Errorf("could not parse operation variables: %w", err)
+		}
+	}
+
+	walker := astvisitor.NewWalker(48)
+	visitor := &fieldArgumentsVisitor{
+		walker:    &walker,
+		variables: variables,
+		arguments: make(map[resolve.GraphCoordinate][]map[string]any),
+	}
+	walker.RegisterEnterDocumentVisitor(visitor)
+	walker.RegisterEnterFieldVisitor(visitor)
+
+	report := &operationreport.Report{}
+	walker.Walk(plan.operationDocument, plan.schemaDocument, report)
+	if report.HasErrors() {
+		return nil, report
+	}
+	return visitor.arguments, visitor.err
+}
+
+// authorizationOperationContext returns the operation of HTTP and WebSocket requests
+func authorizationOperationContext(ctx context.Context) *operationContext {
+	if operation := getOperationContext(ctx); operation != nil {
+		return operation
+	}
+	if requestContext := getRequestContext(ctx); requestContext != nil {
+		return requestContext.operation
+	}
+	return nil
+}
+
+type fieldArgumentsVisitor struct {
+	walker                *astvisitor.Walker
+	operation, definition *ast.Document
+	variables             map[string]any
+	arguments             map[resolve.GraphCoordinate][]map[string]any
+	err                   error
+}
+
+func (v *fieldArgumentsVisitor) EnterDocument(operation, definition *ast.Document) {
+	v.operation = operation
+	v.definition = definition
+}
+
+func (v *fieldArgumentsVisitor) EnterField(ref int) {
+	coordinate := resolve.GraphCoordinate{
+		TypeName:  v.walker.EnclosingTypeDefinition.NameString(v.definition),
+		FieldName: v.operation.FieldNameString(ref),
+	}
+
+	args := make(map[string]any)
+	for _, argumentRef := range v.operation.FieldArguments(ref) {
+		value, err := v.argumentValue(v.operation.Arguments[argumentRef].Value)
+		if err != nil {
+			v.err = err
+			v.walker.Stop()
+			return
+		}
+		args[v.operation.ArgumentNameString(argumentRef)] = value
+	}
+	v.arguments[coordinate] = append(v.arguments[coordinate], args)
+}
+
+func (v *fieldArgumentsVisitor) argumentValue(value ast.Value) (any, error) {
+	// The arguments of normalized operations are extracted into variables
+	if value.Kind == ast.ValueKindVariable {
+		return v.variables[v.operation.VariableValueNameString(value.Ref)], nil
+	}
+	data, err := v.operation.ValueToJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	var result any
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,169 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astparser"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/asttransform"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+func TestAuthorizationExpression(t *testing.T) {
+	input := expressionInput{
+		claims: map[string]any{
+			"sub":         "user-1",
+			"tenant":      "acme",
+			"roles":       []any{"admin", "editor"},
+			"scope":       "read:employee write:employee",
+			"admin_scope": "superadmin admin:readonly",
+			"level":       float64(3),
+			"org":         map[string]any{"id": "org-1"},
+			"active":      true,
+		},
+		args: map[string]any{
+			"tenantId": "acme",
+			"id":       float64(3),
+			"filter":   map[string]any{"org": "org-1"},
+		},
+	}
+
+	for expression, expected := range map[string]bool{
+		`claims.tenant == args.tenantId`:                         true,
+		`claims.tenant != args.tenantId`:                         false,
+		`claims.roles contains "admin"`:                          true,
+		`claims.roles contains "viewer"`:                         false,
+		`claims.scope contains "write:employee"`:                 true,
+		`claims.scope contains "write"`:                          false,
+		`claims.scope contains "employee"`:                       false,
+		`claims.scope contains "read:employee write"`:            false,
+		`"read:employee" in claims.scope`:                        true,
+		`claims.admin_scope contains "admin"`:                    false,
+		`"editor" in claims.roles`:                               true,
+		`claims.level >= 3 && claims.level < 4`:                  true,
+		`claims.level == args.id`:                                true,
+		`claims.org.id == args.filter.org`:                       true,
+		`claims.active`:                                          true,
+		`!claims.active`:                                         false,
+		`claims.sub == "other" || claims.roles contains "admin"`: true,
+		`!(claims.tenant == "acme" && claims.level > 5)`:         true,
+		`claims.missing == args.missing`:                         false,
+		`claims.missing != "admin"`:                              false,
+		`claims.tenant == 3`:                                     false,
+	} {
+		t.Run(expression, func(t *testing.T) {
+			compiled, err := compileAuthorizationExpression(expression)
+			require.NoError(t, err)
+			require.Equal(t, expected, compiled.Evaluate(input))
+		})
+	}
+}
+
+func TestInvalidAuthorizationExpression(t *testing.T) {
+	for expression, message := range map[string]string{
+		`claims.tenant = "acme"`:      "unknown operator '='",
+		`claims.tenant == "acme`:      "unterminated string",
+		`request.tenant == "acme"`:    "unknown variable 'request.tenant'",
+		`claims == "acme"`:            "invalid path 'claims'",
+		`(claims.tenant == "acme"`:    "expected ')'",
+		`claims.tenant == "acme" "x"`: "unexpected 'x'",
+		`claims.tenant ==`:            "unexpected 'end of expression'",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			_, err := compileAuthorizationExpression(expression)
+			require.ErrorContains(t, err, message)
+		})
+	}
+}
+
+func TestLoadAuthorizationPolicies(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "policies.yaml")
+	require.NoError(t, os.WriteFile(policyFile, []byte(`policies:
+  - field: Query.employee
+    rule: claims.tenant == args.tenantId
+  - field: Query.employee
+    rule: claims.roles contains "admin"
+`), 0o600))
+
+	policies, err := LoadAuthorizationPolicies(policyFile)
+	require.NoError(t, err)
+	require.True(t, policies.Has("Query", "employee"))
+	require.False(t, policies.Has("Query", "employees"))
+	require.Equal(t, []resolve.GraphCoordinate{{TypeName: "Query", FieldName: "employee"}}, policies.Coordinates())
+
+	require.NoError(t, os.WriteFile(policyFile, []byte(`policies:
+  - field: employee
+    rule: claims.tenant == args.tenantId
+`), 0o600))
+	_, err = LoadAuthorizationPolicies(policyFile)
+	require.ErrorContains(t, err, "must have the format Type.field")
+
+	require.NoError(t, os.WriteFile(policyFile, []byte(`policies:
+  - field: Query.employee
+    rule: claims.tenant ==
+`), 0o600))
+	_, err = LoadAuthorizationPolicies(policyFile)
+	require.ErrorContains(t, err, "invalid rule")
+}
+
+func TestAuthorizationPoliciesArguments(t *testing.T) {
+	schema, report := astparser.ParseGraphqlDocumentString(`
+		type Query {
+			employee(id: Int!, tenantId: String!): Employee
+		}
+		type Employee {
+			id: Int!
+			details(tenantId: String!): String
+		}
+	`)
+	require.False(t, report.HasErrors())
+	require.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&schema))
+
+	operation, report := astparser.ParseGraphqlDocumentString(`
+		query($a: Int!, $b: String!) {
+			first: employee(id: $a, tenantId: $b) { id details(tenantId: "acme") }
+			second: employee(id: 2, tenantId: "other") { id }
+		}
+	`)
+	require.False(t, report.HasErrors())
+
+	policyFile := filepath.Join(t.TempDir(), "policies.yaml")
+	require.NoError(t, os.WriteFile(policyFile, []byte(`policies:
+  - field: Query.employee
+    rule: claims.tenant == args.tenantId
+  - field: Employee.details
+    rule: claims.tenant == args.tenantId
+`), 0o600))
+	policies, err := LoadAuthorizationPolicies(policyFile)
+	require.NoError(t, err)
+
+	ctx := &resolve.Context{Variables: []byte(`{"a":1,"b":"acme"}`)}
+	ctx = ctx.WithContext(withOperationContext(context.Background(), &operationContext{
+		preparedPlan: &planWithMetaData{operationDocument: &operation, schemaDocument: &schema},
+	}))
+
+	arguments, err := operationFieldArguments(ctx, resolve.GraphCoordinate{TypeName: "Query", FieldName: "employee"})
+	require.NoError(t, err)
+	require.Equal(t, []map[string]any{
+		{"id": float64(1), "tenantId": "acme"},
+		{"id": float64(2), "tenantId": "other"},
+	}, arguments)
+
+	claims := map[string]any{"tenant": "acme"}
+
+	// The aliased second selection belongs to another tenant
+	allowed, err := policies.Authorize(ctx, resolve.GraphCoordinate{TypeName: "Query", FieldName: "employee"}, claims)
+	require.NoError(t, err)
+	require.False(t, allowed)
+
+	allowed, err = policies.Authorize(ctx, resolve.GraphCoordinate{TypeName: "Employee", FieldName: "details"}, claims)
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	allowed, err = policies.Authorize(ctx, resolve.GraphCoordinate{TypeName: "Employee", FieldName: "details"}, nil)
+	require.NoError(t, err)
+	require.False(t, allowed)
+}
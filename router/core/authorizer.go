@@ -5,28 +5,43 @@ import (
 	"encoding/json"
 	"io"
 	"slices"
+	"strconv"
 	"sync"
 
 	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
 	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/authorization"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
 )
 
 type CosmoAuthorizerOptions struct {
 	FieldConfigurations           []*nodev1.FieldConfiguration
 	RejectOperationIfUnauthorized bool
+	// Policies are the claim based rules evaluated in addition to the required scopes
+	Policies *AuthorizationPolicies
+	// ExternalAuthorizer decides after the scopes and policies when set
+	ExternalAuthorizer *authorization.ExternalAuthorizer
+	// ExternalAuthorizationFields limits the external decisions to these fields. All fields with an
+	// authorization rule are decided externally when empty.
+	ExternalAuthorizationFields []resolve.GraphCoordinate
 }
 
 func NewCosmoAuthorizer(opts *CosmoAuthorizerOptions) *CosmoAuthorizer {
 	return &CosmoAuthorizer{
 		fieldConfigurations: opts.FieldConfigurations,
 		rejectUnauthorized:  opts.RejectOperationIfUnauthorized,
+		policies:            opts.Policies,
+		externalAuthorizer:  opts.ExternalAuthorizer,
+		externalFields:      opts.ExternalAuthorizationFields,
 	}
 }
 
 type CosmoAuthorizer struct {
 	fieldConfigurations []*nodev1.FieldConfiguration
 	rejectUnauthorized  bool
+	policies            *AuthorizationPolicies
+	externalAuthorizer  *authorization.ExternalAuthorizer
+	externalFields      []resolve.GraphCoordinate
 }
 
 func (a *CosmoAuthorizer) HasResponseExtensionData(ctx *resolve.Context) bool {
@@ -66,15 +81,95 @@ func (a *CosmoAuthorizer) handleRejectUnauthorized(result *resolve.Authorization
 }
 
 func (a *CosmoAuthorizer) AuthorizePreFetch(ctx *resolve.Context, dataSourceID string, input json.RawMessage, coordinate resolve.GraphCoordinate) (result *resolve.AuthorizationDeny, err error) {
-	isAuthenticated, actual := a.getAuth(ctx.Context())
-	required := a.requiredScopesForField(coordinate)
-	return a.handleRejectUnauthorized(a.validateScopes(ctx, coordinate, required, isAuthenticated, actual))
+	return a.authorize(ctx, coordinate)
 }
 
 func (a *CosmoAuthorizer) AuthorizeObjectField(ctx *resolve.Context, dataSourceID string, object json.RawMessage, coordinate resolve.GraphCoordinate) (result *resolve.AuthorizationDeny, err error) {
+	return a.authorize(ctx, coordinate)
+}
+
+func (a *CosmoAuthorizer) authorize(ctx *resolve.Context, coordinate resolve.GraphCoordinate) (*resolve.AuthorizationDeny, error) {
 	isAuthenticated, actual := a.getAuth(ctx.Context())
 	required := a.requiredScopesForField(coordinate)
-	return a.handleRejectUnauthorized(a.validateScopes(ctx, coordinate, required, isAuthenticated, actual))
+	if result := a.validateScopes(ctx, coordinate, required, isAuthenticated, actual); result != nil {
+		return a.handleRejectUnauthorized(result)
+	}
+	result, err := a.validatePolicies(ctx, coordinate)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		return a.handleRejectUnauthorized(result)
+	}
+	result, err = a.validateExternal(ctx, coordinate)
+	if err != nil {
+		return nil, err
+	}
+	return a.handleRejectUnauthorized(result)
+}
+
+func (a *CosmoAuthorizer) validateExternal(ctx *resolve.Context, coordinate resolve.GraphCoordinate) (*resolve.AuthorizationDeny, error) {
+	if a.externalAuthorizer == nil {
+		return nil, nil
+	}
+	if len(a.externalFields) > 0 && !slices.Contains(a.externalFields, coordinate) {
+		return nil, nil
+	}
+
+	request := &authorization.Request{
+		Coordinate: authorization.Coordinate{TypeName: coordinate.TypeName, FieldName: coordinate.FieldName},
+		Arguments:  []map[string]any{},
+	}
+	if auth := authentication.FromContext(ctx.Context()); auth != nil {
+		request.Claims = auth.Claims()
+	}
+	if operation := authorizationOperationContext(ctx.Context()); operation != nil {
+		request.Operation = authorization.Operation{
+			Name:    operation.Name(),
+			Type:    operation.Type(),
+			Hash:    strconv.FormatUint(operation.Hash(), 10),
+			Content: operation.Content(),
+		}
+	}
+	arguments, err := operationFieldArguments(ctx, coordinate)
+	if err != nil {
+		return nil, err
+	}
+	if len(arguments) > 0 {
+		request.Arguments = arguments
+	}
+
+	decision := a.externalAuthorizer.Authorize(ctx.Context(), request)
+	if decision.Allow {
+		return nil, nil
+	}
+	reason := decision.Reason
+	if reason == "" {
+		reason = "denied by external authorization"
+	}
+	return &resolve.AuthorizationDeny{
+		Reason: reason,
+	}, nil
+}
+
+func (a *CosmoAuthorizer) validatePolicies(ctx *resolve.Context, coordinate resolve.GraphCoordinate) (*resolve.AuthorizationDeny, error) {
+	if !a.policies.Has(coordinate.TypeName, coordinate.FieldName) {
+		return nil, nil
+	}
+	var claims map[string]any
+	if auth := authentication.FromContext(ctx.Context()); auth != nil {
+		claims = auth.Claims()
+	}
+	allowed, err := a.policies.Authorize(ctx, coordinate, claims)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return &resolve.AuthorizationDeny{
+			Reason: "authorization policy not satisfied",
+		}, nil
+	}
+	return nil, nil
 }
 
 func (a *CosmoAuthorizer) validateScopes(ctx *resolve.Context, coordinate resolve.GraphCoordinate, requiredOrScopes []*nodev1.Scopes, isAuthenticated bool, actual []string) (result *resolve.AuthorizationDeny) {
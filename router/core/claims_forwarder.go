@@ -0,0 +1,216 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	rjwt "github.com/wundergraph/cosmo/router/internal/jwt"
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+// registeredClaims are set by the router on the forwarded token and never copied from the client token
+var registeredClaims = []string{"iss", "aud", "exp", "nbf", "iat", "jti"}
+
+// claimsForwarder is a pre-origin handler that forwards the claims of authenticated requests
+// to the subgraphs, either as headers or as a token signed by the router
+type claimsForwarder struct {
+	config config.ForwardClaimsConfiguration
+	signer *rjwt.Signer
+	// headers maps the forwarded claims to their header in the headers mode
+	headers   map[string]string
+	subgraphs map[string]struct{}
+	logger    *zap.Logger
+}
+
+func newClaimsForwarder(cfg config.ForwardClaimsConfiguration, logger *zap.Logger) (*claimsForwarder, error) {
+	if cfg.JWT.TTL <= 0 {
+		cfg.JWT.TTL = 5 * time.Minute
+	}
+	if cfg.JWT.JWKSPath == "" {
+		cfg.JWT.JWKSPath = "/.well-known/jwks.json"
+	}
+
+	f := &claimsForwarder{
+		config:    cfg,
+		headers:   map[string]string{},
+		subgraphs: map[string]struct{}{},
+		logger:    logger,
+	}
+
+	for _, name := range cfg.Subgraphs {
+		f.subgraphs[name] = struct{}{}
+	}
+
+	switch cfg.Mode {
+	case config.ForwardClaimsModeHeaders, "":
+		if len(cfg.Claims) == 0 {
+			return nil, fmt.Errorf("no claims to forward as headers, set the claims")
+		}
+		for _, claim := range cfg.Claims {
+			f.headers[claim] = claimHeaderName(cfg.HeaderPrefix, claim)
+		}
+	case config.ForwardClaimsModeJWT:
+		if cfg.JWT.HeaderName == "" {
+			return nil, fmt.Errorf("no header name for the forwarded claims token")
+		}
+		var err error
+		if cfg.JWT.SigningKeyFile != "" {
+			f.signer, err = rjwt.NewSignerFromFile(cfg.JWT.SigningKeyFile, cfg.JWT.KeyID)
+		} else {
+			logger.Warn("No signing key for the forwarded claims configured. Generating a key, tokens can't be verified after a restart")
+			f.signer, err = rjwt.NewEphemeralSigner()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the signer of the forwarded claims: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown claims forwarding mode '%s'", cfg.Mode)
+	}
+
+	return f, nil
+}
+
+// claimHeaderName returns the header of a claim, e.g. X-Claim-Org-Id for org.id
+func claimHeaderName(prefix, claim string) string {
+	return http.CanonicalHeaderKey(prefix + strings.NewReplacer(".", "-", "_", "-").Replace(claim))
+}
+
+func (f *claimsForwarder) OnOriginRequest(request *http.Request, ctx RequestContext) (*http.Request, *http.Response) {
+	// The headers are owned by the router, never forward them from the client
+	if f.signer != nil {
+		request.Header.Del(f.config.JWT.HeaderName)
+	}
+	for _, header := range f.headers {
+		request.Header.Del(header)
+	}
+
+	if len(f.subgraphs) > 0 {
+		subgraph := ctx.ActiveSubgraph(request)
+		if subgraph == nil {
+			return request, nil
+		}
+		if _, ok := f.subgraphs[subgraph.Name]; !ok {
+			return request, nil
+		}
+	}
+
+	auth := ctx.Authentication()
+	if auth == nil {
+		return request, nil
+	}
+	claims := auth.Claims()
+
+	if f.signer == nil {
+		for claim, header := range f.headers {
+			if value := claimString(claimByPath(claims, claim)); value != "" {
+				request.Header.Set(header, value)
+			}
+		}
+		return request, nil
+	}
+
+	token, err := f.signer.Sign(f.tokenClaims(claims))
+	if err != nil {
+		ctx.Logger().Error("Failed to sign the forwarded claims", zap.Error(err))
+		return request, nil
+	}
+	request.Header.Set(f.config.JWT.HeaderName, token)
+
+	return request, nil
+}
+
+// tokenClaims returns the claims of the forwarded token
+func (f *claimsForwarder) tokenClaims(claims map[string]any) jwt.MapClaims {
+	tokenClaims := jwt.MapClaims(f.forwardedClaims(claims))
+
+	now := time.Now()
+	tokenClaims["iss"] = f.config.JWT.Issuer
+	tokenClaims["iat"] = now.Unix()
+	tokenClaims["exp"] = now.Add(f.config.JWT.TTL).Unix()
+	if f.config.JWT.Audience != "" {
+		tokenClaims["aud"] = f.config.JWT.Audience
+	}
+
+	return tokenClaims
+}
+
+// forwardedClaims returns the client claims copied to the forwarded token
+func (f *claimsForwarder) forwardedClaims(claims map[string]any) map[string]any {
+	forwarded := map[string]any{}
+
+	if len(f.config.Claims) == 0 {
+		for name, value := range claims {
+			forwarded[name] = value
+		}
+		for _, name := range registeredClaims {
+			delete(forwarded, name)
+		}
+		return forwarded
+	}
+
+	for _, claim := range f.config.Claims {
+		value := claimByPath(claims, claim)
+		if value == nil {
+			continue
+		}
+		setClaimByPath(forwarded, claim, value)
+	}
+	return forwarded
+}
+
+// subscriptionKey returns the claims forwarded for auth in a stable encoding, so upstream subscriptions
+// are only shared between clients with the same forwarded claims. It returns nil without authentication.
+func (f *claimsForwarder) subscriptionKey(auth authentication.Authentication) ([]byte, error) {
+	if auth == nil {
+		return nil, nil
+	}
+	claims := auth.Claims()
+
+	if f.signer == nil {
+		values := make(map[string]string, len(f.headers))
+		for claim := range f.headers {
+			if value := claimString(claimByPath(claims, claim)); value != "" {
+				values[claim] = value
+			}
+		}
+		return json.Marshal(values)
+	}
+	// The claims set by the router are the same for all clients
+	return json.Marshal(f.forwardedClaims(claims))
+}
+
+// setClaimByPath sets a claim addressed by a dot separated path and creates the parent objects
+func setClaimByPath(claims map[string]any, path string, value any) {
+	keys := strings.Split(path, ".")
+	object := claims
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := object[key].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			object[key] = child
+		}
+		object = child
+	}
+	object[keys[len(keys)-1]] = value
+}
+
+// JWKSHandler serves the public key to verify the forwarded tokens
+func (f *claimsForwarder) JWKSHandler() (http.Handler, error) {
+	jwks, err := f.signer.JWKS()
+	if err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=300")
+		_, _ = w.Write(jwks)
+	}), nil
+}
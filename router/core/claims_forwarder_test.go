@@ -0,0 +1,204 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func newClaimsForwarderTestContext(t *testing.T, claims authentication.Claims) *requestContext {
+	t.Helper()
+
+	clientReq, err := http.NewRequest("POST", "http://localhost", nil)
+	require.NoError(t, err)
+	if claims != nil {
+		clientReq = clientReq.WithContext(authentication.NewContext(clientReq.Context(), &testAuthentication{claims: claims}))
+	}
+
+	employeesURL, _ := url.Parse("http://employees.local")
+	productsURL, _ := url.Parse("http://products.local")
+
+	return &requestContext{
+		logger:         zap.NewNop(),
+		responseWriter: httptest.NewRecorder(),
+		request:        clientReq,
+		operation:      &operationContext{},
+		subgraphs: []Subgraph{
+			{Name: "employees", Id: "0", Url: employeesURL},
+			{Name: "products", Id: "1", Url: productsURL},
+		},
+	}
+}
+
+func TestForwardClaimsAsHeaders(t *testing.T) {
+	f, err := newClaimsForwarder(config.ForwardClaimsConfiguration{
+		Enabled:      true,
+		Mode:         config.ForwardClaimsModeHeaders,
+		Claims:       []string{"sub", "org.id", "email_verified"},
+		HeaderPrefix: "X-Claim-",
+	}, zap.NewNop())
+	require.NoError(t, err)
+
+	ctx := newClaimsForwarderTestContext(t, authentication.Claims{
+		"sub":            "user-1",
+		"org":            map[string]any{"id": float64(42)},
+		"email_verified": true,
+	})
+
+	originReq, err := http.NewRequest("POST", "http://employees.local", nil)
+	require.NoError(t, err)
+	updatedReq, _ := f.OnOriginRequest(originReq, ctx)
+
+	assert.Equal(t, "user-1", updatedReq.Header.Get("X-Claim-Sub"))
+	assert.Equal(t, "42", updatedReq.Header.Get("X-Claim-Org-Id"))
+	assert.Equal(t, "true", updatedReq.Header.Get("X-Claim-Email-Verified"))
+}
+
+func TestForwardClaimsRemovesClientHeaders(t *testing.T) {
+	f, err := newClaimsForwarder(config.ForwardClaimsConfiguration{
+		Enabled:      true,
+		Mode:         config.ForwardClaimsModeHeaders,
+		Claims:       []string{"sub", "role"},
+		HeaderPrefix: "X-Claim-",
+	}, zap.NewNop())
+	require.NoError(t, err)
+
+	// Spoofed headers are removed for unauthenticated requests too
+	for name, claims := range map[string]authentication.Claims{
+		"authenticated":   {"sub": "user-1"},
+		"unauthenticated": nil,
+	} {
+		t.Run(name, func(t *testing.T) {
+			originReq, err := http.NewRequest("POST", "http://employees.local", nil)
+			require.NoError(t, err)
+			originReq.Header.Set("X-Claim-Role", "admin")
+
+			updatedReq, _ := f.OnOriginRequest(originReq, newClaimsForwarderTestContext(t, claims))
+
+			assert.Empty(t, updatedReq.Header.Get("X-Claim-Role"))
+			assert.Equal(t, claims["sub"] != nil, updatedReq.Header.Get("X-Claim-Sub") != "")
+		})
+	}
+}
+
+func TestForwardClaimsToSelectedSubgraphs(t *testing.T) {
+	f, err := newClaimsForwarder(config.ForwardClaimsConfiguration{
+		Enabled:      true,
+		Mode:         config.ForwardClaimsModeHeaders,
+		Claims:       []string{"sub"},
+		Subgraphs:    []string{"employees"},
+		HeaderPrefix: "X-Claim-",
+	}, zap.NewNop())
+	require.NoError(t, err)
+
+	ctx := newClaimsForwarderTestContext(t, authentication.Claims{"sub": "user-1"})
+
+	employeesReq, err := http.NewRequest("POST", "http://employees.local", nil)
+	require.NoError(t, err)
+	employeesReq, _ = f.OnOriginRequest(employeesReq, ctx)
+	assert.Equal(t, "user-1", employeesReq.Header.Get("X-Claim-Sub"))
+
+	productsReq, err := http.NewRequest("POST", "http://products.local", nil)
+	require.NoError(t, err)
+	productsReq.Header.Set("X-Claim-Sub", "spoofed")
+	productsReq, _ = f.OnOriginRequest(productsReq, ctx)
+	assert.Empty(t, productsReq.Header.Get("X-Claim-Sub"))
+}
+
+func TestForwardClaimsAsJWT(t *testing.T) {
+	f, err := newClaimsForwarder(config.ForwardClaimsConfiguration{
+		Enabled: true,
+		Mode:    config.ForwardClaimsModeJWT,
+		Claims:  []string{"sub", "org.id"},
+		JWT: config.ForwardClaimsJWTOptions{
+			HeaderName: "X-Router-Claims",
+			Issuer:     "cosmo-router",
+			Audience:   "subgraphs",
+		},
+	}, zap.NewNop())
+	require.NoError(t, err)
+
+	ctx := newClaimsForwarderTestContext(t, authentication.Claims{
+		"sub":   "user-1",
+		"org":   map[string]any{"id": "org-1", "name": "WunderGraph"},
+		"email": "user@example.com",
+	})
+
+	originReq, err := http.NewRequest("POST", "http://employees.local", nil)
+	require.NoError(t, err)
+	originReq.Header.Set("X-Router-Claims", "spoofed")
+	updatedReq, _ := f.OnOriginRequest(originReq, ctx)
+
+	token := updatedReq.Header.Get("X-Router-Claims")
+	require.NotEqual(t, "spoofed", token)
+
+	// Verify the token with the public key served by the JWKS endpoint
+	rec := httptest.NewRecorder()
+	handler, err := f.JWKSHandler()
+	require.NoError(t, err)
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", f.config.JWT.JWKSPath, nil))
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	jwks, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(jwks), f.signer.KeyID())
+
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (any, error) {
+		return f.signer.PublicKey(), nil
+	}, jwt.WithIssuer("cosmo-router"), jwt.WithAudience("subgraphs"), jwt.WithExpirationRequired())
+	require.NoError(t, err)
+
+	claims := parsed.Claims.(jwt.MapClaims)
+	assert.Equal(t, "user-1", claims["sub"])
+	assert.Equal(t, map[string]any{"id": "org-1"}, claims["org"])
+	assert.NotContains(t, claims, "email")
+}
+
+func TestForwardAllClaimsAsJWT(t *testing.T) {
+	f, err := newClaimsForwarder(config.ForwardClaimsConfiguration{
+		Enabled: true,
+		Mode:    config.ForwardClaimsModeJWT,
+		JWT: config.ForwardClaimsJWTOptions{
+			HeaderName: "X-Router-Claims",
+			Issuer:     "cosmo-router",
+		},
+	}, zap.NewNop())
+	require.NoError(t, err)
+
+	claims := f.tokenClaims(map[string]any{
+		"sub": "user-1",
+		"iss": "https://idp.example.com",
+		"aud": "client",
+		"jti": "token-1",
+	})
+
+	assert.Equal(t, "user-1", claims["sub"])
+	assert.Equal(t, "cosmo-router", claims["iss"])
+	assert.NotContains(t, claims, "aud")
+	assert.NotContains(t, claims, "jti")
+	assert.Contains(t, claims, "exp")
+}
+
+func TestInvalidClaimsForwarder(t *testing.T) {
+	_, err := newClaimsForwarder(config.ForwardClaimsConfiguration{
+		Enabled: true,
+		Mode:    config.ForwardClaimsModeHeaders,
+	}, zap.NewNop())
+	require.Error(t, err)
+
+	_, err = newClaimsForwarder(config.ForwardClaimsConfiguration{
+		Enabled: true,
+		Mode:    "cookies",
+		Claims:  []string{"sub"},
+	}, zap.NewNop())
+	require.Error(t, err)
+}
@@ -142,6 +142,20 @@ type requestContext struct {
 	sendError error
 	// subgraphs is the list of subgraphs taken from the router config
 	subgraphs []Subgraph
+	// responseHeaders collects the subgraph response headers propagated to the client
+	responseHeaders *responseHeaderPropagation
+}
+
+// responseHeaderPropagation returns the collected subgraph response headers of the request
+func (c *requestContext) responseHeaderPropagation() *responseHeaderPropagation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.responseHeaders == nil {
+		c.responseHeaders = newResponseHeaderPropagation()
+	}
+
+	return c.responseHeaders
 }
 
 func (c *requestContext) SendError() error {
@@ -371,6 +385,8 @@ type operationContext struct {
 	extensions     []byte
 	persistedID    string
 	protocol       OperationProtocol
+	// authorizationArguments are the field arguments of the operation for the authorization
+	authorizationArguments fieldArgumentsCache
 }
 
 func (o *operationContext) Variables() []byte {
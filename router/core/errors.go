@@ -15,6 +15,7 @@ import (
 	"go.uber.org/zap"
 	"net"
 	"net/http"
+	"strings"
 )
 
 type errorType int
@@ -27,6 +28,9 @@ const (
 	errorTypeContextTimeout
 	errorTypeUpgradeFailed
 	errorTypeEDFSNats
+	errorTypeEDFSKafka
+	errorTypeEDFSRedis
+	errorTypeEDFSInMemory
 )
 
 type (
@@ -68,6 +72,18 @@ func getErrorType(err error) errorType {
 	if errors.As(err, &edfsErr) {
 		return errorTypeEDFSNats
 	}
+	var edfsKafkaErr *pubsub.EDFSKafkaError
+	if errors.As(err, &edfsKafkaErr) {
+		return errorTypeEDFSKafka
+	}
+	var edfsRedisErr *pubsub.EDFSRedisError
+	if errors.As(err, &edfsRedisErr) {
+		return errorTypeEDFSRedis
+	}
+	var edfsInMemoryErr *pubsub.EDFSInMemoryError
+	if errors.As(err, &edfsInMemoryErr) {
+		return errorTypeEDFSInMemory
+	}
 	return errorTypeUnknown
 }
 
@@ -117,6 +133,10 @@ func writeRequestErrors(r *http.Request, w http.ResponseWriter, statusCode int,
 		if statusCode != 0 {
 			w.WriteHeader(statusCode)
 		}
+		if strings.HasPrefix(w.Header().Get("Content-Type"), multipartMediaType) {
+			writeMultipartRequestErrors(w, requestErrors, requestLogger)
+			return
+		}
 		if r.URL.Query().Has("wg_sse") {
 			_, err := w.Write([]byte("event: next\ndata: "))
 			if err != nil {
@@ -134,6 +154,22 @@ func writeRequestErrors(r *http.Request, w http.ResponseWriter, statusCode int,
 	}
 }
 
+// writeMultipartRequestErrors ends a multipart subscription response with the request errors. Errors without
+// payload are fatal errors of the subscription.
+func writeMultipartRequestErrors(w http.ResponseWriter, requestErrors graphqlerrors.RequestErrors, requestLogger *zap.Logger) {
+	errs, err := json.Marshal(requestErrors)
+	if err == nil {
+		for _, data := range [][]byte{multipartPartStart, []byte(`{"payload":null,"errors":`), errs, []byte("}"), multipartEnd} {
+			if _, err = w.Write(data); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil && requestLogger != nil {
+		requestLogger.Error("error writing response", zap.Error(err))
+	}
+}
+
 // writeOperationError writes the given error to the http.ResponseWriter but evaluates the error type first.
 // It also logs additional information about the error.
 func writeOperationError(r *http.Request, w http.ResponseWriter, requestLogger *zap.Logger, err error) {
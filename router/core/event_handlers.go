@@ -0,0 +1,421 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/buger/jsonparser"
+	"github.com/cespare/xxhash/v2"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/pubsub"
+)
+
+// eventHandlers transform and filter the events of subscription fields by field coordinate
+type eventHandlers struct {
+	handlers map[string]*eventHandler
+	logger   *zap.Logger
+}
+
+func newEventHandlers(subscriptions []config.EventSubscriptionConfiguration, logger *zap.Logger) (*eventHandlers, error) {
+	h := &eventHandlers{
+		handlers: make(map[string]*eventHandler, len(subscriptions)),
+		logger:   logger,
+	}
+	for _, subscription := range subscriptions {
+		if _, ok := h.handlers[subscription.Field]; ok {
+			return nil, fmt.Errorf("duplicate event subscription configuration for field %s", subscription.Field)
+		}
+		handler, err := newEventHandler(subscription)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event subscription configuration for field %s: %w", subscription.Field, err)
+		}
+		h.handlers[subscription.Field] = handler
+	}
+	return h, nil
+}
+
+// configureSubscription applies the handler of the subscribed field to the trigger of preparedPlan. It must be
+// called before the plan is post-processed. The field and its arguments are added to the input of the trigger,
+// so subscriptions only share events with subscriptions that have the same arguments.
+func (h *eventHandlers) configureSubscription(operation, definition *ast.Document, preparedPlan plan.Plan) error {
+	subscriptionPlan, ok := preparedPlan.(*plan.SubscriptionResponsePlan)
+	if !ok || subscriptionPlan.Response == nil {
+		return nil
+	}
+	fieldRef := subscriptionRootField(operation)
+	if fieldRef == ast.InvalidRef {
+		return nil
+	}
+	coordinate := string(definition.Index.SubscriptionTypeName) + "." + operation.FieldNameString(fieldRef)
+	handler, ok := h.handlers[coordinate]
+	if !ok {
+		return nil
+	}
+
+	trigger := &subscriptionPlan.Response.Trigger
+	input := bytes.TrimSpace(trigger.Input)
+	if len(input) == 0 || input[len(input)-1] != '}' {
+		return fmt.Errorf("unexpected subscription input for field %s", coordinate)
+	}
+
+	var arguments bytes.Buffer
+	arguments.WriteByte('{')
+	for i, argRef := range operation.FieldArguments(fieldRef) {
+		if i > 0 {
+			arguments.WriteByte(',')
+		}
+		name, err := json.Marshal(operation.ArgumentNameString(argRef))
+		if err != nil {
+			return err
+		}
+		arguments.Write(name)
+		arguments.WriteByte(':')
+		value := operation.ArgumentValue(argRef)
+		if value.Kind != ast.ValueKindVariable {
+			literal, err := operation.ValueToJSON(value)
+			if err != nil {
+				return err
+			}
+			arguments.Write(literal)
+			continue
+		}
+		placeholder, _ := trigger.Variables.AddVariable(&resolve.ContextVariable{
+			Path:     []string{operation.VariableValueNameString(value.Ref)},
+			Renderer: resolve.NewJSONVariableRenderer(),
+		})
+		arguments.WriteString(placeholder)
+	}
+	arguments.WriteByte('}')
+
+	field, err := json.Marshal(coordinate)
+	if err != nil {
+		return err
+	}
+	extended := make([]byte, 0, len(input)+len(field)+arguments.Len()+32)
+	extended = append(extended, input[:len(input)-1]...)
+	extended = append(extended, `, "field":`...)
+	extended = append(extended, field...)
+	extended = append(extended, `, "arguments":`...)
+	extended = append(extended, arguments.Bytes()...)
+	extended = append(extended, '}')
+	trigger.Input = extended
+
+	trigger.Source = &eventSubscriptionSource{
+		source:  trigger.Source,
+		handler: handler,
+		logger:  h.logger.With(zap.String("field", coordinate)),
+	}
+	return nil
+}
+
+// subscriptionRootField returns the root field of the subscription operation in operation
+func subscriptionRootField(operation *ast.Document) int {
+	for _, node := range operation.RootNodes {
+		if node.Kind != ast.NodeKindOperationDefinition {
+			continue
+		}
+		definition := operation.OperationDefinitions[node.Ref]
+		if definition.OperationType != ast.OperationTypeSubscription || !definition.HasSelections {
+			continue
+		}
+		for _, selectionRef := range operation.SelectionSets[definition.SelectionSet].SelectionRefs {
+			selection := operation.Selections[selectionRef]
+			if selection.Kind == ast.SelectionKindField && operation.FieldNameString(selection.Ref) != "__typename" {
+				return selection.Ref
+			}
+		}
+	}
+	return ast.InvalidRef
+}
+
+// eventSubscriptionSource passes the events of source through handler
+type eventSubscriptionSource struct {
+	source  resolve.SubscriptionDataSource
+	handler *eventHandler
+	logger  *zap.Logger
+}
+
+func (s *eventSubscriptionSource) UniqueRequestID(ctx *resolve.Context, input []byte, xxh *xxhash.Digest) error {
+	return s.source.UniqueRequestID(ctx, input, xxh)
+}
+
+func (s *eventSubscriptionSource) Start(ctx *resolve.Context, input []byte, updater resolve.SubscriptionUpdater) error {
+	arguments, _, _, err := jsonparser.Get(input, "arguments")
+	if err != nil {
+		return fmt.Errorf("failed to read subscription arguments: %w", err)
+	}
+	return s.source.Start(ctx, input, &eventUpdater{
+		updater:   updater,
+		handler:   s.handler,
+		arguments: arguments,
+		logger:    s.logger,
+	})
+}
+
+type eventUpdater struct {
+	updater   resolve.SubscriptionUpdater
+	handler   *eventHandler
+	arguments []byte
+	logger    *zap.Logger
+}
+
+func (u *eventUpdater) Update(data []byte) {
+	if event, ok := u.handle(data); ok {
+		u.updater.Update(event)
+	}
+}
+
+// UpdateCursor passes the cursor of the event on, if the event is not dropped
+func (u *eventUpdater) UpdateCursor(data []byte, cursor string) {
+	event, ok := u.handle(data)
+	if !ok {
+		return
+	}
+	if updater, isCursorUpdater := u.updater.(pubsub.CursorUpdater); isCursorUpdater {
+		updater.UpdateCursor(event, cursor)
+		return
+	}
+	u.updater.Update(event)
+}
+
+func (u *eventUpdater) handle(data []byte) ([]byte, bool) {
+	event, ok, err := u.handler.handle(data, u.arguments)
+	if err != nil {
+		u.logger.Warn("Dropped event that could not be transformed", zap.Error(err))
+		return nil, false
+	}
+	return event, ok
+}
+
+// UpdateError passes the error that ends the subscription on
+func (u *eventUpdater) UpdateError(err error) {
+	if updater, ok := u.updater.(pubsub.ErrorUpdater); ok {
+		updater.UpdateError(err)
+	}
+}
+
+func (u *eventUpdater) Done() {
+	u.updater.Done()
+}
+
+type eventFieldPath struct {
+	name string
+	path []string
+}
+
+type eventFilterCondition struct {
+	path     []string
+	argument string
+	values   []string
+}
+
+// eventHandler transforms events and decides if they match the arguments of a subscription
+type eventHandler struct {
+	fields   []eventFieldPath
+	typeName string
+	template *template.Template
+	filter   []eventFilterCondition
+}
+
+func newEventHandler(cfg config.EventSubscriptionConfiguration) (*eventHandler, error) {
+	h := &eventHandler{}
+	if transform := cfg.Transform; transform != nil {
+		if transform.Template != "" && (len(transform.Fields) > 0 || transform.TypeName != "") {
+			return nil, errors.New("transform with either fields or a template")
+		}
+		if transform.Template != "" {
+			tmpl, err := template.New(cfg.Field).Funcs(template.FuncMap{
+				"json": eventTemplateJSON,
+			}).Parse(transform.Template)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse template: %w", err)
+			}
+			h.template = tmpl
+		}
+		for name, path := range transform.Fields {
+			keys, err := parseEventPath(path)
+			if err != nil {
+				return nil, err
+			}
+			h.fields = append(h.fields, eventFieldPath{name: name, path: keys})
+		}
+		// Render the fields in a stable order
+		sort.Slice(h.fields, func(i, j int) bool {
+			return h.fields[i].name < h.fields[j].name
+		})
+		h.typeName = transform.TypeName
+	}
+	for _, condition := range cfg.Filter {
+		if (condition.Argument == "") == (len(condition.Values) == 0) {
+			return nil, fmt.Errorf("filter condition on %s needs either an argument or values", condition.Path)
+		}
+		keys, err := parseEventPath(condition.Path)
+		if err != nil {
+			return nil, err
+		}
+		h.filter = append(h.filter, eventFilterCondition{
+			path:     keys,
+			argument: condition.Argument,
+			values:   condition.Values,
+		})
+	}
+	return h, nil
+}
+
+// handle returns the transformed event and whether it matches arguments
+func (h *eventHandler) handle(data, arguments []byte) ([]byte, bool, error) {
+	event, err := h.transform(data, arguments)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, condition := range h.filter {
+		if !condition.matches(event, arguments) {
+			return nil, false, nil
+		}
+	}
+	return event, true, nil
+}
+
+func (h *eventHandler) transform(data, arguments []byte) ([]byte, error) {
+	switch {
+	case h.template != nil:
+		var values struct {
+			Data any
+			Args any
+		}
+		if err := unmarshalEventJSON(data, &values.Data); err != nil {
+			return nil, fmt.Errorf("invalid event: %w", err)
+		}
+		if err := unmarshalEventJSON(arguments, &values.Args); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %w", err)
+		}
+		var out bytes.Buffer
+		if err := h.template.Execute(&out, values); err != nil {
+			return nil, err
+		}
+		if !json.Valid(out.Bytes()) {
+			return nil, fmt.Errorf("template rendered invalid JSON: %s", out.String())
+		}
+		return out.Bytes(), nil
+	case len(h.fields) > 0 || h.typeName != "":
+		out := make([]byte, 0, len(data))
+		out = append(out, '{')
+		for i, field := range h.fields {
+			if i > 0 {
+				out = append(out, ',')
+			}
+			out = appendJSONString(out, field.name)
+			out = append(out, ':')
+			value, valueType, _, err := jsonparser.Get(data, field.path...)
+			switch {
+			case errors.Is(err, jsonparser.KeyPathNotFoundError):
+				out = append(out, "null"...)
+			case err != nil:
+				return nil, fmt.Errorf("invalid event: %w", err)
+			case valueType == jsonparser.String:
+				out = append(out, '"')
+				out = append(out, value...)
+				out = append(out, '"')
+			default:
+				out = append(out, value...)
+			}
+		}
+		if h.typeName != "" {
+			if len(h.fields) > 0 {
+				out = append(out, ',')
+			}
+			out = append(out, `"__typename":`...)
+			out = appendJSONString(out, h.typeName)
+		}
+		out = append(out, '}')
+		return out, nil
+	}
+	return data, nil
+}
+
+func (c *eventFilterCondition) matches(event, arguments []byte) bool {
+	value, valueType, _, err := jsonparser.Get(event, c.path...)
+	if err != nil || valueType == jsonparser.Null {
+		return false
+	}
+	if c.argument == "" {
+		for _, expected := range c.values {
+			if string(value) == expected {
+				return true
+			}
+		}
+		return false
+	}
+	argument, argumentType, _, err := jsonparser.Get(arguments, c.argument)
+	if err != nil || argumentType == jsonparser.Null {
+		// Subscriptions without the argument receive all events
+		return true
+	}
+	if argumentType != jsonparser.Array {
+		return bytes.Equal(value, argument)
+	}
+	matched := false
+	_, _ = jsonparser.ArrayEach(argument, func(item []byte, _ jsonparser.ValueType, _ int, _ error) {
+		if !matched && bytes.Equal(value, item) {
+			matched = true
+		}
+	})
+	return matched
+}
+
+var eventPathIndex = regexp.MustCompile(`^([^\[\]]*)((?:\[\d+\])*)$`)
+
+// parseEventPath returns the jsonparser keys of a path like $.employee.ids[0]
+func parseEventPath(path string) ([]string, error) {
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if trimmed == "" {
+		return nil, fmt.Errorf("invalid JSON path %q", path)
+	}
+	var keys []string
+	for _, segment := range strings.Split(trimmed, ".") {
+		match := eventPathIndex.FindStringSubmatch(segment)
+		if match == nil || (match[1] == "" && match[2] == "") {
+			return nil, fmt.Errorf("invalid JSON path %q", path)
+		}
+		if match[1] != "" {
+			keys = append(keys, match[1])
+		}
+		for _, index := range strings.SplitAfter(match[2], "]") {
+			if index != "" {
+				keys = append(keys, index)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func unmarshalEventJSON(data []byte, v *any) error {
+	if len(data) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// Numbers keep their representation, e.g. large IDs
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func eventTemplateJSON(v any) (string, error) {
+	out, err := json.Marshal(v)
+	return string(out), err
+}
+
+func appendJSONString(out []byte, s string) []byte {
+	encoded, _ := json.Marshal(s)
+	return append(out, encoded...)
+}
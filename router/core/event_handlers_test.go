@@ -0,0 +1,233 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astparser"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/asttransform"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/postprocess"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+type recordingUpdater struct {
+	updates []string
+	done    bool
+}
+
+func (u *recordingUpdater) Update(data []byte) {
+	u.updates = append(u.updates, string(data))
+}
+
+func (u *recordingUpdater) Done() {
+	u.done = true
+}
+
+type recordingSubscriptionSource struct {
+	input   []byte
+	updater resolve.SubscriptionUpdater
+}
+
+func (s *recordingSubscriptionSource) UniqueRequestID(_ *resolve.Context, input []byte, xxh *xxhash.Digest) error {
+	_, err := xxh.Write(input)
+	return err
+}
+
+func (s *recordingSubscriptionSource) Start(_ *resolve.Context, input []byte, updater resolve.SubscriptionUpdater) error {
+	s.input = input
+	s.updater = updater
+	return nil
+}
+
+func TestEventHandlerTransform(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fields", func(t *testing.T) {
+		h, err := newEventHandler(config.EventSubscriptionConfiguration{
+			Field: "Subscription.employeeUpdated",
+			Transform: &config.EventTransformConfiguration{
+				Fields: map[string]string{
+					"id":   "$.employee.id",
+					"tag":  "employee.tags[1]",
+					"name": "employee.name",
+				},
+				TypeName: "Employee",
+			},
+		})
+		require.NoError(t, err)
+
+		event, ok, err := h.handle([]byte(`{"employee":{"id":3,"tags":["a","b"]}}`), []byte(`{}`))
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, `{"id":3,"name":null,"tag":"b","__typename":"Employee"}`, string(event))
+	})
+
+	t.Run("template", func(t *testing.T) {
+		h, err := newEventHandler(config.EventSubscriptionConfiguration{
+			Field: "Subscription.employeeUpdated",
+			Transform: &config.EventTransformConfiguration{
+				Template: `{"id": {{ json .Data.employee.id }}, "requested": {{ json .Args.id }}, "__typename": "Employee"}`,
+			},
+		})
+		require.NoError(t, err)
+
+		event, ok, err := h.handle([]byte(`{"employee":{"id":12345678901234567890}}`), []byte(`{"id":"3"}`))
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.JSONEq(t, `{"id":12345678901234567890,"requested":"3","__typename":"Employee"}`, string(event))
+
+		_, _, err = h.handle([]byte(`not json`), []byte(`{}`))
+		require.Error(t, err)
+	})
+
+	t.Run("invalid template output", func(t *testing.T) {
+		h, err := newEventHandler(config.EventSubscriptionConfiguration{
+			Field:     "Subscription.employeeUpdated",
+			Transform: &config.EventTransformConfiguration{Template: `{"id": {{ .Data.id }}`},
+		})
+		require.NoError(t, err)
+		_, _, err = h.handle([]byte(`{"id":1}`), []byte(`{}`))
+		require.ErrorContains(t, err, "invalid JSON")
+	})
+
+	t.Run("without transform", func(t *testing.T) {
+		h, err := newEventHandler(config.EventSubscriptionConfiguration{Field: "Subscription.employeeUpdated"})
+		require.NoError(t, err)
+		event, ok, err := h.handle([]byte(`{"id":1}`), nil)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, `{"id":1}`, string(event))
+	})
+}
+
+func TestEventHandlerFilter(t *testing.T) {
+	t.Parallel()
+
+	h, err := newEventHandler(config.EventSubscriptionConfiguration{
+		Field: "Subscription.employeeUpdated",
+		Filter: []config.EventFilterCondition{
+			{Path: "id", Argument: "id"},
+			{Path: "details.status", Values: []string{"active", "onboarding"}},
+		},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		event     string
+		arguments string
+		match     bool
+	}{
+		{`{"id":3,"details":{"status":"active"}}`, `{"id":3}`, true},
+		{`{"id":3,"details":{"status":"active"}}`, `{"id":"3"}`, true},
+		{`{"id":4,"details":{"status":"active"}}`, `{"id":3}`, false},
+		{`{"id":3,"details":{"status":"retired"}}`, `{"id":3}`, false},
+		{`{"id":3}`, `{"id":3}`, false},
+		// List arguments match any of their values
+		{`{"id":4,"details":{"status":"onboarding"}}`, `{"id":[3,4]}`, true},
+		{`{"id":5,"details":{"status":"onboarding"}}`, `{"id":[3,4]}`, false},
+		// Subscriptions without the argument receive all events
+		{`{"id":5,"details":{"status":"active"}}`, `{"id":null}`, true},
+		{`{"id":5,"details":{"status":"active"}}`, `{}`, true},
+	}
+	for _, tt := range tests {
+		_, ok, err := h.handle([]byte(tt.event), []byte(tt.arguments))
+		require.NoError(t, err)
+		require.Equal(t, tt.match, ok, "%s %s", tt.event, tt.arguments)
+	}
+}
+
+func TestNewEventHandlersInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := newEventHandlers([]config.EventSubscriptionConfiguration{{
+		Field: "Subscription.employeeUpdated",
+		Transform: &config.EventTransformConfiguration{
+			Template: `{}`,
+			TypeName: "Employee",
+		},
+	}}, zap.NewNop())
+	require.ErrorContains(t, err, "either fields or a template")
+
+	_, err = newEventHandlers([]config.EventSubscriptionConfiguration{{
+		Field:  "Subscription.employeeUpdated",
+		Filter: []config.EventFilterCondition{{Path: "id"}},
+	}}, zap.NewNop())
+	require.ErrorContains(t, err, "either an argument or values")
+
+	_, err = newEventHandlers([]config.EventSubscriptionConfiguration{
+		{Field: "Subscription.employeeUpdated"},
+		{Field: "Subscription.employeeUpdated"},
+	}, zap.NewNop())
+	require.ErrorContains(t, err, "duplicate")
+
+	for _, path := range []string{"", "$", "employee..id", "employee.id]", "employee[a]"} {
+		_, err := parseEventPath(path)
+		require.Error(t, err, path)
+	}
+	keys, err := parseEventPath("$.employees[0][1].id")
+	require.NoError(t, err)
+	require.Equal(t, []string{"employees", "[0]", "[1]", "id"}, keys)
+}
+
+func TestEventHandlersConfigureSubscription(t *testing.T) {
+	t.Parallel()
+
+	definition, report := astparser.ParseGraphqlDocumentString(`
+		type Query { employee(id: ID!): Employee }
+		type Subscription { employeeUpdated(id: ID!, active: Boolean): Employee }
+		type Employee { id: ID! }
+	`)
+	require.False(t, report.HasErrors(), report.Error())
+	require.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&definition))
+
+	operation, report := astparser.ParseGraphqlDocumentString(`subscription Updates($a: ID!) { employeeUpdated(id: $a, active: true) { id } }`)
+	require.False(t, report.HasErrors(), report.Error())
+
+	handlers, err := newEventHandlers([]config.EventSubscriptionConfiguration{{
+		Field:  "Subscription.employeeUpdated",
+		Filter: []config.EventFilterCondition{{Path: "id", Argument: "id"}},
+	}}, zap.NewNop())
+	require.NoError(t, err)
+
+	source := &recordingSubscriptionSource{}
+	subscriptionPlan := &plan.SubscriptionResponsePlan{
+		Response: &resolve.GraphQLSubscription{
+			Trigger: resolve.GraphQLSubscriptionTrigger{
+				Input:  []byte(`{"subjects":["employeeUpdated"], "sourceName":"default"}`),
+				Source: source,
+			},
+			Response: &resolve.GraphQLResponse{
+				Data: &resolve.Object{},
+			},
+		},
+	}
+	require.NoError(t, handlers.configureSubscription(&operation, &definition, subscriptionPlan))
+	(&postprocess.ResolveInputTemplates{}).Process(subscriptionPlan)
+
+	ctx := resolve.NewContext(context.Background())
+	ctx.Variables = []byte(`{"a":"3"}`)
+	var input bytes.Buffer
+	require.NoError(t, subscriptionPlan.Response.Trigger.InputTemplate.Render(ctx, nil, &input))
+	require.Equal(t, `{"subjects":["employeeUpdated"], "sourceName":"default", "field":"Subscription.employeeUpdated", "arguments":{"id":"3","active":true}}`, input.String())
+
+	updater := &recordingUpdater{}
+	require.NoError(t, subscriptionPlan.Response.Trigger.Source.Start(ctx, input.Bytes(), updater))
+	require.Equal(t, input.Bytes(), source.input)
+
+	source.updater.Update([]byte(`{"id":"4"}`))
+	source.updater.Update([]byte(`{"id":"3"}`))
+	source.updater.Done()
+	require.Equal(t, []string{`{"id":"3"}`}, updater.updates)
+	require.True(t, updater.done)
+
+	// Other fields keep their plan
+	queryPlan := &plan.SynchronousResponsePlan{}
+	require.NoError(t, handlers.configureSubscription(&operation, &definition, queryPlan))
+}
@@ -2,19 +2,31 @@ package core
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"time"
 
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/operationreport"
 
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/pubsub_datasource"
 
+	"github.com/wundergraph/cosmo/router/internal/clienttls"
 	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/health"
+	rmetric "github.com/wundergraph/cosmo/router/pkg/metric"
 	"github.com/wundergraph/cosmo/router/pkg/pubsub"
 
 	"go.uber.org/zap"
 
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/astparser"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/asttransform"
@@ -31,6 +43,15 @@ type ExecutorConfigurationBuilder struct {
 	baseURL       string
 	transport     http.RoundTripper
 	logger        *zap.Logger
+	// eventBuses are the buses of the INMEMORY event sources by source name
+	eventBuses map[string]*pubsub.Bus
+	// eventMetrics records the traffic of the event sources, nil if metrics are disabled
+	eventMetrics *rmetric.EventMetrics
+	// readinessChecks are the connection states of the event sources by check name, collected by Build
+	readinessChecks map[string]health.ReadinessCheck
+	// closers close the connections of the event sources, collected by Build. They are called
+	// when the server using the executor is shutdown.
+	closers []func() error
 
 	transportOptions *TransportOptions
 }
@@ -40,9 +61,27 @@ type Executor struct {
 	Definition      *ast.Document
 	Resolver        *resolve.Resolver
 	RenameTypeNames []resolve.RenameTypeName
+	// eventHandlers transform and filter the events of subscriptions, nil if none are configured
+	eventHandlers *eventHandlers
+	// subscriptionFanOut shares upstream subscriptions and buffers the updates of each client
+	subscriptionFanOut *subscriptionFanOut
 }
 
 func (b *ExecutorConfigurationBuilder) Build(ctx context.Context, routerConfig *nodev1.RouterConfig, routerEngineConfig *RouterEngineConfiguration, reporter resolve.Reporter) (*Executor, error) {
+	var handlers *eventHandlers
+	if len(routerEngineConfig.Events.Subscriptions) > 0 {
+		var err error
+		handlers, err = newEventHandlers(routerEngineConfig.Events.Subscriptions, b.logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fanOut, err := newSubscriptionFanOut(routerEngineConfig.Execution, routerEngineConfig.Headers, routerEngineConfig.ClaimsForwarder)
+	if err != nil {
+		return nil, err
+	}
+
 	planConfig, err := b.buildPlannerConfiguration(ctx, routerConfig, routerEngineConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build planner configuration: %w", err)
@@ -123,10 +162,12 @@ func (b *ExecutorConfigurationBuilder) Build(ctx context.Context, routerConfig *
 	}
 
 	return &Executor{
-		PlanConfig:      *planConfig,
-		Definition:      &definition,
-		Resolver:        resolver,
-		RenameTypeNames: renameTypeNames,
+		PlanConfig:         *planConfig,
+		Definition:         &definition,
+		Resolver:           resolver,
+		RenameTypeNames:    renameTypeNames,
+		eventHandlers:      handlers,
+		subscriptionFanOut: fanOut,
 	}, nil
 }
 
@@ -134,8 +175,17 @@ func natsAuthenticationOptions(authentication *config.Authentication) ([]nats.Op
 	if authentication == nil {
 		return nil, nil
 	}
-	if authentication.Token != nil {
+	switch {
+	case authentication.Token != nil:
 		return []nats.Option{nats.Token(*authentication.Token)}, nil
+	case authentication.NKeySeedFile != "":
+		option, err := nats.NkeyOptionFromSeed(authentication.NKeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load NKEY seed: %w", err)
+		}
+		return []nats.Option{option}, nil
+	case authentication.CredentialsFile != "":
+		return []nats.Option{nats.UserCredentials(authentication.CredentialsFile)}, nil
 	}
 	if authentication.Username == nil || authentication.Password == nil {
 		return nil, fmt.Errorf("must provide username and password if token is not provided")
@@ -143,6 +193,224 @@ func natsAuthenticationOptions(authentication *config.Authentication) ([]nats.Op
 	return []nats.Option{nats.UserInfo(*authentication.Username, *authentication.Password)}, nil
 }
 
+const (
+	defaultNatsReconnectInitialWait = 2 * time.Second
+	defaultNatsReconnectMaxWait     = 30 * time.Second
+	defaultNatsReconnectJitter      = 100 * time.Millisecond
+)
+
+// natsReconnectDelay returns the wait before a reconnect attempt. The wait starts at the initial wait and
+// doubles with every failed attempt up to the maximum wait, plus a random jitter.
+func natsReconnectDelay(cfg *config.NatsReconnectConfiguration) nats.ReconnectDelayHandler {
+	initialWait, maxWait, jitter := cfg.InitialWait, cfg.MaxWait, cfg.Jitter
+	if initialWait <= 0 {
+		initialWait = defaultNatsReconnectInitialWait
+	}
+	if maxWait <= 0 {
+		maxWait = defaultNatsReconnectMaxWait
+	}
+	if maxWait < initialWait {
+		maxWait = initialWait
+	}
+	if jitter <= 0 {
+		jitter = defaultNatsReconnectJitter
+	}
+	return func(attempts int) time.Duration {
+		wait := initialWait
+		for i := 1; i < attempts && wait < maxWait; i++ {
+			wait *= 2
+		}
+		if wait > maxWait {
+			wait = maxWait
+		}
+		return wait + time.Duration(rand.Int63n(int64(jitter)))
+	}
+}
+
+// natsOptions returns the connection options of the NATS source sourceName. Reconnects are counted
+// in metrics if it is not nil.
+func natsOptions(sourceName string, eventSource config.EventSource, logger *zap.Logger, metrics *rmetric.EventSourceMetrics) ([]nats.Option, error) {
+	options, err := natsAuthenticationOptions(eventSource.Authentication)
+	if err != nil {
+		return nil, err
+	}
+	if eventSource.TLS != nil {
+		tlsConfig, err := eventSourceTLSConfig(eventSource.TLS, logger)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, nats.Secure(tlsConfig))
+	}
+	if reconnect := eventSource.Reconnect; reconnect != nil {
+		if reconnect.MaxReconnects != 0 {
+			options = append(options, nats.MaxReconnects(reconnect.MaxReconnects))
+		}
+		options = append(options,
+			nats.CustomReconnectDelay(natsReconnectDelay(reconnect)),
+			nats.RetryOnFailedConnect(reconnect.RetryOnFailedConnect),
+		)
+	}
+
+	logger = logger.With(zap.String("source_name", sourceName))
+	options = append(options,
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			// The error is nil when the connection is closed by the router
+			if err != nil {
+				logger.Warn("Disconnected from NATS", zap.Error(err))
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			logger.Info("Reconnected to NATS", zap.String("url", conn.ConnectedUrlRedacted()))
+			if metrics != nil {
+				metrics.Reconnected()
+			}
+		}),
+		nats.ClosedHandler(func(conn *nats.Conn) {
+			if err := conn.LastError(); err != nil {
+				logger.Error("NATS connection closed, reconnects are exhausted", zap.Error(err))
+			}
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, sub *nats.Subscription, err error) {
+			// Slow consumers are reported by the subscriptions with the number of dropped messages
+			if errors.Is(err, nats.ErrSlowConsumer) {
+				return
+			}
+			fields := []zap.Field{zap.Error(err)}
+			if sub != nil {
+				fields = append(fields, zap.String("subject", sub.Subject))
+			}
+			logger.Error("NATS error", fields...)
+		}),
+	)
+	return options, nil
+}
+
+// natsReadinessCheck fails while conn is not connected, e.g. while it reconnects
+func natsReadinessCheck(conn *nats.Conn) health.ReadinessCheck {
+	return func() error {
+		if conn.IsConnected() {
+			return nil
+		}
+		return fmt.Errorf("NATS connection is %s", conn.Status())
+	}
+}
+
+func natsJetStreamOptions(cfg *config.NatsJetStreamConfiguration) (pubsub.JetStreamConsumerOptions, error) {
+	var options pubsub.JetStreamConsumerOptions
+	if cfg == nil {
+		return options, nil
+	}
+	switch cfg.AckPolicy {
+	case "", "explicit":
+		options.AckPolicy = jetstream.AckExplicitPolicy
+	case "all":
+		options.AckPolicy = jetstream.AckAllPolicy
+	case "none":
+		options.AckPolicy = jetstream.AckNonePolicy
+	default:
+		return options, fmt.Errorf("unknown JetStream ack policy \"%s\"", cfg.AckPolicy)
+	}
+	options.MaxDeliver = cfg.MaxDeliver
+	options.BatchSize = cfg.FetchBatchSize
+	options.Ephemeral = cfg.EphemeralConsumers
+	options.InactiveThreshold = cfg.InactiveThreshold
+	return options, nil
+}
+
+// eventSourceTLSConfig returns the TLS configuration for the connections to an event source. The server
+// name is set per connection when it is not configured.
+func eventSourceTLSConfig(cfg *config.TLSClientCertConfiguration, logger *zap.Logger) (*tls.Config, error) {
+	minVersion, err := clienttls.ParseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	loader, err := clienttls.New(clienttls.Options{
+		CAFile:             cfg.CAFile,
+		CertFile:           cfg.CertFile,
+		KeyFile:            cfg.KeyFile,
+		ServerName:         cfg.ServerName,
+		MinVersion:         minVersion,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		Logger:             logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := loader.Config("")
+	tlsConfig.NextProtos = nil
+	return tlsConfig, nil
+}
+
+func kafkaOptions(eventSource config.EventSource, logger *zap.Logger) ([]kgo.Opt, error) {
+	if len(eventSource.Brokers) == 0 {
+		return nil, errors.New("at least one kafka broker is required")
+	}
+	options := []kgo.Opt{
+		kgo.SeedBrokers(eventSource.Brokers...),
+		kgo.ClientID("cosmo-router"),
+		// Topics are created by the brokers on first use, if enabled
+		kgo.AllowAutoTopicCreation(),
+		kgo.WithLogger(&kafkaLogger{logger: logger}),
+	}
+	if eventSource.TLS != nil {
+		tlsConfig, err := eventSourceTLSConfig(eventSource.TLS, logger)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, kgo.DialTLSConfig(tlsConfig))
+	}
+	if authentication := eventSource.Authentication; authentication != nil {
+		if authentication.Username == nil || authentication.Password == nil {
+			return nil, fmt.Errorf("must provide username and password for SASL authentication")
+		}
+		var mechanism sasl.Mechanism
+		switch authentication.Mechanism {
+		case "", "PLAIN":
+			mechanism = plain.Auth{User: *authentication.Username, Pass: *authentication.Password}.AsMechanism()
+		case "SCRAM-SHA-256":
+			mechanism = scram.Auth{User: *authentication.Username, Pass: *authentication.Password}.AsSha256Mechanism()
+		case "SCRAM-SHA-512":
+			mechanism = scram.Auth{User: *authentication.Username, Pass: *authentication.Password}.AsSha512Mechanism()
+		default:
+			return nil, fmt.Errorf("unknown SASL mechanism %q, expected PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512", authentication.Mechanism)
+		}
+		options = append(options, kgo.SASL(mechanism))
+	}
+	return options, nil
+}
+
+// kafkaLogger logs the warnings and errors of Kafka clients
+type kafkaLogger struct {
+	logger *zap.Logger
+}
+
+func (l *kafkaLogger) Level() kgo.LogLevel {
+	return kgo.LogLevelWarn
+}
+
+func (l *kafkaLogger) Log(level kgo.LogLevel, msg string, keyvals ...any) {
+	fields := make([]zap.Field, 0, len(keyvals)/2)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		fields = append(fields, zap.Any(fmt.Sprint(keyvals[i]), keyvals[i+1]))
+	}
+	if level == kgo.LogLevelError {
+		l.logger.Error(msg, fields...)
+		return
+	}
+	l.logger.Warn(msg, fields...)
+}
+
+func (b *ExecutorConfigurationBuilder) addReadinessCheck(name string, check health.ReadinessCheck) {
+	if b.readinessChecks == nil {
+		b.readinessChecks = make(map[string]health.ReadinessCheck)
+	}
+	b.readinessChecks[name] = check
+}
+
+func (b *ExecutorConfigurationBuilder) addCloser(close func() error) {
+	b.closers = append(b.closers, close)
+}
+
 func (b *ExecutorConfigurationBuilder) buildPlannerConfiguration(ctx context.Context, routerCfg *nodev1.RouterConfig, routerEngineCfg *RouterEngineConfiguration) (*plan.Configuration, error) {
 	// this loader is used to take the engine config and create a plan config
 	// the plan config is what the engine uses to turn a GraphQL Request into an execution plan
@@ -166,15 +434,64 @@ func (b *ExecutorConfigurationBuilder) buildPlannerConfiguration(ctx context.Con
 			}
 			switch eventSource.Provider {
 			case "NATS":
-				options, err := natsAuthenticationOptions(eventSource.Authentication)
+				var sourceMetrics *rmetric.EventSourceMetrics
+				if b.eventMetrics != nil {
+					sourceMetrics = b.eventMetrics.Source(eventSource.Provider, eventConfiguration.SourceName)
+				}
+				options, err := natsOptions(eventConfiguration.SourceName, eventSource, b.logger, sourceMetrics)
+				if err != nil {
+					return nil, fmt.Errorf("failed to configure NATS provider with sourceName \"%s\": %w", eventConfiguration.SourceName, err)
+				}
+				jetStreamOptions, err := natsJetStreamOptions(eventSource.JetStream)
 				if err != nil {
-					return nil, fmt.Errorf("failed to add authentication for NATS provider with sourceName \"%s\": %w", eventConfiguration.SourceName, err)
+					return nil, fmt.Errorf("failed to configure NATS provider with sourceName \"%s\": %w", eventConfiguration.SourceName, err)
 				}
 				natsConnection, err := nats.Connect(eventSource.URL, options...)
 				if err != nil {
 					return nil, fmt.Errorf("failed to connect to NATS: %w", err)
 				}
-				pubSubBySourceName[eventConfiguration.SourceName] = pubsub.NewNATSConnector(natsConnection).New(ctx)
+				connectorOptions := []pubsub.NATSOption{
+					pubsub.WithNATSLogger(b.logger),
+					pubsub.WithJetStreamConsumerOptions(jetStreamOptions),
+				}
+				if sourceMetrics != nil {
+					connectorOptions = append(connectorOptions, pubsub.WithNATSMetrics(sourceMetrics))
+				}
+				pubSubBySourceName[eventConfiguration.SourceName] = pubsub.NewNATSConnector(natsConnection, connectorOptions...).New(ctx)
+				b.addReadinessCheck("nats:"+eventConfiguration.SourceName, natsReadinessCheck(natsConnection))
+				// Pending messages are still delivered, the connection is closed when the drain completes
+				b.addCloser(natsConnection.Drain)
+			case "KAFKA":
+				options, err := kafkaOptions(eventSource, b.logger)
+				if err != nil {
+					return nil, fmt.Errorf("failed to configure KAFKA provider with sourceName \"%s\": %w", eventConfiguration.SourceName, err)
+				}
+				kafkaClient, err := kgo.NewClient(options...)
+				if err != nil {
+					return nil, fmt.Errorf("failed to configure KAFKA provider with sourceName \"%s\": %w", eventConfiguration.SourceName, err)
+				}
+				if err := kafkaClient.Ping(ctx); err != nil {
+					kafkaClient.Close()
+					return nil, fmt.Errorf("failed to connect to Kafka: %w", err)
+				}
+				pubSubBySourceName[eventConfiguration.SourceName] = pubsub.NewKafkaConnector(kafkaClient, options, eventSource.ConsumerGroup).New(ctx)
+				b.addCloser(func() error {
+					kafkaClient.Close()
+					return nil
+				})
+			case "INMEMORY":
+				pubSubBySourceName[eventConfiguration.SourceName] = pubsub.NewInMemoryConnector(b.eventBuses[eventConfiguration.SourceName]).New(ctx)
+			case "REDIS":
+				redisClient, err := newRedisClient(config.RedisConfiguration{Url: eventSource.URL})
+				if err != nil {
+					return nil, fmt.Errorf("failed to configure REDIS provider with sourceName \"%s\": %w", eventConfiguration.SourceName, err)
+				}
+				if err := redisClient.Ping(ctx).Err(); err != nil {
+					_ = redisClient.Close()
+					return nil, fmt.Errorf("failed to connect to redis: %w", err)
+				}
+				pubSubBySourceName[eventConfiguration.SourceName] = pubsub.NewRedisConnector(redisClient, eventSource.ConsumerGroup).New(ctx)
+				b.addCloser(redisClient.Close)
 			default:
 				return nil, fmt.Errorf("unknown event source provider %s for sourceName \"%s\"", eventConfiguration.SourceName, eventSource.Provider)
 			}
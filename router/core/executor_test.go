@@ -0,0 +1,35 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestNatsReconnectDelay(t *testing.T) {
+	delay := natsReconnectDelay(&config.NatsReconnectConfiguration{
+		InitialWait: time.Second,
+		MaxWait:     5 * time.Second,
+		Jitter:      time.Millisecond,
+	})
+
+	for attempts, expected := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		// The wait does not overflow after many attempts
+		1000: 5 * time.Second,
+	} {
+		wait := delay(attempts)
+		require.GreaterOrEqual(t, wait, expected, "attempt %d", attempts)
+		require.Less(t, wait, expected+time.Millisecond, "attempt %d", attempts)
+	}
+
+	// Defaults of the NATS client
+	wait := natsReconnectDelay(&config.NatsReconnectConfiguration{})(1)
+	require.GreaterOrEqual(t, wait, defaultNatsReconnectInitialWait)
+	require.Less(t, wait, defaultNatsReconnectInitialWait+defaultNatsReconnectJitter)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"time"
 
 	"github.com/wundergraph/cosmo/router/pkg/config"
@@ -16,6 +17,7 @@ import (
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/pubsub_datasource"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/staticdatasource"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
 
 	"github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/common"
 	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
@@ -130,6 +132,11 @@ type RouterEngineConfiguration struct {
 	Headers                  config.HeaderRules
 	Events                   config.EventsConfiguration
 	SubgraphErrorPropagation config.SubgraphErrorPropagationConfiguration
+	AuthorizationPolicies    *AuthorizationPolicies
+	// ExternalAuthorizationFields are the fields delegated to the external authorization service
+	ExternalAuthorizationFields []resolve.GraphCoordinate
+	// ClaimsForwarder is nil when the claims are not forwarded to the subgraphs
+	ClaimsForwarder *claimsForwarder
 }
 
 func (l *Loader) Load(routerConfig *nodev1.RouterConfig, routerEngineConfig *RouterEngineConfiguration) (*plan.Configuration, error) {
@@ -163,6 +170,24 @@ func (l *Loader) Load(routerConfig *nodev1.RouterConfig, routerEngineConfig *Rou
 		outConfig.Fields = append(outConfig.Fields, fieldConfig)
 	}
 
+	// The engine only calls the authorizer for fields with an authorization rule. Fields that are
+	// only authorized by the router don't have a configuration yet.
+	routerCoordinates := append(routerEngineConfig.AuthorizationPolicies.Coordinates(), routerEngineConfig.ExternalAuthorizationFields...)
+	for _, coordinate := range routerCoordinates {
+		i := slices.IndexFunc(outConfig.Fields, func(field plan.FieldConfiguration) bool {
+			return field.TypeName == coordinate.TypeName && field.FieldName == coordinate.FieldName
+		})
+		if i != -1 {
+			outConfig.Fields[i].HasAuthorizationRule = true
+			continue
+		}
+		outConfig.Fields = append(outConfig.Fields, plan.FieldConfiguration{
+			TypeName:             coordinate.TypeName,
+			FieldName:            coordinate.FieldName,
+			HasAuthorizationRule: true,
+		})
+	}
+
 	for _, configuration := range engineConfig.TypeConfigurations {
 		outConfig.Types = append(outConfig.Types, plan.TypeConfiguration{
 			TypeName: configuration.TypeName,
@@ -3,7 +3,12 @@ package core
 import (
 	"bytes"
 	"context"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
 )
@@ -14,6 +19,20 @@ const (
 	WgSubscribeOnceParam = WgPrefix + "subscribe_once"
 )
 
+// The multipart subscription protocol of Apollo, see
+// https://www.apollographql.com/docs/router/executing-operations/subscription-multipart-protocol
+const (
+	multipartMediaType   = "multipart/mixed"
+	multipartContentType = `multipart/mixed;boundary="graphql";subscriptionSpec="1.0"`
+)
+
+var (
+	multipartPartStart = []byte("\r\n--graphql\r\ncontent-type: application/json\r\n\r\n")
+	multipartEnd       = []byte("\r\n--graphql--\r\n")
+	// multipartHeartbeat is an empty part that keeps idle connections open
+	multipartHeartbeat = []byte("{}")
+)
+
 type HttpFlushWriter struct {
 	ctx           context.Context
 	cancel        context.CancelFunc
@@ -21,18 +40,27 @@ type HttpFlushWriter struct {
 	flusher       http.Flusher
 	subscribeOnce bool
 	sse           bool
+	multipart     bool
 	buf           *bytes.Buffer
 	variables     []byte
+	// mu serializes the writes of the updates and the heartbeats
+	mu sync.Mutex
 }
 
 func (f *HttpFlushWriter) Complete() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	if f.ctx.Err() != nil {
 		return
 	}
 	if f.sse {
 		_, _ = f.writer.Write([]byte("event: complete"))
 	}
-	f.Close()
+	if f.multipart {
+		_, _ = f.writer.Write(multipartEnd)
+		f.flusher.Flush()
+	}
+	f.cancel()
 }
 
 func (f *HttpFlushWriter) Write(p []byte) (n int, err error) {
@@ -42,14 +70,16 @@ func (f *HttpFlushWriter) Write(p []byte) (n int, err error) {
 	return f.buf.Write(p)
 }
 
+// Close stops the writer. Nothing is written to the response after Close returned.
 func (f *HttpFlushWriter) Close() {
-	if f.ctx.Err() != nil {
-		return
-	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.cancel()
 }
 
 func (f *HttpFlushWriter) Flush() (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	if err = f.ctx.Err(); err != nil {
 		return err
 	}
@@ -57,6 +87,10 @@ func (f *HttpFlushWriter) Flush() (err error) {
 	resp := f.buf.Bytes()
 	f.buf.Reset()
 
+	if f.multipart {
+		return f.writeMultipartPart(resp)
+	}
+
 	if f.sse {
 		_, err = f.writer.Write([]byte("event: next\ndata: "))
 		if err != nil {
@@ -81,6 +115,52 @@ func (f *HttpFlushWriter) Flush() (err error) {
 	return nil
 }
 
+// writeMultipartPart writes the update as a part with the response in the payload
+func (f *HttpFlushWriter) writeMultipartPart(resp []byte) error {
+	for _, data := range [][]byte{multipartPartStart, []byte(`{"payload":`), resp, []byte("}")} {
+		if _, err := f.writer.Write(data); err != nil {
+			return err
+		}
+	}
+	f.flusher.Flush()
+	return nil
+}
+
+// startHeartbeat writes an empty part every interval until the writer is closed. Only multipart
+// responses have heartbeats.
+func (f *HttpFlushWriter) startHeartbeat(interval time.Duration) {
+	if !f.multipart || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-f.ctx.Done():
+				return
+			case <-ticker.C:
+				f.heartbeat()
+			}
+		}
+	}()
+}
+
+func (f *HttpFlushWriter) heartbeat() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.ctx.Err() != nil {
+		return
+	}
+	for _, data := range [][]byte{multipartPartStart, multipartHeartbeat} {
+		if _, err := f.writer.Write(data); err != nil {
+			// The client is gone, the request context ends the subscription
+			return
+		}
+	}
+	f.flusher.Flush()
+}
+
 func GetSubscriptionResponseWriter(ctx *resolve.Context, variables []byte, r *http.Request, w http.ResponseWriter) (*resolve.Context, resolve.SubscriptionResponseWriter, bool) {
 	type withFlushWriter interface {
 		SubscriptionResponseWriter() resolve.SubscriptionResponseWriter
@@ -95,7 +175,9 @@ func GetSubscriptionResponseWriter(ctx *resolve.Context, variables []byte, r *ht
 		return ctx, nil, false
 	}
 
-	if !wgParams.SubscribeOnce {
+	if wgParams.UseMultipart {
+		setMultipartSubscriptionHeaders(w)
+	} else if !wgParams.SubscribeOnce {
 		setSubscriptionHeaders(w)
 	}
 
@@ -103,6 +185,7 @@ func GetSubscriptionResponseWriter(ctx *resolve.Context, variables []byte, r *ht
 		writer:    w,
 		flusher:   flusher,
 		sse:       wgParams.UseSse,
+		multipart: wgParams.UseMultipart,
 		buf:       &bytes.Buffer{},
 		ctx:       ctx.Context(),
 		variables: variables,
@@ -125,15 +208,60 @@ func setSubscriptionHeaders(w http.ResponseWriter) {
 	w.Header().Set("X-Accel-Buffering", "no")
 }
 
+func setMultipartSubscriptionHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", multipartContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+}
+
 func NewWgRequestParams(r *http.Request) WgRequestParams {
 	q := r.URL.Query()
-	return WgRequestParams{
+	params := WgRequestParams{
 		UseSse:        q.Has(WgSseParam),
 		SubscribeOnce: q.Has(WgSubscribeOnceParam),
 	}
+	// The query parameters take precedence over the Accept header
+	params.UseMultipart = !params.UseSse && !params.SubscribeOnce && acceptsMultipart(r.Header)
+	return params
 }
 
 type WgRequestParams struct {
 	UseSse        bool
 	SubscribeOnce bool
+	// UseMultipart is true if the client prefers multipart/mixed responses
+	UseMultipart bool
+}
+
+// acceptsMultipart returns true if multipart/mixed has the highest quality of the media types
+// in the Accept header that the router responds with. The first one wins a tie.
+func acceptsMultipart(header http.Header) bool {
+	var (
+		best        string
+		bestQuality float64
+	)
+	for _, value := range header.Values("Accept") {
+		for _, mediaRange := range strings.Split(value, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err != nil {
+				continue
+			}
+			switch mediaType {
+			case multipartMediaType, "application/json", "application/graphql-response+json", "text/event-stream":
+			default:
+				continue
+			}
+			quality := 1.0
+			if q, ok := params["q"]; ok {
+				quality, err = strconv.ParseFloat(q, 64)
+				if err != nil {
+					continue
+				}
+			}
+			if quality > bestQuality {
+				best, bestQuality = mediaType, quality
+			}
+		}
+	}
+	return best == multipartMediaType
 }
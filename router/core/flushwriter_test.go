@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+func TestNewWgRequestParamsMultipart(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		query     string
+		accept    []string
+		multipart bool
+	}{
+		{name: "apollo client", accept: []string{`multipart/mixed;subscriptionSpec="1.0", application/json`}, multipart: true},
+		{name: "without parameters", accept: []string{"multipart/mixed"}, multipart: true},
+		{name: "json first", accept: []string{"application/json, multipart/mixed"}},
+		{name: "higher quality", accept: []string{"application/json;q=0.5, multipart/mixed"}, multipart: true},
+		{name: "excluded", accept: []string{"multipart/mixed;q=0"}},
+		{name: "multiple headers", accept: []string{"text/html", "multipart/mixed"}, multipart: true},
+		{name: "any", accept: []string{"*/*"}},
+		{name: "none"},
+		{name: "sse parameter", query: "?wg_sse", accept: []string{"multipart/mixed"}},
+		{name: "subscribe once parameter", query: "?wg_subscribe_once", accept: []string{"multipart/mixed"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodPost, "/graphql"+tt.query, nil)
+			for _, accept := range tt.accept {
+				r.Header.Add("Accept", accept)
+			}
+			require.Equal(t, tt.multipart, NewWgRequestParams(r).UseMultipart)
+		})
+	}
+}
+
+func TestHttpFlushWriterMultipart(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	r.Header.Set("Accept", `multipart/mixed;subscriptionSpec="1.0", application/json`)
+	recorder := httptest.NewRecorder()
+
+	ctx, writer, ok := GetSubscriptionResponseWriter(resolve.NewContext(context.Background()), nil, r, recorder)
+	require.True(t, ok)
+	require.Equal(t, `multipart/mixed;boundary="graphql";subscriptionSpec="1.0"`, recorder.Header().Get("Content-Type"))
+
+	_, err := writer.Write([]byte(`{"data":{"a":1}}`))
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+	writer.Complete()
+	require.Error(t, ctx.Context().Err())
+
+	require.Equal(t, "\r\n--graphql\r\ncontent-type: application/json\r\n\r\n"+
+		`{"payload":{"data":{"a":1}}}`+
+		"\r\n--graphql--\r\n", recorder.Body.String())
+
+	// Nothing is written after the completion
+	_, _ = writer.Write([]byte(`{"data":{"a":2}}`))
+	require.Error(t, writer.Flush())
+}
+
+func TestHttpFlushWriterMultipartHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	r.Header.Set("Accept", "multipart/mixed")
+	recorder := httptest.NewRecorder()
+
+	_, writer, ok := GetSubscriptionResponseWriter(resolve.NewContext(context.Background()), nil, r, recorder)
+	require.True(t, ok)
+	flushWriter := writer.(*HttpFlushWriter)
+	flushWriter.startHeartbeat(10 * time.Millisecond)
+
+	heartbeat := "\r\n--graphql\r\ncontent-type: application/json\r\n\r\n{}"
+	require.Eventually(t, func() bool {
+		flushWriter.mu.Lock()
+		defer flushWriter.mu.Unlock()
+		return strings.Count(recorder.Body.String(), heartbeat) >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// No heartbeats are written after Close returned
+	flushWriter.Close()
+	body := recorder.Body.String()
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, body, recorder.Body.String())
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/graphqlerrors"
 
@@ -80,6 +81,8 @@ type HandlerOptions struct {
 	RateLimitConfig                        *config.RateLimitConfiguration
 	SubgraphErrorPropagation               config.SubgraphErrorPropagationConfiguration
 	EngineLoaderHooks                      resolve.LoaderHooks
+	// MultipartHeartbeatInterval is the interval of the heartbeats of multipart subscriptions, 0 disables them
+	MultipartHeartbeatInterval time.Duration
 }
 
 func NewGraphQLHandler(opts HandlerOptions) *GraphQLHandler {
@@ -97,6 +100,8 @@ func NewGraphQLHandler(opts HandlerOptions) *GraphQLHandler {
 		rateLimitConfig:          opts.RateLimitConfig,
 		subgraphErrorPropagation: opts.SubgraphErrorPropagation,
 		engineLoaderHooks:        opts.EngineLoaderHooks,
+
+		multipartHeartbeatInterval: opts.MultipartHeartbeatInterval,
 	}
 	return graphQLHandler
 }
@@ -123,6 +128,8 @@ type GraphQLHandler struct {
 	rateLimitConfig          *config.RateLimitConfiguration
 	subgraphErrorPropagation config.SubgraphErrorPropagationConfiguration
 	engineLoaderHooks        resolve.LoaderHooks
+
+	multipartHeartbeatInterval time.Duration
 }
 
 func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -164,6 +171,12 @@ func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		defer pool.PutBytesBuffer(executionBuf)
 
 		err := h.executor.Resolver.ResolveGraphQLResponse(ctx, p.Response, nil, executionBuf)
+
+		// All subgraphs have responded, write their propagated headers before the response
+		if reqContext := getRequestContext(r.Context()); reqContext != nil {
+			reqContext.responseHeaders.applyTo(w.Header())
+		}
+
 		if err != nil {
 			requestLogger.Error("unable to resolve response", zap.Error(err))
 			trackResponseError(ctx.Context(), err)
@@ -192,10 +205,23 @@ func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			writeRequestErrors(r, w, http.StatusInternalServerError, graphqlerrors.RequestErrorsFromError(errCouldNotFlushResponse), requestLogger)
 			return
 		}
+		ctx = withSubscriptionCursor(ctx, operationCtx.extensions)
 		h.websocketStats.ConnectionsInc()
 		defer h.websocketStats.ConnectionsDec()
 
+		flushWriter, _ := writer.(*HttpFlushWriter)
+		if flushWriter != nil {
+			flushWriter.startHeartbeat(h.multipartHeartbeatInterval)
+		}
+
+		writer, closeWriter := h.executor.subscriptionFanOut.clientWriter(writer, requestLogger, h.websocketStats)
+		writer = getSubscriptionCursor(ctx.Context()).writer(writer)
 		err := h.executor.Resolver.ResolveGraphQLSubscription(ctx, p.Response, writer)
+		// The response writer must not be used by the subscription after the handler returned
+		closeWriter()
+		if flushWriter != nil {
+			flushWriter.Close()
+		}
 		if err != nil {
 			if errors.Is(err, ErrUnauthorized) {
 				trackResponseError(ctx.Context(), err)
@@ -325,6 +351,21 @@ func (h *GraphQLHandler) WriteError(ctx *resolve.Context, err error, res *resolv
 		if isHttpResponseWriter {
 			httpWriter.WriteHeader(http.StatusInternalServerError)
 		}
+	case errorTypeEDFSKafka:
+		response.Errors[0].Message = fmt.Sprintf("EDFS Kafka error: %s", err.Error())
+		if isHttpResponseWriter {
+			httpWriter.WriteHeader(http.StatusInternalServerError)
+		}
+	case errorTypeEDFSRedis:
+		response.Errors[0].Message = fmt.Sprintf("EDFS Redis error: %s", err.Error())
+		if isHttpResponseWriter {
+			httpWriter.WriteHeader(http.StatusInternalServerError)
+		}
+	case errorTypeEDFSInMemory:
+		response.Errors[0].Message = fmt.Sprintf("EDFS in-memory error: %s", err.Error())
+		if isHttpResponseWriter {
+			httpWriter.WriteHeader(http.StatusInternalServerError)
+		}
 	}
 	if ctx.TracingOptions.Enable && ctx.TracingOptions.IncludeTraceOutputInResponseExtensions {
 		traceNode := resolve.GetTrace(ctx.Context(), res.Data)
@@ -342,8 +383,16 @@ func (h *GraphQLHandler) WriteError(ctx *resolve.Context, err error, res *resolv
 	if err != nil {
 		requestLogger.Error("unable to write rate limit response", zap.Error(err))
 	}
-	if wsRw, ok := w.(*websocketResponseWriter); ok {
-		_ = wsRw.Flush()
+	switch rw := w.(type) {
+	case *websocketResponseWriter:
+		_ = rw.Flush()
+	case *subscriptionWriter:
+		// The resolver doesn't complete subscriptions whose trigger failed to start
+		_ = rw.Flush()
+		rw.Complete()
+	case *cursorWriter:
+		_ = rw.Flush()
+		rw.Complete()
 	}
 }
 
@@ -379,6 +379,7 @@ func (h *PreHandler) Handler(next http.Handler) http.Handler {
 			if err != nil {
 				finalErr = err
 				requestLogger.Error("failed to authenticate request", zap.Error(err))
+				reportAuthenticationFailure(r.Context(), err, requestLogger, h.metrics.MetricStore())
 
 				// Mark the root span of the router as failed, so we can easily identify failed requests
 				rtrace.AttachErrToSpan(routerSpan, err)
@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 	"net/http"
+	"os"
 	"regexp"
 
 	"github.com/wundergraph/cosmo/router/pkg/config"
@@ -11,8 +12,9 @@ import (
 )
 
 var (
-	_          EnginePreOriginHandler = (*HeaderRuleEngine)(nil)
-	hopHeaders                        = []string{
+	_          EnginePreOriginHandler  = (*HeaderRuleEngine)(nil)
+	_          EnginePostOriginHandler = (*HeaderRuleEngine)(nil)
+	hopHeaders                         = []string{
 		"Connection",
 		"Proxy-Connection", // non-standard but still sent by libcurl and rejected by e.g. google
 		"Keep-Alive",
@@ -23,6 +25,12 @@ var (
 		"Transfer-Encoding",
 		"Upgrade",
 	}
+	// ignoredResponseHeaders describe the subgraph response body and are never propagated to the client
+	ignoredResponseHeaders = []string{
+		"Content-Encoding",
+		"Content-Length",
+		"Content-Type",
+	}
 )
 
 // HeaderRuleEngine is a pre-origin handler that can be used to propagate and
@@ -30,12 +38,18 @@ var (
 type HeaderRuleEngine struct {
 	regex map[string]regexp.Regexp
 	rules config.HeaderRules
+	// envValues are the values of the environment variables of the set rules
+	envValues map[string]string
+	// templates are the parsed value templates of the set rules
+	templates map[string]*headerTemplate
 }
 
 func NewHeaderTransformer(rules config.HeaderRules) (*HeaderRuleEngine, error) {
 	hf := HeaderRuleEngine{
-		rules: rules,
-		regex: map[string]regexp.Regexp{},
+		rules:     rules,
+		regex:     map[string]regexp.Regexp{},
+		envValues: map[string]string{},
+		templates: map[string]*headerTemplate{},
 	}
 
 	var rhrs []config.RequestHeaderRule
@@ -56,14 +70,91 @@ func NewHeaderTransformer(rules config.HeaderRules) (*HeaderRuleEngine, error) {
 				}
 				hf.regex[rule.Matching] = *regex
 			}
+		case config.HeaderRuleOperationRemove:
+			if rule.Named == "" && rule.Matching == "" {
+				return nil, fmt.Errorf("header rule %d removes no header, set 'named' or 'matching'", i)
+			}
+			if rule.Matching != "" {
+				regex, err := regexp.Compile(rule.Matching)
+				if err != nil {
+					return nil, fmt.Errorf("invalid regex '%s' for header rule %d: %w", rule.Matching, i, err)
+				}
+				hf.regex[rule.Matching] = *regex
+			}
+		case config.HeaderRuleOperationSet:
+			if rule.Name == "" {
+				return nil, fmt.Errorf("header rule %d sets no header, set 'name'", i)
+			}
+			switch {
+			case rule.ValueFromEnv != "":
+				value, ok := os.LookupEnv(rule.ValueFromEnv)
+				if !ok && rule.Default == "" {
+					return nil, fmt.Errorf("environment variable '%s' of header rule %d is not set", rule.ValueFromEnv, i)
+				}
+				hf.envValues[rule.ValueFromEnv] = value
+			case rule.ValueTemplate != "":
+				template, err := newHeaderTemplate(rule.ValueTemplate)
+				if err != nil {
+					return nil, fmt.Errorf("invalid value template '%s' for header rule %d: %w", rule.ValueTemplate, i, err)
+				}
+				hf.templates[rule.ValueTemplate] = template
+			case rule.Value == "":
+				return nil, fmt.Errorf("header rule %d sets no value, set 'value', 'value_from_env' or 'value_template'", i)
+			}
 		default:
 			return nil, fmt.Errorf("unhandled operation '%s' for header rule %+v", rule.Operation, rule)
 		}
 	}
 
+	var responseRules []config.ResponseHeaderRule
+
+	responseRules = append(responseRules, rules.All.Response...)
+
+	for _, subgraph := range rules.Subgraphs {
+		responseRules = append(responseRules, subgraph.Response...)
+	}
+
+	for i, rule := range responseRules {
+		if rule.Operation != config.HeaderRuleOperationPropagate {
+			return nil, fmt.Errorf("unhandled operation '%s' for response header rule %+v", rule.Operation, rule)
+		}
+		if rule.Named == "" && rule.Matching == "" {
+			return nil, fmt.Errorf("response header rule %d propagates no header, set 'named' or 'matching'", i)
+		}
+		if rule.Matching != "" {
+			regex, err := regexp.Compile(rule.Matching)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex '%s' for response header rule %d: %w", rule.Matching, i, err)
+			}
+			hf.regex[rule.Matching] = *regex
+		}
+		switch rule.Algorithm {
+		case "",
+			config.ResponseHeaderRuleAlgorithmFirstWrite,
+			config.ResponseHeaderRuleAlgorithmLastWrite,
+			config.ResponseHeaderRuleAlgorithmAppend,
+			config.ResponseHeaderRuleAlgorithmMostRestrictiveCacheControl:
+		default:
+			return nil, fmt.Errorf("unhandled algorithm '%s' for response header rule %d", rule.Algorithm, i)
+		}
+	}
+
 	return &hf, nil
 }
 
+// HasResponseRules returns true if subgraph response headers are propagated to the client
+func (h HeaderRuleEngine) HasResponseRules() bool {
+	if len(h.rules.All.Response) > 0 {
+		return true
+	}
+	for _, subgraph := range h.rules.Subgraphs {
+		if len(subgraph.Response) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (h HeaderRuleEngine) OnOriginRequest(request *http.Request, ctx RequestContext) (*http.Request, *http.Response) {
 	requestRules := h.rules.All.Request
 
@@ -75,6 +166,25 @@ func (h HeaderRuleEngine) OnOriginRequest(request *http.Request, ctx RequestCont
 	}
 
 	for _, rule := range requestRules {
+		switch rule.Operation {
+		case config.HeaderRuleOperationSet:
+			if value := h.setValue(rule, ctx); value != "" {
+				request.Header.Set(rule.Name, value)
+			}
+			continue
+		case config.HeaderRuleOperationRemove:
+			if rule.Named != "" {
+				request.Header.Del(rule.Named)
+			} else if regex, ok := h.regex[rule.Matching]; ok {
+				for name := range request.Header {
+					if regex.MatchString(name) {
+						request.Header.Del(name)
+					}
+				}
+			}
+			continue
+		}
+
 		// Forwards the matching client request header to the upstream
 		if rule.Operation == config.HeaderRuleOperationPropagate {
 			// Rename the header when name is provided
@@ -135,6 +245,108 @@ func (h HeaderRuleEngine) OnOriginRequest(request *http.Request, ctx RequestCont
 	return request, nil
 }
 
+// setValue returns the value of a set rule or the default when the value is empty
+func (h HeaderRuleEngine) setValue(rule config.RequestHeaderRule, ctx RequestContext) string {
+	var value string
+
+	switch {
+	case rule.ValueFromEnv != "":
+		value = h.envValues[rule.ValueFromEnv]
+	case rule.ValueTemplate != "":
+		if template, ok := h.templates[rule.ValueTemplate]; ok {
+			if rendered, resolved := template.Render(ctx); resolved {
+				value = rendered
+			}
+		}
+	default:
+		value = rule.Value
+	}
+
+	if value == "" {
+		return rule.Default
+	}
+
+	return value
+}
+
+// OnOriginResponse collects the subgraph response headers matching the response rules. They are
+// written to the client response when the response of all subgraphs is resolved.
+func (h HeaderRuleEngine) OnOriginResponse(resp *http.Response, ctx RequestContext) *http.Response {
+	reqContext, ok := ctx.(*requestContext)
+	if resp == nil || !ok || reqContext == nil {
+		return nil
+	}
+
+	responseRules := h.rules.All.Response
+
+	subgraph := ctx.ActiveSubgraph(resp.Request)
+	if subgraph != nil {
+		if subgraphRules, ok := h.rules.Subgraphs[subgraph.Name]; ok {
+			// Copy to not share the backing array between concurrent requests
+			responseRules = append(append([]config.ResponseHeaderRule(nil), responseRules...), subgraphRules.Response...)
+		}
+	}
+
+	if len(responseRules) == 0 {
+		return nil
+	}
+
+	propagation := reqContext.responseHeaderPropagation()
+
+	for _, rule := range responseRules {
+		algorithm := rule.Algorithm
+		if algorithm == "" {
+			algorithm = config.ResponseHeaderRuleAlgorithmLastWrite
+		}
+
+		// Exact match
+		if rule.Named != "" {
+			target := rule.Named
+			if rule.Rename != "" {
+				target = rule.Rename
+			}
+			values := resp.Header.Values(rule.Named)
+			if len(values) > 0 && !contains(ignoredResponseHeaders, http.CanonicalHeaderKey(rule.Named)) {
+				propagation.write(target, values, algorithm)
+			} else if algorithm == config.ResponseHeaderRuleAlgorithmMostRestrictiveCacheControl {
+				// A subgraph response without a policy must not make the client response cacheable
+				policy := rule.Default
+				if policy == "" {
+					policy = missingCacheControlPolicy
+				}
+				propagation.write(target, []string{policy}, algorithm)
+			} else if rule.Default != "" {
+				propagation.writeDefault(target, rule.Default)
+			}
+			continue
+		}
+
+		// Regex match
+		if regex, ok := h.regex[rule.Matching]; ok {
+			matched := false
+			for name, values := range resp.Header {
+				if contains(hopHeaders, name) || contains(ignoredResponseHeaders, name) {
+					continue
+				}
+				if !regex.MatchString(name) {
+					continue
+				}
+				matched = true
+				target := name
+				if rule.Rename != "" {
+					target = rule.Rename
+				}
+				propagation.write(target, values, algorithm)
+			}
+			if !matched && rule.Default != "" && rule.Rename != "" {
+				propagation.writeDefault(rule.Rename, rule.Default)
+			}
+		}
+	}
+
+	return nil
+}
+
 func contains(list []string, item string) bool {
 	for _, l := range list {
 		if l == item {
@@ -181,6 +393,9 @@ func PropagatedHeaders(rules []config.RequestHeaderRule) (headerNames []string,
 			} else {
 				return nil, nil, fmt.Errorf("invalid header propagation rule %+v, no header name nor regular expression", rule)
 			}
+		case config.HeaderRuleOperationSet, config.HeaderRuleOperationRemove:
+			// Not taken from the client request, applied to the subgraph request by the HeaderRuleEngine
+			continue
 		default:
 			return nil, nil, fmt.Errorf("invalid header rule operation %q in rule %+v", rule.Operation, rule)
 		}
@@ -1,13 +1,16 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
 	"github.com/wundergraph/cosmo/router/pkg/config"
 
 	"github.com/stretchr/testify/assert"
@@ -482,3 +485,394 @@ func TestSubgraphRenameMatchedHeaderRule(t *testing.T) {
 	assert.Empty(t, updatedClientReq1.Header.Get("X-Test-Default-Subgraph"))
 
 }
+
+type testAuthentication struct {
+	claims authentication.Claims
+}
+
+func (a *testAuthentication) Authenticator() string         { return "test" }
+func (a *testAuthentication) Claims() authentication.Claims { return a.claims }
+func (a *testAuthentication) Scopes() []string              { return nil }
+
+func TestSetHeaderRule(t *testing.T) {
+	t.Setenv("TEST_SUBGRAPH_SECRET", "secret")
+
+	ht, err := NewHeaderTransformer(config.HeaderRules{
+		All: config.GlobalHeaderRule{
+			Request: []config.RequestHeaderRule{
+				{
+					Operation: "set",
+					Name:      "X-Static",
+					Value:     "static",
+				},
+				{
+					Operation:    "set",
+					Name:         "X-Secret",
+					ValueFromEnv: "TEST_SUBGRAPH_SECRET",
+				},
+				{
+					Operation:     "set",
+					Name:          "X-Tenant",
+					ValueTemplate: "{{ claims.org.id }}:{{claims.sub}}",
+				},
+				{
+					Operation:     "set",
+					Name:          "X-Client",
+					ValueTemplate: "{{ client.name }}/{{ client.version }}",
+				},
+				{
+					Operation:     "set",
+					Name:          "X-Operation",
+					ValueTemplate: "{{ operation.type }} {{ operation.name }}",
+				},
+				{
+					Operation:     "set",
+					Name:          "X-Request-Id",
+					ValueTemplate: "{{ request.id }}",
+				},
+				{
+					Operation:     "set",
+					Name:          "X-Missing",
+					ValueTemplate: "{{ claims.missing }}",
+					Default:       "none",
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	clientReq, err := http.NewRequest("POST", "http://localhost", nil)
+	require.NoError(t, err)
+	ctx := context.WithValue(clientReq.Context(), middleware.RequestIDKey, "request-1")
+	ctx = authentication.NewContext(ctx, &testAuthentication{claims: authentication.Claims{
+		"sub": "user-1",
+		"org": map[string]any{"id": float64(42)},
+	}})
+	clientReq = clientReq.WithContext(ctx)
+
+	originReq, err := http.NewRequest("POST", "http://localhost", nil)
+	require.NoError(t, err)
+
+	updatedReq, _ := ht.OnOriginRequest(originReq, &requestContext{
+		logger:         zap.NewNop(),
+		responseWriter: httptest.NewRecorder(),
+		request:        clientReq,
+		operation: &operationContext{
+			name:       "Employees",
+			opType:     "query",
+			clientInfo: &ClientInfo{Name: "web", Version: "1.0.0"},
+		},
+	})
+
+	assert.Equal(t, "static", updatedReq.Header.Get("X-Static"))
+	assert.Equal(t, "secret", updatedReq.Header.Get("X-Secret"))
+	assert.Equal(t, "42:user-1", updatedReq.Header.Get("X-Tenant"))
+	assert.Equal(t, "web/1.0.0", updatedReq.Header.Get("X-Client"))
+	assert.Equal(t, "query Employees", updatedReq.Header.Get("X-Operation"))
+	assert.Equal(t, "request-1", updatedReq.Header.Get("X-Request-Id"))
+	assert.Equal(t, "none", updatedReq.Header.Get("X-Missing"))
+}
+
+func TestSetHeaderRuleWithoutClaims(t *testing.T) {
+	ht, err := NewHeaderTransformer(config.HeaderRules{
+		All: config.GlobalHeaderRule{
+			Request: []config.RequestHeaderRule{
+				{
+					Operation:     "set",
+					Name:          "X-User",
+					ValueTemplate: "user {{ claims.sub }}",
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	clientReq, err := http.NewRequest("POST", "http://localhost", nil)
+	require.NoError(t, err)
+
+	originReq, err := http.NewRequest("POST", "http://localhost", nil)
+	require.NoError(t, err)
+
+	updatedReq, _ := ht.OnOriginRequest(originReq, &requestContext{
+		logger:         zap.NewNop(),
+		responseWriter: httptest.NewRecorder(),
+		request:        clientReq,
+		operation:      &operationContext{},
+	})
+
+	_, ok := updatedReq.Header["X-User"]
+	assert.False(t, ok)
+}
+
+func TestInvalidSetHeaderRule(t *testing.T) {
+	_, err := NewHeaderTransformer(config.HeaderRules{
+		All: config.GlobalHeaderRule{
+			Request: []config.RequestHeaderRule{
+				{
+					Operation:     "set",
+					Name:          "X-Test",
+					ValueTemplate: "{{ unknown.variable }}",
+				},
+			},
+		},
+	})
+	assert.ErrorContains(t, err, "unknown header template variable 'unknown.variable'")
+
+	_, err = NewHeaderTransformer(config.HeaderRules{
+		All: config.GlobalHeaderRule{
+			Request: []config.RequestHeaderRule{
+				{
+					Operation:    "set",
+					Name:         "X-Test",
+					ValueFromEnv: "TEST_HEADER_RULE_UNSET_VARIABLE",
+				},
+			},
+		},
+	})
+	assert.ErrorContains(t, err, "environment variable 'TEST_HEADER_RULE_UNSET_VARIABLE' of header rule 0 is not set")
+
+	_, err = NewHeaderTransformer(config.HeaderRules{
+		All: config.GlobalHeaderRule{
+			Request: []config.RequestHeaderRule{
+				{
+					Operation: "set",
+					Name:      "X-Test",
+				},
+			},
+		},
+	})
+	assert.Error(t, err)
+}
+
+func TestRemoveHeaderRule(t *testing.T) {
+	ht, err := NewHeaderTransformer(config.HeaderRules{
+		All: config.GlobalHeaderRule{
+			Request: []config.RequestHeaderRule{
+				{
+					Operation: "propagate",
+					Matching:  "(?i)^X-Custom-.*",
+				},
+				{
+					Operation: "remove",
+					Matching:  "(?i)^X-Custom-Internal-.*",
+				},
+			},
+		},
+		Subgraphs: map[string]config.GlobalHeaderRule{
+			"subgraph-1": {
+				Request: []config.RequestHeaderRule{
+					{
+						Operation: "remove",
+						Named:     "X-Custom-Public",
+					},
+					{
+						Operation: "set",
+						Name:      "X-Subgraph",
+						Value:     "subgraph-1",
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	clientReq, err := http.NewRequest("POST", "http://localhost", nil)
+	require.NoError(t, err)
+	clientReq.Header.Set("X-Custom-Public", "public")
+	clientReq.Header.Set("X-Custom-Internal-Token", "internal")
+
+	sg1Url, _ := url.Parse("http://subgraph-1.local")
+	sg2Url, _ := url.Parse("http://subgraph-2.local")
+
+	ctx := &requestContext{
+		logger:         zap.NewNop(),
+		responseWriter: httptest.NewRecorder(),
+		request:        clientReq,
+		operation:      &operationContext{},
+		subgraphs: []Subgraph{
+			{Name: "subgraph-1", Id: "subgraph-1", Url: sg1Url},
+			{Name: "subgraph-2", Id: "subgraph-2", Url: sg2Url},
+		},
+	}
+
+	originReq1, err := http.NewRequest("POST", "http://subgraph-1.local", nil)
+	require.NoError(t, err)
+	updatedReq1, _ := ht.OnOriginRequest(originReq1, ctx)
+
+	assert.Empty(t, updatedReq1.Header.Get("X-Custom-Public"))
+	assert.Empty(t, updatedReq1.Header.Get("X-Custom-Internal-Token"))
+	assert.Equal(t, "subgraph-1", updatedReq1.Header.Get("X-Subgraph"))
+
+	originReq2, err := http.NewRequest("POST", "http://subgraph-2.local", nil)
+	require.NoError(t, err)
+	updatedReq2, _ := ht.OnOriginRequest(originReq2, ctx)
+
+	assert.Equal(t, "public", updatedReq2.Header.Get("X-Custom-Public"))
+	assert.Empty(t, updatedReq2.Header.Get("X-Custom-Internal-Token"))
+	assert.Empty(t, updatedReq2.Header.Get("X-Subgraph"))
+}
+
+func TestPropagateResponseHeaderRule(t *testing.T) {
+	ht, err := NewHeaderTransformer(config.HeaderRules{
+		All: config.GlobalHeaderRule{
+			Response: []config.ResponseHeaderRule{
+				{
+					Operation: "propagate",
+					Named:     "Set-Cookie",
+					Algorithm: config.ResponseHeaderRuleAlgorithmAppend,
+				},
+				{
+					Operation: "propagate",
+					Named:     "Cache-Control",
+					Algorithm: config.ResponseHeaderRuleAlgorithmMostRestrictiveCacheControl,
+				},
+				{
+					Operation: "propagate",
+					Matching:  "(?i)^X-Custom-.*",
+					Algorithm: config.ResponseHeaderRuleAlgorithmFirstWrite,
+				},
+				{
+					Operation: "propagate",
+					Named:     "X-Missing",
+					Default:   "default",
+				},
+			},
+		},
+		Subgraphs: map[string]config.GlobalHeaderRule{
+			"subgraph-2": {
+				Response: []config.ResponseHeaderRule{
+					{
+						Operation: "propagate",
+						Named:     "X-Version",
+						Rename:    "X-Subgraph-2-Version",
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, ht.HasResponseRules())
+
+	clientReq, err := http.NewRequest("POST", "http://localhost", nil)
+	require.NoError(t, err)
+
+	sg1Url, _ := url.Parse("http://subgraph-1.local")
+	sg2Url, _ := url.Parse("http://subgraph-2.local")
+
+	ctx := &requestContext{
+		logger:         zap.NewNop(),
+		responseWriter: httptest.NewRecorder(),
+		request:        clientReq,
+		operation:      &operationContext{},
+		subgraphs: []Subgraph{
+			{Name: "subgraph-1", Id: "subgraph-1", Url: sg1Url},
+			{Name: "subgraph-2", Id: "subgraph-2", Url: sg2Url},
+		},
+	}
+
+	originReq1, err := http.NewRequest("POST", "http://subgraph-1.local", nil)
+	require.NoError(t, err)
+	resp1 := &http.Response{Request: originReq1, Header: http.Header{
+		"Set-Cookie":     []string{"a=1"},
+		"Cache-Control":  []string{"max-age=60"},
+		"X-Custom-Trace": []string{"subgraph-1"},
+		"X-Version":      []string{"1"},
+		"Content-Type":   []string{"application/json"},
+	}}
+	assert.Nil(t, ht.OnOriginResponse(resp1, ctx))
+
+	originReq2, err := http.NewRequest("POST", "http://subgraph-2.local", nil)
+	require.NoError(t, err)
+	resp2 := &http.Response{Request: originReq2, Header: http.Header{
+		"Set-Cookie":     []string{"b=2"},
+		"Cache-Control":  []string{"private, max-age=30"},
+		"X-Custom-Trace": []string{"subgraph-2"},
+		"X-Version":      []string{"2"},
+	}}
+	assert.Nil(t, ht.OnOriginResponse(resp2, ctx))
+
+	header := http.Header{}
+	ctx.responseHeaders.applyTo(header)
+
+	assert.Equal(t, []string{"a=1", "b=2"}, header.Values("Set-Cookie"))
+	assert.Equal(t, "private, max-age=30", header.Get("Cache-Control"))
+	assert.Equal(t, "subgraph-1", header.Get("X-Custom-Trace"))
+	assert.Equal(t, "default", header.Get("X-Missing"))
+	assert.Equal(t, "2", header.Get("X-Subgraph-2-Version"))
+	assert.Empty(t, header.Get("X-Version"))
+	assert.Empty(t, header.Get("Content-Type"))
+}
+
+func TestPropagateMostRestrictiveCacheControlMissingHeader(t *testing.T) {
+	run := func(t *testing.T, defaultPolicy string) http.Header {
+		ht, err := NewHeaderTransformer(config.HeaderRules{
+			All: config.GlobalHeaderRule{
+				Response: []config.ResponseHeaderRule{
+					{
+						Operation: "propagate",
+						Named:     "Cache-Control",
+						Default:   defaultPolicy,
+						Algorithm: config.ResponseHeaderRuleAlgorithmMostRestrictiveCacheControl,
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		clientReq, err := http.NewRequest("POST", "http://localhost", nil)
+		require.NoError(t, err)
+
+		ctx := &requestContext{
+			logger:         zap.NewNop(),
+			responseWriter: httptest.NewRecorder(),
+			request:        clientReq,
+			operation:      &operationContext{},
+		}
+
+		originReq1, err := http.NewRequest("POST", "http://subgraph-1.local", nil)
+		require.NoError(t, err)
+		assert.Nil(t, ht.OnOriginResponse(&http.Response{Request: originReq1, Header: http.Header{
+			"Cache-Control": []string{"max-age=60"},
+		}}, ctx))
+
+		originReq2, err := http.NewRequest("POST", "http://subgraph-2.local", nil)
+		require.NoError(t, err)
+		assert.Nil(t, ht.OnOriginResponse(&http.Response{Request: originReq2, Header: http.Header{}}, ctx))
+
+		header := http.Header{}
+		ctx.responseHeaders.applyTo(header)
+		return header
+	}
+
+	// A subgraph without a policy makes the response uncacheable
+	assert.Equal(t, "no-store", run(t, "").Get("Cache-Control"))
+	// unless the rule has a default policy
+	assert.Equal(t, "max-age=30", run(t, "max-age=30").Get("Cache-Control"))
+}
+
+func TestInvalidResponseHeaderRule(t *testing.T) {
+	_, err := NewHeaderTransformer(config.HeaderRules{
+		All: config.GlobalHeaderRule{
+			Response: []config.ResponseHeaderRule{
+				{
+					Operation: "set",
+					Named:     "X-Test",
+				},
+			},
+		},
+	})
+	assert.Error(t, err)
+
+	_, err = NewHeaderTransformer(config.HeaderRules{
+		All: config.GlobalHeaderRule{
+			Response: []config.ResponseHeaderRule{
+				{
+					Operation: "propagate",
+					Named:     "X-Test",
+					Algorithm: "unknown",
+				},
+			},
+		},
+	})
+	assert.ErrorContains(t, err, "unhandled algorithm 'unknown'")
+}
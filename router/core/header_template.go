@@ -0,0 +1,151 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+var headerTemplateVariableRegex = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*}}`)
+
+// headerTemplate is a header value with variables, e.g. "Bearer {{ claims.sub }}".
+// The supported variables are claims.<path>, client.name, client.version, operation.name,
+// operation.type and request.id. Nested claims are addressed with dots, e.g. claims.org.id
+type headerTemplate struct {
+	// parts alternates between literal text and variables, starting with text
+	parts []string
+}
+
+func newHeaderTemplate(template string) (*headerTemplate, error) {
+	t := &headerTemplate{}
+
+	last := 0
+	for _, match := range headerTemplateVariableRegex.FindAllStringSubmatchIndex(template, -1) {
+		variable := template[match[2]:match[3]]
+		if err := validateHeaderTemplateVariable(variable); err != nil {
+			return nil, err
+		}
+		t.parts = append(t.parts, template[last:match[0]], variable)
+		last = match[1]
+	}
+	t.parts = append(t.parts, template[last:])
+
+	return t, nil
+}
+
+func validateHeaderTemplateVariable(variable string) error {
+	switch variable {
+	case "client.name", "client.version", "operation.name", "operation.type", "request.id":
+		return nil
+	}
+	if path, ok := strings.CutPrefix(variable, "claims."); ok && path != "" {
+		return nil
+	}
+	return fmt.Errorf("unknown header template variable '%s'", variable)
+}
+
+// Render returns the value of the template. Missing variables are rendered as empty strings.
+// The second return value is false when none of the variables had a value.
+func (t *headerTemplate) Render(ctx RequestContext) (string, bool) {
+	var (
+		b        strings.Builder
+		resolved bool
+	)
+
+	for i, part := range t.parts {
+		// Odd parts are variables
+		if i%2 == 0 {
+			b.WriteString(part)
+			continue
+		}
+		value := headerTemplateValue(ctx, part)
+		if value != "" {
+			resolved = true
+		}
+		b.WriteString(value)
+	}
+
+	// A template without variables is a static value
+	if len(t.parts) == 1 {
+		resolved = true
+	}
+
+	return b.String(), resolved
+}
+
+func headerTemplateValue(ctx RequestContext, variable string) string {
+	switch variable {
+	case "client.name", "client.version":
+		clientInfo := requestClientInfo(ctx)
+		if variable == "client.name" {
+			return clientInfo.Name
+		}
+		return clientInfo.Version
+	case "operation.name", "operation.type":
+		operation, ok := ctx.Operation().(*operationContext)
+		if !ok || operation == nil {
+			return ""
+		}
+		if variable == "operation.name" {
+			return operation.Name()
+		}
+		return operation.Type()
+	case "request.id":
+		return middleware.GetReqID(ctx.Request().Context())
+	}
+
+	path, _ := strings.CutPrefix(variable, "claims.")
+	auth := ctx.Authentication()
+	if auth == nil {
+		return ""
+	}
+
+	return claimString(claimByPath(auth.Claims(), path))
+}
+
+// claimByPath returns the claim addressed by a dot separated path, e.g. org.id
+func claimByPath(claims map[string]any, path string) any {
+	var value any = claims
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// requestClientInfo returns the client info of the operation or, before the operation was parsed,
+// the one of the request headers
+func requestClientInfo(ctx RequestContext) ClientInfo {
+	if operation, ok := ctx.Operation().(*operationContext); ok && operation != nil && operation.clientInfo != nil {
+		return *operation.clientInfo
+	}
+	return *NewClientInfoFromRequest(ctx.Request())
+}
+
+func claimString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
@@ -76,6 +76,14 @@ func (p *OperationPlanner) preparePlan(requestOperationName []byte, requestOpera
 	if report.HasErrors() {
 		return nil, &reportError{report: &report}
 	}
+	if p.executor.eventHandlers != nil {
+		if err := p.executor.eventHandlers.configureSubscription(&doc, p.executor.Definition, preparedPlan); err != nil {
+			return nil, err
+		}
+	}
+	if p.executor.subscriptionFanOut != nil {
+		p.executor.subscriptionFanOut.configureSubscription(preparedPlan)
+	}
 	post := postprocess.DefaultProcessor()
 	post.Process(preparedPlan)
 
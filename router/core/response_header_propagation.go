@@ -0,0 +1,189 @@
+package core
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+// responseHeaderPropagation collects the headers of the subgraph responses of a client request.
+// The subgraphs of a request are fetched concurrently, so all methods are safe for concurrent use.
+type responseHeaderPropagation struct {
+	mu     sync.Mutex
+	header http.Header
+	// written are the headers already written by a subgraph response
+	written map[string]struct{}
+	// cacheControl is the most restrictive Cache-Control policy of the subgraph responses by header name
+	cacheControl map[string]*cacheControlPolicy
+	// defaults are set when no subgraph response has written the header
+	defaults map[string]string
+}
+
+func newResponseHeaderPropagation() *responseHeaderPropagation {
+	return &responseHeaderPropagation{
+		header:       http.Header{},
+		written:      map[string]struct{}{},
+		cacheControl: map[string]*cacheControlPolicy{},
+		defaults:     map[string]string{},
+	}
+}
+
+// write merges the values of a subgraph response header into the client response header
+func (p *responseHeaderPropagation) write(name string, values []string, algorithm config.ResponseHeaderRuleAlgorithm) {
+	name = http.CanonicalHeaderKey(name)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, written := p.written[name]
+	p.written[name] = struct{}{}
+
+	switch algorithm {
+	case config.ResponseHeaderRuleAlgorithmFirstWrite:
+		if !written {
+			p.header[name] = append([]string(nil), values...)
+		}
+	case config.ResponseHeaderRuleAlgorithmAppend:
+		p.header[name] = append(p.header[name], values...)
+	case config.ResponseHeaderRuleAlgorithmMostRestrictiveCacheControl:
+		policy := parseCacheControl(values)
+		if current, ok := p.cacheControl[name]; ok {
+			policy = current.merge(policy)
+		}
+		p.cacheControl[name] = policy
+		if value := policy.String(); value != "" {
+			p.header.Set(name, value)
+		}
+	default:
+		p.header[name] = append([]string(nil), values...)
+	}
+}
+
+// writeDefault sets the default value of a header in case no subgraph response writes the header
+func (p *responseHeaderPropagation) writeDefault(name, value string) {
+	name = http.CanonicalHeaderKey(name)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.defaults[name]; !ok {
+		p.defaults[name] = value
+	}
+}
+
+// applyTo adds the collected headers to the client response header
+func (p *responseHeaderPropagation) applyTo(header http.Header) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for name, values := range p.header {
+		header[name] = values
+	}
+	for name, value := range p.defaults {
+		if _, ok := p.written[name]; !ok {
+			header.Set(name, value)
+		}
+	}
+}
+
+// missingCacheControlPolicy is merged for the subgraph responses without a Cache-Control header
+// when the rule has no default
+const missingCacheControlPolicy = "no-store"
+
+// cacheControlPolicy is the subset of the Cache-Control directives that can be merged
+type cacheControlPolicy struct {
+	noStore        bool
+	noCache        bool
+	private        bool
+	mustRevalidate bool
+	// maxAge and sMaxAge are -1 when not set
+	maxAge  int
+	sMaxAge int
+}
+
+func parseCacheControl(values []string) *cacheControlPolicy {
+	policy := &cacheControlPolicy{maxAge: -1, sMaxAge: -1}
+
+	for _, value := range values {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			switch strings.ToLower(name) {
+			case "no-store":
+				policy.noStore = true
+			case "no-cache":
+				policy.noCache = true
+			case "private":
+				policy.private = true
+			case "must-revalidate":
+				policy.mustRevalidate = true
+			case "max-age":
+				if seconds, err := strconv.Atoi(strings.Trim(arg, `"`)); err == nil {
+					policy.maxAge = minAge(policy.maxAge, seconds)
+				}
+			case "s-maxage":
+				if seconds, err := strconv.Atoi(strings.Trim(arg, `"`)); err == nil {
+					policy.sMaxAge = minAge(policy.sMaxAge, seconds)
+				}
+			}
+		}
+	}
+
+	return policy
+}
+
+// merge returns the most restrictive policy of both
+func (c *cacheControlPolicy) merge(other *cacheControlPolicy) *cacheControlPolicy {
+	return &cacheControlPolicy{
+		noStore:        c.noStore || other.noStore,
+		noCache:        c.noCache || other.noCache,
+		private:        c.private || other.private,
+		mustRevalidate: c.mustRevalidate || other.mustRevalidate,
+		maxAge:         minAge(c.maxAge, other.maxAge),
+		sMaxAge:        minAge(c.sMaxAge, other.sMaxAge),
+	}
+}
+
+func (c *cacheControlPolicy) String() string {
+	// Nothing can be cached, the other directives are irrelevant
+	if c.noStore {
+		return "no-store"
+	}
+
+	var directives []string
+	if c.noCache {
+		directives = append(directives, "no-cache")
+	}
+	if c.private {
+		directives = append(directives, "private")
+	}
+	if c.maxAge >= 0 {
+		directives = append(directives, "max-age="+strconv.Itoa(c.maxAge))
+	}
+	// Shared caches are not allowed to store private responses
+	if c.sMaxAge >= 0 && !c.private {
+		directives = append(directives, "s-maxage="+strconv.Itoa(c.sMaxAge))
+	}
+	if c.mustRevalidate {
+		directives = append(directives, "must-revalidate")
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+// minAge returns the lower of two ages where -1 means not set
+func minAge(a, b int) int {
+	if a < 0 {
+		return b
+	}
+	if b < 0 || a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestResponseHeaderPropagationAlgorithms(t *testing.T) {
+	p := newResponseHeaderPropagation()
+
+	p.write("X-First", []string{"1"}, config.ResponseHeaderRuleAlgorithmFirstWrite)
+	p.write("X-First", []string{"2"}, config.ResponseHeaderRuleAlgorithmFirstWrite)
+
+	p.write("X-Last", []string{"1"}, config.ResponseHeaderRuleAlgorithmLastWrite)
+	p.write("X-Last", []string{"2"}, config.ResponseHeaderRuleAlgorithmLastWrite)
+
+	p.write("Set-Cookie", []string{"a=1"}, config.ResponseHeaderRuleAlgorithmAppend)
+	p.write("Set-Cookie", []string{"b=2", "c=3"}, config.ResponseHeaderRuleAlgorithmAppend)
+
+	p.writeDefault("X-Default", "default")
+	p.writeDefault("X-Last", "default")
+
+	header := http.Header{}
+	p.applyTo(header)
+
+	assert.Equal(t, []string{"1"}, header.Values("X-First"))
+	assert.Equal(t, []string{"2"}, header.Values("X-Last"))
+	assert.Equal(t, []string{"a=1", "b=2", "c=3"}, header.Values("Set-Cookie"))
+	assert.Equal(t, "default", header.Get("X-Default"))
+}
+
+func TestMostRestrictiveCacheControl(t *testing.T) {
+	cases := []struct {
+		name     string
+		values   [][]string
+		expected string
+	}{
+		{
+			name:     "lowest max-age",
+			values:   [][]string{{"max-age=60, s-maxage=120"}, {"public, max-age=30"}},
+			expected: "max-age=30, s-maxage=120",
+		},
+		{
+			name:     "private drops s-maxage",
+			values:   [][]string{{"max-age=60, s-maxage=120"}, {"private, max-age=300"}},
+			expected: "private, max-age=60",
+		},
+		{
+			name:     "no-store wins",
+			values:   [][]string{{"max-age=60"}, {"no-store"}, {"no-cache"}},
+			expected: "no-store",
+		},
+		{
+			name:     "no-cache and must-revalidate",
+			values:   [][]string{{"no-cache"}, {"max-age=10", "must-revalidate"}},
+			expected: "no-cache, max-age=10, must-revalidate",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := newResponseHeaderPropagation()
+			for _, values := range c.values {
+				p.write("Cache-Control", values, config.ResponseHeaderRuleAlgorithmMostRestrictiveCacheControl)
+			}
+
+			header := http.Header{}
+			p.applyTo(header)
+
+			assert.Equal(t, c.expected, header.Get("Cache-Control"))
+		})
+	}
+}
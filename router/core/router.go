@@ -20,12 +20,14 @@ import (
 
 	"github.com/wundergraph/cosmo/router/internal/recoveryhandler"
 	"github.com/wundergraph/cosmo/router/internal/requestlogger"
+	"github.com/wundergraph/cosmo/router/pkg/authorization"
 	"github.com/wundergraph/cosmo/router/pkg/config"
 	"github.com/wundergraph/cosmo/router/pkg/cors"
 	"github.com/wundergraph/cosmo/router/pkg/health"
 	rmetric "github.com/wundergraph/cosmo/router/pkg/metric"
 	"github.com/wundergraph/cosmo/router/pkg/otel"
 	"github.com/wundergraph/cosmo/router/pkg/otel/otelconfig"
+	"github.com/wundergraph/cosmo/router/pkg/pubsub"
 	rtrace "github.com/wundergraph/cosmo/router/pkg/trace"
 
 	"connectrpc.com/connect"
@@ -45,6 +47,8 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/mitchellh/mapstructure"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel/attribute"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
@@ -52,11 +56,18 @@ import (
 	oteltrace "go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 
 	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+	"github.com/wundergraph/cosmo/router/internal/clienttls"
 	"github.com/wundergraph/cosmo/router/internal/graphiql"
+	"github.com/wundergraph/cosmo/router/internal/hedgetransport"
+	"github.com/wundergraph/cosmo/router/internal/loadbalancer"
 	"github.com/wundergraph/cosmo/router/internal/retrytransport"
+	"github.com/wundergraph/cosmo/router/internal/servertls"
 	"github.com/wundergraph/cosmo/router/internal/stringsx"
+	"github.com/wundergraph/cosmo/router/internal/unixsocket"
 )
 
 type IPAnonymizationMethod string
@@ -83,6 +94,35 @@ type (
 		DialTimeout            time.Duration
 		TLSHandshakeTimeout    time.Duration
 		KeepAliveProbeInterval time.Duration
+		// H2C sends requests to http:// subgraphs with HTTP/2 over cleartext
+		H2C bool
+	}
+
+	SubgraphRetryBudget struct {
+		// Ratio is the maximum ratio of retries to requests within the window
+		Ratio float64
+		// MinRetries is the number of retries that are always allowed within the window
+		MinRetries int
+		Window     time.Duration
+	}
+
+	SubgraphRetryPolicy struct {
+		// RespectRetryAfter waits for the duration of the Retry-After header of 429 and 503 responses
+		RespectRetryAfter bool
+		// StatusCodes are the retryable status codes of all subgraphs. Empty means the default status codes
+		StatusCodes []int
+		// SubgraphStatusCodes overrides StatusCodes by subgraph name
+		SubgraphStatusCodes map[string][]int
+		// Budget limits the retries to a ratio of the subgraph requests. Nil disables the budget
+		Budget *SubgraphRetryBudget
+	}
+
+	SubgraphHedgingPolicy struct {
+		// Percentile of the observed subgraph latencies after which a hedged request is sent
+		Percentile float64
+		MinDelay   time.Duration
+		// MaxDelay is the upper bound of the hedging delay. Zero means no upper bound
+		MaxDelay time.Duration
 	}
 
 	GraphQLMetricsConfig struct {
@@ -100,14 +140,38 @@ type (
 		CertFile string
 	}
 
+	TlsCertificate struct {
+		CertFile string
+		KeyFile  string
+	}
+
 	TlsConfig struct {
 		Enabled  bool
 		CertFile string
 		KeyFile  string
+		// Certificates are additional certificates selected by the SNI of the client.
+		// CertFile and KeyFile are the default certificate.
+		Certificates []TlsCertificate
 
 		ClientAuth *TlsClientAuthConfig
 	}
 
+	// TlsClientConfig configures the TLS connections to subgraphs
+	TlsClientConfig struct {
+		CAFile             string
+		CertFile           string
+		KeyFile            string
+		ServerName         string
+		MinVersion         string
+		InsecureSkipVerify bool
+	}
+
+	SubgraphTlsConfig struct {
+		All *TlsClientConfig
+		// Subgraphs overrides the options of All by subgraph name
+		Subgraphs map[string]*TlsClientConfig
+	}
+
 	// Config defines the configuration options for the Router.
 	Config struct {
 		clusterName              string
@@ -141,6 +205,7 @@ type (
 		cdnConfig                config.CDNConfiguration
 		cdnPersistentOpClient    *cdn.PersistentOperationClient
 		eventsConfig             config.EventsConfiguration
+		eventBuses               map[string]*pubsub.Bus
 		prometheusServer         *http.Server
 		modulesConfig            map[string]interface{}
 		routerMiddlewares        []func(http.Handler) http.Handler
@@ -152,14 +217,33 @@ type (
 		graphqlMetricsConfig     *GraphQLMetricsConfig
 		routerTrafficConfig      *config.RouterTrafficConfiguration
 		accessController         *AccessController
+		forwardClaims            config.ForwardClaimsConfiguration
+		claimsForwarder          *claimsForwarder
 		retryOptions             retrytransport.RetryOptions
+		retryPolicy              *SubgraphRetryPolicy
+		hedgingPolicies          map[string]*SubgraphHedgingPolicy
 		processStartTime         time.Time
 		developmentMode          bool
 		// If connecting to localhost inside Docker fails, fallback to the docker internal address for the host
 		localhostFallbackInsideDocker bool
 
 		tlsServerConfig *tls.Config
-		tlsConfig       *TlsConfig
+		tlsCertStore    *servertls.CertStore
+		tlsMetrics      *rmetric.TLSMetrics
+		eventMetrics    *rmetric.EventMetrics
+		// websocketLimiter is shared by all servers, so the limits span config updates
+		websocketLimiter *websocketLimiter
+		websocketMetrics *rmetric.WebSocketMetrics
+		tlsConfig        *TlsConfig
+
+		subgraphTlsConfig *SubgraphTlsConfig
+
+		// h2cSubgraphs are the names of the subgraphs that are called with HTTP/2 over cleartext
+		h2cSubgraphs map[string]bool
+		// h2c accepts HTTP/2 over cleartext on the listener
+		h2c bool
+		// http3 serves HTTP/3 over QUIC on the UDP port of the listener
+		http3 bool
 
 		// Poller
 		configPoller configpoller.ConfigPoller
@@ -173,6 +257,8 @@ type (
 
 		overrideRoutingURLConfiguration config.OverrideRoutingURLConfiguration
 
+		subgraphPoolsConfiguration config.SubgraphPoolsConfiguration
+
 		authorization *config.AuthorizationConfiguration
 
 		rateLimit *config.RateLimitConfiguration
@@ -184,6 +270,8 @@ type (
 
 	Server interface {
 		HttpServer() *http.Server
+		// HTTP3Server returns the HTTP/3 server or nil when HTTP/3 is disabled
+		HTTP3Server() *http3.Server
 		HealthChecks() health.Checker
 		BaseURL() string
 	}
@@ -191,7 +279,9 @@ type (
 	// server is the main router instance.
 	server struct {
 		Config
-		server      *http.Server
+		server *http.Server
+		// http3Server is nil when HTTP/3 is disabled
+		http3Server *http3.Server
 		metricStore rmetric.Store
 		// rootContext that all services depending on the router should
 		// use as a parent context
@@ -199,6 +289,12 @@ type (
 		rootContextCancel func()
 		routerConfig      *nodev1.RouterConfig
 		healthChecks      health.Checker
+		// removeReadinessChecks removes the readiness checks of the event sources of the server
+		removeReadinessChecks []func()
+		// externalAuthorizer is nil when external authorization is disabled
+		externalAuthorizer *authorization.ExternalAuthorizer
+		// closeEventSources close the connections of the event sources of the server
+		closeEventSources []func() error
 	}
 
 	// Option defines the method to customize server.
@@ -309,6 +405,19 @@ func NewRouter(opts ...Option) (*Router, error) {
 
 	r.preOriginHandlers = append(r.preOriginHandlers, r.headerRuleEngine.OnOriginRequest)
 
+	if r.headerRuleEngine.HasResponseRules() {
+		r.postOriginHandlers = append(r.postOriginHandlers, r.headerRuleEngine.OnOriginResponse)
+	}
+
+	// Runs after the header rules to replace headers propagated from the client
+	if r.forwardClaims.Enabled {
+		r.claimsForwarder, err = newClaimsForwarder(r.forwardClaims, r.logger)
+		if err != nil {
+			return nil, err
+		}
+		r.preOriginHandlers = append(r.preOriginHandlers, r.claimsForwarder.OnOriginRequest)
+	}
+
 	defaultHeaders := []string{
 		// Common headers
 		"authorization",
@@ -335,12 +444,23 @@ func NewRouter(opts ...Option) (*Router, error) {
 	r.corsOptions.AllowHeaders = stringsx.RemoveDuplicates(append(r.corsOptions.AllowHeaders, defaultHeaders...))
 	r.corsOptions.AllowMethods = stringsx.RemoveDuplicates(append(r.corsOptions.AllowMethods, defaultMethods...))
 
-	if r.tlsConfig != nil && r.tlsConfig.Enabled {
+	if socket, ok := unixsocket.ListenPath(r.listenAddr); ok {
+		r.baseURL = unixsocket.BaseURL(socket)
+	} else if r.tlsConfig != nil && r.tlsConfig.Enabled {
 		r.baseURL = fmt.Sprintf("https://%s", r.listenAddr)
 	} else {
 		r.baseURL = fmt.Sprintf("http://%s", r.listenAddr)
 	}
 
+	if r.http3 {
+		if r.tlsConfig == nil || !r.tlsConfig.Enabled {
+			return nil, errors.New("http3 requires tls to be enabled")
+		}
+		if _, ok := unixsocket.ListenPath(r.listenAddr); ok {
+			return nil, errors.New("http3 is not supported on unix sockets")
+		}
+	}
+
 	if r.tlsConfig != nil && r.tlsConfig.Enabled {
 		if r.tlsConfig.CertFile == "" {
 			return nil, errors.New("tls cert file not provided")
@@ -375,16 +495,27 @@ func NewRouter(opts ...Option) (*Router, error) {
 			r.logger.Debug("Client auth enabled", zap.String("mode", clientAuthMode.String()))
 		}
 
-		// Load the server cert and private key
-		cer, err := tls.LoadX509KeyPair(r.tlsConfig.CertFile, r.tlsConfig.KeyFile)
+		certificates := []servertls.CertificateFiles{
+			{CertFile: r.tlsConfig.CertFile, KeyFile: r.tlsConfig.KeyFile},
+		}
+		for _, c := range r.tlsConfig.Certificates {
+			certificates = append(certificates, servertls.CertificateFiles{CertFile: c.CertFile, KeyFile: c.KeyFile})
+		}
+
+		// Load the server certs and private keys. They are reloaded when the files change.
+		certStore, err := servertls.NewCertStore(servertls.Options{
+			Certificates: certificates,
+			Logger:       r.logger,
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to load tls cert and key: %w", err)
+			return nil, err
 		}
 
+		r.tlsCertStore = certStore
 		r.tlsServerConfig = &tls.Config{
-			ClientCAs:    caCertPool,
-			Certificates: []tls.Certificate{cer},
-			ClientAuth:   clientAuthMode,
+			ClientCAs:      caCertPool,
+			GetCertificate: certStore.GetCertificate,
+			ClientAuth:     clientAuthMode,
 		}
 	}
 
@@ -464,6 +595,19 @@ func NewRouter(opts ...Option) (*Router, error) {
 		r.logger.Info("Event source enabled", zap.String("provider", source.Provider), zap.String("url", source.URL))
 	}
 
+	// The buses of in-memory sources are kept across config updates
+	for sourceName, source := range r.eventsConfig.Sources {
+		if source.Provider != "INMEMORY" {
+			continue
+		}
+		if r.eventBuses == nil {
+			r.eventBuses = make(map[string]*pubsub.Bus)
+		}
+		if _, ok := r.eventBuses[sourceName]; !ok {
+			r.eventBuses[sourceName] = pubsub.NewBus()
+		}
+	}
+
 	return r, nil
 }
 
@@ -716,6 +860,8 @@ func (r *Router) bootstrap(ctx context.Context) error {
 		r.tracerProvider = tp
 	}
 
+	r.websocketLimiter = newWebSocketLimiter(r.webSocketConfiguration)
+
 	// Prometheus metrics rely on OTLP metrics
 	if r.metricConfig.IsEnabled() {
 		if r.metricConfig.Prometheus.Enabled {
@@ -741,6 +887,49 @@ func (r *Router) bootstrap(ctx context.Context) error {
 			r.otlpMeterProvider = mp
 		}
 
+		if r.tlsCertStore != nil {
+			tm, err := rmetric.NewTLSMetrics(
+				r.tlsCertStore.Leaves,
+				[]attribute.KeyValue{
+					otel.WgRouterVersion.String(Version),
+					otel.WgRouterClusterName.String(r.clusterName),
+				},
+				r.promMeterProvider,
+				r.otlpMeterProvider,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to create tls metrics: %w", err)
+			}
+			r.tlsMetrics = tm
+		}
+
+		em, err := rmetric.NewEventMetrics(
+			[]attribute.KeyValue{
+				otel.WgRouterVersion.String(Version),
+				otel.WgRouterClusterName.String(r.clusterName),
+			},
+			r.promMeterProvider,
+			r.otlpMeterProvider,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create event metrics: %w", err)
+		}
+		r.eventMetrics = em
+
+		wm, err := rmetric.NewWebSocketMetrics(
+			r.websocketLimiter.counts,
+			[]attribute.KeyValue{
+				otel.WgRouterVersion.String(Version),
+				otel.WgRouterClusterName.String(r.clusterName),
+			},
+			r.promMeterProvider,
+			r.otlpMeterProvider,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create websocket metrics: %w", err)
+		}
+		r.websocketMetrics = wm
+		r.websocketLimiter.metrics = wm
 	}
 
 	r.gqlMetricsExporter = graphqlmetrics.NewNoopExporter()
@@ -862,7 +1051,7 @@ func (r *Router) Start(ctx context.Context) error {
 
 // newServer creates a new server instance.
 // All stateful data is copied from the Router over to the new server instance. Not safe for concurrent use.
-func (r *Router) newServer(ctx context.Context, routerConfig *nodev1.RouterConfig) (*server, error) {
+func (r *Router) newServer(ctx context.Context, routerConfig *nodev1.RouterConfig) (_ *server, err error) {
 	subgraphs, err := r.configureSubgraphOverwrites(routerConfig)
 	if err != nil {
 		return nil, err
@@ -876,6 +1065,13 @@ func (r *Router) newServer(ctx context.Context, routerConfig *nodev1.RouterConfi
 		Config:            r.Config,
 		metricStore:       rmetric.NewNoopMetrics(),
 	}
+	defer func() {
+		// The resources of a server that is never started are not closed by Shutdown
+		if err != nil {
+			rootContextCancel()
+			ro.closeResources()
+		}
+	}()
 
 	baseAttributes := []attribute.KeyValue{
 		otel.WgRouterConfigVersion.String(routerConfig.GetVersion()),
@@ -974,6 +1170,14 @@ func (r *Router) newServer(ctx context.Context, routerConfig *nodev1.RouterConfi
 	httpRouter.Get(r.livenessCheckPath, ro.healthChecks.Liveness())
 	httpRouter.Get(r.readinessCheckPath, ro.healthChecks.Readiness())
 
+	if r.claimsForwarder != nil && r.claimsForwarder.signer != nil {
+		jwksHandler, err := r.claimsForwarder.JWKSHandler()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create jwks handler: %w", err)
+		}
+		httpRouter.Get(r.claimsForwarder.config.JWT.JWKSPath, jwksHandler.ServeHTTP)
+	}
+
 	var (
 		planCache ExecutionPlanCache
 	)
@@ -1029,39 +1233,78 @@ func (r *Router) newServer(ctx context.Context, routerConfig *nodev1.RouterConfi
 		logger:              r.logger,
 	})
 
-	transport := newHTTPTransport(r.subgraphTransportOptions)
+	subgraphTransport, pools, err := r.newSubgraphTransport(routerConfig, subgraphs, ro.metricStore)
+	if err != nil {
+		return nil, err
+	}
 
 	ecb := &ExecutorConfigurationBuilder{
 		introspection: r.introspection,
 		baseURL:       r.baseURL,
-		transport:     transport,
+		transport:     subgraphTransport,
 		logger:        r.logger,
 		includeInfo:   r.graphqlMetricsConfig.Enabled,
+		eventBuses:    r.eventBuses,
+		eventMetrics:  r.eventMetrics,
 		transportOptions: &TransportOptions{
-			RequestTimeout: r.subgraphTransportOptions.RequestTimeout,
-			PreHandlers:    r.preOriginHandlers,
-			PostHandlers:   r.postOriginHandlers,
-			MetricStore:    ro.metricStore,
-			RetryOptions: retrytransport.RetryOptions{
-				Enabled:       r.retryOptions.Enabled,
-				MaxRetryCount: r.retryOptions.MaxRetryCount,
-				MaxDuration:   r.retryOptions.MaxDuration,
-				Interval:      r.retryOptions.Interval,
-				ShouldRetry: func(err error, req *http.Request, resp *http.Response) bool {
-					return retrytransport.IsRetryableError(err, resp) && !isMutationRequest(req.Context())
-				},
-			},
+			RequestTimeout:                r.subgraphTransportOptions.RequestTimeout,
+			PreHandlers:                   r.preOriginHandlers,
+			PostHandlers:                  r.postOriginHandlers,
+			MetricStore:                   ro.metricStore,
+			RetryOptions:                  r.subgraphRetryOptions(ro.metricStore),
+			HedgeOptions:                  r.subgraphHedgeOptions(ro.metricStore),
 			TracerProvider:                r.tracerProvider,
 			LocalhostFallbackInsideDocker: r.localhostFallbackInsideDocker,
 			Logger:                        r.logger,
 		},
 	}
 
+	var authorizationPolicies *AuthorizationPolicies
+	if r.Config.authorization != nil && r.Config.authorization.PolicyFile != "" {
+		authorizationPolicies, err = LoadAuthorizationPolicies(r.Config.authorization.PolicyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		externalAuthorizer          *authorization.ExternalAuthorizer
+		externalAuthorizationFields []resolve.GraphCoordinate
+	)
+	if r.Config.authorization != nil && r.Config.authorization.External.Enabled {
+		external := r.Config.authorization.External
+		for _, field := range external.Fields {
+			coordinate, err := parseGraphCoordinate(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid external authorization field: %w", err)
+			}
+			externalAuthorizationFields = append(externalAuthorizationFields, coordinate)
+		}
+		externalAuthorizer, err = authorization.NewExternalAuthorizer(authorization.ExternalAuthorizerOptions{
+			Protocol:       external.Protocol,
+			URL:            external.URL,
+			Timeout:        external.Timeout,
+			FailOpen:       external.FailOpen,
+			CacheTTL:       external.CacheTTL,
+			CacheSize:      external.CacheSize,
+			TracerProvider: r.tracerProvider,
+			Logger:         r.logger,
+		})
+		if err != nil {
+			return nil, err
+		}
+		ro.externalAuthorizer = externalAuthorizer
+	}
+
 	routerEngineConfig := &RouterEngineConfiguration{
 		Execution:                r.engineExecutionConfiguration,
 		Headers:                  r.headerRules,
 		Events:                   r.eventsConfig,
 		SubgraphErrorPropagation: r.subgraphErrorPropagation,
+		AuthorizationPolicies:    authorizationPolicies,
+		// Without explicit fields, only fields that already have an authorization rule are decided externally
+		ExternalAuthorizationFields: externalAuthorizationFields,
+		ClaimsForwarder:             r.claimsForwarder,
 	}
 
 	if r.developmentMode && r.engineExecutionConfiguration.EnableRequestTracing && r.graphApiToken == "" {
@@ -1069,6 +1312,8 @@ func (r *Router) newServer(ctx context.Context, routerConfig *nodev1.RouterConfi
 	}
 
 	executor, err := ecb.Build(ctx, routerConfig, routerEngineConfig, r.WebsocketStats)
+	// The event sources created before a failure are closed as well
+	ro.closeEventSources = ecb.closers
 	if err != nil {
 		return nil, fmt.Errorf("failed to build plan configuration: %w", err)
 	}
@@ -1098,6 +1343,9 @@ func (r *Router) newServer(ctx context.Context, routerConfig *nodev1.RouterConfi
 	authorizerOptions := &CosmoAuthorizerOptions{
 		FieldConfigurations:           routerConfig.EngineConfig.FieldConfigurations,
 		RejectOperationIfUnauthorized: false,
+		Policies:                      authorizationPolicies,
+		ExternalAuthorizer:            externalAuthorizer,
+		ExternalAuthorizationFields:   externalAuthorizationFields,
 	}
 
 	if r.Config.authorization != nil {
@@ -1113,19 +1361,19 @@ func (r *Router) newServer(ctx context.Context, routerConfig *nodev1.RouterConfi
 		Authorizer:                             NewCosmoAuthorizer(authorizerOptions),
 		SubgraphErrorPropagation:               r.subgraphErrorPropagation,
 		EngineLoaderHooks:                      NewEngineRequestHooks(ro.metricStore),
+		MultipartHeartbeatInterval:             routerEngineConfig.Execution.SubscriptionMultipartHeartbeatInterval,
 	}
 
 	if r.Config.rateLimit != nil && r.Config.rateLimit.Enabled {
 		handlerOpts.RateLimitConfig = r.Config.rateLimit
-		options, err := redis.ParseURL(r.Config.rateLimit.Storage.Url)
+		client, err := newRedisClient(r.Config.rateLimit.Storage)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse the redis connection url: %w", err)
+			return nil, err
 		}
 
-		client := redis.NewClient(options)
-
 		err = client.FlushDB(ctx).Err()
 		if err != nil {
+			_ = client.Close()
 			return nil, fmt.Errorf("failed to connect to redis: %w", err)
 		}
 		handlerOpts.RateLimiter = NewCosmoRateLimiter(&CosmoRateLimiterOptions{
@@ -1188,6 +1436,7 @@ func (r *Router) newServer(ctx context.Context, routerConfig *nodev1.RouterConfi
 			AccessController:           r.accessController,
 			Logger:                     r.logger,
 			Stats:                      r.WebsocketStats,
+			Limiter:                    r.websocketLimiter,
 			ReadTimeout:                r.engineExecutionConfiguration.WebSocketReadTimeout,
 			EnableWebSocketEpollKqueue: r.engineExecutionConfiguration.EnableWebSocketEpollKqueue,
 			EpollKqueuePollTimeout:     r.engineExecutionConfiguration.EpollKqueuePollTimeout,
@@ -1235,23 +1484,71 @@ func (r *Router) newServer(ctx context.Context, routerConfig *nodev1.RouterConfi
 		zap.String("url", graphqlEndpointURL),
 	)
 
+	var handler http.Handler = httpRouter
+	// With TLS HTTP/2 is negotiated by ALPN
+	if r.h2c && (r.tlsConfig == nil || !r.tlsConfig.Enabled) {
+		handler = h2c.NewHandler(httpRouter, &http2.Server{})
+	}
+
+	if r.http3 {
+		ro.http3Server = &http3.Server{
+			Addr:      r.listenAddr,
+			Handler:   httpRouter,
+			TLSConfig: r.tlsServerConfig,
+		}
+		handler = altSvcHandler(ro.http3Server, handler)
+	}
+
 	ro.server = &http.Server{
 		Addr: r.listenAddr,
 		// https://ieftimov.com/posts/make-resilient-golang-net-http-servers-using-timeouts-deadlines-context-cancellation/
 		ReadTimeout:       1 * time.Minute,
 		WriteTimeout:      2 * time.Minute,
 		ReadHeaderTimeout: 20 * time.Second,
-		Handler:           httpRouter,
+		Handler:           handler,
 		ErrorLog:          zap.NewStdLog(r.logger),
 		TLSConfig:         r.tlsServerConfig,
 	}
 
+	// Health checks run until the server is shutdown
+	for _, pool := range pools {
+		pool.Start(rootContext)
+	}
+
+	// Connections to event sources reconnect in the background, the server is not ready while they are down
+	if registry, ok := ro.healthChecks.(health.ReadinessCheckRegistry); ok {
+		for name, check := range ecb.readinessChecks {
+			ro.removeReadinessChecks = append(ro.removeReadinessChecks, registry.AddReadinessCheck(name, check))
+		}
+	}
+
 	return ro, nil
 }
 
 // listenAndServe starts the server and blocks until the server is shutdown.
 func (r *server) listenAndServe() error {
+	if socket, ok := unixsocket.ListenPath(r.listenAddr); ok {
+		return r.serveUnixSocket(socket)
+	}
+
 	if r.tlsConfig != nil && r.tlsConfig.Enabled {
+		if r.http3Server != nil {
+			// Both servers stop when the server is shutdown
+			errs := make(chan error, 2)
+			go func() {
+				errs <- r.http3Server.ListenAndServe()
+			}()
+			go func() {
+				errs <- r.server.ListenAndServeTLS("", "")
+			}()
+			if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) {
+				// Don't keep serving one protocol when the other one failed
+				_ = r.server.Close()
+				_ = r.http3Server.Close()
+				return err
+			}
+			return nil
+		}
 		// Leave the cert and key empty to use the default ones
 		if err := r.server.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			return err
@@ -1264,6 +1561,25 @@ func (r *server) listenAndServe() error {
 	return nil
 }
 
+// serveUnixSocket serves on a Unix domain socket, e.g. for a reverse proxy on the same host.
+// The socket file is removed when the server is shutdown.
+func (r *server) serveUnixSocket(socket string) error {
+	ln, err := unixsocket.Listen(socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on unix socket: %w", err)
+	}
+
+	if r.tlsConfig != nil && r.tlsConfig.Enabled {
+		err = r.server.ServeTLS(ln, "", "")
+	} else {
+		err = r.server.Serve(ln)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
 // Shutdown gracefully shuts down the router. It blocks until the server is shutdown.
 // If the router is already shutdown, the method returns immediately without error. Not safe for concurrent use.
 func (r *Router) Shutdown(ctx context.Context) (err error) {
@@ -1286,6 +1602,18 @@ func (r *Router) Shutdown(ctx context.Context) (err error) {
 		}
 	}
 
+	if r.tlsMetrics != nil {
+		if subErr := r.tlsMetrics.Stop(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to stop tls metrics: %w", subErr))
+		}
+	}
+
+	if r.websocketMetrics != nil {
+		if subErr := r.websocketMetrics.Stop(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to stop websocket metrics: %w", subErr))
+		}
+	}
+
 	var wg sync.WaitGroup
 
 	if r.prometheusServer != nil {
@@ -1385,17 +1713,42 @@ func (r *server) Shutdown(ctx context.Context) error {
 	}
 
 	r.healthChecks.SetReady(false)
+	for _, remove := range r.removeReadinessChecks {
+		remove()
+	}
+
+	if r.http3Server != nil {
+		// The requests of HTTP/3 connections are aborted, graceful shutdown is not supported by quic-go yet
+		if err := r.http3Server.Close(); err != nil {
+			r.logger.Error("Failed to close http3 server", zap.Error(err))
+		}
+	}
 
 	if r.server != nil {
 		// HTTP server shutdown
-		if err := r.server.Shutdown(ctx); err != nil {
-			return err
+		if shutdownErr := r.server.Shutdown(ctx); shutdownErr != nil {
+			err = shutdownErr
 		}
 	}
 
+	// Resources used by the requests are closed once the requests are done
+	r.closeResources()
+
 	return err
 }
 
+// closeResources closes the external authorizer and the connections of the event sources
+func (r *server) closeResources() {
+	if r.externalAuthorizer != nil {
+		r.externalAuthorizer.Close()
+	}
+	for _, closeEventSource := range r.closeEventSources {
+		if err := closeEventSource(); err != nil {
+			r.logger.Error("Failed to close event source", zap.Error(err))
+		}
+	}
+}
+
 func (r *server) HealthChecks() health.Checker {
 	return r.healthChecks
 }
@@ -1404,6 +1757,19 @@ func (r *server) HttpServer() *http.Server {
 	return r.server
 }
 
+func (r *server) HTTP3Server() *http3.Server {
+	return r.http3Server
+}
+
+// altSvcHandler announces the HTTP/3 server to the clients of the HTTP/1.1 and HTTP/2 server
+func altSvcHandler(h3 *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fails until the HTTP/3 server listens
+		_ = h3.SetQuicHeaders(w.Header())
+		next.ServeHTTP(w, r)
+	})
+}
+
 func WithListenerAddr(addr string) Option {
 	return func(r *Router) {
 		r.listenAddr = addr
@@ -1565,6 +1931,17 @@ func WithEvents(cfg config.EventsConfiguration) Option {
 	}
 }
 
+// WithEventBus sets the bus of the INMEMORY event source with sourceName. Events published on the bus
+// are delivered to the subscriptions of the router and the other way around.
+func WithEventBus(sourceName string, bus *pubsub.Bus) Option {
+	return func(r *Router) {
+		if r.eventBuses == nil {
+			r.eventBuses = make(map[string]*pubsub.Bus)
+		}
+		r.eventBuses[sourceName] = bus
+	}
+}
+
 func WithHeaderRules(headers config.HeaderRules) Option {
 	return func(r *Router) {
 		r.headerRules = headers
@@ -1577,6 +1954,12 @@ func WithOverrideRoutingURL(overrideRoutingURL config.OverrideRoutingURLConfigur
 	}
 }
 
+func WithSubgraphPools(cfg config.SubgraphPoolsConfiguration) Option {
+	return func(r *Router) {
+		r.subgraphPoolsConfiguration = cfg
+	}
+}
+
 func WithSecurityConfig(cfg config.SecurityConfiguration) Option {
 	return func(r *Router) {
 		r.securityConfiguration = cfg
@@ -1606,6 +1989,22 @@ func WithSubgraphRetryOptions(enabled bool, maxRetryCount int, retryMaxDuration,
 	}
 }
 
+// WithSubgraphRetryPolicy extends the subgraph retry options with Retry-After handling, a retry budget
+// and the retryable status codes for all or specific subgraphs.
+func WithSubgraphRetryPolicy(policy *SubgraphRetryPolicy) Option {
+	return func(r *Router) {
+		r.retryPolicy = policy
+	}
+}
+
+// WithSubgraphHedgingPolicies enables hedged requests for the subgraphs with the given names.
+// Only queries are hedged.
+func WithSubgraphHedgingPolicies(policies map[string]*SubgraphHedgingPolicy) Option {
+	return func(r *Router) {
+		r.hedgingPolicies = policies
+	}
+}
+
 func WithRouterTrafficConfig(cfg *config.RouterTrafficConfiguration) Option {
 	return func(r *Router) {
 		r.routerTrafficConfig = cfg
@@ -1618,6 +2017,13 @@ func WithAccessController(controller *AccessController) Option {
 	}
 }
 
+// WithForwardClaims forwards the claims of authenticated requests to the subgraphs
+func WithForwardClaims(cfg config.ForwardClaimsConfiguration) Option {
+	return func(r *Router) {
+		r.forwardClaims = cfg
+	}
+}
+
 func WithAuthorizationConfig(cfg *config.AuthorizationConfiguration) Option {
 	return func(r *Router) {
 		r.Config.authorization = cfg
@@ -1711,14 +2117,316 @@ func WithTLSConfig(cfg *TlsConfig) Option {
 	}
 }
 
-func newHTTPTransport(opts *SubgraphTransportOptions) *http.Transport {
+// WithH2C accepts HTTP/2 over cleartext on the listener.
+func WithH2C(enabled bool) Option {
+	return func(r *Router) {
+		r.h2c = enabled
+	}
+}
+
+// WithHTTP3 serves HTTP/3 over QUIC on the UDP port of the listener address. It is experimental and requires TLS.
+func WithHTTP3(enabled bool) Option {
+	return func(r *Router) {
+		r.http3 = enabled
+	}
+}
+
+// WithH2CSubgraphs calls the subgraphs with HTTP/2 over cleartext.
+// Use SubgraphTransportOptions.H2C to enable it for all subgraphs.
+func WithH2CSubgraphs(subgraphs ...string) Option {
+	return func(r *Router) {
+		r.h2cSubgraphs = make(map[string]bool, len(subgraphs))
+		for _, name := range subgraphs {
+			r.h2cSubgraphs[name] = true
+		}
+	}
+}
+
+func WithSubgraphTLSConfig(cfg *SubgraphTlsConfig) Option {
+	return func(r *Router) {
+		r.subgraphTlsConfig = cfg
+	}
+}
+
+func (r *Router) subgraphRetryOptions(metricStore rmetric.Store) retrytransport.RetryOptions {
+	opts := retrytransport.RetryOptions{
+		Enabled:       r.retryOptions.Enabled,
+		MaxRetryCount: r.retryOptions.MaxRetryCount,
+		MaxDuration:   r.retryOptions.MaxDuration,
+		Interval:      r.retryOptions.Interval,
+		ShouldRetry: func(err error, req *http.Request, resp *http.Response) bool {
+			if isMutationRequest(req.Context()) {
+				return false
+			}
+			if statusCodes := r.retryPolicy.retryableStatusCodes(req); len(statusCodes) > 0 {
+				return retrytransport.IsRetryableErrorWithStatusCodes(err, resp, statusCodes)
+			}
+			return retrytransport.IsRetryableError(err, resp)
+		},
+		OnRetry: newSubgraphRetryRecorder(metricStore),
+	}
+
+	if r.retryPolicy != nil {
+		opts.RespectRetryAfter = r.retryPolicy.RespectRetryAfter
+		if r.retryPolicy.Budget != nil {
+			opts.Budget = retrytransport.NewBudget(r.retryPolicy.Budget.Ratio, r.retryPolicy.Budget.MinRetries, r.retryPolicy.Budget.Window)
+		}
+	}
+
+	return opts
+}
+
+func (r *Router) subgraphHedgeOptions(metricStore rmetric.Store) hedgetransport.HedgeOptions {
+	return hedgetransport.HedgeOptions{
+		Enabled: len(r.hedgingPolicies) > 0,
+		Policy: func(req *http.Request) *hedgetransport.Policy {
+			// Only read-only operations are safe to send twice
+			if isMutationRequest(req.Context()) {
+				return nil
+			}
+			// Subscriptions are long-lived and must not be duplicated
+			if req.Header.Get("Upgrade") != "" || req.Header.Get("Accept") == "text/event-stream" {
+				return nil
+			}
+			reqContext := getRequestContext(req.Context())
+			if reqContext == nil {
+				return nil
+			}
+			subgraph := reqContext.ActiveSubgraph(req)
+			if subgraph == nil {
+				return nil
+			}
+			policy, ok := r.hedgingPolicies[subgraph.Name]
+			if !ok {
+				return nil
+			}
+			return &hedgetransport.Policy{
+				Key:        subgraph.Name,
+				Percentile: policy.Percentile,
+				MinDelay:   policy.MinDelay,
+				MaxDelay:   policy.MaxDelay,
+			}
+		},
+		OnHedge:          newSubgraphHedgeRecorder(),
+		OnHedgeCompleted: newSubgraphHedgeCompletionRecorder(metricStore),
+	}
+}
+
+// newSubgraphTransport creates the base transport of the subgraph requests. Subgraphs with their own
+// TLS configuration or an endpoint pool are sent through a dedicated transport.
+func (r *Router) newSubgraphTransport(routerConfig *nodev1.RouterConfig, subgraphs []Subgraph, metricStore rmetric.Store) (http.RoundTripper, []*loadbalancer.Pool, error) {
+	var defaultTLS *TlsClientConfig
+	if r.subgraphTlsConfig != nil {
+		defaultTLS = r.subgraphTlsConfig.All
+	}
+
+	defaultLoader, err := r.newTLSClientLoader(defaultTLS, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create subgraph tls client config: %w", err)
+	}
+
+	transport := newHTTPTransport(r.subgraphTransportOptions, defaultLoader)
+
+	var defaultTransport http.RoundTripper = transport
+	if r.subgraphTransportOptions.H2C {
+		defaultTransport = newH2CTransport(r.subgraphTransportOptions, transport)
+	}
+
+	var pools []*loadbalancer.Pool
+	transports := map[string]http.RoundTripper{}
+
+	for _, subgraph := range subgraphs {
+		// Virtual subgraphs have no routing URL
+		if subgraph.Url == nil || (subgraph.Url.Host == "" && !unixsocket.IsURL(subgraph.Url)) {
+			continue
+		}
+
+		var subgraphTransport http.RoundTripper = defaultTransport
+		dedicated := false
+
+		tlsConfig, overridden := r.subgraphTLSClientConfig(subgraph.Name)
+		mtls := datasourceMTLSConfiguration(routerConfig, subgraph.Id)
+		h2c := r.subgraphTransportOptions.H2C || r.h2cSubgraphs[subgraph.Name]
+
+		if overridden || mtls != nil || h2c != r.subgraphTransportOptions.H2C {
+			base := transport
+			if overridden || mtls != nil {
+				loader, err := r.newTLSClientLoader(tlsConfig, mtls)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to create tls client config for subgraph '%s': %w", subgraph.Name, err)
+				}
+				base = newHTTPTransport(r.subgraphTransportOptions, loader)
+			}
+
+			subgraphTransport = base
+			if h2c {
+				subgraphTransport = newH2CTransport(r.subgraphTransportOptions, base)
+			}
+			dedicated = true
+		}
+
+		if poolConfig, ok := r.subgraphPoolsConfiguration.Subgraphs[subgraph.Name]; ok {
+			pool, err := r.newSubgraphPool(subgraph.Name, poolConfig, subgraphTransport, metricStore)
+			if err != nil {
+				return nil, nil, err
+			}
+			pools = append(pools, pool)
+			subgraphTransport = loadbalancer.NewTransport(subgraphTransport, map[string]*loadbalancer.Pool{
+				loadbalancer.Key(subgraph.Url): pool,
+			})
+			dedicated = true
+		}
+
+		if dedicated {
+			transports[loadbalancer.Key(subgraph.Url)] = subgraphTransport
+		}
+	}
+
+	if len(transports) == 0 {
+		return defaultTransport, pools, nil
+	}
+
+	return newSubgraphRoundTripper(defaultTransport, transports), pools, nil
+}
+
+// subgraphTLSClientConfig merges the TLS options of the subgraph with the global ones.
+// It returns true if the subgraph has its own options.
+func (r *Router) subgraphTLSClientConfig(subgraphName string) (*TlsClientConfig, bool) {
+	if r.subgraphTlsConfig == nil {
+		return nil, false
+	}
+
+	override, ok := r.subgraphTlsConfig.Subgraphs[subgraphName]
+	if !ok || override == nil {
+		return r.subgraphTlsConfig.All, false
+	}
+
+	merged := TlsClientConfig{}
+	if r.subgraphTlsConfig.All != nil {
+		merged = *r.subgraphTlsConfig.All
+	}
+	if override.CAFile != "" {
+		merged.CAFile = override.CAFile
+	}
+	if override.CertFile != "" {
+		merged.CertFile = override.CertFile
+		merged.KeyFile = override.KeyFile
+	}
+	if override.ServerName != "" {
+		merged.ServerName = override.ServerName
+	}
+	if override.MinVersion != "" {
+		merged.MinVersion = override.MinVersion
+	}
+	merged.InsecureSkipVerify = merged.InsecureSkipVerify || override.InsecureSkipVerify
+
+	return &merged, true
+}
+
+// datasourceMTLSConfiguration returns the mTLS configuration of the subgraph from the router config
+func datasourceMTLSConfiguration(routerConfig *nodev1.RouterConfig, subgraphID string) *nodev1.MTLSConfiguration {
+	for _, conf := range routerConfig.GetEngineConfig().GetDatasourceConfigurations() {
+		if conf.Id == subgraphID {
+			return conf.GetCustomGraphql().GetFetch().GetMtls()
+		}
+	}
+	return nil
+}
+
+// newTLSClientLoader returns nil when neither the configuration nor the router config customize TLS.
+// A client certificate from the router config is only used when no cert file is configured.
+func (r *Router) newTLSClientLoader(cfg *TlsClientConfig, mtls *nodev1.MTLSConfiguration) (*clienttls.Loader, error) {
+	if cfg == nil && mtls == nil {
+		return nil, nil
+	}
+	if cfg == nil {
+		cfg = &TlsClientConfig{}
+	}
+	if *cfg == (TlsClientConfig{}) && mtls == nil {
+		return nil, nil
+	}
+
+	minVersion, err := clienttls.ParseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := clienttls.Options{
+		CAFile:             cfg.CAFile,
+		CertFile:           cfg.CertFile,
+		KeyFile:            cfg.KeyFile,
+		ServerName:         cfg.ServerName,
+		MinVersion:         minVersion,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		HandshakeTimeout:   r.subgraphTransportOptions.TLSHandshakeTimeout,
+		Logger:             r.logger,
+	}
+
+	if mtls != nil {
+		opts.InsecureSkipVerify = opts.InsecureSkipVerify || mtls.GetInsecureSkipVerify()
+
+		cert := config.LoadStringVariable(mtls.GetCert())
+		key := config.LoadStringVariable(mtls.GetKey())
+		if opts.CertFile == "" && cert != "" && key != "" {
+			opts.CertPEM = []byte(cert)
+			opts.KeyPEM = []byte(key)
+		}
+	}
+
+	return clienttls.New(opts)
+}
+
+func (r *Router) newSubgraphPool(subgraphName string, poolConfig config.SubgraphPool, transport http.RoundTripper, metricStore rmetric.Store) (*loadbalancer.Pool, error) {
+	urls := make([]*url.URL, 0, len(poolConfig.URLs))
+	for _, u := range poolConfig.URLs {
+		parsedURL, err := url.Parse(u)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse pool url '%s' of subgraph '%s': %w", u, subgraphName, err)
+		}
+		urls = append(urls, parsedURL)
+	}
+
+	return loadbalancer.NewPool(loadbalancer.PoolOptions{
+		Name:       subgraphName,
+		URLs:       urls,
+		Algorithm:  loadbalancer.Algorithm(poolConfig.LoadBalancing),
+		HashHeader: poolConfig.HashHeader,
+		HealthCheck: loadbalancer.HealthCheckOptions{
+			Enabled:            poolConfig.HealthCheck.Enabled,
+			Path:               poolConfig.HealthCheck.Path,
+			Interval:           poolConfig.HealthCheck.Interval,
+			Timeout:            poolConfig.HealthCheck.Timeout,
+			HealthyThreshold:   poolConfig.HealthCheck.HealthyThreshold,
+			UnhealthyThreshold: poolConfig.HealthCheck.UnhealthyThreshold,
+		},
+		OutlierDetection: loadbalancer.OutlierDetectionOptions{
+			Enabled:             poolConfig.OutlierDetection.Enabled,
+			ConsecutiveFailures: poolConfig.OutlierDetection.ConsecutiveFailures,
+			EjectionDuration:    poolConfig.OutlierDetection.EjectionDuration,
+		},
+		OnHealthChange: func(endpoint *url.URL, healthy bool) {
+			delta := int64(-1)
+			if healthy {
+				delta = 1
+			}
+			metricStore.MeasureSubgraphEndpointHealth(context.Background(), delta,
+				otel.WgSubgraphName.String(subgraphName),
+				otel.WgSubgraphEndpoint.String(endpoint.String()),
+			)
+		},
+		Client: &http.Client{Transport: transport},
+		Logger: r.logger,
+	})
+}
+
+func newHTTPTransport(opts *SubgraphTransportOptions, tlsLoader *clienttls.Loader) *http.Transport {
 	dialer := &net.Dialer{
 		Timeout:   opts.DialTimeout,
 		KeepAlive: opts.KeepAliveProbeInterval,
 	}
 	// Great source of inspiration: https://gitlab.com/gitlab-org/gitlab-pages
 	// A pages proxy in go that handles tls to upstreams, rate limiting, and more
-	return &http.Transport{
+	transport := &http.Transport{
 		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
 			return dialer.DialContext(ctx, network, addr)
 		},
@@ -1738,4 +2446,45 @@ func newHTTPTransport(opts *SubgraphTransportOptions) *http.Transport {
 		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
 		ExpectContinueTimeout: opts.ExpectContinueTimeout,
 	}
+
+	// A TLS configuration is created per connection to pick up reloaded certificates
+	if tlsLoader != nil {
+		transport.DialTLSContext = tlsLoader.DialTLSContext(dialer.DialContext)
+	}
+
+	// Subgraphs running as sidecars can be reached with unix:// routing URLs
+	unixsocket.Register(transport, dialer)
+
+	return transport
+}
+
+// newH2CTransport sends requests to http:// subgraphs with HTTP/2 over cleartext with prior knowledge.
+// Upgrade requests and https:// subgraphs are sent through the fallback transport.
+func newH2CTransport(opts *SubgraphTransportOptions, fallback http.RoundTripper) http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout:   opts.DialTimeout,
+		KeepAlive: opts.KeepAliveProbeInterval,
+	}
+
+	return &h2cTransport{
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			// Dial without TLS, the connection speaks HTTP/2 right away
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+			// Ping idle connections to detect broken connections
+			ReadIdleTimeout: opts.KeepAliveProbeInterval,
+		},
+		fallback: fallback,
+	}
+}
+
+// newRedisClient creates a client for the redis server of cfg. It does not connect to the server.
+func newRedisClient(cfg config.RedisConfiguration) (*redis.Client, error) {
+	options, err := redis.ParseURL(cfg.Url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the redis connection url: %w", err)
+	}
+	return redis.NewClient(options), nil
 }
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/buger/jsonparser"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+
+	"github.com/wundergraph/cosmo/router/pkg/pubsub"
+)
+
+// subscriptionCursor returns the cursor of every update of a subscription started with a cursor in
+// the response extensions, so the client can resume after the last update it received.
+//
+// The updater hands the cursor of an update over to the writer and waits until the writer flushed the
+// update, so every response carries the cursor of its own update.
+type subscriptionCursor struct {
+	// cursors holds the cursor of the update in flight, empty for events without a cursor
+	cursors chan string
+	// flushed signals that the writer flushed the update in flight
+	flushed chan struct{}
+}
+
+type subscriptionCursorKey struct{}
+
+// withSubscriptionCursor passes the cursor of the request extensions to the event sources, so
+// subscriptions to Redis streams resume after the last entry the client received.
+func withSubscriptionCursor(ctx *resolve.Context, extensions []byte) *resolve.Context {
+	cursor, err := jsonparser.GetString(extensions, "cursor")
+	if err != nil || cursor == "" {
+		return ctx
+	}
+	c := &subscriptionCursor{
+		cursors: make(chan string, 1),
+		flushed: make(chan struct{}, 1),
+	}
+	return ctx.WithContext(context.WithValue(pubsub.WithCursor(ctx.Context(), cursor), subscriptionCursorKey{}, c))
+}
+
+// getSubscriptionCursor returns the cursor set by withSubscriptionCursor or nil
+func getSubscriptionCursor(ctx context.Context) *subscriptionCursor {
+	c, _ := ctx.Value(subscriptionCursorKey{}).(*subscriptionCursor)
+	return c
+}
+
+// writer returns w adding the cursors of the updates to the responses, or w itself if c is nil
+func (c *subscriptionCursor) writer(w resolve.SubscriptionResponseWriter) resolve.SubscriptionResponseWriter {
+	if c == nil {
+		return w
+	}
+	return &cursorWriter{SubscriptionResponseWriter: w, cursor: c}
+}
+
+// updater returns u handing the cursors of the events over to the writer. It stops waiting for the
+// writer when ctx is done.
+func (c *subscriptionCursor) updater(ctx context.Context, u resolve.SubscriptionUpdater) resolve.SubscriptionUpdater {
+	return &cursorUpdater{ctx: ctx, updater: u, cursor: c}
+}
+
+type cursorUpdater struct {
+	ctx     context.Context
+	updater resolve.SubscriptionUpdater
+	cursor  *subscriptionCursor
+}
+
+var (
+	_ pubsub.CursorUpdater = (*cursorUpdater)(nil)
+	_ pubsub.ErrorUpdater  = (*cursorUpdater)(nil)
+)
+
+func (u *cursorUpdater) Update(data []byte) {
+	u.UpdateCursor(data, "")
+}
+
+func (u *cursorUpdater) UpdateCursor(data []byte, cursor string) {
+	select {
+	case u.cursor.cursors <- cursor:
+	case <-u.ctx.Done():
+		return
+	}
+	u.updater.Update(data)
+	select {
+	case <-u.cursor.flushed:
+	case <-u.ctx.Done():
+	}
+}
+
+// UpdateError passes the error that ends the subscription on without a cursor
+func (u *cursorUpdater) UpdateError(err error) {
+	if updater, ok := u.updater.(pubsub.ErrorUpdater); ok {
+		updater.UpdateError(err)
+	}
+}
+
+func (u *cursorUpdater) Done() {
+	u.updater.Done()
+}
+
+// cursorWriter buffers the response of an update and adds the cursor of the update when it is flushed
+type cursorWriter struct {
+	resolve.SubscriptionResponseWriter
+	cursor *subscriptionCursor
+	buf    bytes.Buffer
+}
+
+func (w *cursorWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *cursorWriter) Flush() error {
+	response := w.buf.Bytes()
+	inFlight := false
+	select {
+	case cursor := <-w.cursor.cursors:
+		inFlight = true
+		if cursor != "" {
+			value, err := json.Marshal(cursor)
+			if err == nil {
+				response, err = jsonparser.Set(bytes.Clone(response), value, "extensions", "cursor")
+			}
+			if err != nil {
+				w.flushed(inFlight)
+				return err
+			}
+		}
+	default:
+	}
+	_, err := w.SubscriptionResponseWriter.Write(response)
+	if err == nil {
+		err = w.SubscriptionResponseWriter.Flush()
+	}
+	w.flushed(inFlight)
+	return err
+}
+
+// flushed resets the buffer and lets the updater continue with the next update
+func (w *cursorWriter) flushed(inFlight bool) {
+	w.buf.Reset()
+	if !inFlight {
+		return
+	}
+	select {
+	case w.cursor.flushed <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/pubsub"
+)
+
+// writingUpdater writes every update to the writer in the background like the resolver
+type writingUpdater struct {
+	writer resolve.SubscriptionResponseWriter
+}
+
+func (u *writingUpdater) Update(data []byte) {
+	go func() {
+		_ = flushUpdate(u.writer, `{"data":`+string(data)+`}`)
+	}()
+}
+
+func (u *writingUpdater) Done() {}
+
+func TestSubscriptionCursor(t *testing.T) {
+	t.Parallel()
+
+	ctx := resolve.NewContext(context.Background())
+	require.Same(t, ctx, withSubscriptionCursor(ctx, []byte(`{"persistedQuery":{}}`)))
+	require.Nil(t, getSubscriptionCursor(ctx.Context()))
+
+	ctx = withSubscriptionCursor(ctx, []byte(`{"cursor":"$"}`))
+	require.Equal(t, pubsub.LatestCursor, pubsub.CursorFromContext(ctx.Context()))
+	cursor := getSubscriptionCursor(ctx.Context())
+	require.NotNil(t, cursor)
+
+	fanOut, err := newSubscriptionFanOut(config.EngineExecutionConfiguration{}, config.HeaderRules{}, nil)
+	require.NoError(t, err)
+	source := &recordingSubscriptionSource{}
+	w := newBlockingSubscriptionWriter()
+	shared := &sharedSubscriptionSource{source: source, fanOut: fanOut}
+	require.NoError(t, shared.Start(ctx, []byte(`{}`), &writingUpdater{writer: cursor.writer(w)}))
+
+	updater, ok := source.updater.(pubsub.CursorUpdater)
+	require.True(t, ok)
+
+	update := func(data, id string) string {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if id == "" {
+				updater.Update([]byte(data))
+				return
+			}
+			updater.UpdateCursor([]byte(data), id)
+		}()
+		next := w.next(t)
+		// The next update waits until the update was flushed
+		select {
+		case <-done:
+			t.Fatal("update returned before it was flushed")
+		case <-time.After(10 * time.Millisecond):
+		}
+		w.release <- struct{}{}
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the update to return")
+		}
+		return next
+	}
+
+	require.Equal(t, `{"data":{"id":1},"extensions":{"cursor":"1526919030474-55"}}`, update(`{"id":1}`, "1526919030474-55"))
+
+	// Events of sources without cursors are written as they are
+	require.Equal(t, `{"data":{"id":2}}`, update(`{"id":2}`, ""))
+}
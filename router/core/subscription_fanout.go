@@ -0,0 +1,488 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/buger/jsonparser"
+	"github.com/cespare/xxhash/v2"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/graphql_datasource"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/pubsub_datasource"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/pubsub"
+)
+
+const defaultSubscriptionClientBufferSize = 64
+
+var errSlowConsumer = errors.New("subscription closed because the client could not keep up with its updates")
+
+// subscriptionFanOut shares one upstream subscription between identical client subscriptions and
+// buffers the updates of every client, so a client that reads slowly only delays its own updates.
+//
+// The resolver runs one trigger per unique request ID and delivers each update to all the client
+// subscriptions of the trigger. The fan-out decides which subscriptions end up on the same trigger.
+type subscriptionFanOut struct {
+	deduplicate bool
+	bufferSize  int
+	policy      config.SlowConsumerPolicy
+	// headerNames and headerRegexps match the client headers propagated to any subgraph
+	headerNames   []string
+	headerRegexps []*regexp.Regexp
+	// claims forwards the claims of authenticated clients to the subgraphs, nil when disabled
+	claims *claimsForwarder
+	// triggers numbers the subscriptions when deduplication is disabled
+	triggers atomic.Uint64
+}
+
+func newSubscriptionFanOut(execution config.EngineExecutionConfiguration, headers config.HeaderRules, claims *claimsForwarder) (*subscriptionFanOut, error) {
+	switch execution.SubscriptionSlowConsumerPolicy {
+	case "", config.SlowConsumerPolicyDropOldest, config.SlowConsumerPolicyDropNewest, config.SlowConsumerPolicyClose:
+	default:
+		return nil, errors.New("invalid subscription slow consumer policy: " + string(execution.SubscriptionSlowConsumerPolicy))
+	}
+
+	// The headers propagated to any subgraph are part of the key. This shares less than
+	// possible for subgraphs without the rule but never shares streams with different headers.
+	rules := append([]config.RequestHeaderRule(nil), headers.All.Request...)
+	for _, subgraph := range headers.Subgraphs {
+		rules = append(rules, subgraph.Request...)
+	}
+	names, regexps, err := PropagatedHeaders(rules)
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range names {
+		names[i] = textproto.CanonicalMIMEHeaderKey(name)
+	}
+	sort.Strings(names)
+
+	f := &subscriptionFanOut{
+		deduplicate:   execution.SubscriptionDeduplication,
+		bufferSize:    execution.SubscriptionClientBufferSize,
+		policy:        execution.SubscriptionSlowConsumerPolicy,
+		headerNames:   names,
+		headerRegexps: regexps,
+		claims:        claims,
+	}
+	if f.bufferSize <= 0 {
+		f.bufferSize = defaultSubscriptionClientBufferSize
+	}
+	if f.policy == "" {
+		f.policy = config.SlowConsumerPolicyDropOldest
+	}
+	return f, nil
+}
+
+// configureSubscription keys the trigger of a subscription plan by the shared subscription it belongs to
+func (f *subscriptionFanOut) configureSubscription(preparedPlan plan.Plan) {
+	subscription, ok := preparedPlan.(*plan.SubscriptionResponsePlan)
+	if !ok || subscription.Response == nil || subscription.Response.Trigger.Source == nil {
+		return
+	}
+	trigger := &subscription.Response.Trigger
+	shared := &sharedSubscriptionSource{
+		source: trigger.Source,
+		fanOut: f,
+	}
+	source := trigger.Source
+	if events, isEvents := source.(*eventSubscriptionSource); isEvents {
+		source = events.source
+	}
+	if _, shared.events = source.(*pubsub_datasource.SubscriptionSource); shared.events {
+		// Events are passed to the resolver as the data of a response, so errors of the
+		// event source can be sent to the clients
+		trigger.PostProcessing.SelectResponseDataPath = []string{"data"}
+		trigger.PostProcessing.SelectResponseErrorsPath = []string{"errors"}
+	}
+	trigger.Source = shared
+}
+
+// clientWriter buffers the updates of a client subscription. The returned function stops the
+// delivery and must be called before the underlying writer becomes invalid.
+func (f *subscriptionFanOut) clientWriter(writer resolve.SubscriptionResponseWriter, logger *zap.Logger, stats WebSocketsStatistics) (resolve.SubscriptionResponseWriter, func()) {
+	if f == nil {
+		return writer, func() {}
+	}
+	w := newSubscriptionWriter(writer, f.bufferSize, f.policy, logger, stats)
+	return w, w.Close
+}
+
+// sharedSubscriptionSource computes the trigger ID from the normalized upstream request, so
+// identical subscriptions share one upstream stream
+type sharedSubscriptionSource struct {
+	source resolve.SubscriptionDataSource
+	fanOut *subscriptionFanOut
+	// events is set for event sources, whose events are wrapped in responses
+	events bool
+}
+
+var (
+	graphqlTriggerPrefix = []byte("graphql:")
+	eventsTriggerPrefix  = []byte("events:")
+	uniqueTriggerPrefix  = []byte("unique:")
+)
+
+func (s *sharedSubscriptionSource) UniqueRequestID(ctx *resolve.Context, input []byte, xxh *xxhash.Digest) error {
+	// Subscriptions with a cursor resume at their own position of the stream
+	if !s.fanOut.deduplicate || getSubscriptionCursor(ctx.Context()) != nil {
+		// Every client subscription starts its own upstream subscription
+		if _, err := xxh.Write(uniqueTriggerPrefix); err != nil {
+			return err
+		}
+		_, err := xxh.WriteString(strconv.FormatUint(s.fanOut.triggers.Inc(), 10))
+		return err
+	}
+
+	source := s.source
+	if events, ok := source.(*eventSubscriptionSource); ok {
+		source = events.source
+	}
+
+	switch source.(type) {
+	case *pubsub_datasource.SubscriptionSource:
+		// Event sources neither receive the initial payload nor the extensions of the client
+		normalized := jsonparser.Delete(bytes.Clone(input), "initial_payload")
+		normalized = jsonparser.Delete(normalized, "body")
+		normalized, err := canonicalJSON(normalized)
+		if err != nil {
+			return err
+		}
+		if _, err = xxh.Write(eventsTriggerPrefix); err != nil {
+			return err
+		}
+		_, err = xxh.Write(normalized)
+		return err
+	case *graphql_datasource.SubscriptionSource:
+		// The input holds the upstream URL, the operation, its variables, the extensions and the initial payload
+		normalized, err := canonicalJSON(input)
+		if err != nil {
+			return err
+		}
+		if _, err = xxh.Write(graphqlTriggerPrefix); err != nil {
+			return err
+		}
+		if _, err = xxh.Write(normalized); err != nil {
+			return err
+		}
+		if err = s.writeForwardedHeaders(ctx, xxh); err != nil {
+			return err
+		}
+		return s.writeForwardedClaims(ctx, xxh)
+	default:
+		return s.source.UniqueRequestID(ctx, input, xxh)
+	}
+}
+
+// writeForwardedHeaders hashes the propagated client headers in a stable order
+func (s *sharedSubscriptionSource) writeForwardedHeaders(ctx *resolve.Context, xxh *xxhash.Digest) error {
+	names := s.fanOut.headerNames
+	if len(s.fanOut.headerRegexps) > 0 {
+		names = append([]string(nil), names...)
+		for name := range ctx.Request.Header {
+			for _, re := range s.fanOut.headerRegexps {
+				if re.MatchString(name) {
+					names = append(names, name)
+					break
+				}
+			}
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		values := ctx.Request.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if _, err := xxh.WriteString(name); err != nil {
+			return err
+		}
+		for _, value := range values {
+			if _, err := xxh.WriteString(":" + value); err != nil {
+				return err
+			}
+		}
+		if _, err := xxh.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeForwardedClaims hashes the claims forwarded to the subgraphs. Like the headers, they are part of
+// the key even for subgraphs that don't receive them.
+func (s *sharedSubscriptionSource) writeForwardedClaims(ctx *resolve.Context, xxh *xxhash.Digest) error {
+	if s.fanOut.claims == nil {
+		return nil
+	}
+	key, err := s.fanOut.claims.subscriptionKey(authentication.FromContext(ctx.Context()))
+	if err != nil || key == nil {
+		return err
+	}
+	if _, err = xxh.WriteString("claims:"); err != nil {
+		return err
+	}
+	_, err = xxh.Write(key)
+	return err
+}
+
+func (s *sharedSubscriptionSource) Start(ctx *resolve.Context, input []byte, updater resolve.SubscriptionUpdater) error {
+	if s.events {
+		updater = &eventResponseUpdater{updater: updater}
+	}
+	if cursor := getSubscriptionCursor(ctx.Context()); cursor != nil {
+		updater = cursor.updater(ctx.Context(), updater)
+	}
+	return s.source.Start(ctx, input, updater)
+}
+
+// eventResponseUpdater passes the events of an event source to the resolver as the data of a response
+// and the error that ends the subscription as its errors
+type eventResponseUpdater struct {
+	updater resolve.SubscriptionUpdater
+}
+
+var _ pubsub.ErrorUpdater = (*eventResponseUpdater)(nil)
+
+func (u *eventResponseUpdater) Update(data []byte) {
+	response := make([]byte, 0, len(data)+9)
+	response = append(response, `{"data":`...)
+	response = append(response, data...)
+	response = append(response, '}')
+	u.updater.Update(response)
+}
+
+func (u *eventResponseUpdater) UpdateError(err error) {
+	// Without data the resolver responds with the errors and null data
+	response, err := json.Marshal(struct {
+		Errors []graphqlError `json:"errors"`
+	}{
+		Errors: []graphqlError{{Message: eventSourceErrorMessage(err)}},
+	})
+	if err != nil {
+		return
+	}
+	u.updater.Update(response)
+}
+
+func (u *eventResponseUpdater) Done() {
+	u.updater.Done()
+}
+
+// eventSourceErrorMessage returns the message of an error of an event source sent to the clients
+func eventSourceErrorMessage(err error) string {
+	switch getErrorType(err) {
+	case errorTypeEDFSNats:
+		return fmt.Sprintf("EDFS NATS error: %s", err.Error())
+	case errorTypeEDFSKafka:
+		return fmt.Sprintf("EDFS Kafka error: %s", err.Error())
+	case errorTypeEDFSRedis:
+		return fmt.Sprintf("EDFS Redis error: %s", err.Error())
+	case errorTypeEDFSInMemory:
+		return fmt.Sprintf("EDFS in-memory error: %s", err.Error())
+	default:
+		return "Internal server error"
+	}
+}
+
+// canonicalJSON sorts the object keys of data, so the order chosen by the client does not matter
+func canonicalJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// subscriptionWriter delivers the updates of a client subscription from its own goroutine. The
+// resolver flushes into a bounded queue and applies the slow consumer policy when it is full.
+//
+// The resolver serializes Write, Flush and Complete for a subscription.
+type subscriptionWriter struct {
+	writer resolve.SubscriptionResponseWriter
+	policy config.SlowConsumerPolicy
+	logger *zap.Logger
+	stats  WebSocketsStatistics
+
+	buf     bytes.Buffer
+	updates chan []byte
+	warned  bool
+	// slow is set when the close policy gave up on the client
+	slow bool
+	// completed is set by the resolver and read by Close
+	completed atomic.Bool
+
+	complete  chan struct{}
+	stop      chan struct{}
+	stopOnce  sync.Once
+	delivered chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+var _ resolve.SubscriptionResponseWriter = (*subscriptionWriter)(nil)
+
+func newSubscriptionWriter(writer resolve.SubscriptionResponseWriter, bufferSize int, policy config.SlowConsumerPolicy, logger *zap.Logger, stats WebSocketsStatistics) *subscriptionWriter {
+	w := &subscriptionWriter{
+		writer:    writer,
+		policy:    policy,
+		logger:    logger,
+		stats:     stats,
+		updates:   make(chan []byte, bufferSize),
+		complete:  make(chan struct{}),
+		stop:      make(chan struct{}),
+		delivered: make(chan struct{}),
+	}
+	go w.deliver()
+	return w
+}
+
+func (w *subscriptionWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+// Flush queues the buffered update. It returns an error once the client can't receive updates anymore,
+// which makes the resolver remove the subscription.
+func (w *subscriptionWriter) Flush() error {
+	if w.slow {
+		w.buf.Reset()
+		return errSlowConsumer
+	}
+	if err := w.failure(); err != nil {
+		w.buf.Reset()
+		return err
+	}
+	if w.buf.Len() == 0 || w.completed.Load() {
+		return nil
+	}
+	update := bytes.Clone(w.buf.Bytes())
+	w.buf.Reset()
+
+	select {
+	case w.updates <- update:
+		return nil
+	default:
+	}
+
+	switch w.policy {
+	case config.SlowConsumerPolicyDropNewest:
+		w.dropped()
+		return nil
+	case config.SlowConsumerPolicyClose:
+		// The queued updates are delivered before the resolver completes the subscription
+		w.dropped()
+		w.slow = true
+		return errSlowConsumer
+	default:
+		select {
+		case <-w.updates:
+			w.dropped()
+		default:
+		}
+		// Flush is the only sender, so the queue has room now
+		w.updates <- update
+		return nil
+	}
+}
+
+// Complete completes the client subscription after the queued updates were delivered
+func (w *subscriptionWriter) Complete() {
+	if w.completed.Swap(true) {
+		return
+	}
+	close(w.complete)
+}
+
+// Close stops the delivery and waits for a pending write to return. A completed subscription
+// delivers its queued updates first.
+func (w *subscriptionWriter) Close() {
+	if !w.completed.Load() {
+		w.stopOnce.Do(func() {
+			close(w.stop)
+		})
+	}
+	<-w.delivered
+}
+
+func (w *subscriptionWriter) deliver() {
+	defer close(w.delivered)
+	for {
+		select {
+		case <-w.stop:
+			return
+		case update := <-w.updates:
+			w.write(update)
+		case <-w.complete:
+			for {
+				select {
+				case <-w.stop:
+					return
+				case update := <-w.updates:
+					w.write(update)
+				default:
+					w.writer.Complete()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *subscriptionWriter) write(update []byte) {
+	select {
+	case <-w.stop:
+		// Close was called while the previous update was written
+		return
+	default:
+	}
+	if w.failure() != nil {
+		return
+	}
+	_, err := w.writer.Write(update)
+	if err == nil {
+		err = w.writer.Flush()
+	}
+	if err != nil {
+		w.fail(err)
+	}
+}
+
+func (w *subscriptionWriter) dropped() {
+	w.stats.SubscriptionUpdateDropped()
+	if !w.warned {
+		w.warned = true
+		w.logger.Warn("Subscription client can't keep up with its updates",
+			zap.String("policy", string(w.policy)),
+			zap.Int("buffer_size", cap(w.updates)),
+		)
+	}
+}
+
+func (w *subscriptionWriter) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *subscriptionWriter) failure() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
@@ -0,0 +1,434 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/graphql_datasource"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/pubsub_datasource"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/pubsub"
+)
+
+// blockingSubscriptionWriter records the flushed updates and blocks each flush until it is released
+type blockingSubscriptionWriter struct {
+	buf       bytes.Buffer
+	updates   chan string
+	release   chan struct{}
+	completed chan struct{}
+}
+
+func newBlockingSubscriptionWriter() *blockingSubscriptionWriter {
+	return &blockingSubscriptionWriter{
+		updates:   make(chan string, 16),
+		release:   make(chan struct{}, 16),
+		completed: make(chan struct{}),
+	}
+}
+
+func (w *blockingSubscriptionWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *blockingSubscriptionWriter) Flush() error {
+	w.updates <- w.buf.String()
+	w.buf.Reset()
+	<-w.release
+	return nil
+}
+
+func (w *blockingSubscriptionWriter) Complete() {
+	close(w.completed)
+}
+
+func (w *blockingSubscriptionWriter) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case update := <-w.updates:
+		return update
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an update")
+		return ""
+	}
+}
+
+func flushUpdate(w resolve.SubscriptionResponseWriter, update string) error {
+	_, _ = w.Write([]byte(update))
+	return w.Flush()
+}
+
+func TestSubscriptionWriterSlowConsumerPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		policy    config.SlowConsumerPolicy
+		delivered []string
+	}{
+		{config.SlowConsumerPolicyDropOldest, []string{"1", "3", "4"}},
+		{config.SlowConsumerPolicyDropNewest, []string{"1", "2", "3"}},
+		{config.SlowConsumerPolicyClose, []string{"1", "2", "3"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(string(tt.policy), func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stats := NewWebSocketStats(ctx, zap.NewNop())
+
+			inner := newBlockingSubscriptionWriter()
+			w := newSubscriptionWriter(inner, 2, tt.policy, zap.NewNop(), stats)
+
+			require.NoError(t, flushUpdate(w, "1"))
+			// The first update is being written, the next two fill the buffer
+			require.Equal(t, "1", inner.next(t))
+			require.NoError(t, flushUpdate(w, "2"))
+			require.NoError(t, flushUpdate(w, "3"))
+
+			err := flushUpdate(w, "4")
+			if tt.policy == config.SlowConsumerPolicyClose {
+				require.ErrorIs(t, err, errSlowConsumer)
+				require.ErrorIs(t, flushUpdate(w, "5"), errSlowConsumer)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, uint64(1), stats.GetReport().MessagesDropped)
+
+			w.Complete()
+			inner.release <- struct{}{}
+			for _, update := range tt.delivered[1:] {
+				require.Equal(t, update, inner.next(t))
+				inner.release <- struct{}{}
+			}
+			select {
+			case <-inner.completed:
+			case <-time.After(5 * time.Second):
+				t.Fatal("subscription was not completed")
+			}
+			w.Close()
+			require.Empty(t, inner.updates)
+		})
+	}
+}
+
+func TestSubscriptionWriterClose(t *testing.T) {
+	t.Parallel()
+
+	inner := newBlockingSubscriptionWriter()
+	w := newSubscriptionWriter(inner, 4, config.SlowConsumerPolicyDropOldest, zap.NewNop(), NewNoopWebSocketStats())
+
+	require.NoError(t, flushUpdate(w, "1"))
+	require.Equal(t, "1", inner.next(t))
+	require.NoError(t, flushUpdate(w, "2"))
+
+	// Close waits for the pending write and discards the queued updates
+	closed := make(chan struct{})
+	go func() {
+		w.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned during a write")
+	case <-time.After(50 * time.Millisecond):
+	}
+	inner.release <- struct{}{}
+	<-closed
+
+	require.Empty(t, inner.updates)
+	select {
+	case <-inner.completed:
+		t.Fatal("closed subscription must not be completed")
+	default:
+	}
+}
+
+// failingSubscriptionSource fails to start every subscription
+type failingSubscriptionSource struct{}
+
+func (failingSubscriptionSource) UniqueRequestID(_ *resolve.Context, input []byte, xxh *xxhash.Digest) error {
+	_, err := xxh.Write(input)
+	return err
+}
+
+func (failingSubscriptionSource) Start(*resolve.Context, []byte, resolve.SubscriptionUpdater) error {
+	return errors.New("failed to start")
+}
+
+func TestSubscriptionWriterTriggerFailsToStart(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler := &GraphQLHandler{log: zap.NewNop()}
+	resolver := resolve.New(ctx, resolve.ResolverOptions{AsyncErrorWriter: handler})
+
+	inner := newBlockingSubscriptionWriter()
+	inner.release <- struct{}{}
+	w := newSubscriptionWriter(inner, 4, config.SlowConsumerPolicyDropOldest, zap.NewNop(), NewNoopWebSocketStats())
+	subscription := &resolve.GraphQLSubscription{
+		Trigger: resolve.GraphQLSubscriptionTrigger{
+			Source: failingSubscriptionSource{},
+			InputTemplate: resolve.InputTemplate{Segments: []resolve.TemplateSegment{
+				{SegmentType: resolve.StaticSegmentType, Data: []byte(`{}`)},
+			}},
+		},
+		Response: &resolve.GraphQLResponse{Data: &resolve.Object{}},
+	}
+	id := resolve.SubscriptionIdentifier{ConnectionID: 1, SubscriptionID: 1}
+	require.NoError(t, resolver.AsyncResolveGraphQLSubscription(resolve.NewContext(ctx), subscription, w, id))
+
+	// The error is delivered and the subscription is completed, which ends the delivery
+	require.JSONEq(t, `{"errors":[{"message":"Internal server error"}],"data":null}`, inner.next(t))
+	select {
+	case <-inner.completed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription was not completed")
+	}
+	select {
+	case <-w.delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery did not end")
+	}
+}
+
+// startedSubscriptionSource hands the updaters of the started subscriptions over
+type startedSubscriptionSource struct {
+	updaters chan resolve.SubscriptionUpdater
+}
+
+func (s *startedSubscriptionSource) UniqueRequestID(_ *resolve.Context, input []byte, xxh *xxhash.Digest) error {
+	_, err := xxh.Write(input)
+	return err
+}
+
+func (s *startedSubscriptionSource) Start(_ *resolve.Context, _ []byte, updater resolve.SubscriptionUpdater) error {
+	s.updaters <- updater
+	return nil
+}
+
+func TestSharedSubscriptionSourceEventErrors(t *testing.T) {
+	t.Parallel()
+
+	fanOut, err := newSubscriptionFanOut(config.EngineExecutionConfiguration{}, config.HeaderRules{}, nil)
+	require.NoError(t, err)
+	subscription := &plan.SubscriptionResponsePlan{Response: &resolve.GraphQLSubscription{
+		Trigger: resolve.GraphQLSubscriptionTrigger{
+			Source: &pubsub_datasource.SubscriptionSource{},
+			InputTemplate: resolve.InputTemplate{Segments: []resolve.TemplateSegment{
+				{SegmentType: resolve.StaticSegmentType, Data: []byte(`{}`)},
+			}},
+			PostProcessing: resolve.PostProcessingConfiguration{MergePath: []string{"employeeUpdated"}},
+		},
+		Response: &resolve.GraphQLResponse{Data: &resolve.Object{Fields: []*resolve.Field{{
+			Name: []byte("employeeUpdated"),
+			Value: &resolve.Object{Path: []string{"employeeUpdated"}, Fields: []*resolve.Field{{
+				Name:  []byte("id"),
+				Value: &resolve.Integer{Path: []string{"id"}},
+			}}},
+		}}}},
+	}}
+	fanOut.configureSubscription(subscription)
+
+	trigger := subscription.Response.Trigger
+	require.Equal(t, []string{"data"}, trigger.PostProcessing.SelectResponseDataPath)
+	require.Equal(t, []string{"errors"}, trigger.PostProcessing.SelectResponseErrorsPath)
+	shared, ok := trigger.Source.(*sharedSubscriptionSource)
+	require.True(t, ok)
+	require.True(t, shared.events)
+	source := &startedSubscriptionSource{updaters: make(chan resolve.SubscriptionUpdater, 1)}
+	shared.source = source
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resolver := resolve.New(ctx, resolve.ResolverOptions{AsyncErrorWriter: &GraphQLHandler{log: zap.NewNop()}})
+	inner := newBlockingSubscriptionWriter()
+	for i := 0; i < 2; i++ {
+		inner.release <- struct{}{}
+	}
+	w := newSubscriptionWriter(inner, 4, config.SlowConsumerPolicyDropOldest, zap.NewNop(), NewNoopWebSocketStats())
+	id := resolve.SubscriptionIdentifier{ConnectionID: 1, SubscriptionID: 1}
+	require.NoError(t, resolver.AsyncResolveGraphQLSubscription(resolve.NewContext(ctx), subscription.Response, w, id))
+	updater := <-source.updaters
+
+	updater.Update([]byte(`{"id":1}`))
+	require.Equal(t, `{"data":{"employeeUpdated":{"id":1}}}`, inner.next(t))
+
+	// The error that ends the subscription is sent before it is completed
+	errorUpdater, ok := updater.(pubsub.ErrorUpdater)
+	require.True(t, ok)
+	errorUpdater.UpdateError(&pubsub.EDFSNatsError{Err: errors.New("consumer deleted")})
+	require.Equal(t, `{"errors":[{"message":"EDFS NATS error: consumer deleted"}],"data":null}`, inner.next(t))
+	updater.Done()
+	select {
+	case <-inner.completed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription was not completed")
+	}
+}
+
+func TestSharedSubscriptionSourceUniqueRequestID(t *testing.T) {
+	t.Parallel()
+
+	execution := config.EngineExecutionConfiguration{SubscriptionDeduplication: true}
+	headers := config.HeaderRules{
+		All: config.GlobalHeaderRule{
+			Request: []config.RequestHeaderRule{
+				{Operation: config.HeaderRuleOperationPropagate, Named: "authorization"},
+			},
+		},
+		Subgraphs: map[string]config.GlobalHeaderRule{
+			"employees": {
+				Request: []config.RequestHeaderRule{
+					{Operation: config.HeaderRuleOperationPropagate, Matching: "^X-Tenant"},
+				},
+			},
+		},
+	}
+	fanOut, err := newSubscriptionFanOut(execution, headers, nil)
+	require.NoError(t, err)
+
+	requestID := func(source resolve.SubscriptionDataSource, input string, header http.Header) uint64 {
+		t.Helper()
+		shared := &sharedSubscriptionSource{source: source, fanOut: fanOut}
+		ctx := resolve.NewContext(context.Background())
+		ctx.Request.Header = header
+		xxh := xxhash.New()
+		require.NoError(t, shared.UniqueRequestID(ctx, []byte(input), xxh))
+		return xxh.Sum64()
+	}
+
+	t.Run("graphql", func(t *testing.T) {
+		t.Parallel()
+
+		source := &graphql_datasource.SubscriptionSource{}
+		header := http.Header{
+			"Authorization": []string{"Bearer a"},
+			"X-Tenant-Id":   []string{"1"},
+			"X-Tenant-Name": []string{"acme"},
+			"X-Request-Id":  []string{"1"},
+		}
+		id := requestID(source, `{"url":"http://employees/graphql","body":{"query":"subscription{a}","variables":{"a":1,"b":2}}}`, header)
+
+		// Key order and headers that are not forwarded don't matter
+		other := header.Clone()
+		other.Set("X-Request-Id", "2")
+		require.Equal(t, id, requestID(source, `{"body":{"variables":{"b":2,"a":1},"query":"subscription{a}"},"url":"http://employees/graphql"}`, other))
+
+		require.NotEqual(t, id, requestID(source, `{"url":"http://employees/graphql","body":{"query":"subscription{a}","variables":{"a":2,"b":2}}}`, header))
+		require.NotEqual(t, id, requestID(source, `{"url":"http://employees/graphql","body":{"query":"subscription{a}","variables":{"a":1,"b":2}},"initial_payload":{"token":"a"}}`, header))
+
+		for _, name := range []string{"Authorization", "X-Tenant-Name"} {
+			other := header.Clone()
+			other.Set(name, "other")
+			require.NotEqual(t, id, requestID(source, `{"url":"http://employees/graphql","body":{"query":"subscription{a}","variables":{"a":1,"b":2}}}`, other), name)
+		}
+	})
+
+	t.Run("events", func(t *testing.T) {
+		t.Parallel()
+
+		source := &pubsub_datasource.SubscriptionSource{}
+		id := requestID(source, `{"subjects":["employeeUpdated.1"],"sourceName":"default"}`, http.Header{"Authorization": []string{"Bearer a"}})
+
+		// Event sources don't receive the initial payload, the extensions or the headers of the client
+		require.Equal(t, id, requestID(source, `{"sourceName":"default","subjects":["employeeUpdated.1"],"initial_payload":{"token":"b"},"body":{"extensions":{"a":1}}}`, http.Header{"Authorization": []string{"Bearer b"}}))
+		require.Equal(t, id, requestID(&eventSubscriptionSource{source: source}, `{"subjects":["employeeUpdated.1"],"sourceName":"default"}`, nil))
+
+		require.NotEqual(t, id, requestID(source, `{"subjects":["employeeUpdated.2"],"sourceName":"default"}`, nil))
+	})
+
+	t.Run("forwarded claims", func(t *testing.T) {
+		t.Parallel()
+
+		for _, mode := range []config.ForwardClaimsMode{config.ForwardClaimsModeHeaders, config.ForwardClaimsModeJWT} {
+			mode := mode
+			t.Run(string(mode), func(t *testing.T) {
+				t.Parallel()
+
+				claims, err := newClaimsForwarder(config.ForwardClaimsConfiguration{
+					Enabled:      true,
+					Mode:         mode,
+					Claims:       []string{"sub", "org.id"},
+					HeaderPrefix: "X-Claim-",
+					JWT:          config.ForwardClaimsJWTOptions{HeaderName: "X-Router-Claims"},
+				}, zap.NewNop())
+				require.NoError(t, err)
+				fanOut, err := newSubscriptionFanOut(execution, config.HeaderRules{}, claims)
+				require.NoError(t, err)
+				shared := &sharedSubscriptionSource{source: &graphql_datasource.SubscriptionSource{}, fanOut: fanOut}
+
+				// Both clients send the same operation with tokens that carry different claims
+				requestID := func(claims authentication.Claims) uint64 {
+					t.Helper()
+					ctx := resolve.NewContext(context.Background())
+					if claims != nil {
+						ctx = ctx.WithContext(authentication.NewContext(context.Background(), &testAuthentication{claims: claims}))
+					}
+					xxh := xxhash.New()
+					require.NoError(t, shared.UniqueRequestID(ctx, []byte(`{"url":"http://employees/graphql","body":{"query":"subscription{a}"}}`), xxh))
+					return xxh.Sum64()
+				}
+				id := requestID(authentication.Claims{"sub": "user-1", "org": map[string]any{"id": float64(1)}, "exp": float64(1)})
+
+				// Claims that are not forwarded don't matter
+				require.Equal(t, id, requestID(authentication.Claims{"sub": "user-1", "org": map[string]any{"id": float64(1)}, "exp": float64(2)}))
+
+				require.NotEqual(t, id, requestID(authentication.Claims{"sub": "user-2", "org": map[string]any{"id": float64(1)}, "exp": float64(1)}))
+				require.NotEqual(t, id, requestID(authentication.Claims{"sub": "user-1", "org": map[string]any{"id": float64(2)}, "exp": float64(1)}))
+				require.NotEqual(t, id, requestID(nil))
+			})
+		}
+	})
+
+	t.Run("without deduplication", func(t *testing.T) {
+		t.Parallel()
+
+		fanOut, err := newSubscriptionFanOut(config.EngineExecutionConfiguration{}, config.HeaderRules{}, nil)
+		require.NoError(t, err)
+		shared := &sharedSubscriptionSource{source: &pubsub_datasource.SubscriptionSource{}, fanOut: fanOut}
+
+		ids := make(map[uint64]struct{})
+		for i := 0; i < 2; i++ {
+			xxh := xxhash.New()
+			require.NoError(t, shared.UniqueRequestID(resolve.NewContext(context.Background()), []byte(`{"subjects":["a"]}`), xxh))
+			ids[xxh.Sum64()] = struct{}{}
+		}
+		require.Len(t, ids, 2)
+	})
+
+	t.Run("with a cursor", func(t *testing.T) {
+		t.Parallel()
+
+		shared := &sharedSubscriptionSource{source: &pubsub_datasource.SubscriptionSource{}, fanOut: fanOut}
+
+		// Subscriptions with a cursor don't share the stream, even with the same cursor
+		ids := make(map[uint64]struct{})
+		for i := 0; i < 2; i++ {
+			ctx := withSubscriptionCursor(resolve.NewContext(context.Background()), []byte(`{"cursor":"$"}`))
+			xxh := xxhash.New()
+			require.NoError(t, shared.UniqueRequestID(ctx, []byte(`{"subjects":["a"]}`), xxh))
+			ids[xxh.Sum64()] = struct{}{}
+		}
+		require.Len(t, ids, 2)
+	})
+}
+
+func TestNewSubscriptionFanOutInvalidPolicy(t *testing.T) {
+	t.Parallel()
+
+	_, err := newSubscriptionFanOut(config.EngineExecutionConfiguration{SubscriptionSlowConsumerPolicy: "block"}, config.HeaderRules{}, nil)
+	require.ErrorContains(t, err, "slow consumer policy")
+}
@@ -14,9 +14,12 @@ import (
 	"github.com/wundergraph/cosmo/router/pkg/metric"
 	"github.com/wundergraph/cosmo/router/pkg/otel"
 	"github.com/wundergraph/cosmo/router/pkg/trace"
+	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 
 	"github.com/wundergraph/cosmo/router/internal/docker"
+	"github.com/wundergraph/cosmo/router/internal/hedgetransport"
+	"github.com/wundergraph/cosmo/router/internal/loadbalancer"
 	"github.com/wundergraph/cosmo/router/internal/retrytransport"
 	"github.com/wundergraph/cosmo/router/internal/unsafebytes"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
@@ -44,6 +47,7 @@ func NewCustomTransport(
 	logger *zap.Logger,
 	roundTripper http.RoundTripper,
 	retryOptions retrytransport.RetryOptions,
+	hedgeOptions hedgetransport.HedgeOptions,
 	metricStore metric.Store,
 	enableSingleFlight bool,
 ) *CustomTransport {
@@ -51,6 +55,10 @@ func NewCustomTransport(
 	ct := &CustomTransport{
 		metricStore: metricStore,
 	}
+	// Hedged requests are sent below the retry transport, so that a retry can be hedged as well
+	if hedgeOptions.Enabled {
+		roundTripper = hedgetransport.NewHedgeHTTPTransport(roundTripper, hedgeOptions, logger)
+	}
 	if retryOptions.Enabled {
 		ct.roundTripper = retrytransport.NewRetryHTTPTransport(roundTripper, retryOptions, logger)
 	} else {
@@ -151,6 +159,97 @@ func (ct *CustomTransport) RoundTrip(req *http.Request) (resp *http.Response, er
 	return resp, err
 }
 
+// retryableStatusCodes returns the status codes that are retried for the subgraph of the request.
+// Nil means that the default status codes are retried.
+func (p *SubgraphRetryPolicy) retryableStatusCodes(req *http.Request) []int {
+	if p == nil {
+		return nil
+	}
+	if len(p.SubgraphStatusCodes) > 0 {
+		if reqContext := getRequestContext(req.Context()); reqContext != nil {
+			if subgraph := reqContext.ActiveSubgraph(req); subgraph != nil {
+				if statusCodes, ok := p.SubgraphStatusCodes[subgraph.Name]; ok && len(statusCodes) > 0 {
+					return statusCodes
+				}
+			}
+		}
+	}
+	return p.StatusCodes
+}
+
+// newSubgraphRetryRecorder records every retry of a subgraph request as span event and in the retry counter.
+func newSubgraphRetryRecorder(metricStore metric.Store) func(count int, req *http.Request, resp *http.Response, err error) {
+	return func(count int, req *http.Request, resp *http.Response, err error) {
+		var subgraphAttributes []attribute.KeyValue
+
+		reqContext := getRequestContext(req.Context())
+		if reqContext != nil {
+			if subgraph := reqContext.ActiveSubgraph(req); subgraph != nil {
+				subgraphAttributes = append(subgraphAttributes,
+					otel.WgSubgraphName.String(subgraph.Name),
+					otel.WgSubgraphID.String(subgraph.Id),
+				)
+			}
+		}
+
+		eventAttributes := append([]attribute.KeyValue{otel.WgSubgraphRetryCount.Int(count + 1)}, subgraphAttributes...)
+		if resp != nil {
+			eventAttributes = append(eventAttributes, semconv.HTTPStatusCode(resp.StatusCode))
+		}
+		if err != nil {
+			eventAttributes = append(eventAttributes, otel.WgRequestError.Bool(true))
+		}
+
+		span := otrace.SpanFromContext(req.Context())
+		span.AddEvent("Subgraph request retry", otrace.WithAttributes(eventAttributes...))
+
+		if reqContext != nil {
+			subgraphAttributes = append(subgraphAttributes, setAttributesFromOperationContext(reqContext.operation)...)
+		}
+
+		metricStore.MeasureRequestRetry(req.Context(), subgraphAttributes...)
+	}
+}
+
+// newSubgraphHedgeRecorder records a span event when a subgraph request is hedged.
+func newSubgraphHedgeRecorder() func(req *http.Request, delay time.Duration) {
+	return func(req *http.Request, delay time.Duration) {
+		var attributes []attribute.KeyValue
+		if reqContext := getRequestContext(req.Context()); reqContext != nil {
+			if subgraph := reqContext.ActiveSubgraph(req); subgraph != nil {
+				attributes = append(attributes,
+					otel.WgSubgraphName.String(subgraph.Name),
+					otel.WgSubgraphID.String(subgraph.Id),
+				)
+			}
+		}
+		attributes = append(attributes, attribute.Int64("delay_ms", delay.Milliseconds()))
+
+		span := otrace.SpanFromContext(req.Context())
+		span.AddEvent("Subgraph request hedged", otrace.WithAttributes(attributes...))
+	}
+}
+
+// newSubgraphHedgeCompletionRecorder records the outcome of a hedged subgraph request in the hedge counter.
+func newSubgraphHedgeCompletionRecorder(metricStore metric.Store) func(req *http.Request, won bool) {
+	return func(req *http.Request, won bool) {
+		attributes := []attribute.KeyValue{
+			otel.WgSubgraphHedgeWon.Bool(won),
+		}
+		if reqContext := getRequestContext(req.Context()); reqContext != nil {
+			if subgraph := reqContext.ActiveSubgraph(req); subgraph != nil {
+				attributes = append(attributes,
+					otel.WgSubgraphName.String(subgraph.Name),
+					otel.WgSubgraphID.String(subgraph.Id),
+				)
+			}
+			attributes = append(attributes, setAttributesFromOperationContext(reqContext.operation)...)
+		}
+
+		metricStore.MeasureRequestHedge(req.Context(), attributes...)
+	}
+}
+
 type responseWithBody struct {
 	res  *http.Response
 	body []byte
@@ -272,6 +371,7 @@ type TransportFactory struct {
 	preHandlers                   []TransportPreHandler
 	postHandlers                  []TransportPostHandler
 	retryOptions                  retrytransport.RetryOptions
+	hedgeOptions                  hedgetransport.HedgeOptions
 	requestTimeout                time.Duration
 	localhostFallbackInsideDocker bool
 	metricStore                   metric.Store
@@ -285,6 +385,7 @@ type TransportOptions struct {
 	PreHandlers                   []TransportPreHandler
 	PostHandlers                  []TransportPostHandler
 	RetryOptions                  retrytransport.RetryOptions
+	HedgeOptions                  hedgetransport.HedgeOptions
 	RequestTimeout                time.Duration
 	LocalhostFallbackInsideDocker bool
 	MetricStore                   metric.Store
@@ -297,6 +398,7 @@ func NewTransport(opts *TransportOptions) *TransportFactory {
 		preHandlers:                   opts.PreHandlers,
 		postHandlers:                  opts.PostHandlers,
 		retryOptions:                  opts.RetryOptions,
+		hedgeOptions:                  opts.HedgeOptions,
 		requestTimeout:                opts.RequestTimeout,
 		localhostFallbackInsideDocker: opts.LocalhostFallbackInsideDocker,
 		metricStore:                   opts.MetricStore,
@@ -337,6 +439,7 @@ func (t TransportFactory) RoundTripper(enableSingleFlight bool, transport http.R
 		t.logger,
 		traceTransport,
 		t.retryOptions,
+		t.hedgeOptions,
 		t.metricStore,
 		enableSingleFlight,
 	)
@@ -356,6 +459,41 @@ func (t TransportFactory) DefaultHTTPProxyURL() *url.URL {
 	return nil
 }
 
+// subgraphRoundTripper sends the requests of subgraphs with a dedicated transport, e.g. for their own
+// TLS configuration, through that transport. Transports are keyed by the routing URL of the subgraph.
+type subgraphRoundTripper struct {
+	defaultTransport http.RoundTripper
+	transports       map[string]http.RoundTripper
+}
+
+func newSubgraphRoundTripper(defaultTransport http.RoundTripper, transports map[string]http.RoundTripper) *subgraphRoundTripper {
+	return &subgraphRoundTripper{
+		defaultTransport: defaultTransport,
+		transports:       transports,
+	}
+}
+
+func (t *subgraphRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if transport, ok := t.transports[loadbalancer.Key(req.URL)]; ok {
+		return transport.RoundTrip(req)
+	}
+	return t.defaultTransport.RoundTrip(req)
+}
+
+// h2cTransport sends requests to http:// subgraphs with HTTP/2 over cleartext.
+// HTTP/2 has no connection upgrades, so WebSocket upgrades use the fallback transport.
+type h2cTransport struct {
+	h2c      http.RoundTripper
+	fallback http.RoundTripper
+}
+
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" || req.Header.Get("Upgrade") != "" {
+		return t.fallback.RoundTrip(req)
+	}
+	return t.h2c.RoundTrip(req)
+}
+
 // SpanNameFormatter formats the span name based on the http request
 func SpanNameFormatter(_ string, r *http.Request) string {
 	opCtx := getOperationContext(r.Context())
@@ -23,6 +23,7 @@ import (
 	"github.com/wundergraph/cosmo/router/internal/epoller"
 	"github.com/wundergraph/cosmo/router/internal/pool"
 	"github.com/wundergraph/cosmo/router/internal/wsproto"
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
 	"github.com/wundergraph/cosmo/router/pkg/config"
 	"github.com/wundergraph/cosmo/router/pkg/logging"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
@@ -33,8 +34,26 @@ import (
 
 var (
 	errClientTerminatedConnection = errors.New("client terminated connection")
+	errInitializationTimeout      = errors.New("connection initialisation timeout")
 )
 
+// Close codes of the graphql-ws protocol, see https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+const (
+	// wsCloseCodeForbidden is sent when the authentication of a connection fails or expires
+	wsCloseCodeForbidden ws.StatusCode = 4403
+	// wsCloseCodeInitializationTimeout is sent when the client doesn't send connection_init in time
+	wsCloseCodeInitializationTimeout ws.StatusCode = 4408
+	// wsCloseCodeSubscriberAlreadyExists is sent when a client reuses the ID of an active subscription
+	wsCloseCodeSubscriberAlreadyExists ws.StatusCode = 4409
+	// wsCloseCodeTooManyInitialisationRequests is sent when the client sends connection_init again
+	wsCloseCodeTooManyInitialisationRequests ws.StatusCode = 4429
+	// wsCloseCodeTryAgainLater is sent when the router is over its connection limit
+	wsCloseCodeTryAgainLater ws.StatusCode = 1013
+)
+
+// wsCloseFrameWriteTimeout limits the time to send a close frame to a client that stopped reading
+const wsCloseFrameWriteTimeout = time.Second
+
 type WebsocketMiddlewareOptions struct {
 	OperationProcessor *OperationProcessor
 	OperationBlocker   *OperationBlocker
@@ -45,6 +64,8 @@ type WebsocketMiddlewareOptions struct {
 	Logger             *zap.Logger
 	Stats              WebSocketsStatistics
 	ReadTimeout        time.Duration
+	// Limiter enforces the connection limits, a new one is created when nil
+	Limiter *websocketLimiter
 
 	EnableWebSocketEpollKqueue bool
 	EpollKqueuePollTimeout     time.Duration
@@ -68,6 +89,10 @@ func NewWebsocketMiddleware(ctx context.Context, opts WebsocketMiddlewareOptions
 			stats:              opts.Stats,
 			readTimeout:        opts.ReadTimeout,
 			config:             opts.WebSocketConfiguration,
+			limiter:            opts.Limiter,
+		}
+		if handler.limiter == nil {
+			handler.limiter = newWebSocketLimiter(opts.WebSocketConfiguration)
 		}
 		if opts.WebSocketConfiguration != nil && opts.WebSocketConfiguration.AbsintheProtocol.Enabled {
 			handler.absintheHandlerEnabled = true
@@ -141,6 +166,22 @@ func (c *wsConnectionWrapper) WriteJSON(v interface{}) error {
 	return c.rw.Flush()
 }
 
+// WriteCloseFrame sends a close frame with the status code and reason. The connection must be
+// closed afterward.
+func (c *wsConnectionWrapper) WriteCloseFrame(code ws.StatusCode, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.conn.SetWriteDeadline(time.Now().Add(wsCloseFrameWriteTimeout))
+	if err != nil {
+		return err
+	}
+	err = ws.WriteFrame(c.rw, ws.NewCloseFrame(ws.NewCloseFrameBody(code, reason)))
+	if err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
 func (c *wsConnectionWrapper) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -166,7 +207,8 @@ type WebsocketHandler struct {
 	handlerPool   *pond.WorkerPool
 	connectionIDs atomic.Int64
 
-	stats WebSocketsStatistics
+	stats   WebSocketsStatistics
+	limiter *websocketLimiter
 
 	readTimeout time.Duration
 
@@ -193,15 +235,23 @@ func (h *WebsocketHandler) handleUpgradeRequest(w http.ResponseWriter, r *http.R
 
 	// Check access control before upgrading the connection
 	validatedReq, err := h.accessController.Access(w, r)
+	initialPayloadAuthenticationRequired := false
 	if err != nil {
-		statusCode := http.StatusForbidden
-		if errors.Is(err, ErrUnauthorized) {
-			statusCode = http.StatusUnauthorized
+		var authErr *authenticationError
+		if h.config == nil || !h.config.Authentication.FromInitialPayload.Enabled || !errors.Is(err, ErrUnauthorized) || errors.As(err, &authErr) {
+			reportAuthenticationFailure(r.Context(), err, requestLogger, h.metrics.MetricStore())
+			statusCode := http.StatusForbidden
+			if errors.Is(err, ErrUnauthorized) {
+				statusCode = http.StatusUnauthorized
+			}
+			http.Error(w, http.StatusText(statusCode), statusCode)
+			return
 		}
-		http.Error(w, http.StatusText(statusCode), statusCode)
-		return
+		// The upgrade request has no authentication information, but the initial payload might
+		initialPayloadAuthenticationRequired = true
+	} else {
+		r = validatedReq
 	}
-	r = validatedReq
 
 	upgrader := ws.HTTPUpgrader{
 		Timeout: time.Second * 5,
@@ -237,26 +287,46 @@ func (h *WebsocketHandler) handleUpgradeRequest(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	releaseConnection, err := h.limiter.acquireConnection(h.limiter.clientKey(r))
+	if err != nil {
+		var limitErr *websocketLimitError
+		if errors.As(err, &limitErr) {
+			_ = conn.WriteCloseFrame(limitErr.code, limitErr.reason)
+		}
+		h.stats.ConnectionRejected()
+		requestLogger.Debug("Rejected websocket connection", zap.Error(err))
+		_ = c.Close()
+		return
+	}
+
 	handler := NewWebsocketConnectionHandler(h.ctx, WebSocketConnectionHandlerOptions{
-		OperationProcessor: h.operationProcessor,
-		OperationBlocker:   h.operationBlocker,
-		Planner:            h.planner,
-		GraphQLHandler:     h.graphqlHandler,
-		Metrics:            h.metrics,
-		ResponseWriter:     w,
-		Request:            r,
-		Connection:         conn,
-		Protocol:           protocol,
-		Logger:             h.logger,
-		Stats:              h.stats,
-		ConnectionID:       h.connectionIDs.Inc(),
-		ClientInfo:         clientInfo,
-		InitRequestID:      requestID,
-		Config:             h.config,
+		OperationProcessor:                   h.operationProcessor,
+		OperationBlocker:                     h.operationBlocker,
+		Planner:                              h.planner,
+		GraphQLHandler:                       h.graphqlHandler,
+		Metrics:                              h.metrics,
+		AccessController:                     h.accessController,
+		ResponseWriter:                       w,
+		Request:                              r,
+		Connection:                           conn,
+		Protocol:                             protocol,
+		Logger:                               h.logger,
+		Stats:                                h.stats,
+		ConnectionID:                         h.connectionIDs.Inc(),
+		ClientInfo:                           clientInfo,
+		InitRequestID:                        requestID,
+		Config:                               h.config,
+		InitialPayloadAuthenticationRequired: initialPayloadAuthenticationRequired,
+		Limiter:                              h.limiter,
+		ReleaseConnection:                    releaseConnection,
 	})
 	err = handler.Initialize()
 	if err != nil {
-		requestLogger.Error("Initializing websocket connection", zap.Error(err))
+		if errors.Is(err, wsproto.ErrInitialPayloadRejected) || errors.Is(err, errInitializationTimeout) {
+			requestLogger.Debug("Rejected websocket connection", zap.Error(err))
+		} else {
+			requestLogger.Error("Initializing websocket connection", zap.Error(err))
+		}
 		handler.Close()
 		return
 	}
@@ -267,12 +337,15 @@ func (h *WebsocketHandler) handleUpgradeRequest(w http.ResponseWriter, r *http.R
 		if err != nil {
 			requestLogger.Error("Adding connection to epoll", zap.Error(err))
 			handler.Close()
+			return
 		}
+		handler.startKeepAlive()
 		return
 	}
 
 	// Handle messages sync when epoll is not available
 
+	handler.startKeepAlive()
 	go h.handleConnectionSync(handler)
 }
 
@@ -319,14 +392,24 @@ func (h *WebsocketHandler) addConnection(conn net.Conn, handler *WebSocketConnec
 	defer h.connectionsMu.Unlock()
 	fd := socketFd(conn)
 	h.connections[fd] = handler
+	handler.setRemove(func() {
+		h.removeConnection(conn, handler, fd)
+	})
 	return h.epoll.Add(conn)
 }
 
+// removeConnection unregisters the connection from the epoller and closes it. Connections
+// closed by the server and by the client might be removed twice.
 func (h *WebsocketHandler) removeConnection(conn net.Conn, handler *WebSocketConnectionHandler, fd int) {
-	h.stats.ConnectionsDec()
 	h.connectionsMu.Lock()
+	if h.connections[fd] != handler {
+		h.connectionsMu.Unlock()
+		handler.Close()
+		return
+	}
 	delete(h.connections, fd)
 	h.connectionsMu.Unlock()
+	h.stats.ConnectionsDec()
 	err := h.epoll.Remove(conn)
 	if err != nil {
 		h.logger.Warn("Removing connection from epoll", zap.Error(err))
@@ -412,7 +495,7 @@ func (h *WebsocketHandler) runPoller() {
 					h.logger.Debug("Handling websocket message", zap.Error(err))
 					if errors.Is(err, errClientTerminatedConnection) {
 						h.removeConnection(conn, handler, fd)
-						return
+						continue
 					}
 				}
 			}
@@ -429,6 +512,8 @@ type websocketResponseWriter struct {
 	logger          *zap.Logger
 	stats           WebSocketsStatistics
 	propagateErrors bool
+	// onComplete is called when the subscription is completed
+	onComplete func()
 }
 
 var _ http.ResponseWriter = (*websocketResponseWriter)(nil)
@@ -458,6 +543,9 @@ func (rw *websocketResponseWriter) Complete() {
 	if err != nil {
 		rw.logger.Debug("Sending complete message", zap.Error(err))
 	}
+	if rw.onComplete != nil {
+		rw.onComplete()
+	}
 }
 
 func (rw *websocketResponseWriter) Write(data []byte) (int, error) {
@@ -516,6 +604,7 @@ type WebSocketConnectionHandlerOptions struct {
 	Planner            *OperationPlanner
 	GraphQLHandler     *GraphQLHandler
 	Metrics            RouterMetrics
+	AccessController   *AccessController
 	ResponseWriter     http.ResponseWriter
 	Request            *http.Request
 	Connection         *wsConnectionWrapper
@@ -526,6 +615,13 @@ type WebSocketConnectionHandlerOptions struct {
 	RequestContext     context.Context
 	ClientInfo         *ClientInfo
 	InitRequestID      string
+	// InitialPayloadAuthenticationRequired is true if the upgrade request had no authentication
+	// information, but authentication is required
+	InitialPayloadAuthenticationRequired bool
+	// Limiter counts the subscriptions of the connection
+	Limiter *websocketLimiter
+	// ReleaseConnection is called once when the connection is closed
+	ReleaseConnection func()
 }
 
 type WebSocketConnectionHandler struct {
@@ -535,6 +631,7 @@ type WebSocketConnectionHandler struct {
 	planner            *OperationPlanner
 	graphqlHandler     *GraphQLHandler
 	metrics            RouterMetrics
+	accessController   *AccessController
 	w                  http.ResponseWriter
 	r                  *http.Request
 	conn               *wsConnectionWrapper
@@ -542,6 +639,33 @@ type WebSocketConnectionHandler struct {
 	clientInfo         *ClientInfo
 	logger             *zap.Logger
 
+	initialPayloadAuthentication         config.InitialPayloadAuthenticationConfiguration
+	initialPayloadAuthenticationRequired bool
+	// authProvider provides the authentication information of the connection for its revalidation
+	authProvider      authentication.Provider
+	revalidationTimer *time.Timer
+
+	initializationTimeout time.Duration
+	pingInterval          time.Duration
+	pongTimeout           time.Duration
+	maxLifetime           time.Duration
+	pingTimer             *time.Timer
+	// pongTimer is running while the client owes a pong
+	pongTimer     *time.Timer
+	lifetimeTimer *time.Timer
+
+	// mu guards the timers, remove, releaseConnection and closed
+	mu     sync.Mutex
+	closed bool
+	// remove unregisters the connection from the epoller and closes it, nil without epoll
+	remove            func()
+	releaseConnection func()
+
+	limiter          *websocketLimiter
+	maxSubscriptions int
+	// activeSubscriptions counts the entries of subscriptions
+	activeSubscriptions atomic.Int64
+
 	initialPayload            json.RawMessage
 	upgradeRequestHeaders     json.RawMessage
 	upgradeRequestQueryParams json.RawMessage
@@ -558,26 +682,41 @@ type WebSocketConnectionHandler struct {
 }
 
 func NewWebsocketConnectionHandler(ctx context.Context, opts WebSocketConnectionHandlerOptions) *WebSocketConnectionHandler {
-	return &WebSocketConnectionHandler{
-		ctx:                              ctx,
-		operationProcessor:               opts.OperationProcessor,
-		operationBlocker:                 opts.OperationBlocker,
-		planner:                          opts.Planner,
-		graphqlHandler:                   opts.GraphQLHandler,
-		metrics:                          opts.Metrics,
-		w:                                opts.ResponseWriter,
-		r:                                opts.Request,
-		conn:                             opts.Connection,
-		protocol:                         opts.Protocol,
-		logger:                           opts.Logger,
-		connectionID:                     opts.ConnectionID,
-		stats:                            opts.Stats,
-		clientInfo:                       opts.ClientInfo,
-		initRequestID:                    opts.InitRequestID,
-		forwardUpgradeRequestHeaders:     opts.Config != nil && opts.Config.ForwardUpgradeHeaders,
-		forwardUpgradeRequestQueryParams: opts.Config != nil && opts.Config.ForwardUpgradeQueryParams,
-		forwardInitialPayload:            opts.Config != nil && opts.Config.ForwardInitialPayload,
+	handler := &WebSocketConnectionHandler{
+		ctx:                                  ctx,
+		operationProcessor:                   opts.OperationProcessor,
+		operationBlocker:                     opts.OperationBlocker,
+		planner:                              opts.Planner,
+		graphqlHandler:                       opts.GraphQLHandler,
+		metrics:                              opts.Metrics,
+		accessController:                     opts.AccessController,
+		w:                                    opts.ResponseWriter,
+		r:                                    opts.Request,
+		conn:                                 opts.Connection,
+		protocol:                             opts.Protocol,
+		logger:                               opts.Logger,
+		connectionID:                         opts.ConnectionID,
+		stats:                                opts.Stats,
+		clientInfo:                           opts.ClientInfo,
+		initRequestID:                        opts.InitRequestID,
+		initialPayloadAuthenticationRequired: opts.InitialPayloadAuthenticationRequired,
+		limiter:                              opts.Limiter,
+		releaseConnection:                    opts.ReleaseConnection,
+		forwardUpgradeRequestHeaders:         opts.Config != nil && opts.Config.ForwardUpgradeHeaders,
+		forwardUpgradeRequestQueryParams:     opts.Config != nil && opts.Config.ForwardUpgradeQueryParams,
+		forwardInitialPayload:                opts.Config != nil && opts.Config.ForwardInitialPayload,
+	}
+	if opts.Config != nil {
+		if opts.Config.Authentication.FromInitialPayload.Enabled {
+			handler.initialPayloadAuthentication = opts.Config.Authentication.FromInitialPayload
+		}
+		handler.initializationTimeout = opts.Config.InitializationTimeout
+		handler.pingInterval = opts.Config.PingInterval
+		handler.pongTimeout = opts.Config.PongTimeout
+		handler.maxLifetime = opts.Config.MaxConnectionLifetime
+		handler.maxSubscriptions = opts.Config.Limits.MaxSubscriptionsPerConnection
 	}
+	return handler
 }
 
 func (h *WebSocketConnectionHandler) requestError(err error) error {
@@ -634,6 +773,17 @@ func (h *WebSocketConnectionHandler) parseAndPlan(payload []byte) (*ParsedOperat
 func (h *WebSocketConnectionHandler) executeSubscription(msg *wsproto.Message, id resolve.SubscriptionIdentifier) {
 
 	rw := newWebsocketResponseWriter(msg.ID, h.protocol, h.graphqlHandler.subgraphErrorPropagation.Enabled, h.logger, h.stats)
+	rw.onComplete = func() {
+		h.releaseSubscription(msg.ID, id.SubscriptionID)
+	}
+
+	// Subscriptions that fail before the resolver takes them over are released right away
+	subscribed := false
+	defer func() {
+		if !subscribed {
+			h.releaseSubscription(msg.ID, id.SubscriptionID)
+		}
+	}()
 
 	_, operationCtx, err := h.parseAndPlan(msg.Payload)
 	if err != nil {
@@ -691,12 +841,18 @@ func (h *WebSocketConnectionHandler) executeSubscription(msg *wsproto.Message, i
 	if h.forwardInitialPayload && operationCtx.initialPayload != nil {
 		resolveCtx.InitialPayload = operationCtx.initialPayload
 	}
-	resolveCtx = resolveCtx.WithContext(withRequestContext(h.ctx, buildRequestContext(nil, h.r, operationCtx, h.logger)))
+	// The connection outlives the upgrade request, so only its authentication is carried over
+	ctx := h.ctx
+	if auth := authentication.FromContext(h.r.Context()); auth != nil {
+		ctx = authentication.NewContext(ctx, auth)
+	}
+	resolveCtx = resolveCtx.WithContext(withRequestContext(ctx, buildRequestContext(nil, h.r, operationCtx, h.logger)))
 	if h.graphqlHandler.authorizer != nil {
 		resolveCtx = WithAuthorizationExtension(resolveCtx)
 		resolveCtx.SetAuthorizer(h.graphqlHandler.authorizer)
 	}
 	resolveCtx = h.graphqlHandler.configureRateLimiting(resolveCtx)
+	resolveCtx = withSubscriptionCursor(resolveCtx, operationCtx.extensions)
 
 	// Put in a closure to evaluate err after the defer
 	defer func() {
@@ -716,14 +872,18 @@ func (h *WebSocketConnectionHandler) executeSubscription(msg *wsproto.Message, i
 		_ = rw.Flush()
 		rw.Complete()
 	case *plan.SubscriptionResponsePlan:
-		err = h.graphqlHandler.executor.Resolver.AsyncResolveGraphQLSubscription(resolveCtx, p.Response, rw.SubscriptionResponseWriter(), id)
+		writer, closeWriter := h.graphqlHandler.executor.subscriptionFanOut.clientWriter(rw.SubscriptionResponseWriter(), rw.logger, h.stats)
+		writer = getSubscriptionCursor(resolveCtx.Context()).writer(writer)
+		err = h.graphqlHandler.executor.Resolver.AsyncResolveGraphQLSubscription(resolveCtx, p.Response, writer, id)
 		if err != nil {
+			closeWriter()
 			h.logger.Warn("Resolving GraphQL subscription", zap.Error(err))
 			buf := pool.GetBytesBuffer()
 			defer pool.PutBytesBuffer(buf)
 			h.graphqlHandler.WriteError(resolveCtx, err, p.Response.Response, rw, buf)
 			return
 		}
+		subscribed = true
 	}
 }
 
@@ -731,12 +891,19 @@ func (h *WebSocketConnectionHandler) handleSubscribe(msg *wsproto.Message) error
 	if msg.ID == "" {
 		return fmt.Errorf("missing id in subscribe")
 	}
-	_, exists := h.subscriptions.Load(msg.ID)
-	if exists {
+	subscriptionID := h.subscriptionIDs.Inc()
+	if _, exists := h.subscriptions.LoadOrStore(msg.ID, subscriptionID); exists {
+		h.closeWithCode(wsCloseCodeSubscriberAlreadyExists, fmt.Sprintf("Subscriber for %s already exists", msg.ID))
 		return fmt.Errorf("subscription with id %q already exists", msg.ID)
 	}
-	subscriptionID := h.subscriptionIDs.Inc()
-	h.subscriptions.Store(msg.ID, subscriptionID)
+	h.limiter.subscriptionStarted()
+	if count := h.activeSubscriptions.Inc(); h.maxSubscriptions > 0 && count > int64(h.maxSubscriptions) {
+		h.releaseSubscription(msg.ID, subscriptionID)
+		h.limiter.rejected(errTooManySubscriptions)
+		h.stats.SubscriptionRejected()
+		h.closeWithCode(errTooManySubscriptions.code, errTooManySubscriptions.reason)
+		return errTooManySubscriptions
+	}
 	id := resolve.SubscriptionIdentifier{
 		ConnectionID:   h.connectionID,
 		SubscriptionID: subscriptionID,
@@ -750,11 +917,12 @@ func (h *WebSocketConnectionHandler) handleComplete(msg *wsproto.Message) error
 	if !exists {
 		return h.requestError(fmt.Errorf("no subscription was registered for ID %q", msg.ID))
 	}
-	h.subscriptions.Delete(msg.ID)
 	subscriptionID, ok := value.(int64)
 	if !ok {
+		h.subscriptions.Delete(msg.ID)
 		return h.requestError(fmt.Errorf("invalid subscription state for ID %q", msg.ID))
 	}
+	h.releaseSubscription(msg.ID, subscriptionID)
 	id := resolve.SubscriptionIdentifier{
 		ConnectionID:   h.connectionID,
 		SubscriptionID: subscriptionID,
@@ -771,7 +939,11 @@ func (h *WebsocketHandler) HandleMessage(handler *WebSocketConnectionHandler, ms
 		_ = handler.protocol.Pong(msg)
 	case wsproto.MessageTypePong:
 		// "Furthermore, the Pong message may even be sent unsolicited as a unidirectional heartbeat"
+		handler.handlePong()
 		return nil
+	case wsproto.MessageTypeInit:
+		handler.closeWithCode(wsCloseCodeTooManyInitialisationRequests, "Too many initialisation requests")
+		return fmt.Errorf("received connection_init on an initialized connection")
 	case wsproto.MessageTypeSubscribe:
 		h.handlerPool.Submit(func() {
 			err := handler.handleSubscribe(msg)
@@ -792,12 +964,31 @@ func (h *WebsocketHandler) HandleMessage(handler *WebSocketConnectionHandler, ms
 
 func (h *WebSocketConnectionHandler) Initialize() (err error) {
 	h.logger.Debug("Websocket connection", zap.String("protocol", h.protocol.Subprotocol()))
-	h.initialPayload, err = h.protocol.Initialize()
+	if h.initializationTimeout > 0 {
+		if err = h.conn.conn.SetReadDeadline(time.Now().Add(h.initializationTimeout)); err != nil {
+			return err
+		}
+	}
+	h.initialPayload, err = h.protocol.Initialize(h.authenticateInitialPayload)
 	if err != nil {
+		if errors.Is(err, wsproto.ErrInitialPayloadRejected) {
+			_ = h.conn.WriteCloseFrame(wsCloseCodeForbidden, "Forbidden")
+			return err
+		}
+		if isReadTimeout(err) {
+			_ = h.conn.WriteCloseFrame(wsCloseCodeInitializationTimeout, "Connection initialisation timeout")
+			return fmt.Errorf("%w: %w", errInitializationTimeout, err)
+		}
 		h.logger.Error("Initializing websocket connection", zap.Error(err))
 		_ = h.requestError(fmt.Errorf("error initializing session"))
 		return err
 	}
+	if auth := authentication.FromContext(h.r.Context()); auth != nil {
+		if h.authProvider == nil {
+			h.authProvider = authenticationHeaders(h.r.Header)
+		}
+		h.scheduleRevalidation(auth)
+	}
 	if h.forwardUpgradeRequestQueryParams {
 		query := h.r.URL.Query()
 		if len(query) != 0 {
@@ -825,6 +1016,167 @@ func (h *WebSocketConnectionHandler) Initialize() (err error) {
 	return nil
 }
 
+// authenticateInitialPayload authenticates the connection with the token of the initial payload
+// when enabled. A token in the payload takes precedence over the upgrade request.
+func (h *WebSocketConnectionHandler) authenticateInitialPayload(payload json.RawMessage) error {
+	if !h.initialPayloadAuthentication.Enabled || h.accessController == nil {
+		return nil
+	}
+	token, err := jsonparser.GetString(payload, h.initialPayloadAuthentication.Key)
+	if err != nil || token == "" {
+		if h.initialPayloadAuthenticationRequired {
+			return ErrUnauthorized
+		}
+		return nil
+	}
+	provider := authenticationHeaders{}
+	http.Header(provider).Set(h.initialPayloadAuthentication.HeaderName, token)
+	ctx, err := h.accessController.AccessProvider(h.r.Context(), provider)
+	if err != nil {
+		reportAuthenticationFailure(h.r.Context(), err, h.logger, h.metrics.MetricStore())
+		return err
+	}
+	h.r = h.r.WithContext(ctx)
+	h.authProvider = provider
+	return nil
+}
+
+// scheduleRevalidation authenticates the connection again when the token expires. Tokens without
+// expiration are never revalidated.
+func (h *WebSocketConnectionHandler) scheduleRevalidation(auth authentication.Authentication) {
+	expiresAt, ok := authenticationExpiry(auth)
+	if !ok || h.accessController == nil {
+		return
+	}
+	// Authenticators might accept tokens shortly after their expiration, so check again later
+	delay := time.Until(expiresAt)
+	if delay < time.Second {
+		delay = time.Second
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.revalidationTimer = time.AfterFunc(delay, h.revalidateAuthentication)
+}
+
+// revalidateAuthentication closes the connection with all of its subscriptions when its
+// authentication isn't valid anymore
+func (h *WebSocketConnectionHandler) revalidateAuthentication() {
+	ctx, err := h.accessController.AccessProvider(h.ctx, h.authProvider)
+	if err == nil {
+		if auth := authentication.FromContext(ctx); auth != nil {
+			h.scheduleRevalidation(auth)
+			return
+		}
+	}
+	reportAuthenticationFailure(h.ctx, err, h.logger, h.metrics.MetricStore())
+	h.logger.Debug("Closing websocket connection, authentication expired", zap.Int64("connection_id", h.connectionID))
+	h.closeWithCode(wsCloseCodeForbidden, "Forbidden")
+}
+
+// startKeepAlive starts the pings and the lifetime of an initialized connection
+func (h *WebSocketConnectionHandler) startKeepAlive() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	if h.pingInterval > 0 {
+		h.pingTimer = time.AfterFunc(h.pingInterval, h.ping)
+	}
+	if h.maxLifetime > 0 {
+		h.lifetimeTimer = time.AfterFunc(h.maxLifetime, func() {
+			h.logger.Debug("Closing websocket connection, lifetime exceeded", zap.Int64("connection_id", h.connectionID))
+			h.closeWithCode(ws.StatusGoingAway, "Connection lifetime exceeded")
+		})
+	}
+}
+
+func (h *WebSocketConnectionHandler) ping() {
+	awaitPong, err := h.protocol.Ping()
+	if err != nil {
+		// The read loop notices the broken connection
+		h.logger.Debug("Sending websocket ping", zap.Error(err))
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	// The timeout runs from the first unanswered ping
+	if awaitPong && h.pongTimeout > 0 && h.pongTimer == nil {
+		h.pongTimer = time.AfterFunc(h.pongTimeout, func() {
+			h.logger.Debug("Closing websocket connection, pong timeout", zap.Int64("connection_id", h.connectionID))
+			h.closeWithCode(ws.StatusGoingAway, "Pong timeout")
+		})
+	}
+	h.pingTimer.Reset(h.pingInterval)
+}
+
+func (h *WebSocketConnectionHandler) handlePong() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.pongTimer != nil {
+		h.pongTimer.Stop()
+		h.pongTimer = nil
+	}
+}
+
+func (h *WebSocketConnectionHandler) setRemove(remove func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove = remove
+}
+
+// releaseSubscription removes a subscription from the active subscriptions. The ID might have been
+// reused by a new subscription, so only the subscription of the identifier is removed.
+func (h *WebSocketConnectionHandler) releaseSubscription(id string, subscriptionID int64) {
+	if h.subscriptions.CompareAndDelete(id, subscriptionID) {
+		h.activeSubscriptions.Dec()
+		h.limiter.subscriptionDone()
+	}
+}
+
+// closeWithCode sends a close frame and closes the connection with all of its subscriptions
+func (h *WebSocketConnectionHandler) closeWithCode(code ws.StatusCode, reason string) {
+	_ = h.conn.WriteCloseFrame(code, reason)
+	h.mu.Lock()
+	remove := h.remove
+	h.mu.Unlock()
+	if remove != nil {
+		remove()
+		return
+	}
+	h.Close()
+}
+
+// authenticationExpiry returns the time of the exp claim
+func authenticationExpiry(auth authentication.Authentication) (time.Time, bool) {
+	switch exp := auth.Claims()["exp"].(type) {
+	case float64:
+		return time.Unix(int64(exp), 0), true
+	case int64:
+		return time.Unix(exp, 0), true
+	case json.Number:
+		value, err := exp.Int64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(value, 0), true
+	}
+	return time.Time{}, false
+}
+
+// authenticationHeaders provides the authentication information of a WebSocket connection
+type authenticationHeaders http.Header
+
+func (a authenticationHeaders) AuthenticationHeaders() http.Header {
+	return http.Header(a)
+}
+
 func (h *WebSocketConnectionHandler) ignoreHeader(k string) bool {
 	switch k {
 	case "Sec-Websocket-Protocol",
@@ -854,6 +1206,27 @@ func (h *WebSocketConnectionHandler) Complete(rw *websocketResponseWriter) {
 }
 
 func (h *WebSocketConnectionHandler) Close() {
+	h.mu.Lock()
+	h.closed = true
+	for _, timer := range []*time.Timer{h.revalidationTimer, h.pingTimer, h.pongTimer, h.lifetimeTimer} {
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+	releaseConnection := h.releaseConnection
+	h.releaseConnection = nil
+	h.mu.Unlock()
+
+	if releaseConnection != nil {
+		releaseConnection()
+	}
+	h.subscriptions.Range(func(key, value any) bool {
+		if subscriptionID, ok := value.(int64); ok {
+			h.releaseSubscription(key.(string), subscriptionID)
+		}
+		return true
+	})
+
 	// Remove any pending IDs associated with this connection
 	err := h.graphqlHandler.executor.Resolver.AsyncUnsubscribeClient(h.connectionID)
 	if err != nil {
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gobwas/ws"
+	"go.uber.org/atomic"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	rmetric "github.com/wundergraph/cosmo/router/pkg/metric"
+	ctrace "github.com/wundergraph/cosmo/router/pkg/trace"
+)
+
+// websocketLimitError rejects a connection or a subscription over a limit. The connection is
+// closed with the code and the reason.
+type websocketLimitError struct {
+	code   ws.StatusCode
+	reason string
+	// limit is reported as the rejection reason of the metrics
+	limit string
+}
+
+func (e *websocketLimitError) Error() string {
+	return strings.ToLower(e.reason)
+}
+
+var (
+	errTooManyConnections = &websocketLimitError{
+		code:   wsCloseCodeTryAgainLater,
+		reason: "Too many connections",
+		limit:  "max_connections",
+	}
+	errTooManyClientConnections = &websocketLimitError{
+		code:   ws.StatusPolicyViolation,
+		reason: "Too many connections of the client",
+		limit:  "max_connections_per_client",
+	}
+	errTooManySubscriptions = &websocketLimitError{
+		code:   ws.StatusPolicyViolation,
+		reason: "Too many subscriptions",
+		limit:  "max_subscriptions_per_connection",
+	}
+)
+
+// websocketLimiter enforces the connection limits and counts the open connections and subscriptions.
+// It belongs to the router, so the connections of servers replaced by a config update keep counting.
+type websocketLimiter struct {
+	maxConnections          int
+	maxConnectionsPerClient int
+	clientIdentifier        config.WebSocketClientIdentifier
+	// metrics is nil when metrics are disabled
+	metrics *rmetric.WebSocketMetrics
+
+	mu          sync.Mutex
+	connections int
+	// clients counts the connections per client when the per client limit is enabled
+	clients map[string]int
+
+	subscriptions atomic.Int64
+}
+
+func newWebSocketLimiter(cfg *config.WebSocketConfiguration) *websocketLimiter {
+	l := &websocketLimiter{
+		clients: make(map[string]int),
+	}
+	if cfg != nil {
+		l.maxConnections = cfg.Limits.MaxConnections
+		l.maxConnectionsPerClient = cfg.Limits.MaxConnectionsPerClient
+		l.clientIdentifier = cfg.Limits.ClientIdentifier
+	}
+	return l
+}
+
+// clientKey identifies the client of an upgrade request for the per client limit
+func (l *websocketLimiter) clientKey(r *http.Request) string {
+	if l.clientIdentifier == config.WebSocketClientIdentifierClientName {
+		if name := ctrace.GetClientInfo(r.Header, "graphql-client-name", "apollographql-client-name", ""); name != "" {
+			return "client:" + name
+		}
+	}
+	// The RealIP middleware replaces the remote address with the address of the forwarded headers
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// acquireConnection counts a new connection of the client. The returned function releases it
+// and must be called exactly once when the connection is closed.
+func (l *websocketLimiter) acquireConnection(client string) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxConnections > 0 && l.connections >= l.maxConnections {
+		l.rejected(errTooManyConnections)
+		return nil, errTooManyConnections
+	}
+	if l.maxConnectionsPerClient > 0 {
+		if l.clients[client] >= l.maxConnectionsPerClient {
+			l.rejected(errTooManyClientConnections)
+			return nil, errTooManyClientConnections
+		}
+		l.clients[client]++
+	}
+	l.connections++
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.connections--
+		if l.maxConnectionsPerClient > 0 {
+			if l.clients[client] <= 1 {
+				delete(l.clients, client)
+			} else {
+				l.clients[client]--
+			}
+		}
+	}, nil
+}
+
+func (l *websocketLimiter) rejected(err *websocketLimitError) {
+	if l == nil || l.metrics == nil {
+		return
+	}
+	l.metrics.Rejected(err.limit)
+}
+
+func (l *websocketLimiter) subscriptionStarted() {
+	if l == nil {
+		return
+	}
+	l.subscriptions.Inc()
+}
+
+func (l *websocketLimiter) subscriptionDone() {
+	if l == nil {
+		return
+	}
+	l.subscriptions.Dec()
+}
+
+// counts returns the current counts for the metrics
+func (l *websocketLimiter) counts() rmetric.WebSocketCounts {
+	l.mu.Lock()
+	connections := l.connections
+	l.mu.Unlock()
+	return rmetric.WebSocketCounts{
+		Connections:   int64(connections),
+		Subscriptions: l.subscriptions.Load(),
+	}
+}
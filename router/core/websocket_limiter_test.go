@@ -0,0 +1,65 @@
+package core
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestWebSocketLimiterConnections(t *testing.T) {
+	t.Parallel()
+
+	l := newWebSocketLimiter(&config.WebSocketConfiguration{
+		Limits: config.WebSocketLimitsConfiguration{
+			MaxConnections:          3,
+			MaxConnectionsPerClient: 2,
+		},
+	})
+
+	releaseA1, err := l.acquireConnection("a")
+	require.NoError(t, err)
+	_, err = l.acquireConnection("a")
+	require.NoError(t, err)
+	_, err = l.acquireConnection("a")
+	require.ErrorIs(t, err, errTooManyClientConnections)
+
+	_, err = l.acquireConnection("b")
+	require.NoError(t, err)
+	_, err = l.acquireConnection("c")
+	require.ErrorIs(t, err, errTooManyConnections)
+	require.Equal(t, int64(3), l.counts().Connections)
+
+	// A released connection makes room for the client and the router
+	releaseA1()
+	_, err = l.acquireConnection("c")
+	require.NoError(t, err)
+	_, err = l.acquireConnection("a")
+	require.ErrorIs(t, err, errTooManyConnections)
+	require.Equal(t, int64(3), l.counts().Connections)
+}
+
+func TestWebSocketLimiterClientKey(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest("GET", "/graphql", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("graphql-client-name", "web")
+
+	byIP := newWebSocketLimiter(&config.WebSocketConfiguration{})
+	require.Equal(t, "ip:192.0.2.1", byIP.clientKey(r))
+
+	byName := newWebSocketLimiter(&config.WebSocketConfiguration{
+		Limits: config.WebSocketLimitsConfiguration{
+			ClientIdentifier: config.WebSocketClientIdentifierClientName,
+		},
+	})
+	require.Equal(t, "client:web", byName.clientKey(r))
+
+	// Clients without a name fall back to their IP address
+	r.Header.Del("graphql-client-name")
+	r.RemoteAddr = "192.0.2.2"
+	require.Equal(t, "ip:192.0.2.2", byName.clientKey(r))
+}
@@ -13,8 +13,11 @@ type WebSocketsStatistics interface {
 	Subscribe(ctx context.Context) chan *UsageReport
 	GetReport() *UsageReport
 	SubscriptionUpdateSent()
+	SubscriptionUpdateDropped()
 	ConnectionsInc()
 	ConnectionsDec()
+	ConnectionRejected()
+	SubscriptionRejected()
 	SubscriptionCountInc(count int)
 	SubscriptionCountDec(count int)
 	TriggerCountInc(count int)
@@ -28,16 +31,24 @@ type WebSocketStats struct {
 	connections   atomic.Uint64
 	subscriptions atomic.Uint64
 	messagesSent  atomic.Uint64
+	messagesDrop  atomic.Uint64
 	triggers      atomic.Uint64
+	// rejectedConns and rejectedSubs count the connections and subscriptions over the limits
+	rejectedConns atomic.Uint64
+	rejectedSubs  atomic.Uint64
 	update        chan struct{}
 	subscribers   map[context.Context]chan *UsageReport
 }
 
 type UsageReport struct {
-	Connections   uint64
-	Subscriptions uint64
-	MessagesSent  uint64
-	Triggers      uint64
+	Connections     uint64
+	Subscriptions   uint64
+	MessagesSent    uint64
+	MessagesDropped uint64
+	Triggers        uint64
+	// ConnectionsRejected and SubscriptionsRejected count the rejections of the limits
+	ConnectionsRejected   uint64
+	SubscriptionsRejected uint64
 }
 
 func NewWebSocketStats(ctx context.Context, logger *zap.Logger) *WebSocketStats {
@@ -63,10 +74,14 @@ func (s *WebSocketStats) Subscribe(ctx context.Context) chan *UsageReport {
 
 func (s *WebSocketStats) GetReport() *UsageReport {
 	report := &UsageReport{
-		Connections:   s.connections.Load(),
-		Subscriptions: s.subscriptions.Load(),
-		MessagesSent:  s.messagesSent.Load(),
-		Triggers:      s.triggers.Load(),
+		Connections:     s.connections.Load(),
+		Subscriptions:   s.subscriptions.Load(),
+		MessagesSent:    s.messagesSent.Load(),
+		MessagesDropped: s.messagesDrop.Load(),
+		Triggers:        s.triggers.Load(),
+
+		ConnectionsRejected:   s.rejectedConns.Load(),
+		SubscriptionsRejected: s.rejectedSubs.Load(),
 	}
 	return report
 }
@@ -102,6 +117,8 @@ func (s *WebSocketStats) reportConnections() {
 	s.logger.Info("WebSocket Stats",
 		zap.Uint64("open_connections", s.connections.Load()),
 		zap.Uint64("active_subscriptions", s.subscriptions.Load()),
+		zap.Uint64("rejected_connections", s.rejectedConns.Load()),
+		zap.Uint64("rejected_subscriptions", s.rejectedSubs.Load()),
 	)
 }
 
@@ -114,6 +131,11 @@ func (s *WebSocketStats) SubscriptionUpdateSent() {
 	s.publish()
 }
 
+func (s *WebSocketStats) SubscriptionUpdateDropped() {
+	s.messagesDrop.Inc()
+	s.publish()
+}
+
 func (s *WebSocketStats) ConnectionsInc() {
 	s.connections.Inc()
 	s.publish()
@@ -124,6 +146,16 @@ func (s *WebSocketStats) ConnectionsDec() {
 	s.publish()
 }
 
+func (s *WebSocketStats) ConnectionRejected() {
+	s.rejectedConns.Inc()
+	s.publish()
+}
+
+func (s *WebSocketStats) SubscriptionRejected() {
+	s.rejectedSubs.Inc()
+	s.publish()
+}
+
 func (s *WebSocketStats) SubscriptionCountInc(count int) {
 	s.subscriptions.Add(uint64(count))
 	s.publish()
@@ -160,10 +192,16 @@ func (s *NoopWebSocketStats) GetReport() *UsageReport {
 
 func (s *NoopWebSocketStats) SubscriptionUpdateSent() {}
 
+func (s *NoopWebSocketStats) SubscriptionUpdateDropped() {}
+
 func (s *NoopWebSocketStats) ConnectionsInc() {}
 
 func (s *NoopWebSocketStats) ConnectionsDec() {}
 
+func (s *NoopWebSocketStats) ConnectionRejected() {}
+
+func (s *NoopWebSocketStats) SubscriptionRejected() {}
+
 func (s *NoopWebSocketStats) SubscriptionCountInc(_ int) {}
 
 func (s *NoopWebSocketStats) SubscriptionCountDec(_ int) {}
@@ -0,0 +1,252 @@
+package clienttls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultCheckInterval = 5 * time.Second
+
+type Options struct {
+	// CAFile is a PEM bundle of the authorities used to verify the upstream. Empty means the system pool.
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// CertPEM and KeyPEM are used when no cert and key files are configured
+	CertPEM []byte
+	KeyPEM  []byte
+	// ServerName overrides the name used for SNI and certificate verification
+	ServerName         string
+	MinVersion         uint16
+	InsecureSkipVerify bool
+	// HandshakeTimeout bounds the TLS handshake. Zero means no timeout
+	HandshakeTimeout time.Duration
+	// CheckInterval is the minimum time between two checks of the files for changes
+	CheckInterval time.Duration
+	Logger        *zap.Logger
+}
+
+// Loader creates client TLS configurations for upstream connections. Certificate and CA files
+// are checked for changes at most every CheckInterval and reloaded when they were modified.
+// When a reload fails the previous certificates are kept.
+type Loader struct {
+	opts Options
+
+	// mu guards the certificates
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	rootCAs *x509.CertPool
+
+	// lastCheck is the unix time in nanoseconds of the last check. It is read on every dial,
+	// so the files are only checked once per interval without taking a lock.
+	lastCheck atomic.Int64
+	// checkMu serializes the checks and guards the modification times of the files
+	checkMu  sync.Mutex
+	modTimes map[string]time.Time
+}
+
+func New(opts Options) (*Loader, error) {
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, errors.New("tls client cert file and key file must be provided together")
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = defaultCheckInterval
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+
+	l := &Loader{
+		opts: opts,
+	}
+
+	cert, rootCAs, modTimes, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	l.cert, l.rootCAs, l.modTimes = cert, rootCAs, modTimes
+	l.lastCheck.Store(time.Now().UnixNano())
+
+	return l, nil
+}
+
+// ParseVersion converts a TLS version such as "1.2" to its crypto/tls constant. An empty version returns 0.
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported tls version %q", version)
+	}
+}
+
+// Config returns the TLS configuration for a connection to host with the current certificates.
+func (l *Loader) Config(host string) *tls.Config {
+	l.reloadIfChanged()
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	cfg := &tls.Config{
+		ServerName:         host,
+		MinVersion:         l.opts.MinVersion,
+		RootCAs:            l.rootCAs,
+		InsecureSkipVerify: l.opts.InsecureSkipVerify,
+		NextProtos:         []string{"h2", "http/1.1"},
+	}
+
+	if l.opts.ServerName != "" {
+		cfg.ServerName = l.opts.ServerName
+	}
+
+	if l.cert != nil {
+		cfg.Certificates = []tls.Certificate{*l.cert}
+	}
+
+	return cfg
+}
+
+// DialTLSContext returns a dial function for http.Transport that establishes a TLS connection over the
+// connection returned by dial.
+func (l *Loader) DialTLSContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if l.opts.HandshakeTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, l.opts.HandshakeTimeout)
+			defer cancel()
+		}
+
+		tlsConn := tls.Client(conn, l.Config(host))
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+
+		return tlsConn, nil
+	}
+}
+
+func (l *Loader) files() []string {
+	var files []string
+	for _, f := range []string{l.opts.CAFile, l.opts.CertFile, l.opts.KeyFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func (l *Loader) reloadIfChanged() {
+	if time.Since(time.Unix(0, l.lastCheck.Load())) < l.opts.CheckInterval {
+		return
+	}
+	// Another dial is already checking the files
+	if !l.checkMu.TryLock() {
+		return
+	}
+	defer l.checkMu.Unlock()
+
+	if time.Since(time.Unix(0, l.lastCheck.Load())) < l.opts.CheckInterval {
+		return
+	}
+	l.lastCheck.Store(time.Now().UnixNano())
+
+	changed := false
+	for _, f := range l.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			l.opts.Logger.Error("Failed to check tls client file for changes", zap.String("file", f), zap.Error(err))
+			return
+		}
+		if !info.ModTime().Equal(l.modTimes[f]) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return
+	}
+
+	cert, rootCAs, modTimes, err := l.load()
+	if err != nil {
+		l.opts.Logger.Error("Failed to reload tls client certificates. Keeping the previous ones", zap.Error(err))
+		return
+	}
+
+	l.mu.Lock()
+	l.cert, l.rootCAs = cert, rootCAs
+	l.mu.Unlock()
+	l.modTimes = modTimes
+
+	l.opts.Logger.Info("Reloaded tls client certificates")
+}
+
+// load reads the certificates and the modification times of their files
+func (l *Loader) load() (*tls.Certificate, *x509.CertPool, map[string]time.Time, error) {
+	modTimes := map[string]time.Time{}
+	for _, f := range l.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to stat tls client file: %w", err)
+		}
+		modTimes[f] = info.ModTime()
+	}
+
+	var rootCAs *x509.CertPool
+	if l.opts.CAFile != "" {
+		caCert, err := os.ReadFile(l.opts.CAFile)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to read ca file: %w", err)
+		}
+		rootCAs = x509.NewCertPool()
+		if ok := rootCAs.AppendCertsFromPEM(caCert); !ok {
+			return nil, nil, nil, errors.New("failed to append ca file to pool")
+		}
+	}
+
+	var cert *tls.Certificate
+	switch {
+	case l.opts.CertFile != "":
+		c, err := tls.LoadX509KeyPair(l.opts.CertFile, l.opts.KeyFile)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to load tls client cert and key: %w", err)
+		}
+		cert = &c
+	case len(l.opts.CertPEM) > 0:
+		c, err := tls.X509KeyPair(l.opts.CertPEM, l.opts.KeyPEM)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to parse tls client cert and key: %w", err)
+		}
+		cert = &c
+	}
+
+	return cert, rootCAs, modTimes, nil
+}
@@ -0,0 +1,284 @@
+package clienttls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type certificate struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newCertificate(t *testing.T, cn string, parent *certificate, isCA bool) *certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{cn},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:         isCA,
+
+		BasicConstraintsValid: true,
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &certificate{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+// newMTLSServer starts a server that requires a client certificate signed by clientCA
+func newMTLSServer(t *testing.T, serverCert *certificate, clientCA *certificate) *httptest.Server {
+	t.Helper()
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.cert)
+
+	tlsCert, err := tls.X509KeyPair(serverCert.certPEM, serverCert.keyPEM)
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{tlsCert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func newClient(l *Loader) *http.Client {
+	dialer := &net.Dialer{}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialTLSContext: l.DialTLSContext(dialer.DialContext),
+		},
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newCertificate(t, "ca", nil, true)
+	serverCert := newCertificate(t, "products.internal", ca, false)
+	clientCert := newCertificate(t, "router", ca, false)
+
+	srv := newMTLSServer(t, serverCert, ca)
+
+	dir := t.TempDir()
+	now := time.Now()
+	writeFile(t, filepath.Join(dir, "ca.pem"), ca.certPEM, now)
+	writeFile(t, filepath.Join(dir, "cert.pem"), clientCert.certPEM, now)
+	writeFile(t, filepath.Join(dir, "key.pem"), clientCert.keyPEM, now)
+
+	l, err := New(Options{
+		CAFile:     filepath.Join(dir, "ca.pem"),
+		CertFile:   filepath.Join(dir, "cert.pem"),
+		KeyFile:    filepath.Join(dir, "key.pem"),
+		ServerName: "products.internal",
+		MinVersion: tls.VersionTLS12,
+	})
+	require.NoError(t, err)
+
+	resp, err := newClient(l).Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestUntrustedServer(t *testing.T) {
+	ca := newCertificate(t, "ca", nil, true)
+	otherCA := newCertificate(t, "other-ca", nil, true)
+	serverCert := newCertificate(t, "products.internal", otherCA, false)
+	clientCert := newCertificate(t, "router", ca, false)
+
+	srv := newMTLSServer(t, serverCert, ca)
+
+	l, err := New(Options{
+		CAFile:  writeTemp(t, ca.certPEM),
+		CertPEM: clientCert.certPEM,
+		KeyPEM:  clientCert.keyPEM,
+	})
+	require.NoError(t, err)
+
+	_, err = newClient(l).Get(srv.URL)
+	assert.Error(t, err)
+}
+
+func TestReloadOnFileChange(t *testing.T) {
+	ca := newCertificate(t, "ca", nil, true)
+	serverCert := newCertificate(t, "products.internal", ca, false)
+	first := newCertificate(t, "first", ca, false)
+	second := newCertificate(t, "second", ca, false)
+
+	srv := newMTLSServer(t, serverCert, ca)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	created := time.Now().Add(-time.Minute)
+	writeFile(t, certFile, first.certPEM, created)
+	writeFile(t, keyFile, first.keyPEM, created)
+
+	l, err := New(Options{
+		CAFile:        writeTemp(t, ca.certPEM),
+		CertFile:      certFile,
+		KeyFile:       keyFile,
+		ServerName:    "products.internal",
+		CheckInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	get := func() string {
+		// A new client per request to force a new handshake
+		resp, err := newClient(l).Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		buf := make([]byte, 16)
+		n, _ := resp.Body.Read(buf)
+		return string(buf[:n])
+	}
+
+	assert.Equal(t, "first", get())
+
+	writeFile(t, certFile, second.certPEM, time.Now())
+	writeFile(t, keyFile, second.keyPEM, time.Now())
+	time.Sleep(5 * time.Millisecond)
+
+	assert.Equal(t, "second", get())
+
+	// A broken file keeps the previous certificate
+	writeFile(t, certFile, []byte("broken"), time.Now().Add(time.Minute))
+	time.Sleep(5 * time.Millisecond)
+
+	assert.Equal(t, "second", get())
+}
+
+func TestReloadConcurrently(t *testing.T) {
+	ca := newCertificate(t, "ca", nil, true)
+	first := newCertificate(t, "first", ca, false)
+	second := newCertificate(t, "second", ca, false)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	created := time.Now().Add(-time.Minute)
+	writeFile(t, certFile, first.certPEM, created)
+	writeFile(t, keyFile, first.keyPEM, created)
+
+	l, err := New(Options{
+		CertFile:      certFile,
+		KeyFile:       keyFile,
+		CheckInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	commonName := func() string {
+		cfg := l.Config("products.internal")
+		leaf, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+		require.NoError(t, err)
+		return leaf.Subject.CommonName
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				assert.Contains(t, []string{"first", "second"}, commonName())
+			}
+		}()
+	}
+
+	writeFile(t, certFile, second.certPEM, time.Now())
+	writeFile(t, keyFile, second.keyPEM, time.Now())
+
+	assert.Eventually(t, func() bool {
+		return commonName() == "second"
+	}, 5*time.Second, time.Millisecond)
+
+	close(done)
+	wg.Wait()
+}
+
+func TestParseVersion(t *testing.T) {
+	v, err := ParseVersion("1.3")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), v)
+
+	v, err = ParseVersion("")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0), v)
+
+	_, err = ParseVersion("1.4")
+	assert.Error(t, err)
+}
+
+func writeTemp(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file.pem")
+	writeFile(t, path, data, time.Now())
+	return path
+}
@@ -0,0 +1,206 @@
+package hedgetransport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Policy describes when a hedged request is sent for a request.
+type Policy struct {
+	// Key identifies the latency distribution the request belongs to, e.g. the subgraph name
+	Key string
+	// Percentile of the observed latencies after which the hedged request is sent, e.g. 95
+	Percentile float64
+	// MinDelay is the lower bound of the hedging delay
+	MinDelay time.Duration
+	// MaxDelay is the upper bound of the hedging delay. Zero means no upper bound
+	MaxDelay time.Duration
+}
+
+type HedgeOptions struct {
+	Enabled bool
+	// Policy returns the hedging policy of the request. Returning nil disables hedging for the request.
+	Policy func(req *http.Request) *Policy
+	// OnHedge is called when the hedged request is sent
+	OnHedge func(req *http.Request, delay time.Duration)
+	// OnHedgeCompleted is called when a hedged race is decided. won is true when the hedged request answered first.
+	OnHedgeCompleted func(req *http.Request, won bool)
+}
+
+// HedgeHTTPTransport sends a second request when the first one hasn't answered within a delay
+// derived from the latencies observed for the same key. The first response wins and the other
+// request is cancelled. Only use it for idempotent requests.
+type HedgeHTTPTransport struct {
+	RoundTripper http.RoundTripper
+	HedgeOptions HedgeOptions
+	Logger       *zap.Logger
+
+	mu        sync.Mutex
+	latencies map[string]*latencyWindow
+}
+
+func NewHedgeHTTPTransport(roundTripper http.RoundTripper, hedgeOptions HedgeOptions, logger *zap.Logger) *HedgeHTTPTransport {
+	return &HedgeHTTPTransport{
+		RoundTripper: roundTripper,
+		HedgeOptions: hedgeOptions,
+		Logger:       logger,
+		latencies:    map[string]*latencyWindow{},
+	}
+}
+
+type attempt struct {
+	resp   *http.Response
+	err    error
+	hedged bool
+	start  time.Time
+	cancel context.CancelFunc
+}
+
+func (ht *HedgeHTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var policy *Policy
+	if ht.HedgeOptions.Policy != nil {
+		policy = ht.HedgeOptions.Policy(req)
+	}
+
+	// The body of the hedged request can only be created if the request allows it
+	if policy == nil || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return ht.RoundTripper.RoundTrip(req)
+	}
+
+	window := ht.window(policy.Key)
+
+	delay, ok := window.delay(policy)
+	if !ok {
+		// Not enough samples to derive a delay yet
+		start := time.Now()
+		resp, err := ht.RoundTripper.RoundTrip(req)
+		if err == nil {
+			window.record(time.Since(start))
+		}
+		return resp, err
+	}
+
+	results := make(chan *attempt, 2)
+	var attempts []*attempt
+
+	send := func(r *http.Request, hedged bool) {
+		ctx, cancel := context.WithCancel(r.Context())
+		a := &attempt{
+			hedged: hedged,
+			start:  time.Now(),
+			cancel: cancel,
+		}
+		attempts = append(attempts, a)
+		go func() {
+			a.resp, a.err = ht.RoundTripper.RoundTrip(r.WithContext(ctx))
+			results <- a
+		}()
+	}
+
+	send(req, false)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	hedgeTimer := timer.C
+	inFlight := 1
+
+	for {
+		select {
+		case <-hedgeTimer:
+			hedgeTimer = nil
+
+			hedgeReq := req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					continue
+				}
+				hedgeReq.Body = body
+			}
+
+			inFlight++
+			send(hedgeReq, true)
+
+			ht.Logger.Debug("Sending hedged request",
+				zap.String("url", req.URL.String()),
+				zap.Duration("delay", delay),
+			)
+
+			if ht.HedgeOptions.OnHedge != nil {
+				ht.HedgeOptions.OnHedge(req, delay)
+			}
+		case a := <-results:
+			inFlight--
+
+			// Wait for the other request if this one failed, it might still succeed
+			if a.err != nil && inFlight > 0 {
+				a.cancel()
+				continue
+			}
+
+			if a.err == nil {
+				window.record(time.Since(a.start))
+				// Cancel the request context when the body is closed
+				a.resp.Body = &cancelOnCloseBody{ReadCloser: a.resp.Body, cancel: a.cancel}
+			} else {
+				a.cancel()
+			}
+
+			// Cancel the request that lost the race
+			if inFlight > 0 {
+				for _, other := range attempts {
+					if other != a {
+						other.cancel()
+					}
+				}
+				go drain(results, inFlight)
+			}
+
+			if len(attempts) > 1 && ht.HedgeOptions.OnHedgeCompleted != nil {
+				ht.HedgeOptions.OnHedgeCompleted(req, a.err == nil && a.hedged)
+			}
+
+			return a.resp, a.err
+		}
+	}
+}
+
+// drain releases the connections of the requests that lost the race.
+func drain(results chan *attempt, inFlight int) {
+	for i := 0; i < inFlight; i++ {
+		a := <-results
+		if a.resp != nil && a.resp.Body != nil {
+			_ = a.resp.Body.Close()
+		}
+	}
+}
+
+func (ht *HedgeHTTPTransport) window(key string) *latencyWindow {
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+
+	w, ok := ht.latencies[key]
+	if !ok {
+		w = newLatencyWindow(defaultWindowSize)
+		ht.latencies[key] = w
+	}
+
+	return w
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,191 @@
+package hedgetransport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type MockTransport struct {
+	handler func(req *http.Request) (*http.Response, error)
+}
+
+func (dt *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return dt.handler(req)
+}
+
+func warmUp(tr *HedgeHTTPTransport, key string, latency time.Duration) {
+	w := tr.window(key)
+	for i := 0; i < minSamples; i++ {
+		w.record(latency)
+	}
+}
+
+func TestHedgedRequestWins(t *testing.T) {
+
+	var calls atomic.Int32
+	var hedged, won atomic.Bool
+
+	tr := NewHedgeHTTPTransport(&MockTransport{
+		handler: func(req *http.Request) (*http.Response, error) {
+			if calls.Add(1) == 1 {
+				// The first request hangs until it is cancelled
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader("hedged")),
+			}, nil
+		},
+	}, HedgeOptions{
+		Enabled: true,
+		Policy: func(req *http.Request) *Policy {
+			return &Policy{Key: "products", Percentile: 95, MaxDelay: time.Second}
+		},
+		OnHedge: func(req *http.Request, delay time.Duration) {
+			hedged.Store(true)
+		},
+		OnHedgeCompleted: func(req *http.Request, hedgeWon bool) {
+			won.Store(hedgeWon)
+		},
+	}, zap.NewNop())
+
+	warmUp(tr, "products", 5*time.Millisecond)
+
+	req := httptest.NewRequest("POST", "http://localhost:3000/graphql", strings.NewReader(`{"query":"{a}"}`))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`{"query":"{a}"}`)), nil
+	}
+
+	resp, err := tr.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "hedged", string(body))
+	assert.Nil(t, resp.Body.Close())
+
+	assert.Equal(t, int32(2), calls.Load())
+	assert.True(t, hedged.Load())
+	assert.True(t, won.Load())
+}
+
+func TestNoHedgeWhenPrimaryIsFast(t *testing.T) {
+
+	var calls atomic.Int32
+	var hedged atomic.Bool
+
+	tr := NewHedgeHTTPTransport(&MockTransport{
+		handler: func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader("primary")),
+			}, nil
+		},
+	}, HedgeOptions{
+		Enabled: true,
+		Policy: func(req *http.Request) *Policy {
+			return &Policy{Key: "products", Percentile: 95, MinDelay: time.Second}
+		},
+		OnHedge: func(req *http.Request, delay time.Duration) {
+			hedged.Store(true)
+		},
+	}, zap.NewNop())
+
+	warmUp(tr, "products", time.Millisecond)
+
+	req := httptest.NewRequest("GET", "http://localhost:3000/graphql", nil)
+
+	resp, err := tr.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.False(t, hedged.Load())
+}
+
+func TestNoHedgeWithoutSamples(t *testing.T) {
+
+	var calls atomic.Int32
+
+	tr := NewHedgeHTTPTransport(&MockTransport{
+		handler: func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			time.Sleep(10 * time.Millisecond)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader("primary")),
+			}, nil
+		},
+	}, HedgeOptions{
+		Enabled: true,
+		Policy: func(req *http.Request) *Policy {
+			return &Policy{Key: "products", Percentile: 95}
+		},
+	}, zap.NewNop())
+
+	req := httptest.NewRequest("GET", "http://localhost:3000/graphql", nil)
+
+	resp, err := tr.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, 1, tr.window("products").count)
+}
+
+func TestNoHedgeWithoutPolicy(t *testing.T) {
+
+	var calls atomic.Int32
+
+	tr := NewHedgeHTTPTransport(&MockTransport{
+		handler: func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			time.Sleep(10 * time.Millisecond)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader("primary")),
+			}, nil
+		},
+	}, HedgeOptions{
+		Enabled: true,
+		Policy: func(req *http.Request) *Policy {
+			return nil
+		},
+	}, zap.NewNop())
+
+	warmUp(tr, "products", time.Millisecond)
+
+	req := httptest.NewRequest("GET", "http://localhost:3000/graphql", nil)
+
+	_, err := tr.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestPercentile(t *testing.T) {
+	w := newLatencyWindow(100)
+	for i := 1; i <= 100; i++ {
+		w.record(time.Duration(i) * time.Millisecond)
+	}
+
+	d, ok := w.delay(&Policy{Percentile: 95})
+	assert.True(t, ok)
+	assert.Equal(t, 95*time.Millisecond, d)
+
+	d, ok = w.delay(&Policy{Percentile: 50, MinDelay: 80 * time.Millisecond})
+	assert.True(t, ok)
+	assert.Equal(t, 80*time.Millisecond, d)
+
+	d, ok = w.delay(&Policy{Percentile: 99, MaxDelay: 20 * time.Millisecond})
+	assert.True(t, ok)
+	assert.Equal(t, 20*time.Millisecond, d)
+}
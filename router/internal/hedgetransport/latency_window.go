@@ -0,0 +1,89 @@
+package hedgetransport
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWindowSize = 1000
+	// minSamples is the number of latencies that have to be observed before requests are hedged
+	minSamples = 20
+	// recomputeEvery controls how many new samples are recorded before the percentiles are recomputed
+	recomputeEvery = 20
+)
+
+// latencyWindow keeps the most recent latencies in a ring buffer and computes percentiles over them.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	count   int
+
+	sorted []time.Duration
+	dirty  int
+}
+
+func newLatencyWindow(size int) *latencyWindow {
+	return &latencyWindow{
+		samples: make([]time.Duration, size),
+	}
+}
+
+func (w *latencyWindow) record(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % len(w.samples)
+	if w.count < len(w.samples) {
+		w.count++
+	}
+	w.dirty++
+}
+
+// delay returns the hedging delay of the policy. It returns false until enough latencies were observed.
+func (w *latencyWindow) delay(policy *Policy) (time.Duration, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.count < minSamples {
+		return 0, false
+	}
+
+	if w.sorted == nil || w.dirty >= recomputeEvery {
+		w.sorted = append(w.sorted[:0], w.samples[:w.count]...)
+		sort.Slice(w.sorted, func(i, j int) bool {
+			return w.sorted[i] < w.sorted[j]
+		})
+		w.dirty = 0
+	}
+
+	d := percentile(w.sorted, policy.Percentile)
+
+	if d < policy.MinDelay {
+		d = policy.MinDelay
+	}
+	if policy.MaxDelay > 0 && d > policy.MaxDelay {
+		d = policy.MaxDelay
+	}
+
+	return d, true
+}
+
+// percentile returns the nearest-rank percentile p (0-100) of the sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if p <= 0 {
+		return sorted[0]
+	}
+	if p >= 100 {
+		return sorted[len(sorted)-1]
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
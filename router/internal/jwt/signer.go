@@ -0,0 +1,168 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signer signs tokens with a private key and publishes the public key as JSON Web Key Set
+type Signer struct {
+	key    crypto.Signer
+	method jwt.SigningMethod
+	keyID  string
+	jwk    map[string]string
+}
+
+// NewSignerFromFile loads a PEM encoded RSA or ECDSA private key. The key ID defaults to the
+// RFC 7638 thumbprint of the public key.
+func NewSignerFromFile(keyFile, keyID string) (*Signer, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode signing key, no PEM block found")
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing key type %T", key)
+	}
+
+	return NewSigner(signer, keyID)
+}
+
+// NewEphemeralSigner generates an ECDSA P-256 key. Tokens signed by a previous process can't be
+// verified anymore, so it should only be used when tokens are short-lived.
+func NewEphemeralSigner() (*Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return NewSigner(key, "")
+}
+
+func NewSigner(key crypto.Signer, keyID string) (*Signer, error) {
+	s := &Signer{key: key}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("rsa signing key must have at least 2048 bits")
+		}
+		s.method = jwt.SigningMethodRS256
+		s.jwk = map[string]string{
+			"kty": "RSA",
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+		}
+	case *ecdsa.PrivateKey:
+		var crv string
+		switch k.Curve {
+		case elliptic.P256():
+			crv, s.method = "P-256", jwt.SigningMethodES256
+		case elliptic.P384():
+			crv, s.method = "P-384", jwt.SigningMethodES384
+		case elliptic.P521():
+			crv, s.method = "P-521", jwt.SigningMethodES512
+		default:
+			return nil, errors.New("unsupported ecdsa curve of signing key")
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		s.jwk = map[string]string{
+			"kty": "EC",
+			"crv": crv,
+			"x":   base64.RawURLEncoding.EncodeToString(k.X.FillBytes(make([]byte, size))),
+			"y":   base64.RawURLEncoding.EncodeToString(k.Y.FillBytes(make([]byte, size))),
+		}
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", key)
+	}
+
+	if keyID == "" {
+		thumbprint, err := s.thumbprint()
+		if err != nil {
+			return nil, err
+		}
+		keyID = thumbprint
+	}
+	s.keyID = keyID
+
+	s.jwk["kid"] = keyID
+	s.jwk["alg"] = s.method.Alg()
+	s.jwk["use"] = "sig"
+
+	return s, nil
+}
+
+// thumbprint returns the RFC 7638 thumbprint of the public key
+func (s *Signer) thumbprint() (string, error) {
+	// The members are required in lexicographic order, json.Marshal sorts the keys of a map
+	members := map[string]string{}
+	for _, name := range []string{"crv", "e", "kty", "n", "x", "y"} {
+		if value, ok := s.jwk[name]; ok {
+			members[name] = value
+		}
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// Sign returns the signed compact token of the claims
+func (s *Signer) Sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(s.method, claims)
+	token.Header["kid"] = s.keyID
+	return token.SignedString(s.key)
+}
+
+// KeyID returns the ID of the signing key
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// PublicKey returns the public key to verify the signed tokens
+func (s *Signer) PublicKey() crypto.PublicKey {
+	return s.key.Public()
+}
+
+// JWKS returns the JSON Web Key Set with the public key
+func (s *Signer) JWKS() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"keys": []map[string]string{s.jwk},
+	})
+}
@@ -0,0 +1,143 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// publicKeyFromJWKS returns the key ID and public key of the only key in the set, like a subgraph would
+func publicKeyFromJWKS(t *testing.T, data []byte) (string, any) {
+	t.Helper()
+
+	var jwks struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(data, &jwks))
+	require.Len(t, jwks.Keys, 1)
+	key := jwks.Keys[0]
+	require.Equal(t, "sig", key["use"])
+
+	decode := func(name string) *big.Int {
+		b, err := base64.RawURLEncoding.DecodeString(key[name])
+		require.NoError(t, err)
+		return new(big.Int).SetBytes(b)
+	}
+
+	switch key["kty"] {
+	case "RSA":
+		return key["kid"], &rsa.PublicKey{N: decode("n"), E: int(decode("e").Int64())}
+	case "EC":
+		require.Equal(t, "P-256", key["crv"])
+		return key["kid"], &ecdsa.PublicKey{Curve: elliptic.P256(), X: decode("x"), Y: decode("y")}
+	}
+
+	t.Fatalf("unexpected key type %s", key["kty"])
+	return "", nil
+}
+
+func TestSignerVerifiesWithJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		key crypto.Signer
+		alg string
+	}{
+		"rsa":   {key: rsaKey, alg: "RS256"},
+		"ecdsa": {key: ecKey, alg: "ES256"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			signer, err := NewSigner(tc.key, "")
+			require.NoError(t, err)
+
+			token, err := signer.Sign(jwt.MapClaims{"sub": "user-1"})
+			require.NoError(t, err)
+
+			data, err := signer.JWKS()
+			require.NoError(t, err)
+			keyID, publicKey := publicKeyFromJWKS(t, data)
+			require.Equal(t, signer.KeyID(), keyID)
+
+			parsed, err := jwt.Parse(token, func(token *jwt.Token) (any, error) {
+				require.Equal(t, keyID, token.Header["kid"])
+				return publicKey, nil
+			}, jwt.WithValidMethods([]string{tc.alg}))
+			require.NoError(t, err)
+			require.Equal(t, "user-1", parsed.Claims.(jwt.MapClaims)["sub"])
+		})
+	}
+}
+
+func TestSignerKeyID(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	signer, err := NewSigner(key, "router-key")
+	require.NoError(t, err)
+	require.Equal(t, "router-key", signer.KeyID())
+
+	// The thumbprint is stable for the same key
+	first, err := NewSigner(key, "")
+	require.NoError(t, err)
+	second, err := NewSigner(key, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, first.KeyID())
+	require.Equal(t, first.KeyID(), second.KeyID())
+}
+
+func TestSignerRFC7638Thumbprint(t *testing.T) {
+	// Example key of RFC 7638, section 3.1
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	require.NoError(t, err)
+
+	s := &Signer{jwk: map[string]string{
+		"kty": "RSA",
+		"e":   "AQAB",
+		"n":   base64.RawURLEncoding.EncodeToString(n),
+		"kid": "ignored",
+	}}
+	thumbprint, err := s.thumbprint()
+	require.NoError(t, err)
+	require.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint)
+}
+
+func TestNewSignerFromFile(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	signer, err := NewSignerFromFile(keyFile, "")
+	require.NoError(t, err)
+	require.Equal(t, jwt.SigningMethodES256, signer.method)
+
+	_, err = NewSignerFromFile(filepath.Join(t.TempDir(), "missing.pem"), "")
+	require.Error(t, err)
+}
+
+func TestNewSignerRejectsWeakRSAKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	_, err = NewSigner(key, "")
+	require.ErrorContains(t, err, "at least 2048 bits")
+}
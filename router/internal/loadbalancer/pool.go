@@ -0,0 +1,393 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/wundergraph/cosmo/router/internal/unixsocket"
+	"go.uber.org/zap"
+)
+
+type Algorithm string
+
+const (
+	AlgorithmRoundRobin     Algorithm = "round_robin"
+	AlgorithmLeastRequests  Algorithm = "least_requests"
+	AlgorithmConsistentHash Algorithm = "consistent_hash"
+)
+
+type HealthCheckOptions struct {
+	Enabled bool
+	// Path is resolved against the endpoint URL, e.g. /health
+	Path     string
+	Interval time.Duration
+	Timeout  time.Duration
+	// HealthyThreshold is the number of consecutive successful checks to mark an endpoint healthy
+	HealthyThreshold int
+	// UnhealthyThreshold is the number of consecutive failed checks to mark an endpoint unhealthy
+	UnhealthyThreshold int
+}
+
+type OutlierDetectionOptions struct {
+	Enabled bool
+	// ConsecutiveFailures is the number of consecutive failed requests after which an endpoint is ejected
+	ConsecutiveFailures int
+	// EjectionDuration is the time an ejected endpoint doesn't receive traffic
+	EjectionDuration time.Duration
+}
+
+type PoolOptions struct {
+	Name      string
+	URLs      []*url.URL
+	Algorithm Algorithm
+	// HashHeader is the request header used as key for the consistent hash algorithm
+	HashHeader       string
+	HealthCheck      HealthCheckOptions
+	OutlierDetection OutlierDetectionOptions
+	// OnHealthChange is called whenever an endpoint becomes available or unavailable
+	OnHealthChange func(endpoint *url.URL, healthy bool)
+	// Client is used for the active health checks
+	Client *http.Client
+	Logger *zap.Logger
+}
+
+// Endpoint is a single upstream of a pool.
+type Endpoint struct {
+	URL *url.URL
+
+	inFlight atomic.Int64
+
+	mu sync.Mutex
+	// healthy is the result of the active health checks
+	healthy              bool
+	checkSuccesses       int
+	checkFailures        int
+	consecutiveFailures  int
+	ejectedUntil         time.Time
+	ejectionTimer        *time.Timer
+	reportedAvailability bool
+}
+
+func (e *Endpoint) available(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.healthy && !now.Before(e.ejectedUntil)
+}
+
+// Pool balances the requests of a subgraph over a set of endpoints.
+// Endpoints are taken out of the rotation by active health checks and passive outlier detection.
+type Pool struct {
+	opts      PoolOptions
+	endpoints []*Endpoint
+	next      atomic.Uint64
+
+	startOnce sync.Once
+	done      chan struct{}
+}
+
+func NewPool(opts PoolOptions) (*Pool, error) {
+	if len(opts.URLs) == 0 {
+		return nil, fmt.Errorf("pool %s has no endpoints", opts.Name)
+	}
+
+	switch opts.Algorithm {
+	case "":
+		opts.Algorithm = AlgorithmRoundRobin
+	case AlgorithmRoundRobin, AlgorithmLeastRequests:
+	case AlgorithmConsistentHash:
+		if opts.HashHeader == "" {
+			return nil, fmt.Errorf("pool %s uses the consistent hash algorithm but no hash header is configured", opts.Name)
+		}
+	default:
+		return nil, fmt.Errorf("pool %s has an unknown load balancing algorithm %q", opts.Name, opts.Algorithm)
+	}
+
+	if opts.HealthCheck.Interval <= 0 {
+		opts.HealthCheck.Interval = 10 * time.Second
+	}
+	if opts.HealthCheck.Timeout <= 0 {
+		opts.HealthCheck.Timeout = 2 * time.Second
+	}
+	if opts.HealthCheck.HealthyThreshold <= 0 {
+		opts.HealthCheck.HealthyThreshold = 2
+	}
+	if opts.HealthCheck.UnhealthyThreshold <= 0 {
+		opts.HealthCheck.UnhealthyThreshold = 3
+	}
+	if opts.HealthCheck.Path == "" {
+		opts.HealthCheck.Path = "/health"
+	}
+	if opts.OutlierDetection.ConsecutiveFailures <= 0 {
+		opts.OutlierDetection.ConsecutiveFailures = 5
+	}
+	if opts.OutlierDetection.EjectionDuration <= 0 {
+		opts.OutlierDetection.EjectionDuration = 30 * time.Second
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+
+	p := &Pool{
+		opts: opts,
+		done: make(chan struct{}),
+	}
+
+	for _, u := range opts.URLs {
+		p.endpoints = append(p.endpoints, &Endpoint{
+			URL: u,
+			// Endpoints are considered healthy until a health check says otherwise
+			healthy: true,
+		})
+	}
+
+	return p, nil
+}
+
+// Start reports the initial health of the endpoints and runs the health checks until the context is done.
+// When the context is done all endpoints are reported as unavailable.
+func (p *Pool) Start(ctx context.Context) {
+	p.startOnce.Do(func() {
+		for _, e := range p.endpoints {
+			p.report(e)
+		}
+
+		go func() {
+			defer close(p.done)
+
+			if p.opts.HealthCheck.Enabled {
+				p.runHealthChecks(ctx)
+			} else {
+				<-ctx.Done()
+			}
+
+			for _, e := range p.endpoints {
+				e.mu.Lock()
+				if e.ejectionTimer != nil {
+					e.ejectionTimer.Stop()
+				}
+				wasAvailable := e.reportedAvailability
+				e.reportedAvailability = false
+				e.mu.Unlock()
+
+				if wasAvailable && p.opts.OnHealthChange != nil {
+					p.opts.OnHealthChange(e.URL, false)
+				}
+			}
+		}()
+	})
+}
+
+// Endpoints returns the endpoints of the pool.
+func (p *Pool) Endpoints() []*Endpoint {
+	return p.endpoints
+}
+
+// Pick selects the endpoint for the request. If no endpoint is available, all endpoints are considered
+// to avoid failing requests because of a misbehaving health check.
+func (p *Pool) Pick(req *http.Request) *Endpoint {
+	now := time.Now()
+
+	candidates := make([]*Endpoint, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		if e.available(now) {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = p.endpoints
+	}
+
+	switch p.opts.Algorithm {
+	case AlgorithmLeastRequests:
+		start := int(p.next.Add(1) % uint64(len(candidates)))
+		best := candidates[start]
+		for i := 1; i < len(candidates); i++ {
+			e := candidates[(start+i)%len(candidates)]
+			if e.inFlight.Load() < best.inFlight.Load() {
+				best = e
+			}
+		}
+		return best
+	case AlgorithmConsistentHash:
+		if key := req.Header.Get(p.opts.HashHeader); key != "" {
+			return rendezvous(key, candidates)
+		}
+	}
+
+	return candidates[p.next.Add(1)%uint64(len(candidates))]
+}
+
+// rendezvous picks the endpoint with the highest hash of key and endpoint.
+// Only the keys of a removed endpoint are moved to other endpoints.
+func rendezvous(key string, candidates []*Endpoint) *Endpoint {
+	var best *Endpoint
+	var bestScore uint64
+
+	for _, e := range candidates {
+		d := xxhash.New()
+		_, _ = d.WriteString(key)
+		_, _ = d.WriteString(e.URL.String())
+		if score := d.Sum64(); best == nil || score > bestScore {
+			best = e
+			bestScore = score
+		}
+	}
+
+	return best
+}
+
+// observe feeds the result of a request into the outlier detection. Cancelled requests, e.g. hedged
+// requests that lost the race or requests of disconnected clients, say nothing about the endpoint.
+func (p *Pool) observe(e *Endpoint, req *http.Request, resp *http.Response, err error) {
+	if !p.opts.OutlierDetection.Enabled {
+		return
+	}
+	if errors.Is(err, context.Canceled) || req.Context().Err() != nil {
+		return
+	}
+
+	failed := err != nil || (resp != nil && resp.StatusCode >= http.StatusInternalServerError)
+
+	e.mu.Lock()
+
+	if !failed {
+		e.consecutiveFailures = 0
+		e.mu.Unlock()
+		return
+	}
+
+	e.consecutiveFailures++
+	if e.consecutiveFailures < p.opts.OutlierDetection.ConsecutiveFailures {
+		e.mu.Unlock()
+		return
+	}
+
+	e.consecutiveFailures = 0
+	e.ejectedUntil = time.Now().Add(p.opts.OutlierDetection.EjectionDuration)
+	if e.ejectionTimer != nil {
+		e.ejectionTimer.Stop()
+	}
+	// Report the endpoint as available again when the ejection expires
+	e.ejectionTimer = time.AfterFunc(p.opts.OutlierDetection.EjectionDuration, func() {
+		p.report(e)
+	})
+
+	e.mu.Unlock()
+
+	p.opts.Logger.Warn("Ejecting subgraph endpoint after consecutive failures",
+		zap.String("subgraph", p.opts.Name),
+		zap.String("endpoint", e.URL.String()),
+		zap.Duration("duration", p.opts.OutlierDetection.EjectionDuration),
+	)
+
+	p.report(e)
+}
+
+// report calls OnHealthChange when the availability of the endpoint changed since the last report.
+func (p *Pool) report(e *Endpoint) {
+	available := e.available(time.Now())
+
+	e.mu.Lock()
+	changed := e.reportedAvailability != available
+	e.reportedAvailability = available
+	e.mu.Unlock()
+
+	if changed && p.opts.OnHealthChange != nil {
+		p.opts.OnHealthChange(e.URL, available)
+	}
+}
+
+func (p *Pool) runHealthChecks(ctx context.Context) {
+	ticker := time.NewTicker(p.opts.HealthCheck.Interval)
+	defer ticker.Stop()
+
+	for {
+		var wg sync.WaitGroup
+		for _, e := range p.endpoints {
+			wg.Add(1)
+			go func(e *Endpoint) {
+				defer wg.Done()
+				p.checkEndpoint(ctx, e)
+			}(e)
+		}
+		wg.Wait()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool) checkEndpoint(ctx context.Context, e *Endpoint) {
+	err := p.probe(ctx, e)
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+
+	e.mu.Lock()
+	if err == nil {
+		e.checkFailures = 0
+		e.checkSuccesses++
+		if !e.healthy && e.checkSuccesses >= p.opts.HealthCheck.HealthyThreshold {
+			e.healthy = true
+		}
+	} else {
+		e.checkSuccesses = 0
+		e.checkFailures++
+		if e.healthy && e.checkFailures >= p.opts.HealthCheck.UnhealthyThreshold {
+			e.healthy = false
+			p.opts.Logger.Warn("Subgraph endpoint is unhealthy",
+				zap.String("subgraph", p.opts.Name),
+				zap.String("endpoint", e.URL.String()),
+				zap.Error(err),
+			)
+		}
+	}
+	e.mu.Unlock()
+
+	p.report(e)
+}
+
+func (p *Pool) probe(ctx context.Context, e *Endpoint) error {
+	ctx, cancel := context.WithTimeout(ctx, p.opts.HealthCheck.Timeout)
+	defer cancel()
+
+	target := e.URL.ResolveReference(&url.URL{Path: p.opts.HealthCheck.Path}).String()
+	// The path of a socket URL addresses the socket, only the HTTP path is resolved
+	if unixsocket.IsURL(e.URL) {
+		socket, path := unixsocket.SplitURL(e.URL)
+		target = unixsocket.URL(socket, (&url.URL{Path: path}).ResolveReference(&url.URL{Path: p.opts.HealthCheck.Path}).Path)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,368 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/internal/hedgetransport"
+)
+
+type MockTransport struct {
+	handler func(req *http.Request) (*http.Response, error)
+}
+
+func (dt *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return dt.handler(req)
+}
+
+func mustParse(t *testing.T, urls ...string) []*url.URL {
+	t.Helper()
+	var parsed []*url.URL
+	for _, u := range urls {
+		p, err := url.Parse(u)
+		require.NoError(t, err)
+		parsed = append(parsed, p)
+	}
+	return parsed
+}
+
+func okResponse(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(req.URL.Host)),
+	}, nil
+}
+
+func TestRoundRobin(t *testing.T) {
+	pool, err := NewPool(PoolOptions{
+		Name: "products",
+		URLs: mustParse(t, "http://a:4001/graphql", "http://b:4001/graphql"),
+	})
+	require.NoError(t, err)
+
+	var hosts []string
+	tr := NewTransport(&MockTransport{handler: func(req *http.Request) (*http.Response, error) {
+		hosts = append(hosts, req.URL.Host)
+		assert.Equal(t, "/graphql", req.URL.Path)
+		assert.Equal(t, "a=1", req.URL.RawQuery)
+		return okResponse(req)
+	}}, map[string]*Pool{"http://products:4001/graphql": pool})
+
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest("GET", "http://products:4001/graphql?a=1", nil)
+		_, err := tr.RoundTrip(req)
+		require.NoError(t, err)
+		assert.Equal(t, "products:4001", req.URL.Host, "original request must not be modified")
+	}
+
+	assert.ElementsMatch(t, []string{"a:4001", "b:4001", "a:4001", "b:4001"}, hosts)
+	assert.NotEqual(t, hosts[0], hosts[1])
+}
+
+func TestPassThroughWithoutPool(t *testing.T) {
+	var host string
+	tr := NewTransport(&MockTransport{handler: func(req *http.Request) (*http.Response, error) {
+		host = req.URL.Host
+		return okResponse(req)
+	}}, map[string]*Pool{})
+
+	_, err := tr.RoundTrip(httptest.NewRequest("POST", "http://inventory:4002/graphql", nil))
+	require.NoError(t, err)
+	assert.Equal(t, "inventory:4002", host)
+}
+
+func TestLeastRequests(t *testing.T) {
+	pool, err := NewPool(PoolOptions{
+		Name:      "products",
+		URLs:      mustParse(t, "http://a:4001/graphql", "http://b:4001/graphql"),
+		Algorithm: AlgorithmLeastRequests,
+	})
+	require.NoError(t, err)
+
+	pool.Endpoints()[0].inFlight.Store(3)
+
+	for i := 0; i < 5; i++ {
+		e := pool.Pick(httptest.NewRequest("POST", "http://products:4001/graphql", nil))
+		assert.Equal(t, "b:4001", e.URL.Host)
+	}
+}
+
+func TestLeastRequestsCountsResponseBodies(t *testing.T) {
+	pool, err := NewPool(PoolOptions{
+		Name:      "products",
+		URLs:      mustParse(t, "http://a:4001/graphql", "http://b:4001/graphql"),
+		Algorithm: AlgorithmLeastRequests,
+	})
+	require.NoError(t, err)
+
+	tr := NewTransport(&MockTransport{handler: okResponse}, map[string]*Pool{"http://products:4001/graphql": pool})
+
+	// The request is in flight while its response is streamed
+	streamed, err := tr.RoundTrip(httptest.NewRequest("POST", "http://products:4001/graphql", nil))
+	require.NoError(t, err)
+	endpoint := pool.Endpoints()[0]
+	if body, _ := io.ReadAll(streamed.Body); string(body) != endpoint.URL.Host {
+		endpoint = pool.Endpoints()[1]
+	}
+	assert.Equal(t, int64(1), endpoint.inFlight.Load())
+
+	for i := 0; i < 4; i++ {
+		resp, err := tr.RoundTrip(httptest.NewRequest("POST", "http://products:4001/graphql", nil))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		assert.NotEqual(t, endpoint.URL.Host, string(body))
+		require.NoError(t, resp.Body.Close())
+	}
+
+	require.NoError(t, streamed.Body.Close())
+	require.NoError(t, streamed.Body.Close())
+	assert.Equal(t, int64(0), pool.Endpoints()[0].inFlight.Load())
+	assert.Equal(t, int64(0), pool.Endpoints()[1].inFlight.Load())
+
+	// Failed requests are not in flight anymore
+	tr = NewTransport(&MockTransport{handler: func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}}, map[string]*Pool{"http://products:4001/graphql": pool})
+	_, err = tr.RoundTrip(httptest.NewRequest("POST", "http://products:4001/graphql", nil))
+	require.Error(t, err)
+	assert.Equal(t, int64(0), pool.Endpoints()[0].inFlight.Load()+pool.Endpoints()[1].inFlight.Load())
+}
+
+func TestConsistentHash(t *testing.T) {
+	pool, err := NewPool(PoolOptions{
+		Name:       "products",
+		URLs:       mustParse(t, "http://a:4001/graphql", "http://b:4001/graphql", "http://c:4001/graphql"),
+		Algorithm:  AlgorithmConsistentHash,
+		HashHeader: "X-User-Id",
+	})
+	require.NoError(t, err)
+
+	pick := func(user string) *Endpoint {
+		req := httptest.NewRequest("POST", "http://products:4001/graphql", nil)
+		req.Header.Set("X-User-Id", user)
+		return pool.Pick(req)
+	}
+
+	first := pick("user-1")
+	for i := 0; i < 10; i++ {
+		assert.Same(t, first, pick("user-1"))
+	}
+
+	// Only the keys of an ejected endpoint move
+	assignments := map[string]*Endpoint{}
+	for _, user := range []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"} {
+		assignments[user] = pick(user)
+	}
+	ejected := pool.Endpoints()[0]
+	ejected.ejectedUntil = time.Now().Add(time.Minute)
+	for user, e := range assignments {
+		if e != ejected {
+			assert.Same(t, e, pick(user))
+		} else {
+			assert.NotSame(t, ejected, pick(user))
+		}
+	}
+
+	_, err = NewPool(PoolOptions{
+		Name:      "products",
+		URLs:      mustParse(t, "http://a:4001/graphql"),
+		Algorithm: AlgorithmConsistentHash,
+	})
+	assert.Error(t, err)
+}
+
+func TestOutlierEjection(t *testing.T) {
+	var mu sync.Mutex
+	var changes []bool
+
+	pool, err := NewPool(PoolOptions{
+		Name: "products",
+		URLs: mustParse(t, "http://a:4001/graphql", "http://b:4001/graphql"),
+		OutlierDetection: OutlierDetectionOptions{
+			Enabled:             true,
+			ConsecutiveFailures: 2,
+			EjectionDuration:    50 * time.Millisecond,
+		},
+		OnHealthChange: func(endpoint *url.URL, healthy bool) {
+			if endpoint.Host == "a:4001" {
+				mu.Lock()
+				changes = append(changes, healthy)
+				mu.Unlock()
+			}
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.Start(ctx)
+
+	var bCalls int
+	tr := NewTransport(&MockTransport{handler: func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "a:4001" {
+			return nil, errors.New("connection refused")
+		}
+		bCalls++
+		return okResponse(req)
+	}}, map[string]*Pool{"http://products:4001/graphql": pool})
+
+	for i := 0; i < 4; i++ {
+		_, _ = tr.RoundTrip(httptest.NewRequest("POST", "http://products:4001/graphql", nil))
+	}
+	assert.Equal(t, 2, bCalls)
+
+	// a is ejected, all requests go to b
+	for i := 0; i < 4; i++ {
+		resp, err := tr.RoundTrip(httptest.NewRequest("POST", "http://products:4001/graphql", nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	assert.Equal(t, 6, bCalls)
+
+	// a is reported available again after the ejection expired
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(changes) == 3
+	}, time.Second, 10*time.Millisecond)
+	assert.True(t, pool.Endpoints()[0].available(time.Now()))
+
+	cancel()
+	<-pool.done
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []bool{true, false, true, false}, changes)
+}
+
+func TestOutlierDetectionIgnoresCancelledRequests(t *testing.T) {
+	pool, err := NewPool(PoolOptions{
+		Name: "products",
+		URLs: mustParse(t, "http://a:4001/graphql", "http://b:4001/graphql"),
+		OutlierDetection: OutlierDetectionOptions{
+			Enabled:             true,
+			ConsecutiveFailures: 2,
+			EjectionDuration:    time.Minute,
+		},
+	})
+	require.NoError(t, err)
+
+	var slow atomic.Bool
+	tr := NewTransport(&MockTransport{handler: func(req *http.Request) (*http.Response, error) {
+		if slow.Load() && req.URL.Host == "a:4001" {
+			select {
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			case <-time.After(5 * time.Second):
+			}
+		}
+		return okResponse(req)
+	}}, map[string]*Pool{"http://products:4001/graphql": pool})
+
+	var hedges atomic.Int32
+	hedge := hedgetransport.NewHedgeHTTPTransport(tr, hedgetransport.HedgeOptions{
+		Enabled: true,
+		Policy: func(*http.Request) *hedgetransport.Policy {
+			return &hedgetransport.Policy{Key: "products", Percentile: 50, MinDelay: 10 * time.Millisecond}
+		},
+		OnHedge: func(*http.Request, time.Duration) {
+			hedges.Add(1)
+		},
+	}, zap.NewNop())
+
+	roundTrip := func(rt http.RoundTripper) {
+		resp, err := rt.RoundTrip(httptest.NewRequest("POST", "http://products:4001/graphql", nil))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	// Collect the latencies the hedging delay is derived from
+	for i := 0; i < 20; i++ {
+		roundTrip(hedge)
+	}
+
+	// The requests to a lose the race against the hedged requests to b and are cancelled
+	slow.Store(true)
+	for i := 0; i < 6; i++ {
+		roundTrip(hedge)
+	}
+	assert.GreaterOrEqual(t, hedges.Load(), int32(2))
+	assert.True(t, pool.Endpoints()[0].available(time.Now()))
+
+	// Requests of disconnected clients don't count either
+	for i := 0; i < 4; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest("POST", "http://products:4001/graphql", nil).WithContext(ctx)
+		go cancel()
+		resp, err := tr.RoundTrip(req)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}
+	assert.True(t, pool.Endpoints()[0].available(time.Now()))
+}
+
+func TestActiveHealthCheck(t *testing.T) {
+	var mu sync.Mutex
+	healthy := false
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path != "/health" || !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	pool, err := NewPool(PoolOptions{
+		Name: "products",
+		URLs: mustParse(t, upstream.URL+"/graphql", "http://127.0.0.1:1/graphql"),
+		HealthCheck: HealthCheckOptions{
+			Enabled:            true,
+			Interval:           10 * time.Millisecond,
+			Timeout:            100 * time.Millisecond,
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 1,
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool.Start(ctx)
+
+	assert.Eventually(t, func() bool {
+		return !pool.Endpoints()[0].available(time.Now()) && !pool.Endpoints()[1].available(time.Now())
+	}, time.Second, 10*time.Millisecond)
+
+	// Without available endpoints all endpoints receive traffic
+	assert.NotNil(t, pool.Pick(httptest.NewRequest("POST", "http://products/graphql", nil)))
+
+	mu.Lock()
+	healthy = true
+	mu.Unlock()
+
+	assert.Eventually(t, func() bool {
+		return pool.Endpoints()[0].available(time.Now())
+	}, time.Second, 10*time.Millisecond)
+
+	for i := 0; i < 4; i++ {
+		e := pool.Pick(httptest.NewRequest("POST", "http://products/graphql", nil))
+		assert.Same(t, pool.Endpoints()[0], e)
+	}
+}
@@ -0,0 +1,90 @@
+package loadbalancer
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// Transport sends the requests of a subgraph to an endpoint of its pool.
+// Requests to URLs without a pool are passed through unchanged.
+type Transport struct {
+	RoundTripper http.RoundTripper
+	// pools maps the routing URL of a subgraph to its pool
+	pools map[string]*Pool
+}
+
+func NewTransport(roundTripper http.RoundTripper, pools map[string]*Pool) *Transport {
+	return &Transport{
+		RoundTripper: roundTripper,
+		pools:        pools,
+	}
+}
+
+// Key returns the key of a routing URL in the pools map. The query is ignored.
+func Key(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + u.Path
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	pool, ok := t.pools[Key(req.URL)]
+	if !ok {
+		return t.RoundTripper.RoundTrip(req)
+	}
+
+	endpoint := pool.Pick(req)
+
+	target := *endpoint.URL
+	target.RawQuery = req.URL.RawQuery
+
+	// A RoundTripper must not modify the request
+	outReq := new(http.Request)
+	*outReq = *req
+	outReq.URL = &target
+	outReq.Host = ""
+
+	endpoint.inFlight.Add(1)
+	resp, err := t.RoundTripper.RoundTrip(outReq)
+
+	pool.observe(endpoint, outReq, resp, err)
+
+	if err != nil || resp.Body == nil {
+		endpoint.inFlight.Add(-1)
+		return resp, err
+	}
+
+	// The request is in flight until its response was read, e.g. while a subscription is streamed
+	body := &inFlightBody{ReadCloser: resp.Body, endpoint: endpoint}
+	if rw, ok := resp.Body.(io.ReadWriteCloser); ok {
+		// Upgraded connections are written to through the body
+		resp.Body = &inFlightReadWriteBody{inFlightBody: body, Writer: rw}
+	} else {
+		resp.Body = body
+	}
+
+	// The caller identifies the response by its request, e.g. to find the subgraph
+	resp.Request = req
+
+	return resp, nil
+}
+
+// inFlightBody counts the request of the response as in flight until the body is closed
+type inFlightBody struct {
+	io.ReadCloser
+	endpoint *Endpoint
+	once     sync.Once
+}
+
+func (b *inFlightBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.endpoint.inFlight.Add(-1)
+	})
+	return err
+}
+
+type inFlightReadWriteBody struct {
+	*inFlightBody
+	io.Writer
+}
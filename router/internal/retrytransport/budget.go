@@ -0,0 +1,71 @@
+package retrytransport
+
+import (
+	"sync"
+	"time"
+)
+
+// Budget limits the retries to a ratio of the requests observed within a window of time.
+// This prevents retries from multiplying the load on a subgraph that is already struggling.
+// MinRetries are always allowed within a window so that low traffic can still be retried.
+type Budget struct {
+	ratio      float64
+	minRetries int
+	window     time.Duration
+
+	mu          sync.Mutex
+	windowStart time.Time
+	requests    int
+	retries     int
+
+	now func() time.Time
+}
+
+// NewBudget creates a retry budget. A ratio of 0.2 allows one retry for every five requests.
+func NewBudget(ratio float64, minRetries int, window time.Duration) *Budget {
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+	return &Budget{
+		ratio:      ratio,
+		minRetries: minRetries,
+		window:     window,
+		now:        time.Now,
+	}
+}
+
+func (b *Budget) recordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rotate()
+	b.requests++
+}
+
+// tryAcquire reports whether a retry is allowed and accounts for it.
+func (b *Budget) tryAcquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rotate()
+
+	allowed := b.minRetries + int(float64(b.requests)*b.ratio)
+	if b.retries >= allowed {
+		return false
+	}
+
+	b.retries++
+
+	return true
+}
+
+// rotate starts a new window when the current one has expired. Requires b.mu to be locked.
+func (b *Budget) rotate() {
+	now := b.now()
+	if now.Sub(b.windowStart) < b.window {
+		return
+	}
+	b.windowStart = now
+	b.requests = 0
+	b.retries = 0
+}
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"github.com/cloudflare/backoff"
 	"go.uber.org/zap"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -40,6 +42,11 @@ type RetryOptions struct {
 	MaxDuration   time.Duration
 	OnRetry       func(count int, req *http.Request, resp *http.Response, err error)
 	ShouldRetry   ShouldRetryFunc
+	// RespectRetryAfter waits for the duration announced in the Retry-After header of 429 and 503 responses
+	// instead of the backoff. If the announced duration exceeds MaxDuration, the response is returned as is.
+	RespectRetryAfter bool
+	// Budget limits the retries to a ratio of the requests. Nil means no budget.
+	Budget *Budget
 }
 
 type RetryHTTPTransport struct {
@@ -58,6 +65,10 @@ func NewRetryHTTPTransport(roundTripper http.RoundTripper, retryOptions RetryOpt
 
 func (rt *RetryHTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 
+	if rt.RetryOptions.Budget != nil {
+		rt.RetryOptions.Budget.recordRequest()
+	}
+
 	resp, err := rt.RoundTripper.RoundTrip(req)
 	// Short circuit if the request was successful
 	if err == nil && resp.StatusCode == http.StatusOK {
@@ -70,23 +81,76 @@ func (rt *RetryHTTPTransport) RoundTrip(req *http.Request) (*http.Response, erro
 	// Retry logic
 	retries := 0
 	for rt.RetryOptions.ShouldRetry(err, req, resp) && retries < rt.RetryOptions.MaxRetryCount {
+
+		// Wait for the specified backoff period or the period the server asked for
+		sleepDuration := b.Duration()
+
+		if rt.RetryOptions.RespectRetryAfter {
+			if retryAfter, ok := parseRetryAfter(resp); ok {
+				if retryAfter > rt.RetryOptions.MaxDuration {
+					rt.Logger.Debug("Retry-After exceeds the max retry duration, not retrying",
+						zap.String("url", req.URL.String()),
+						zap.Duration("retry_after", retryAfter),
+					)
+					break
+				}
+				sleepDuration = retryAfter
+			}
+		}
+
+		// The body can only be replayed if the request allows it. This is checked first, so requests
+		// that can't be retried don't use up the budget.
+		var body io.ReadCloser
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				break
+			}
+			var bodyErr error
+			body, bodyErr = req.GetBody()
+			if bodyErr != nil {
+				break
+			}
+		}
+
+		if rt.RetryOptions.Budget != nil && !rt.RetryOptions.Budget.tryAcquire() {
+			if body != nil {
+				_ = body.Close()
+			}
+			rt.Logger.Debug("Retry budget exhausted, not retrying",
+				zap.String("url", req.URL.String()),
+			)
+			break
+		}
+
+		if body != nil {
+			req.Body = body
+		}
+
 		if rt.RetryOptions.OnRetry != nil {
 			rt.RetryOptions.OnRetry(retries, req, resp, err)
 		}
 
 		retries++
 
-		// Wait for the specified backoff period
-		sleepDuration := b.Duration()
-
 		rt.Logger.Debug("Retrying request",
 			zap.Int("retry", retries),
 			zap.String("url", req.URL.String()),
 			zap.Duration("sleep", sleepDuration),
 		)
 
-		// Wait for the specified backoff period
-		time.Sleep(sleepDuration)
+		timer := time.NewTimer(sleepDuration)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			// The client is gone, return the last result
+			return resp, err
+		case <-timer.C:
+		}
+
+		// Release the connection of the previous attempt
+		if resp != nil {
+			drainBody(resp)
+		}
 
 		// Retry the request
 		resp, err = rt.RoundTripper.RoundTrip(req)
@@ -101,7 +165,55 @@ func (rt *RetryHTTPTransport) RoundTrip(req *http.Request) (*http.Response, erro
 	return resp, err
 }
 
+func drainBody(resp *http.Response) {
+	if resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
+
+// parseRetryAfter returns the duration announced by the Retry-After header of 429 and 503 responses.
+// The header can either contain the delay in seconds or an HTTP date.
+func parseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		d := time.Until(date)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
+
 func IsRetryableError(err error, resp *http.Response) bool {
+	return IsRetryableErrorWithStatusCodes(err, resp, defaultRetryableStatusCodes)
+}
+
+// IsRetryableErrorWithStatusCodes works like IsRetryableError but retries the given status codes
+// instead of the default ones.
+func IsRetryableErrorWithStatusCodes(err error, resp *http.Response, statusCodes []int) bool {
 
 	if err != nil {
 		// Network
@@ -117,7 +229,7 @@ func IsRetryableError(err error, resp *http.Response) bool {
 
 	if resp != nil {
 		// HTTP
-		for _, retryableStatusCode := range defaultRetryableStatusCodes {
+		for _, retryableStatusCode := range statusCodes {
 			if resp.StatusCode == retryableStatusCode {
 				return true
 			}
@@ -5,6 +5,7 @@ import (
 	"go.uber.org/zap"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -108,3 +109,243 @@ func TestRetryOnNetErrors(t *testing.T) {
 	assert.Equal(t, len(defaultRetryableErrors), retries)
 
 }
+
+func TestRetryAfterHeaderIsRespected(t *testing.T) {
+
+	logger := zap.NewNop()
+	attempts := 0
+
+	tr := RetryHTTPTransport{
+		RoundTripper: &MockTransport{
+			handler: func(req *http.Request) (*http.Response, error) {
+				attempts++
+				if attempts == 1 {
+					return &http.Response{
+						StatusCode: http.StatusTooManyRequests,
+						Header:     http.Header{"Retry-After": []string{"1"}},
+					}, nil
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+				}, nil
+			},
+		},
+		RetryOptions: RetryOptions{
+			MaxRetryCount:     3,
+			Interval:          1 * time.Millisecond,
+			MaxDuration:       5 * time.Second,
+			RespectRetryAfter: true,
+			ShouldRetry: func(err error, req *http.Request, resp *http.Response) bool {
+				return IsRetryableError(err, resp)
+			},
+		},
+		Logger: logger,
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost:3000/graphql", nil)
+
+	start := time.Now()
+	resp, err := tr.RoundTrip(req)
+	assert.Nil(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, attempts)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestRetryAfterExceedingMaxDurationIsNotRetried(t *testing.T) {
+
+	logger := zap.NewNop()
+	attempts := 0
+
+	tr := RetryHTTPTransport{
+		RoundTripper: &MockTransport{
+			handler: func(req *http.Request) (*http.Response, error) {
+				attempts++
+				return &http.Response{
+					StatusCode: http.StatusServiceUnavailable,
+					Header:     http.Header{"Retry-After": []string{"120"}},
+				}, nil
+			},
+		},
+		RetryOptions: RetryOptions{
+			MaxRetryCount:     3,
+			Interval:          1 * time.Millisecond,
+			MaxDuration:       10 * time.Millisecond,
+			RespectRetryAfter: true,
+			ShouldRetry: func(err error, req *http.Request, resp *http.Response) bool {
+				return IsRetryableError(err, resp)
+			},
+		},
+		Logger: logger,
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost:3000/graphql", nil)
+
+	resp, err := tr.RoundTrip(req)
+	assert.Nil(t, err)
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	d, ok := parseRetryAfter(&http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"3"}},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, d)
+
+	d, ok = parseRetryAfter(&http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Header:     http.Header{"Retry-After": []string{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}},
+	})
+	assert.True(t, ok)
+	assert.Greater(t, d, 59*time.Minute)
+
+	_, ok = parseRetryAfter(&http.Response{
+		StatusCode: http.StatusBadGateway,
+		Header:     http.Header{"Retry-After": []string{"3"}},
+	})
+	assert.False(t, ok)
+
+	_, ok = parseRetryAfter(&http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"soon"}},
+	})
+	assert.False(t, ok)
+}
+
+func TestRetryOnCustomStatusCodes(t *testing.T) {
+
+	logger := zap.NewNop()
+	attempts := 0
+
+	tr := RetryHTTPTransport{
+		RoundTripper: &MockTransport{
+			handler: func(req *http.Request) (*http.Response, error) {
+				attempts++
+				return &http.Response{
+					StatusCode: http.StatusInternalServerError,
+				}, nil
+			},
+		},
+		RetryOptions: RetryOptions{
+			MaxRetryCount: 3,
+			Interval:      1 * time.Millisecond,
+			MaxDuration:   10 * time.Millisecond,
+			ShouldRetry: func(err error, req *http.Request, resp *http.Response) bool {
+				return IsRetryableErrorWithStatusCodes(err, resp, []int{http.StatusServiceUnavailable})
+			},
+		},
+		Logger: logger,
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost:3000/graphql", nil)
+
+	resp, err := tr.RoundTrip(req)
+	assert.Nil(t, err)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryBudget(t *testing.T) {
+
+	logger := zap.NewNop()
+	attempts := 0
+
+	tr := RetryHTTPTransport{
+		RoundTripper: &MockTransport{
+			handler: func(req *http.Request) (*http.Response, error) {
+				attempts++
+				return &http.Response{
+					StatusCode: http.StatusBadGateway,
+				}, nil
+			},
+		},
+		RetryOptions: RetryOptions{
+			MaxRetryCount: 5,
+			Interval:      1 * time.Millisecond,
+			MaxDuration:   10 * time.Millisecond,
+			Budget:        NewBudget(0.5, 1, time.Minute),
+			ShouldRetry: func(err error, req *http.Request, resp *http.Response) bool {
+				return IsRetryableError(err, resp)
+			},
+		},
+		Logger: logger,
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost:3000/graphql", nil)
+
+	// 1 request allows 1 min retry + 0.5 retries
+	_, err := tr.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempts)
+
+	// 2 requests allow 1 min retry + 1 retry, one is already used
+	attempts = 0
+	_, err = tr.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempts)
+
+	// 3 requests allow 1 min retry + 1.5 retries, both are used
+	attempts = 0
+	_, err = tr.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryBudgetNotUsedWithoutReplayableBody(t *testing.T) {
+
+	logger := zap.NewNop()
+	attempts := 0
+
+	tr := RetryHTTPTransport{
+		RoundTripper: &MockTransport{
+			handler: func(req *http.Request) (*http.Response, error) {
+				attempts++
+				return &http.Response{
+					StatusCode: http.StatusBadGateway,
+				}, nil
+			},
+		},
+		RetryOptions: RetryOptions{
+			MaxRetryCount: 5,
+			Interval:      1 * time.Millisecond,
+			MaxDuration:   10 * time.Millisecond,
+			Budget:        NewBudget(0, 1, time.Minute),
+			ShouldRetry: func(err error, req *http.Request, resp *http.Response) bool {
+				return IsRetryableError(err, resp)
+			},
+		},
+		Logger: logger,
+	}
+
+	// The body of the request can't be replayed, so it is not retried
+	req := httptest.NewRequest("POST", "http://localhost:3000/graphql", strings.NewReader(`{"query":"{a}"}`))
+	req.GetBody = nil
+	_, err := tr.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, attempts)
+
+	// The budget allows 1 retry, which is still available
+	attempts = 0
+	_, err = tr.RoundTrip(httptest.NewRequest("GET", "http://localhost:3000/graphql", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestRetryBudgetWindow(t *testing.T) {
+	now := time.Now()
+	b := NewBudget(0, 1, time.Second)
+	b.now = func() time.Time { return now }
+
+	b.recordRequest()
+	assert.True(t, b.tryAcquire())
+	assert.False(t, b.tryAcquire())
+
+	now = now.Add(2 * time.Second)
+	assert.True(t, b.tryAcquire())
+}
@@ -0,0 +1,200 @@
+package servertls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultCheckInterval = 5 * time.Second
+
+type CertificateFiles struct {
+	CertFile string
+	KeyFile  string
+}
+
+type Options struct {
+	// Certificates are selected by the SNI of the client. The first one is the default.
+	Certificates []CertificateFiles
+	// CheckInterval is the minimum time between two checks of the files for changes
+	CheckInterval time.Duration
+	Logger        *zap.Logger
+}
+
+type entry struct {
+	files CertificateFiles
+	cert  *tls.Certificate
+	// modTimes are only accessed by the check holding checkMu
+	modTimes [2]time.Time
+}
+
+// CertStore serves the server certificates and reloads them when their files change.
+// When a reload fails the previous certificate is kept.
+type CertStore struct {
+	opts Options
+
+	// mu guards the certificates of the entries
+	mu      sync.RWMutex
+	entries []*entry
+
+	// lastCheck is the unix time in nanoseconds of the last check. It is read on every handshake,
+	// so the files are only checked once per interval without taking a lock.
+	lastCheck atomic.Int64
+	// checkMu serializes the checks and guards the modification times of the entries
+	checkMu sync.Mutex
+}
+
+func NewCertStore(opts Options) (*CertStore, error) {
+	if len(opts.Certificates) == 0 {
+		return nil, errors.New("no tls certificate provided")
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = defaultCheckInterval
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+
+	s := &CertStore{
+		opts: opts,
+	}
+	s.lastCheck.Store(time.Now().UnixNano())
+
+	for _, files := range opts.Certificates {
+		if files.CertFile == "" {
+			return nil, errors.New("tls cert file not provided")
+		}
+		if files.KeyFile == "" {
+			return nil, errors.New("tls key file not provided")
+		}
+
+		cert, modTimes, err := load(files)
+		if err != nil {
+			return nil, err
+		}
+		s.entries = append(s.entries, &entry{files: files, cert: cert, modTimes: modTimes})
+	}
+
+	return s, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate. It returns the first certificate valid for the
+// requested server name or the default certificate.
+func (s *CertStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.reloadIfChanged()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if serverName := strings.ToLower(strings.TrimSuffix(hello.ServerName, ".")); serverName != "" {
+		for _, e := range s.entries {
+			if e.cert.Leaf.VerifyHostname(serverName) == nil {
+				return e.cert, nil
+			}
+		}
+	}
+
+	return s.entries[0].cert, nil
+}
+
+// Leaves returns the current leaf certificates, e.g. to report their expiry.
+func (s *CertStore) Leaves() []*x509.Certificate {
+	s.reloadIfChanged()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	leaves := make([]*x509.Certificate, 0, len(s.entries))
+	for _, e := range s.entries {
+		leaves = append(leaves, e.cert.Leaf)
+	}
+
+	return leaves
+}
+
+func (s *CertStore) reloadIfChanged() {
+	if time.Since(time.Unix(0, s.lastCheck.Load())) < s.opts.CheckInterval {
+		return
+	}
+	// Another handshake is already checking the files
+	if !s.checkMu.TryLock() {
+		return
+	}
+	defer s.checkMu.Unlock()
+
+	if time.Since(time.Unix(0, s.lastCheck.Load())) < s.opts.CheckInterval {
+		return
+	}
+	s.lastCheck.Store(time.Now().UnixNano())
+
+	for _, e := range s.entries {
+		modTimes, err := stat(e.files)
+		if err != nil {
+			s.opts.Logger.Error("Failed to check tls certificate for changes", zap.String("cert_file", e.files.CertFile), zap.Error(err))
+			continue
+		}
+		if modTimes == e.modTimes {
+			continue
+		}
+
+		cert, modTimes, err := load(e.files)
+		if err != nil {
+			s.opts.Logger.Error("Failed to reload tls certificate. Keeping the previous one", zap.String("cert_file", e.files.CertFile), zap.Error(err))
+			continue
+		}
+
+		s.mu.Lock()
+		e.cert = cert
+		s.mu.Unlock()
+		e.modTimes = modTimes
+
+		s.opts.Logger.Info("Reloaded tls certificate",
+			zap.String("cert_file", e.files.CertFile),
+			zap.Time("not_after", cert.Leaf.NotAfter),
+		)
+	}
+}
+
+func stat(files CertificateFiles) ([2]time.Time, error) {
+	var modTimes [2]time.Time
+
+	for i, f := range []string{files.CertFile, files.KeyFile} {
+		info, err := os.Stat(f)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+func load(files CertificateFiles) (*tls.Certificate, [2]time.Time, error) {
+	modTimes, err := stat(files)
+	if err != nil {
+		return nil, modTimes, fmt.Errorf("failed to stat tls cert and key: %w", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+	if err != nil {
+		return nil, modTimes, fmt.Errorf("failed to load tls cert and key: %w", err)
+	}
+
+	// The leaf is parsed since Go 1.23, parse it for older versions
+	if cert.Leaf == nil {
+		cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, modTimes, fmt.Errorf("failed to parse tls cert: %w", err)
+		}
+	}
+
+	return &cert, modTimes, nil
+}
@@ -0,0 +1,163 @@
+package servertls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes a self-signed certificate for the DNS names and returns the file paths
+func writeCertificate(t *testing.T, dir, name string, notAfter time.Time, modTime time.Time, dnsNames ...string) CertificateFiles {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     dnsNames,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	files := CertificateFiles{
+		CertFile: filepath.Join(dir, name+".pem"),
+		KeyFile:  filepath.Join(dir, name+"-key.pem"),
+	}
+
+	require.NoError(t, os.WriteFile(files.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(files.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	require.NoError(t, os.Chtimes(files.CertFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(files.KeyFile, modTime, modTime))
+
+	return files
+}
+
+func TestSelectCertificateBySNI(t *testing.T) {
+	dir := t.TempDir()
+	expiry := time.Now().Add(time.Hour)
+
+	store, err := NewCertStore(Options{
+		Certificates: []CertificateFiles{
+			writeCertificate(t, dir, "default", expiry, time.Now(), "router.example.com"),
+			writeCertificate(t, dir, "api", expiry, time.Now(), "api.example.com"),
+			writeCertificate(t, dir, "wildcard", expiry, time.Now(), "*.internal.example.com"),
+		},
+	})
+	require.NoError(t, err)
+
+	subject := func(serverName string) string {
+		cert, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		require.NoError(t, err)
+		return cert.Leaf.Subject.CommonName
+	}
+
+	assert.Equal(t, "default", subject(""))
+	assert.Equal(t, "default", subject("unknown.example.com"))
+	assert.Equal(t, "api", subject("api.example.com"))
+	assert.Equal(t, "api", subject("API.example.com."))
+	assert.Equal(t, "wildcard", subject("products.internal.example.com"))
+}
+
+func TestReloadCertificate(t *testing.T) {
+	dir := t.TempDir()
+
+	files := writeCertificate(t, dir, "router", time.Now().Add(time.Hour), time.Now().Add(-time.Minute), "router.example.com")
+
+	store, err := NewCertStore(Options{
+		Certificates:  []CertificateFiles{files},
+		CheckInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	first := store.Leaves()[0]
+
+	renewedExpiry := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	writeCertificate(t, dir, "router", renewedExpiry, time.Now(), "router.example.com")
+	time.Sleep(5 * time.Millisecond)
+
+	renewed := store.Leaves()[0]
+	assert.NotEqual(t, first.SerialNumber, renewed.SerialNumber)
+	assert.Equal(t, renewedExpiry.UTC(), renewed.NotAfter.UTC())
+
+	// A broken certificate keeps the previous one
+	require.NoError(t, os.WriteFile(files.CertFile, []byte("broken"), 0600))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(files.CertFile, future, future))
+	time.Sleep(5 * time.Millisecond)
+
+	cert, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: "router.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, renewed.SerialNumber, cert.Leaf.SerialNumber)
+}
+
+func TestReloadCertificateConcurrently(t *testing.T) {
+	dir := t.TempDir()
+
+	files := writeCertificate(t, dir, "router", time.Now().Add(time.Hour), time.Now().Add(-time.Minute), "router.example.com")
+
+	store, err := NewCertStore(Options{
+		Certificates:  []CertificateFiles{files},
+		CheckInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				cert, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: "router.example.com"})
+				assert.NoError(t, err)
+				assert.NotNil(t, cert.Leaf)
+			}
+		}()
+	}
+
+	renewedExpiry := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	writeCertificate(t, dir, "router", renewedExpiry, time.Now(), "router.example.com")
+
+	assert.Eventually(t, func() bool {
+		return store.Leaves()[0].NotAfter.Equal(renewedExpiry)
+	}, 5*time.Second, time.Millisecond)
+
+	close(done)
+	wg.Wait()
+}
+
+func TestMissingCertificate(t *testing.T) {
+	_, err := NewCertStore(Options{})
+	assert.Error(t, err)
+
+	_, err = NewCertStore(Options{
+		Certificates: []CertificateFiles{{CertFile: "missing.pem", KeyFile: "missing-key.pem"}},
+	})
+	assert.Error(t, err)
+}
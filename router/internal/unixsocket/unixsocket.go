@@ -0,0 +1,147 @@
+// Package unixsocket serves and calls HTTP over Unix domain sockets.
+//
+// Addresses use the unix scheme. The socket path of a subgraph URL is separated from the HTTP path by a colon,
+// e.g. unix:///var/run/employees.sock:/graphql. Without a colon the HTTP path is "/".
+package unixsocket
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	Scheme = "unix"
+
+	// hostSuffix marks the hosts of rewritten requests. The host carries the encoded socket path
+	// so that every socket gets its own connection pool.
+	hostSuffix = ".unix-socket"
+)
+
+// IsURL reports whether the URL addresses a Unix domain socket
+func IsURL(u *url.URL) bool {
+	return u != nil && u.Scheme == Scheme
+}
+
+// ListenPath returns the socket path of a listen address like unix:///var/run/router.sock
+func ListenPath(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, Scheme+"://") {
+		return "", false
+	}
+	return strings.TrimPrefix(addr, Scheme+"://"), true
+}
+
+// SplitURL returns the socket path and the HTTP path of the URL
+func SplitURL(u *url.URL) (socket, path string) {
+	// Relative socket paths end up in the host, e.g. unix://employees.sock:/graphql
+	full := u.Host + u.Path
+	socket, path, found := strings.Cut(full, ":")
+	if !found || path == "" {
+		path = "/"
+	}
+	return socket, path
+}
+
+// URL returns the URL of the HTTP path on the socket
+func URL(socket, path string) string {
+	if path == "" || path == "/" {
+		return Scheme + "://" + socket
+	}
+	return BaseURL(socket) + path
+}
+
+// BaseURL returns the URL of the socket that HTTP paths can be joined to with url.JoinPath
+func BaseURL(socket string) string {
+	return Scheme + "://" + socket + ":"
+}
+
+// Listen listens on the socket path. A stale socket file of a previous process is removed.
+func Listen(socket string) (net.Listener, error) {
+	if socket == "" {
+		return nil, errors.New("unix socket path not provided")
+	}
+
+	if info, err := os.Stat(socket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("unix socket path '%s' exists and is not a socket", socket)
+		}
+		// Only remove the socket when nobody is listening on it anymore
+		if conn, err := net.Dial("unix", socket); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("unix socket '%s' is already in use", socket)
+		}
+		if err := os.Remove(socket); err != nil {
+			return nil, fmt.Errorf("failed to remove stale unix socket '%s': %w", socket, err)
+		}
+	}
+
+	return net.Listen("unix", socket)
+}
+
+// Register enables unix:// URLs on the transport. Requests to a socket are sent as HTTP/1.1 through the
+// connection pool of the transport and are dialed with the dialer.
+func Register(transport *http.Transport, dialer *net.Dialer) {
+	dial := transport.DialContext
+	if dial == nil {
+		dial = dialer.DialContext
+	}
+
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if socket, ok := socketFromAddr(addr); ok {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		return dial(ctx, network, addr)
+	}
+
+	transport.RegisterProtocol(Scheme, &roundTripper{transport: transport})
+}
+
+type roundTripper struct {
+	transport *http.Transport
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	socket, path := SplitURL(req.URL)
+	if socket == "" {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, fmt.Errorf("unix socket path missing in url '%s'", req.URL.String())
+	}
+
+	socketReq := req.Clone(req.Context())
+	socketReq.URL = &url.URL{
+		Scheme:   "http",
+		Host:     hex.EncodeToString([]byte(socket)) + hostSuffix,
+		Path:     path,
+		RawQuery: req.URL.RawQuery,
+	}
+	// Keep an explicitly set host header, the URL host is not a valid host header
+	if socketReq.Host == "" || socketReq.Host == req.URL.Host {
+		socketReq.Host = "localhost"
+	}
+
+	resp, err := t.transport.RoundTrip(socketReq)
+	if resp != nil {
+		resp.Request = req
+	}
+	return resp, err
+}
+
+func socketFromAddr(addr string) (string, bool) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || !strings.HasSuffix(host, hostSuffix) {
+		return "", false
+	}
+	socket, err := hex.DecodeString(strings.TrimSuffix(host, hostSuffix))
+	if err != nil {
+		return "", false
+	}
+	return string(socket), true
+}
@@ -0,0 +1,108 @@
+package unixsocket
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// socketPath returns a short socket path, the path of a socket is limited to about 100 bytes
+func socketPath(t *testing.T, name string) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "sock")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	return filepath.Join(dir, name)
+}
+
+func TestSplitURL(t *testing.T) {
+	cases := []struct {
+		url    string
+		socket string
+		path   string
+	}{
+		{url: "unix:///var/run/employees.sock:/graphql", socket: "/var/run/employees.sock", path: "/graphql"},
+		{url: "unix:///var/run/employees.sock", socket: "/var/run/employees.sock", path: "/"},
+		{url: "unix:///var/run/employees.sock:", socket: "/var/run/employees.sock", path: "/"},
+		{url: "unix://employees.sock:/graphql", socket: "employees.sock", path: "/graphql"},
+	}
+
+	for _, c := range cases {
+		u, err := url.Parse(c.url)
+		require.NoError(t, err)
+
+		socket, path := SplitURL(u)
+		assert.Equal(t, c.socket, socket, c.url)
+		assert.Equal(t, c.path, path, c.url)
+	}
+
+	assert.Equal(t, "unix:///var/run/employees.sock:/graphql", URL("/var/run/employees.sock", "/graphql"))
+
+	joined, err := url.JoinPath(BaseURL("/var/run/router.sock"), "/graphql")
+	require.NoError(t, err)
+	assert.Equal(t, "unix:///var/run/router.sock:/graphql", joined)
+}
+
+func TestRoundTrip(t *testing.T) {
+	socket := socketPath(t, "subgraph.sock")
+
+	ln, err := Listen(socket)
+	require.NoError(t, err)
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Method+" "+r.Host+" "+r.URL.RequestURI()+" "+r.Header.Get("X-Custom"))
+	})}
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	transport := &http.Transport{}
+	Register(transport, &net.Dialer{})
+	client := &http.Client{Transport: transport}
+
+	req, err := http.NewRequest(http.MethodPost, URL(socket, "/graphql")+"?a=b", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Custom", "propagated")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "POST localhost /graphql?a=b propagated", string(body))
+	assert.Equal(t, req, resp.Request)
+}
+
+func TestListenRemovesStaleSocket(t *testing.T) {
+	socket := socketPath(t, "router.sock")
+
+	ln, err := Listen(socket)
+	require.NoError(t, err)
+
+	// A socket in use is not taken over
+	_, err = Listen(socket)
+	require.Error(t, err)
+
+	// Leave the socket file behind like a crashed process
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, ln.Close())
+
+	ln, err = Listen(socket)
+	require.NoError(t, err)
+	require.NoError(t, ln.Close())
+
+	// Other files are never removed
+	file := socketPath(t, "file")
+	require.NoError(t, os.WriteFile(file, []byte("data"), 0600))
+	_, err = Listen(file)
+	require.Error(t, err)
+}
@@ -106,7 +106,7 @@ func (p *absintheWSProtocol) Subprotocol() string {
 	return GraphQLWSSubprotocol
 }
 
-func (p *absintheWSProtocol) Initialize() (json.RawMessage, error) {
+func (p *absintheWSProtocol) Initialize(validate InitialPayloadValidator) (json.RawMessage, error) {
 	var msg absintheMessage
 	if err := p.conn.ReadJSON(&msg); err != nil {
 		return nil, fmt.Errorf("error reading phx_join: %w", err)
@@ -114,6 +114,20 @@ func (p *absintheWSProtocol) Initialize() (json.RawMessage, error) {
 	if msg.Type != absintheMessageEventTypeJoin {
 		return nil, fmt.Errorf("first message should be %s, got %s", absintheMessageEventTypeJoin, msg.Type)
 	}
+	if validate != nil {
+		if err := validate(msg.Payload); err != nil {
+			if wErr := p.conn.WriteJSON(absintheMessage{
+				ID:       msg.ID,
+				Channel:  msg.Channel,
+				Protocol: "__absinthe__:control",
+				Type:     absintheMessageEventTypeReply,
+				Payload:  absintheErrorPayload,
+			}); wErr != nil {
+				return nil, fmt.Errorf("sending %s: for join %w", absintheMessageEventTypeReply, wErr)
+			}
+			return nil, fmt.Errorf("%w: %w", ErrInitialPayloadRejected, err)
+		}
+	}
 	if err := p.conn.WriteJSON(absintheMessage{
 		ID:       msg.ID,
 		Channel:  msg.Channel,
@@ -173,6 +187,11 @@ func (p *absintheWSProtocol) Pong(msg *Message) error {
 	})
 }
 
+// Ping sends nothing, Phoenix heartbeats are sent by the client
+func (p *absintheWSProtocol) Ping() (bool, error) {
+	return false, nil
+}
+
 func (p *absintheWSProtocol) WriteGraphQLData(id string, data json.RawMessage, extensions json.RawMessage) error {
 	return p.conn.WriteJSON(absintheMessage{
 		ID:       &id,
@@ -46,7 +46,7 @@ func (p *graphQLWSProtocol) Subprotocol() string {
 	return GraphQLWSSubprotocol
 }
 
-func (p *graphQLWSProtocol) Initialize() (json.RawMessage, error) {
+func (p *graphQLWSProtocol) Initialize(validate InitialPayloadValidator) (json.RawMessage, error) {
 	// First message must be a connection_init
 	var msg graphQLWSMessage
 	if err := p.conn.ReadJSON(&msg); err != nil {
@@ -55,6 +55,12 @@ func (p *graphQLWSProtocol) Initialize() (json.RawMessage, error) {
 	if msg.Type != graphQLWSMessageTypeConnectionInit {
 		return nil, fmt.Errorf("first message should be %s, got %s", graphQLWSMessageTypeConnectionInit, msg.Type)
 	}
+	if validate != nil {
+		if err := validate(msg.Payload); err != nil {
+			// The protocol expects the server to close the connection with 4403: Forbidden, which is up to the caller
+			return nil, fmt.Errorf("%w: %w", ErrInitialPayloadRejected, err)
+		}
+	}
 	if err := p.conn.WriteJSON(graphQLWSMessage{Type: graphQLWSMessageTypeConnectionAck}); err != nil {
 		return nil, fmt.Errorf("sending %s: %w", graphQLWSMessageTypeConnectionAck, err)
 	}
@@ -68,6 +74,8 @@ func (p *graphQLWSProtocol) ReadMessage() (*Message, error) {
 	}
 	var messageType MessageType
 	switch msg.Type {
+	case graphQLWSMessageTypeConnectionInit:
+		messageType = MessageTypeInit
 	case graphQLWSMessageTypePing:
 		messageType = MessageTypePing
 	case graphQLWSMessageTypePong:
@@ -91,6 +99,10 @@ func (p *graphQLWSProtocol) Pong(msg *Message) error {
 	return p.conn.WriteJSON(graphQLWSMessage{ID: msg.ID, Type: graphQLWSMessageTypePong, Payload: msg.Payload})
 }
 
+func (p *graphQLWSProtocol) Ping() (bool, error) {
+	return true, p.conn.WriteJSON(graphQLWSMessage{Type: graphQLWSMessageTypePing})
+}
+
 func (p *graphQLWSProtocol) WriteGraphQLData(id string, data json.RawMessage, extensions json.RawMessage) error {
 	return p.conn.WriteJSON(graphQLWSMessage{
 		ID:         id,
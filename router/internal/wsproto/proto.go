@@ -2,23 +2,36 @@ package wsproto
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 )
 
+// ErrInitialPayloadRejected is returned by Initialize when the InitialPayloadValidator rejected the payload
+var ErrInitialPayloadRejected = errors.New("initial payload rejected")
+
 type Proto interface {
 	Subprotocol() string
-	// Initialize starts the protocol and returns the initial payload received from the client
-	Initialize() (json.RawMessage, error)
+	// Initialize starts the protocol and returns the initial payload received from the client.
+	// The connection is only acknowledged if validate accepts the payload, validate might be nil.
+	Initialize(validate InitialPayloadValidator) (json.RawMessage, error)
 	ReadMessage() (*Message, error)
 
 	Pong(*Message) error
+	// Ping sends a keep-alive message to the client. It returns true if the protocol requires
+	// the client to answer with a pong, protocols without server pings send nothing.
+	Ping() (bool, error)
 	WriteGraphQLData(id string, data json.RawMessage, extensions json.RawMessage) error
 	WriteGraphQLErrors(id string, errors json.RawMessage, extensions json.RawMessage) error
 	// Done is sent to indicate the requested operation is done and no more results will come in
 	Done(id string) error
 }
 
+// InitialPayloadValidator is called with the initial payload before the connection is acknowledged.
+// If it returns an error, the client is notified as defined by the protocol and Initialize returns
+// the error wrapped in ErrInitialPayloadRejected.
+type InitialPayloadValidator func(payload json.RawMessage) error
+
 type JSONConn interface {
 	ReadJSON(v interface{}) error
 	WriteJSON(v interface{}) error
@@ -33,6 +46,8 @@ const (
 	MessageTypeSubscribe
 	MessageTypeComplete
 	MessageTypeTerminate
+	// MessageTypeInit is a connection_init message received after the connection was initialized
+	MessageTypeInit
 )
 
 type Message struct {
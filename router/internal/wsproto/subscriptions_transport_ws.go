@@ -50,7 +50,7 @@ func (p *subscriptionsTransportWSProtocol) Subprotocol() string {
 	return SubscriptionsTransportWSSubprotocol
 }
 
-func (p *subscriptionsTransportWSProtocol) Initialize() (json.RawMessage, error) {
+func (p *subscriptionsTransportWSProtocol) Initialize(validate InitialPayloadValidator) (json.RawMessage, error) {
 	// First message must be a connection_init
 	var msg subscriptionsTransportWSMessage
 	if err := p.conn.ReadJSON(&msg); err != nil {
@@ -59,6 +59,15 @@ func (p *subscriptionsTransportWSProtocol) Initialize() (json.RawMessage, error)
 	if msg.Type != subscriptionsTransportWSMessageTypeConnectionInit {
 		return nil, fmt.Errorf("first message should be %s, got %s", subscriptionsTransportWSMessageTypeConnectionInit, msg.Type)
 	}
+	if validate != nil {
+		if err := validate(msg.Payload); err != nil {
+			payload, _ := json.Marshal(map[string]string{"message": err.Error()})
+			if wErr := p.conn.WriteJSON(subscriptionsTransportWSMessage{Type: subscriptionsTransportWSMessageTypeConnectionError, Payload: payload}); wErr != nil {
+				return nil, fmt.Errorf("sending %s: %w", subscriptionsTransportWSMessageTypeConnectionError, wErr)
+			}
+			return nil, fmt.Errorf("%w: %w", ErrInitialPayloadRejected, err)
+		}
+	}
 	if err := p.conn.WriteJSON(subscriptionsTransportWSMessage{Type: subscriptionsTransportWSMessageTypeConnectionAck}); err != nil {
 		return nil, fmt.Errorf("sending %s: %w", subscriptionsTransportWSMessageTypeConnectionAck, err)
 	}
@@ -72,6 +81,8 @@ func (p *subscriptionsTransportWSProtocol) ReadMessage() (*Message, error) {
 	}
 	var messageType MessageType
 	switch msg.Type {
+	case subscriptionsTransportWSMessageTypeConnectionInit:
+		messageType = MessageTypeInit
 	case subscriptionsTransportWSMessageTypeConnectionTerminate:
 		messageType = MessageTypeTerminate
 	case subscriptionsTransportWSMessageTypeStart:
@@ -97,6 +108,11 @@ func (p *subscriptionsTransportWSProtocol) Pong(msg *Message) error {
 	})
 }
 
+// Ping sends a keep-alive message, the protocol has no pong
+func (p *subscriptionsTransportWSProtocol) Ping() (bool, error) {
+	return false, p.conn.WriteJSON(subscriptionsTransportWSMessage{Type: subscriptionsTransportWSMessageTypeKeepAlive})
+}
+
 func (p *subscriptionsTransportWSProtocol) WriteGraphQLData(id string, data json.RawMessage, extensions json.RawMessage) error {
 	return p.conn.WriteJSON(subscriptionsTransportWSMessage{
 		ID:         id,
@@ -0,0 +1,139 @@
+package authentication
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	defaultAPIKeyHeaderName = "X-API-Key"
+	apiKeyHashPrefix        = "sha256:"
+)
+
+var errUnknownAPIKey = errors.New("unknown API key")
+
+// APIKeyFile is the file of the API key authenticator. The keys are stored as SHA-256 hashes, so
+// the file doesn't have to be kept secret:
+//
+//	keys:
+//	  - name: ci
+//	    hash: sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	    scopes: ["read:employee"]
+type APIKeyFile struct {
+	Keys []APIKey `yaml:"keys"`
+}
+
+type APIKey struct {
+	// Name identifies the key holder. It is returned as sub claim.
+	Name string `yaml:"name"`
+	// Hash is the SHA-256 hash of the key, hex encoded with the sha256: prefix
+	Hash string `yaml:"hash"`
+	// Scopes are returned as space separated scope claim
+	Scopes []string `yaml:"scopes"`
+}
+
+type apiKeyAuthenticator struct {
+	name string
+	// keys maps the hash of a key to its claims
+	keys                map[[sha256.Size]byte]Claims
+	headerNames         []string
+	headerValuePrefixes []string
+}
+
+func (a *apiKeyAuthenticator) Name() string {
+	return a.name
+}
+
+func (a *apiKeyAuthenticator) Authenticate(ctx context.Context, p Provider) (Claims, error) {
+	var errs error
+	for _, key := range headerTokens(p.AuthenticationHeaders(), a.headerNames, a.headerValuePrefixes) {
+		claims, ok := a.keys[sha256.Sum256([]byte(key))]
+		if !ok {
+			errs = errors.Join(errs, &ValidationError{Reason: FailureReasonUnknownAPIKey, Err: errUnknownAPIKey})
+			continue
+		}
+		// Claims can be modified by the caller, never return the stored map
+		result := make(Claims, len(claims))
+		for name, value := range claims {
+			result[name] = value
+		}
+		return result, nil
+	}
+	return nil, errs
+}
+
+// APIKeyAuthenticatorOptions contains the available options for the API key authenticator
+type APIKeyAuthenticatorOptions struct {
+	// Name is the authenticator name. It cannot be empty.
+	Name string
+	// KeyFile is the path of the APIKeyFile, it is mandatory.
+	KeyFile string
+	// HeaderNames are the header names to use for retrieving the key. It defaults to
+	// X-API-Key
+	HeaderNames []string
+	// HeaderValuePrefixes are the prefixes to use for retrieving the key. It defaults to
+	// the whole header value.
+	HeaderValuePrefixes []string
+}
+
+// NewAPIKeyAuthenticator returns an authenticator validating opaque API keys against the hashes
+// of a key file. See APIKeyAuthenticatorOptions for the available options.
+func NewAPIKeyAuthenticator(opts APIKeyAuthenticatorOptions) (Authenticator, error) {
+	if opts.Name == "" {
+		return nil, fmt.Errorf("authenticator Name must be provided")
+	}
+
+	data, err := os.ReadFile(opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read API key file: %w", err)
+	}
+
+	var file APIKeyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("could not parse API key file: %w", err)
+	}
+
+	keys := make(map[[sha256.Size]byte]Claims, len(file.Keys))
+	for i, key := range file.Keys {
+		if key.Name == "" {
+			return nil, fmt.Errorf("API key #%d has no name", i)
+		}
+		hexHash, ok := strings.CutPrefix(key.Hash, apiKeyHashPrefix)
+		if !ok {
+			return nil, fmt.Errorf("hash of API key %q must start with %q", key.Name, apiKeyHashPrefix)
+		}
+		hash, err := hex.DecodeString(hexHash)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("hash of API key %q is not a hex encoded SHA-256 hash", key.Name)
+		}
+
+		claims := Claims{"sub": key.Name}
+		if len(key.Scopes) > 0 {
+			claims["scope"] = strings.Join(key.Scopes, " ")
+		}
+		keys[[sha256.Size]byte(hash)] = claims
+	}
+
+	headerNames := opts.HeaderNames
+	if len(headerNames) == 0 {
+		headerNames = []string{defaultAPIKeyHeaderName}
+	}
+	headerValuePrefixes := opts.HeaderValuePrefixes
+	if len(headerValuePrefixes) == 0 {
+		headerValuePrefixes = []string{""}
+	}
+
+	return &apiKeyAuthenticator{
+		name:                opts.Name,
+		keys:                keys,
+		headerNames:         headerNames,
+		headerValuePrefixes: headerValuePrefixes,
+	}, nil
+}
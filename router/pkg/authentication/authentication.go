@@ -3,6 +3,7 @@ package authentication
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 )
@@ -75,7 +76,7 @@ func Authenticate(ctx context.Context, authenticators []Authenticator, p Provide
 		if err != nil {
 			// If authentication fails for one provider, we try the
 			// rest before returning an error.
-			joinedErrors = errors.Join(joinedErrors, err)
+			joinedErrors = errors.Join(joinedErrors, &authenticatorError{authenticator: auth.Name(), err: err})
 			continue
 		}
 		if claims != nil {
@@ -89,3 +90,65 @@ func Authenticate(ctx context.Context, authenticators []Authenticator, p Provide
 	// even if to claims were found.
 	return nil, joinedErrors
 }
+
+// authenticatorError is the error of an authenticator in the joined error of Authenticate
+type authenticatorError struct {
+	authenticator string
+	err           error
+}
+
+func (e *authenticatorError) Error() string {
+	return fmt.Sprintf("%s: %v", e.authenticator, e.err)
+}
+
+func (e *authenticatorError) Unwrap() error {
+	return e.err
+}
+
+// Failure is the reason an authenticator rejected the authentication information
+type Failure struct {
+	Authenticator string
+	Reason        FailureReason
+	Err           error
+}
+
+// Failures returns the failures of every authenticator in an error returned by Authenticate
+func Failures(err error) []Failure {
+	var failures []Failure
+	walkErrors(err, func(err error) bool {
+		authErr, ok := err.(*authenticatorError)
+		if !ok {
+			return true
+		}
+		reasons := 0
+		walkErrors(authErr.err, func(err error) bool {
+			validationErr, ok := err.(*ValidationError)
+			if !ok {
+				return true
+			}
+			failures = append(failures, Failure{Authenticator: authErr.authenticator, Reason: validationErr.Reason, Err: validationErr})
+			reasons++
+			return false
+		})
+		if reasons == 0 {
+			failures = append(failures, Failure{Authenticator: authErr.authenticator, Reason: FailureReasonInvalid, Err: authErr.err})
+		}
+		return false
+	})
+	return failures
+}
+
+// walkErrors calls visit for the error and, as long as visit returns true, its wrapped errors
+func walkErrors(err error, visit func(err error) bool) {
+	if err == nil || !visit(err) {
+		return
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, wrapped := range e.Unwrap() {
+			walkErrors(wrapped, visit)
+		}
+	case interface{ Unwrap() error }:
+		walkErrors(e.Unwrap(), visit)
+	}
+}
@@ -3,6 +3,7 @@ package authentication
 import (
 	"context"
 	"net/http"
+	"strings"
 )
 
 type httpRequestProvider http.Request
@@ -17,3 +18,23 @@ func AuthenticateHTTPRequest(ctx context.Context, authenticators []Authenticator
 	provider := (*httpRequestProvider)(r)
 	return Authenticate(ctx, authenticators, provider)
 }
+
+// headerTokens returns the values of the headers that start with one of the prefixes, with the
+// prefix removed. An empty prefix matches any non-empty value.
+func headerTokens(headers http.Header, headerNames, headerValuePrefixes []string) []string {
+	var tokens []string
+	for _, header := range headerNames {
+		value := headers.Get(header)
+		if value == "" {
+			continue
+		}
+		for _, prefix := range headerValuePrefixes {
+			if strings.HasPrefix(value, prefix) {
+				if token := strings.TrimSpace(value[len(prefix):]); token != "" {
+					tokens = append(tokens, token)
+				}
+			}
+		}
+	}
+	return tokens
+}
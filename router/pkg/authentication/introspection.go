@@ -0,0 +1,235 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+)
+
+var errInactiveToken = errors.New("token is not active")
+
+type introspectionAuthenticator struct {
+	name                string
+	url                 string
+	clientID            string
+	clientSecret        string
+	tokenTypeHint       string
+	headerNames         []string
+	headerValuePrefixes []string
+	httpClient          *http.Client
+	cacheTTL            time.Duration
+	// cache stores the claims of active tokens and errInactiveToken for inactive tokens by token
+	cache *ristretto.Cache
+}
+
+func (a *introspectionAuthenticator) Name() string {
+	return a.name
+}
+
+func (a *introspectionAuthenticator) Authenticate(ctx context.Context, p Provider) (Claims, error) {
+	var errs error
+	for _, token := range headerTokens(p.AuthenticationHeaders(), a.headerNames, a.headerValuePrefixes) {
+		claims, err := a.introspect(ctx, token)
+		if errors.Is(err, errInactiveToken) {
+			err = &ValidationError{Reason: FailureReasonInactive, Err: err}
+		}
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("could not validate token: %w", err))
+			continue
+		}
+		return claims, nil
+	}
+	return nil, errs
+}
+
+func (a *introspectionAuthenticator) introspect(ctx context.Context, token string) (Claims, error) {
+	if a.cache != nil {
+		if item, ok := a.cache.Get(token); ok {
+			if claims, ok := item.(Claims); ok {
+				// Claims can be modified by the caller, never return the cached map
+				return maps.Clone(claims), nil
+			}
+			return nil, errInactiveToken
+		}
+	}
+
+	claims, ttl, err := a.request(ctx, token)
+	if err != nil && !errors.Is(err, errInactiveToken) {
+		// Failures of the endpoint are not cached
+		return nil, err
+	}
+
+	if a.cache != nil {
+		if ttl <= 0 || ttl > a.cacheTTL {
+			ttl = a.cacheTTL
+		}
+		if err != nil {
+			a.cache.SetWithTTL(token, err, 1, ttl)
+		} else {
+			a.cache.SetWithTTL(token, maps.Clone(claims), 1, ttl)
+		}
+	}
+
+	return claims, err
+}
+
+// request introspects the token as described in RFC 7662. It returns the claims of an active token
+// and the duration until the token expires, if the response contains the expiration.
+func (a *introspectionAuthenticator) request(ctx context.Context, token string) (Claims, time.Duration, error) {
+	form := url.Values{"token": {token}}
+	if a.tokenTypeHint != "" {
+		form.Set("token_type_hint", a.tokenTypeHint)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if a.clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret))
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("introspection request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, 0, fmt.Errorf("introspection endpoint responded with status %d", resp.StatusCode)
+	}
+
+	var claims Claims
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, 0, fmt.Errorf("could not decode introspection response: %w", err)
+	}
+
+	if active, _ := claims["active"].(bool); !active {
+		return nil, 0, errInactiveToken
+	}
+	delete(claims, "active")
+
+	var ttl time.Duration
+	if exp, ok := claims["exp"].(float64); ok {
+		ttl = time.Until(time.Unix(int64(exp), 0))
+		if ttl <= 0 {
+			return nil, 0, errInactiveToken
+		}
+	}
+
+	return claims, ttl, nil
+}
+
+// IntrospectionAuthenticatorOptions contains the available options for the OAuth2 token
+// introspection authenticator
+type IntrospectionAuthenticatorOptions struct {
+	// Name is the authenticator name. It cannot be empty.
+	Name string
+	// URL is the URL of the introspection endpoint, it is mandatory.
+	URL string
+	// ClientID and ClientSecret authenticate the router at the introspection endpoint
+	// with HTTP Basic authentication
+	ClientID     string
+	ClientSecret string
+	// TokenTypeHint is sent as token_type_hint. It defaults to access_token
+	TokenTypeHint string
+	// HeaderNames are the header names to use for retrieving the token. It defaults to
+	// Authorization
+	HeaderNames []string
+	// HeaderValuePrefixes are the prefixes to use for retrieving the token. It defaults to
+	// Bearer
+	HeaderValuePrefixes []string
+	// CacheTTL is the maximum duration the result of an introspection is cached. Results are never
+	// cached past the expiration of the token. It defaults to 1 minute, caching is disabled when negative.
+	CacheTTL time.Duration
+	// CacheSize is the maximum number of cached tokens. It defaults to 10000.
+	CacheSize int64
+	// Timeout is the timeout of the introspection requests. It defaults to 5 seconds.
+	Timeout time.Duration
+	// HTTPClient is the client used for the introspection requests. It defaults to a client
+	// with the Timeout.
+	HTTPClient *http.Client
+}
+
+// NewIntrospectionAuthenticator returns an authenticator validating opaque tokens with an OAuth2
+// token introspection endpoint (RFC 7662). See IntrospectionAuthenticatorOptions for the available options.
+func NewIntrospectionAuthenticator(opts IntrospectionAuthenticatorOptions) (Authenticator, error) {
+	if opts.Name == "" {
+		return nil, fmt.Errorf("authenticator Name must be provided")
+	}
+	if opts.URL == "" {
+		return nil, fmt.Errorf("introspection URL must be provided")
+	}
+	if _, err := url.ParseRequestURI(opts.URL); err != nil {
+		return nil, fmt.Errorf("invalid introspection URL %q: %w", opts.URL, err)
+	}
+
+	headerNames := opts.HeaderNames
+	if len(headerNames) == 0 {
+		headerNames = []string{defaultHeaderName}
+	}
+	headerValuePrefixes := opts.HeaderValuePrefixes
+	if len(headerValuePrefixes) == 0 {
+		headerValuePrefixes = []string{defaultHeaderValuePrefix}
+	}
+	tokenTypeHint := opts.TokenTypeHint
+	if tokenTypeHint == "" {
+		tokenTypeHint = "access_token"
+	}
+
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		timeout := opts.Timeout
+		if timeout <= 0 {
+			timeout = 5 * time.Second
+		}
+		httpClient = &http.Client{Timeout: timeout}
+	}
+
+	cacheTTL := opts.CacheTTL
+	if cacheTTL == 0 {
+		cacheTTL = time.Minute
+	}
+
+	a := &introspectionAuthenticator{
+		name:                opts.Name,
+		url:                 opts.URL,
+		clientID:            opts.ClientID,
+		clientSecret:        opts.ClientSecret,
+		tokenTypeHint:       tokenTypeHint,
+		headerNames:         headerNames,
+		headerValuePrefixes: headerValuePrefixes,
+		httpClient:          httpClient,
+		cacheTTL:            cacheTTL,
+	}
+
+	if cacheTTL > 0 {
+		cacheSize := opts.CacheSize
+		if cacheSize <= 0 {
+			cacheSize = 10000
+		}
+		cache, err := ristretto.NewCache(&ristretto.Config{
+			NumCounters: cacheSize * 10,
+			MaxCost:     cacheSize,
+			BufferItems: 64,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("initializing introspection cache: %w", err)
+		}
+		a.cache = cache
+	}
+
+	return a, nil
+}
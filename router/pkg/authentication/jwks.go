@@ -4,11 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/MicahParks/keyfunc/v2"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 const (
@@ -23,6 +21,7 @@ type jwksAuthenticator struct {
 	name                string
 	headerNames         []string
 	headerValuePrefixes []string
+	validator           *tokenValidator
 }
 
 func (a *jwksAuthenticator) Name() string {
@@ -30,22 +29,14 @@ func (a *jwksAuthenticator) Name() string {
 }
 
 func (a *jwksAuthenticator) Authenticate(ctx context.Context, p Provider) (Claims, error) {
-	headers := p.AuthenticationHeaders()
 	var errs error
-	for _, header := range a.headerNames {
-		authorization := headers.Get(header)
-		for _, prefix := range a.headerValuePrefixes {
-			if strings.HasPrefix(authorization, prefix) {
-				tokenString := strings.TrimSpace(authorization[len(prefix):])
-				token, err := jwt.Parse(tokenString, a.jwks.Keyfunc)
-				if err != nil {
-					errs = errors.Join(errs, fmt.Errorf("could not validate token: %w", err))
-					continue
-				}
-				claims := token.Claims.(jwt.MapClaims)
-				return Claims(claims), nil
-			}
+	for _, tokenString := range headerTokens(p.AuthenticationHeaders(), a.headerNames, a.headerValuePrefixes) {
+		claims, err := a.validator.parse(tokenString, a.jwks.Keyfunc)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("could not validate token: %w", err))
+			continue
 		}
+		return claims, nil
 	}
 	return nil, errs
 }
@@ -65,6 +56,8 @@ type JWKSAuthenticatorOptions struct {
 	// RefreshInterval is the minimum time interval between two JWKS refreshes. It
 	// defaults to 1 minute.
 	RefreshInterval time.Duration
+	// Validation are the checks of the token claims
+	Validation TokenValidationOptions
 }
 
 // NewJWKSAuthenticator returns a JWKS based authenticator. See JWKSAuthenticatorOptions
@@ -94,5 +87,6 @@ func NewJWKSAuthenticator(opts JWKSAuthenticatorOptions) (Authenticator, error)
 		name:                opts.Name,
 		headerNames:         headerNames,
 		headerValuePrefixes: headerValuePrefixes,
+		validator:           newTokenValidator(opts.Validation),
 	}, nil
 }
@@ -0,0 +1,166 @@
+package authentication
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	hmacAlgorithms  = []string{"HS256", "HS384", "HS512"}
+	rsaAlgorithms   = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	ecdsaAlgorithms = []string{"ES256", "ES384", "ES512"}
+)
+
+type jwtAuthenticator struct {
+	name                string
+	key                 any
+	headerNames         []string
+	headerValuePrefixes []string
+	validator           *tokenValidator
+}
+
+func (a *jwtAuthenticator) Name() string {
+	return a.name
+}
+
+func (a *jwtAuthenticator) Authenticate(ctx context.Context, p Provider) (Claims, error) {
+	var errs error
+	for _, tokenString := range headerTokens(p.AuthenticationHeaders(), a.headerNames, a.headerValuePrefixes) {
+		claims, err := a.validator.parse(tokenString, func(token *jwt.Token) (any, error) {
+			return a.key, nil
+		})
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("could not validate token: %w", err))
+			continue
+		}
+		return claims, nil
+	}
+	return nil, errs
+}
+
+// JWTAuthenticatorOptions contains the available options for the JWT authenticator. Exactly one
+// of Secret, SecretFile, PublicKey and PublicKeyFile must be provided.
+type JWTAuthenticatorOptions struct {
+	// Name is the authenticator name. It cannot be empty.
+	Name string
+	// Secret is the shared secret of tokens signed with HMAC
+	Secret string
+	// SecretFile is a file containing the shared secret of tokens signed with HMAC
+	SecretFile string
+	// PublicKey is the PEM encoded RSA or ECDSA public key of the token issuer
+	PublicKey string
+	// PublicKeyFile is a file containing the PEM encoded RSA or ECDSA public key of the token issuer
+	PublicKeyFile string
+	// HeaderNames are the header names to use for retrieving the token. It defaults to
+	// Authorization
+	HeaderNames []string
+	// HeaderValuePrefixes are the prefixes to use for retrieving the token. It defaults to
+	// Bearer
+	HeaderValuePrefixes []string
+	// Validation are the checks of the token claims. The algorithms default to all the algorithms
+	// of the key type.
+	Validation TokenValidationOptions
+}
+
+// NewJWTAuthenticator returns an authenticator validating tokens with a static HMAC secret or
+// public key. See JWTAuthenticatorOptions for the available options.
+func NewJWTAuthenticator(opts JWTAuthenticatorOptions) (Authenticator, error) {
+	if opts.Name == "" {
+		return nil, fmt.Errorf("authenticator Name must be provided")
+	}
+
+	provided := 0
+	for _, value := range []string{opts.Secret, opts.SecretFile, opts.PublicKey, opts.PublicKeyFile} {
+		if value != "" {
+			provided++
+		}
+	}
+	if provided != 1 {
+		return nil, fmt.Errorf("exactly one of secret, secret file, public key and public key file must be provided")
+	}
+
+	var (
+		key        any
+		algorithms []string
+	)
+
+	switch {
+	case opts.Secret != "" || opts.SecretFile != "":
+		secret := []byte(opts.Secret)
+		if opts.SecretFile != "" {
+			data, err := os.ReadFile(opts.SecretFile)
+			if err != nil {
+				return nil, fmt.Errorf("could not read secret file: %w", err)
+			}
+			secret = bytes.TrimRight(data, "\r\n")
+		}
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("secret must have at least 32 bytes")
+		}
+		key, algorithms = secret, hmacAlgorithms
+	default:
+		data := []byte(opts.PublicKey)
+		if opts.PublicKeyFile != "" {
+			var err error
+			data, err = os.ReadFile(opts.PublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("could not read public key file: %w", err)
+			}
+		}
+		if rsaKey, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+			key, algorithms = rsaKey, rsaAlgorithms
+		} else if ecdsaKey, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+			key, algorithms = ecdsaKey, ecdsaAlgorithms
+		} else {
+			return nil, fmt.Errorf("public key must be a PEM encoded RSA or ECDSA key")
+		}
+	}
+
+	// Restricting the algorithms to the key type prevents tokens signed with HMAC using the public key as secret
+	validation := opts.Validation
+	if len(validation.Algorithms) > 0 {
+		for _, algorithm := range validation.Algorithms {
+			if !slices.Contains(algorithms, algorithm) {
+				return nil, fmt.Errorf("algorithm %q can't be used with a %s", algorithm, keyTypeName(key))
+			}
+		}
+	} else {
+		validation.Algorithms = algorithms
+	}
+
+	headerNames := opts.HeaderNames
+	if len(headerNames) == 0 {
+		headerNames = []string{defaultHeaderName}
+	}
+	headerValuePrefixes := opts.HeaderValuePrefixes
+	if len(headerValuePrefixes) == 0 {
+		headerValuePrefixes = []string{defaultHeaderValuePrefix}
+	}
+
+	return &jwtAuthenticator{
+		name:                opts.Name,
+		key:                 key,
+		headerNames:         headerNames,
+		headerValuePrefixes: headerValuePrefixes,
+		validator:           newTokenValidator(validation),
+	}, nil
+}
+
+func keyTypeName(key any) string {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return "RSA key"
+	case *ecdsa.PublicKey:
+		return "ECDSA key"
+	default:
+		return "secret"
+	}
+}
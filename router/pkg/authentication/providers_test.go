@@ -0,0 +1,248 @@
+package authentication
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type headerProvider http.Header
+
+func (p headerProvider) AuthenticationHeaders() http.Header {
+	return http.Header(p)
+}
+
+func bearer(token string) headerProvider {
+	return headerProvider{"Authorization": []string{"Bearer " + token}}
+}
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestJWTAuthenticatorSecret(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte(testSecret+"\n"), 0o600))
+
+	for name, opts := range map[string]JWTAuthenticatorOptions{
+		"inline": {Name: "jwt", Secret: testSecret},
+		"file":   {Name: "jwt", SecretFile: secretFile},
+	} {
+		t.Run(name, func(t *testing.T) {
+			authenticator, err := NewJWTAuthenticator(opts)
+			require.NoError(t, err)
+
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-1"}).SignedString([]byte(testSecret))
+			require.NoError(t, err)
+
+			claims, err := authenticator.Authenticate(context.Background(), bearer(token))
+			require.NoError(t, err)
+			require.Equal(t, "user-1", claims["sub"])
+
+			token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-1"}).SignedString([]byte("another secret of at least 32 bytes"))
+			require.NoError(t, err)
+
+			_, err = authenticator.Authenticate(context.Background(), bearer(token))
+			require.Error(t, err)
+
+			// No token is no error
+			claims, err = authenticator.Authenticate(context.Background(), headerProvider{})
+			require.NoError(t, err)
+			require.Nil(t, claims)
+		})
+	}
+}
+
+func TestJWTAuthenticatorPublicKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	authenticator, err := NewJWTAuthenticator(JWTAuthenticatorOptions{
+		Name:       "jwt",
+		PublicKey:  string(publicKey),
+		Validation: TokenValidationOptions{Algorithms: []string{"RS256"}},
+	})
+	require.NoError(t, err)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user-1"}).SignedString(key)
+	require.NoError(t, err)
+	claims, err := authenticator.Authenticate(context.Background(), bearer(token))
+	require.NoError(t, err)
+	require.Equal(t, "user-1", claims["sub"])
+
+	// Not in the allowed algorithms
+	token, err = jwt.NewWithClaims(jwt.SigningMethodPS256, jwt.MapClaims{"sub": "user-1"}).SignedString(key)
+	require.NoError(t, err)
+	_, err = authenticator.Authenticate(context.Background(), bearer(token))
+	require.Error(t, err)
+
+	// HMAC tokens signed with the public key must be rejected
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-1"}).SignedString(publicKey)
+	require.NoError(t, err)
+	_, err = authenticator.Authenticate(context.Background(), bearer(token))
+	require.Error(t, err)
+}
+
+func TestInvalidJWTAuthenticator(t *testing.T) {
+	_, err := NewJWTAuthenticator(JWTAuthenticatorOptions{Name: "jwt"})
+	require.Error(t, err)
+
+	_, err = NewJWTAuthenticator(JWTAuthenticatorOptions{Name: "jwt", Secret: "short"})
+	require.ErrorContains(t, err, "at least 32 bytes")
+
+	_, err = NewJWTAuthenticator(JWTAuthenticatorOptions{Name: "jwt", Secret: testSecret, Validation: TokenValidationOptions{Algorithms: []string{"RS256"}}})
+	require.ErrorContains(t, err, "can't be used with a secret")
+}
+
+func TestIntrospectionAuthenticator(t *testing.T) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || clientID != "router" || clientSecret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "access_token", r.PostForm.Get("token_type_hint"))
+
+		response := map[string]any{"active": false}
+		switch r.PostForm.Get("token") {
+		case "active":
+			response = map[string]any{
+				"active": true,
+				"sub":    "user-1",
+				"scope":  "read:employee",
+				"exp":    time.Now().Add(time.Hour).Unix(),
+			}
+		case "expired":
+			response = map[string]any{
+				"active": true,
+				"exp":    time.Now().Add(-time.Minute).Unix(),
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	authenticator, err := NewIntrospectionAuthenticator(IntrospectionAuthenticatorOptions{
+		Name:         "introspection",
+		URL:          server.URL,
+		ClientID:     "router",
+		ClientSecret: "secret",
+	})
+	require.NoError(t, err)
+
+	claims, err := authenticator.Authenticate(context.Background(), bearer("active"))
+	require.NoError(t, err)
+	require.Equal(t, "user-1", claims["sub"])
+	require.Equal(t, "read:employee", claims["scope"])
+	require.NotContains(t, claims, "active")
+	// Modifying the claims doesn't affect the cached ones
+	claims["sub"] = "modified"
+
+	_, err = authenticator.Authenticate(context.Background(), bearer("inactive"))
+	require.ErrorIs(t, err, errInactiveToken)
+
+	_, err = authenticator.Authenticate(context.Background(), bearer("expired"))
+	require.ErrorIs(t, err, errInactiveToken)
+
+	// The results are cached, ristretto applies writes asynchronously
+	introspection := authenticator.(*introspectionAuthenticator)
+	introspection.cache.Wait()
+	before := requests.Load()
+
+	claims, err = authenticator.Authenticate(context.Background(), bearer("active"))
+	require.NoError(t, err)
+	require.Equal(t, "user-1", claims["sub"])
+	claims["sub"] = "modified"
+	claims, err = authenticator.Authenticate(context.Background(), bearer("active"))
+	require.NoError(t, err)
+	require.Equal(t, "user-1", claims["sub"])
+	_, err = authenticator.Authenticate(context.Background(), bearer("inactive"))
+	require.ErrorIs(t, err, errInactiveToken)
+	require.Equal(t, before, requests.Load())
+}
+
+func TestIntrospectionAuthenticatorEndpointFailure(t *testing.T) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	authenticator, err := NewIntrospectionAuthenticator(IntrospectionAuthenticatorOptions{
+		Name: "introspection",
+		URL:  server.URL,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = authenticator.Authenticate(context.Background(), bearer("active"))
+		require.ErrorContains(t, err, "status 500")
+		authenticator.(*introspectionAuthenticator).cache.Wait()
+	}
+
+	// Failures are not cached
+	require.Equal(t, int32(2), requests.Load())
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	hash := sha256.Sum256([]byte("my-api-key"))
+	keyFile := filepath.Join(t.TempDir(), "api_keys.yaml")
+	require.NoError(t, os.WriteFile(keyFile, []byte(`keys:
+  - name: ci
+    hash: sha256:`+hex.EncodeToString(hash[:])+`
+    scopes: ["read:employee", "read:private"]
+`), 0o600))
+
+	authenticator, err := NewAPIKeyAuthenticator(APIKeyAuthenticatorOptions{
+		Name:    "api-key",
+		KeyFile: keyFile,
+	})
+	require.NoError(t, err)
+
+	claims, err := authenticator.Authenticate(context.Background(), headerProvider{"X-Api-Key": []string{"my-api-key"}})
+	require.NoError(t, err)
+	require.Equal(t, Claims{"sub": "ci", "scope": "read:employee read:private"}, claims)
+
+	_, err = authenticator.Authenticate(context.Background(), headerProvider{"X-Api-Key": []string{"wrong-key"}})
+	require.ErrorIs(t, err, errUnknownAPIKey)
+
+	claims, err = authenticator.Authenticate(context.Background(), headerProvider{})
+	require.NoError(t, err)
+	require.Nil(t, claims)
+}
+
+func TestInvalidAPIKeyFile(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "api_keys.yaml")
+	require.NoError(t, os.WriteFile(keyFile, []byte(`keys:
+  - name: ci
+    hash: md5:1bc29b36f623ba82aaf6724fd3b16718
+`), 0o600))
+
+	_, err := NewAPIKeyAuthenticator(APIKeyAuthenticatorOptions{Name: "api-key", KeyFile: keyFile})
+	require.ErrorContains(t, err, "must start with")
+}
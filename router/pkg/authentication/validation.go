@@ -0,0 +1,155 @@
+package authentication
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// FailureReason is the reason an authenticator rejected the authentication information
+type FailureReason string
+
+const (
+	FailureReasonMalformed        FailureReason = "malformed"
+	FailureReasonInvalidSignature FailureReason = "invalid_signature"
+	FailureReasonInvalidAlgorithm FailureReason = "invalid_algorithm"
+	FailureReasonUnknownKey       FailureReason = "unknown_key"
+	FailureReasonExpired          FailureReason = "expired"
+	FailureReasonNotYetValid      FailureReason = "not_yet_valid"
+	FailureReasonInvalidAudience  FailureReason = "invalid_audience"
+	FailureReasonInvalidIssuer    FailureReason = "invalid_issuer"
+	FailureReasonMissingClaim     FailureReason = "missing_claim"
+	FailureReasonInactive         FailureReason = "inactive"
+	FailureReasonUnknownAPIKey    FailureReason = "unknown_api_key"
+	// FailureReasonInvalid is used for failures without a more specific reason, e.g. an unreachable
+	// introspection endpoint
+	FailureReasonInvalid FailureReason = "invalid"
+)
+
+// ValidationError is returned by the authenticators when the authentication information is invalid
+type ValidationError struct {
+	Reason FailureReason
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// TokenValidationOptions are the checks of a token in addition to its signature and expiration
+type TokenValidationOptions struct {
+	// Audiences are the accepted audiences. The aud claim must contain at least one of them.
+	// Any audience is accepted when empty.
+	Audiences []string
+	// Issuers are the accepted issuers. Any issuer is accepted when empty.
+	Issuers []string
+	// ClockSkew is the tolerance when checking the exp, nbf and iat claims
+	ClockSkew time.Duration
+	// ValidateIssuedAt rejects tokens with an iat claim in the future. It is disabled by default
+	// because the clocks of the issuers are often slightly ahead.
+	ValidateIssuedAt bool
+	// RequiredClaims must be present in the token
+	RequiredClaims []string
+	// Algorithms are the accepted signing algorithms. Any algorithm supported by the key is
+	// accepted when empty.
+	Algorithms []string
+}
+
+type tokenValidator struct {
+	options TokenValidationOptions
+	parser  *jwt.Parser
+}
+
+func newTokenValidator(options TokenValidationOptions) *tokenValidator {
+	parserOptions := []jwt.ParserOption{jwt.WithLeeway(options.ClockSkew)}
+	if options.ValidateIssuedAt {
+		parserOptions = append(parserOptions, jwt.WithIssuedAt())
+	}
+	return &tokenValidator{
+		options: options,
+		parser:  jwt.NewParser(parserOptions...),
+	}
+}
+
+// parse verifies the token with the key of keyFunc and validates the claims
+func (v *tokenValidator) parse(tokenString string, keyFunc jwt.Keyfunc) (Claims, error) {
+	token, err := v.parser.Parse(tokenString, func(token *jwt.Token) (any, error) {
+		if len(v.options.Algorithms) > 0 && !slices.Contains(v.options.Algorithms, token.Method.Alg()) {
+			return nil, &ValidationError{
+				Reason: FailureReasonInvalidAlgorithm,
+				Err:    fmt.Errorf("signing algorithm %s is not allowed", token.Method.Alg()),
+			}
+		}
+		return keyFunc(token)
+	})
+	if err != nil {
+		return nil, tokenValidationError(err)
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+
+	if len(v.options.Issuers) > 0 {
+		issuer, _ := claims.GetIssuer()
+		if !slices.Contains(v.options.Issuers, issuer) {
+			return nil, &ValidationError{
+				Reason: FailureReasonInvalidIssuer,
+				Err:    fmt.Errorf("issuer %q is not accepted", issuer),
+			}
+		}
+	}
+
+	if len(v.options.Audiences) > 0 {
+		audiences, _ := claims.GetAudience()
+		if !slices.ContainsFunc(audiences, func(audience string) bool {
+			return slices.Contains(v.options.Audiences, audience)
+		}) {
+			return nil, &ValidationError{
+				Reason: FailureReasonInvalidAudience,
+				Err:    fmt.Errorf("audience %v is not accepted", []string(audiences)),
+			}
+		}
+	}
+
+	for _, claim := range v.options.RequiredClaims {
+		if value, ok := claims[claim]; !ok || value == nil {
+			return nil, &ValidationError{
+				Reason: FailureReasonMissingClaim,
+				Err:    fmt.Errorf("required claim %q is missing", claim),
+			}
+		}
+	}
+
+	return Claims(claims), nil
+}
+
+// tokenValidationError returns the error of a token that failed to parse with its reason
+func tokenValidationError(err error) error {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr
+	}
+
+	reason := FailureReasonInvalid
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		reason = FailureReasonMalformed
+	case errors.Is(err, jwt.ErrTokenUnverifiable):
+		// The key function failed, e.g. because no key with the kid of the token exists
+		reason = FailureReasonUnknownKey
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		reason = FailureReasonInvalidSignature
+	case errors.Is(err, jwt.ErrTokenExpired):
+		reason = FailureReasonExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		reason = FailureReasonNotYetValid
+	}
+
+	return &ValidationError{Reason: reason, Err: err}
+}
@@ -0,0 +1,176 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func signTestToken(t *testing.T, method jwt.SigningMethod, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestTokenValidation(t *testing.T) {
+	authenticator, err := NewJWTAuthenticator(JWTAuthenticatorOptions{
+		Name:   "jwt",
+		Secret: testSecret,
+		Validation: TokenValidationOptions{
+			Audiences:      []string{"router", "api"},
+			Issuers:        []string{"https://idp.example.com/"},
+			ClockSkew:      time.Minute,
+			RequiredClaims: []string{"sub", "org"},
+			Algorithms:     []string{"HS256"},
+		},
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub": "user-1",
+			"org": "org-1",
+			"iss": "https://idp.example.com/",
+			"aud": []string{"other", "api"},
+			"exp": now.Add(time.Hour).Unix(),
+		}
+	}
+
+	claims, err := authenticator.Authenticate(context.Background(), bearer(signTestToken(t, jwt.SigningMethodHS256, valid())))
+	require.NoError(t, err)
+	require.Equal(t, "user-1", claims["sub"])
+
+	// Within the clock skew
+	withinSkew := valid()
+	withinSkew["exp"] = now.Add(-30 * time.Second).Unix()
+	withinSkew["nbf"] = now.Add(30 * time.Second).Unix()
+	_, err = authenticator.Authenticate(context.Background(), bearer(signTestToken(t, jwt.SigningMethodHS256, withinSkew)))
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		modify func(claims jwt.MapClaims)
+		method jwt.SigningMethod
+		token  string
+		reason FailureReason
+	}{
+		"expired": {
+			modify: func(claims jwt.MapClaims) { claims["exp"] = now.Add(-2 * time.Minute).Unix() },
+			reason: FailureReasonExpired,
+		},
+		"not yet valid": {
+			modify: func(claims jwt.MapClaims) { claims["nbf"] = now.Add(2 * time.Minute).Unix() },
+			reason: FailureReasonNotYetValid,
+		},
+		"invalid audience": {
+			modify: func(claims jwt.MapClaims) { claims["aud"] = "other" },
+			reason: FailureReasonInvalidAudience,
+		},
+		"missing audience": {
+			modify: func(claims jwt.MapClaims) { delete(claims, "aud") },
+			reason: FailureReasonInvalidAudience,
+		},
+		"invalid issuer": {
+			modify: func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com/" },
+			reason: FailureReasonInvalidIssuer,
+		},
+		"missing claim": {
+			modify: func(claims jwt.MapClaims) { delete(claims, "org") },
+			reason: FailureReasonMissingClaim,
+		},
+		"invalid algorithm": {
+			method: jwt.SigningMethodHS512,
+			reason: FailureReasonInvalidAlgorithm,
+		},
+		"malformed": {
+			token:  "not-a-token",
+			reason: FailureReasonMalformed,
+		},
+		"invalid signature": {
+			token:  signTestToken(t, jwt.SigningMethodHS256, valid()) + "x",
+			reason: FailureReasonInvalidSignature,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			token := tc.token
+			if token == "" {
+				claims := valid()
+				if tc.modify != nil {
+					tc.modify(claims)
+				}
+				method := tc.method
+				if method == nil {
+					method = jwt.SigningMethodHS256
+				}
+				token = signTestToken(t, method, claims)
+			}
+
+			_, err := authenticator.Authenticate(context.Background(), bearer(token))
+			var validationErr *ValidationError
+			require.True(t, errors.As(err, &validationErr), "unexpected error: %v", err)
+			require.Equal(t, tc.reason, validationErr.Reason)
+		})
+	}
+}
+
+func TestIssuedAtValidation(t *testing.T) {
+	authenticate := func(t *testing.T, validation TokenValidationOptions, issuedAt time.Time) error {
+		authenticator, err := NewJWTAuthenticator(JWTAuthenticatorOptions{
+			Name:       "jwt",
+			Secret:     testSecret,
+			Validation: validation,
+		})
+		require.NoError(t, err)
+
+		token := signTestToken(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-1", "iat": issuedAt.Unix()})
+		_, err = authenticator.Authenticate(context.Background(), bearer(token))
+		return err
+	}
+
+	future := time.Now().Add(30 * time.Second)
+
+	// The iat claim is not validated by default
+	require.NoError(t, authenticate(t, TokenValidationOptions{}, future))
+
+	err := authenticate(t, TokenValidationOptions{ValidateIssuedAt: true}, future)
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr), "unexpected error: %v", err)
+	require.Equal(t, FailureReasonNotYetValid, validationErr.Reason)
+
+	// Within the clock skew
+	require.NoError(t, authenticate(t, TokenValidationOptions{ValidateIssuedAt: true, ClockSkew: time.Minute}, future))
+}
+
+func TestFailures(t *testing.T) {
+	first, err := NewJWTAuthenticator(JWTAuthenticatorOptions{
+		Name:       "first",
+		Secret:     testSecret,
+		Validation: TokenValidationOptions{Issuers: []string{"https://idp.example.com/"}},
+	})
+	require.NoError(t, err)
+	second, err := NewJWTAuthenticator(JWTAuthenticatorOptions{
+		Name:       "second",
+		Secret:     testSecret,
+		Validation: TokenValidationOptions{RequiredClaims: []string{"org"}},
+	})
+	require.NoError(t, err)
+
+	token := signTestToken(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-1", "iss": "other"})
+
+	auth, err := Authenticate(context.Background(), []Authenticator{first, second}, bearer(token))
+	require.Nil(t, auth)
+	require.Error(t, err)
+
+	failures := Failures(err)
+	require.Len(t, failures, 2)
+	require.Equal(t, "first", failures[0].Authenticator)
+	require.Equal(t, FailureReasonInvalidIssuer, failures[0].Reason)
+	require.Equal(t, "second", failures[1].Authenticator)
+	require.Equal(t, FailureReasonMissingClaim, failures[1].Reason)
+}
@@ -0,0 +1,345 @@
+package authorization
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/dgraph-io/ristretto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	rotel "github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+const (
+	ProtocolHTTP = "http"
+	ProtocolGRPC = "grpc"
+
+	// GRPCProcedure is the procedure called on gRPC services. Request and Decision are sent as
+	// google.protobuf.Struct messages with the same fields as their JSON representation.
+	GRPCProcedure = "/wg.cosmo.authorization.v1.AuthorizationService/Authorize"
+
+	ExternalAuthorizerScopeName    = "wundergraph/cosmo/router/external_authorizer"
+	ExternalAuthorizerScopeVersion = "0.0.1"
+
+	defaultTimeout   = time.Second
+	defaultCacheTTL  = time.Minute
+	defaultCacheSize = 10000
+)
+
+// Request is sent to the external authorization service for every field that has to be authorized
+type Request struct {
+	Operation  Operation      `json:"operation"`
+	Coordinate Coordinate     `json:"coordinate"`
+	Claims     map[string]any `json:"claims"`
+	// Arguments are the arguments of every selection of the field in the operation
+	Arguments []map[string]any `json:"arguments"`
+}
+
+type Operation struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Hash    string `json:"hash"`
+	Content string `json:"content"`
+}
+
+type Coordinate struct {
+	TypeName  string `json:"typeName"`
+	FieldName string `json:"fieldName"`
+}
+
+func (c Coordinate) String() string {
+	return c.TypeName + "." + c.FieldName
+}
+
+// Decision is the response of the external authorization service
+type Decision struct {
+	Allow bool `json:"allow"`
+	// Reason is returned to the client when the field is denied
+	Reason string `json:"reason,omitempty"`
+}
+
+// ExternalAuthorizerOptions contains the available options for the ExternalAuthorizer
+type ExternalAuthorizerOptions struct {
+	// Protocol is either ProtocolHTTP (default) or ProtocolGRPC
+	Protocol string
+	// URL is the endpoint receiving the Request as JSON POST body for ProtocolHTTP and the base URL of
+	// the service implementing GRPCProcedure for ProtocolGRPC
+	URL string
+	// Timeout of a decision, defaults to 1s
+	Timeout time.Duration
+	// FailOpen allows fields when the service can't be reached or returns an invalid response.
+	// Fields are denied by default.
+	FailOpen bool
+	// CacheTTL is the time decisions are cached for the same claims, coordinate and arguments.
+	// It defaults to 1m, a negative value disables the cache.
+	CacheTTL time.Duration
+	// CacheSize is the maximum number of cached decisions, defaults to 10000
+	CacheSize int64
+	// HTTPClient is the client used to call the service. HTTP/2 over cleartext is used for gRPC
+	// services with a http:// URL when empty.
+	HTTPClient     *http.Client
+	TracerProvider trace.TracerProvider
+	Logger         *zap.Logger
+}
+
+// ExternalAuthorizer delegates authorization decisions to an external policy service, e.g. OPA
+type ExternalAuthorizer struct {
+	url        string
+	httpClient *http.Client
+	grpcClient *connect.Client[structpb.Struct, structpb.Struct]
+	timeout    time.Duration
+	failOpen   bool
+	cacheTTL   time.Duration
+	// cache stores decisions by the hash of the claims, coordinate and arguments
+	cache  *ristretto.Cache
+	tracer trace.Tracer
+	logger *zap.Logger
+}
+
+// NewExternalAuthorizer returns an authorizer calling the service of the options. See
+// ExternalAuthorizerOptions for the available options.
+func NewExternalAuthorizer(opts ExternalAuthorizerOptions) (*ExternalAuthorizer, error) {
+	endpoint, err := url.Parse(opts.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("external authorization URL must be an absolute http or https URL, got %q", opts.URL)
+	}
+
+	a := &ExternalAuthorizer{
+		url:      opts.URL,
+		timeout:  opts.Timeout,
+		failOpen: opts.FailOpen,
+		cacheTTL: opts.CacheTTL,
+		logger:   opts.Logger,
+	}
+	if a.timeout <= 0 {
+		a.timeout = defaultTimeout
+	}
+	if a.logger == nil {
+		a.logger = zap.NewNop()
+	}
+
+	tracerProvider := opts.TracerProvider
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	a.tracer = tracerProvider.Tracer(ExternalAuthorizerScopeName, trace.WithInstrumentationVersion(ExternalAuthorizerScopeVersion))
+
+	httpClient := opts.HTTPClient
+	switch opts.Protocol {
+	case "", ProtocolHTTP:
+		if httpClient == nil {
+			httpClient = http.DefaultClient
+		}
+		a.httpClient = httpClient
+	case ProtocolGRPC:
+		if httpClient == nil {
+			httpClient = newGRPCHTTPClient(endpoint)
+		}
+		a.grpcClient = connect.NewClient[structpb.Struct, structpb.Struct](
+			httpClient,
+			strings.TrimSuffix(opts.URL, "/")+GRPCProcedure,
+			connect.WithGRPC(),
+		)
+	default:
+		return nil, fmt.Errorf("unknown external authorization protocol %q, expected %q or %q", opts.Protocol, ProtocolHTTP, ProtocolGRPC)
+	}
+
+	if a.cacheTTL >= 0 {
+		if a.cacheTTL == 0 {
+			a.cacheTTL = defaultCacheTTL
+		}
+		cacheSize := opts.CacheSize
+		if cacheSize <= 0 {
+			cacheSize = defaultCacheSize
+		}
+		a.cache, err = ristretto.NewCache(&ristretto.Config{
+			NumCounters: cacheSize * 10,
+			MaxCost:     cacheSize,
+			BufferItems: 64,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not create external authorization cache: %w", err)
+		}
+	}
+
+	return a, nil
+}
+
+// newGRPCHTTPClient returns a client speaking HTTP/2, over cleartext with prior knowledge for http:// URLs
+func newGRPCHTTPClient(endpoint *url.URL) *http.Client {
+	if endpoint.Scheme == "https" {
+		return &http.Client{Transport: &http2.Transport{}}
+	}
+	return &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+	}
+}
+
+// Close stops the cache of the authorizer. Decisions are not cached after the authorizer is closed.
+func (a *ExternalAuthorizer) Close() {
+	if a.cache != nil {
+		a.cache.Close()
+	}
+}
+
+// Authorize returns the decision of the service for the request. Failures of the service result in
+// a decision according to the fail-open setting, so only the decision has to be checked.
+func (a *ExternalAuthorizer) Authorize(ctx context.Context, request *Request) *Decision {
+	ctx, span := a.tracer.Start(ctx, "Authorization - External Decision",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(rotel.WgAuthorizationCoordinate.String(request.Coordinate.String())),
+	)
+	defer span.End()
+
+	key, err := a.cacheKey(request)
+	if err != nil {
+		return a.failure(span, request, err)
+	}
+
+	if a.cache != nil {
+		if item, ok := a.cache.Get(key); ok {
+			decision := item.(*Decision)
+			span.SetAttributes(
+				rotel.WgAuthorizationCacheHit.Bool(true),
+				rotel.WgAuthorizationAllowed.Bool(decision.Allow),
+			)
+			return decision
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	var decision *Decision
+	if a.grpcClient != nil {
+		decision, err = a.requestGRPC(ctx, request)
+	} else {
+		decision, err = a.requestHTTP(ctx, request)
+	}
+	if err != nil {
+		// Failures are not cached
+		return a.failure(span, request, err)
+	}
+
+	if a.cache != nil {
+		a.cache.SetWithTTL(key, decision, 1, a.cacheTTL)
+	}
+
+	span.SetAttributes(
+		rotel.WgAuthorizationCacheHit.Bool(false),
+		rotel.WgAuthorizationAllowed.Bool(decision.Allow),
+	)
+	return decision
+}
+
+func (a *ExternalAuthorizer) failure(span trace.Span, request *Request, err error) *Decision {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.SetAttributes(rotel.WgAuthorizationAllowed.Bool(a.failOpen))
+
+	a.logger.Warn("External authorization failed",
+		zap.String("coordinate", request.Coordinate.String()),
+		zap.Bool("fail_open", a.failOpen),
+		zap.Error(err),
+	)
+
+	if a.failOpen {
+		return &Decision{Allow: true}
+	}
+	return &Decision{Reason: "external authorization failed"}
+}
+
+// cacheKey identifies decisions by claims, coordinate and arguments. The operation is not part of
+// the key, the decision for a field doesn't depend on the rest of the operation.
+func (a *ExternalAuthorizer) cacheKey(request *Request) (string, error) {
+	// Maps are marshalled with sorted keys, so equal requests have equal keys
+	data, err := json.Marshal(struct {
+		Coordinate Coordinate       `json:"coordinate"`
+		Claims     map[string]any   `json:"claims"`
+		Arguments  []map[string]any `json:"arguments"`
+	}{request.Coordinate, request.Claims, request.Arguments})
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return string(hash[:]), nil
+}
+
+func (a *ExternalAuthorizer) requestHTTP(ctx context.Context, request *Request) (*Decision, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("external authorization service responded with status %d", resp.StatusCode)
+	}
+
+	var decision Decision
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&decision); err != nil {
+		return nil, fmt.Errorf("could not decode decision: %w", err)
+	}
+	return &decision, nil
+}
+
+func (a *ExternalAuthorizer) requestGRPC(ctx context.Context, request *Request) (*Decision, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	var message structpb.Struct
+	if err := protojson.Unmarshal(body, &message); err != nil {
+		return nil, err
+	}
+
+	resp, err := a.grpcClient.CallUnary(ctx, connect.NewRequest(&message))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := protojson.Marshal(resp.Msg)
+	if err != nil {
+		return nil, err
+	}
+	var decision Decision
+	if err := json.Unmarshal(data, &decision); err != nil {
+		return nil, fmt.Errorf("could not decode decision: %w", err)
+	}
+	return &decision, nil
+}
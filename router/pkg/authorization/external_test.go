@@ -0,0 +1,162 @@
+package authorization
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// decide allows admins and everybody for their own employee
+func decide(request *Request) *Decision {
+	if request.Claims["role"] == "admin" {
+		return &Decision{Allow: true}
+	}
+	for _, args := range request.Arguments {
+		if args["id"] != request.Claims["employee_id"] {
+			return &Decision{Reason: "not your employee"}
+		}
+	}
+	return &Decision{Allow: len(request.Arguments) > 0}
+}
+
+func newTestServer(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var request Request
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&request)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(decide(&request))
+	})
+	mux.Handle(GRPCProcedure, connect.NewUnaryHandler(GRPCProcedure, func(ctx context.Context, req *connect.Request[structpb.Struct]) (*connect.Response[structpb.Struct], error) {
+		requests.Add(1)
+		data, err := req.Msg.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		var request Request
+		if err := json.Unmarshal(data, &request); err != nil {
+			return nil, err
+		}
+		decision := decide(&request)
+		response, err := structpb.NewStruct(map[string]any{"allow": decision.Allow, "reason": decision.Reason})
+		if err != nil {
+			return nil, err
+		}
+		return connect.NewResponse(response), nil
+	}))
+
+	server := httptest.NewServer(h2c.NewHandler(mux, &http2.Server{}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExternalAuthorizer(t *testing.T) {
+	var requests atomic.Int32
+	server := newTestServer(t, &requests)
+
+	for name, opts := range map[string]ExternalAuthorizerOptions{
+		"http": {URL: server.URL + "/authorize"},
+		"grpc": {Protocol: ProtocolGRPC, URL: server.URL},
+	} {
+		t.Run(name, func(t *testing.T) {
+			authorizer, err := NewExternalAuthorizer(opts)
+			require.NoError(t, err)
+
+			request := func(claims map[string]any, id float64) *Request {
+				return &Request{
+					Operation:  Operation{Name: "Employee", Type: "query"},
+					Coordinate: Coordinate{TypeName: "Query", FieldName: "employee"},
+					Claims:     claims,
+					Arguments:  []map[string]any{{"id": id}},
+				}
+			}
+
+			decision := authorizer.Authorize(context.Background(), request(map[string]any{"employee_id": float64(1)}, 1))
+			require.True(t, decision.Allow)
+
+			decision = authorizer.Authorize(context.Background(), request(map[string]any{"employee_id": float64(1)}, 2))
+			require.False(t, decision.Allow)
+			require.Equal(t, "not your employee", decision.Reason)
+
+			decision = authorizer.Authorize(context.Background(), request(map[string]any{"role": "admin"}, 2))
+			require.True(t, decision.Allow)
+
+			// Decisions are cached by claims, coordinate and arguments
+			authorizer.cache.Wait()
+			before := requests.Load()
+			decision = authorizer.Authorize(context.Background(), request(map[string]any{"employee_id": float64(1)}, 2))
+			require.False(t, decision.Allow)
+			require.Equal(t, before, requests.Load())
+
+			// Closed authorizers still decide without the cache
+			authorizer.Close()
+			decision = authorizer.Authorize(context.Background(), request(map[string]any{"employee_id": float64(1)}, 2))
+			require.False(t, decision.Allow)
+			require.Equal(t, before+1, requests.Load())
+		})
+	}
+}
+
+func TestExternalAuthorizerFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	request := &Request{Coordinate: Coordinate{TypeName: "Query", FieldName: "employees"}}
+
+	for name, tc := range map[string]struct {
+		opts    ExternalAuthorizerOptions
+		allowed bool
+	}{
+		"fail closed":         {opts: ExternalAuthorizerOptions{URL: server.URL}},
+		"fail open":           {opts: ExternalAuthorizerOptions{URL: server.URL, FailOpen: true}, allowed: true},
+		"fail open timeout":   {opts: ExternalAuthorizerOptions{URL: server.URL, FailOpen: true, Timeout: time.Millisecond}, allowed: true},
+		"fail closed timeout": {opts: ExternalAuthorizerOptions{URL: server.URL, Timeout: time.Millisecond}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			tc.opts.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+			authorizer, err := NewExternalAuthorizer(tc.opts)
+			require.NoError(t, err)
+
+			decision := authorizer.Authorize(context.Background(), request)
+			require.Equal(t, tc.allowed, decision.Allow)
+
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 1)
+			require.Equal(t, "Authorization - External Decision", spans[0].Name)
+			require.Contains(t, spans[0].Attributes, attribute.String("wg.authorization.coordinate", "Query.employees"))
+			require.Contains(t, spans[0].Attributes, attribute.Bool("wg.authorization.allowed", tc.allowed))
+			require.Len(t, spans[0].Events, 1)
+		})
+	}
+}
+
+func TestInvalidExternalAuthorizer(t *testing.T) {
+	_, err := NewExternalAuthorizer(ExternalAuthorizerOptions{URL: "localhost:8181"})
+	require.ErrorContains(t, err, "absolute http or https URL")
+
+	_, err = NewExternalAuthorizer(ExternalAuthorizerOptions{URL: "http://localhost:8181", Protocol: "websocket"})
+	require.ErrorContains(t, err, "unknown external authorization protocol")
+}
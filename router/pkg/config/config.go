@@ -103,6 +103,30 @@ type TrafficShapingRules struct {
 	All GlobalSubgraphRequestRule `yaml:"all"`
 	// Apply to requests from clients to the router
 	Router RouterTrafficConfiguration `yaml:"router"`
+	// Subgraphs is a set of rules that apply to requests to a specific subgraph
+	Subgraphs map[string]SubgraphTrafficRequestRule `yaml:"subgraphs,omitempty"`
+}
+
+type SubgraphTrafficRequestRule struct {
+	Retry   SubgraphRetryRule   `yaml:"retry,omitempty"`
+	Hedging SubgraphHedgingRule `yaml:"hedging,omitempty"`
+	// H2C sends requests to the subgraph with HTTP/2 over cleartext
+	H2C bool `yaml:"h2c,omitempty"`
+}
+
+type SubgraphHedgingRule struct {
+	Enabled bool `yaml:"enabled"`
+	// Percentile of the observed subgraph latencies after which a hedged request is sent. Defaults to 95
+	Percentile float64 `yaml:"percentile,omitempty"`
+	// MinDelay is the minimum time to wait before a hedged request is sent
+	MinDelay time.Duration `yaml:"min_delay,omitempty"`
+	// MaxDelay is the maximum time to wait before a hedged request is sent. Zero means no upper bound
+	MaxDelay time.Duration `yaml:"max_delay,omitempty"`
+}
+
+type SubgraphRetryRule struct {
+	// StatusCodes overrides the retryable status codes of the global retry configuration
+	StatusCodes []int `yaml:"status_codes,omitempty"`
 }
 
 type RouterTrafficConfiguration struct {
@@ -120,6 +144,8 @@ type GlobalSubgraphRequestRule struct {
 	TLSHandshakeTimeout    time.Duration `yaml:"tls_handshake_timeout,omitempty" default:"10s"`
 	KeepAliveIdleTimeout   time.Duration `yaml:"keep_alive_idle_timeout,omitempty" default:"0s"`
 	KeepAliveProbeInterval time.Duration `yaml:"keep_alive_probe_interval,omitempty" default:"30s"`
+	// H2C sends requests to http:// subgraphs with HTTP/2 over cleartext
+	H2C bool `yaml:"h2c" default:"false"`
 }
 
 type GraphqlMetrics struct {
@@ -133,6 +159,20 @@ type BackoffJitterRetry struct {
 	MaxAttempts int           `yaml:"max_attempts" default:"5"`
 	MaxDuration time.Duration `yaml:"max_duration" default:"10s"`
 	Interval    time.Duration `yaml:"interval" default:"3s"`
+	// RespectRetryAfter waits for the duration of the Retry-After header of 429 and 503 responses
+	RespectRetryAfter bool `yaml:"respect_retry_after" default:"true"`
+	// StatusCodes are the retryable status codes. Empty means 500, 502, 503, 504 and 429
+	StatusCodes []int       `yaml:"status_codes,omitempty"`
+	Budget      RetryBudget `yaml:"budget,omitempty"`
+}
+
+type RetryBudget struct {
+	Enabled bool `yaml:"enabled" default:"false"`
+	// Ratio is the maximum ratio of retries to requests within the window e.g. 0.2 allows 20% of the requests to be retried
+	Ratio float64 `yaml:"ratio" default:"0.2"`
+	// MinRetries is the number of retries that are always allowed within the window
+	MinRetries int           `yaml:"min_retries" default:"10"`
+	Window     time.Duration `yaml:"window" default:"10s"`
 }
 
 type HeaderRules struct {
@@ -144,12 +184,16 @@ type HeaderRules struct {
 type GlobalHeaderRule struct {
 	// Request is a set of rules that apply to requests
 	Request []RequestHeaderRule `yaml:"request,omitempty"`
+	// Response is a set of rules that apply to the subgraph responses
+	Response []ResponseHeaderRule `yaml:"response,omitempty"`
 }
 
 type HeaderRuleOperation string
 
 const (
 	HeaderRuleOperationPropagate HeaderRuleOperation = "propagate"
+	HeaderRuleOperationSet       HeaderRuleOperation = "set"
+	HeaderRuleOperationRemove    HeaderRuleOperation = "remove"
 )
 
 type RequestHeaderRule struct {
@@ -163,6 +207,45 @@ type RequestHeaderRule struct {
 	Rename string `yaml:"rename,omitempty"`
 	// Default is the default value to set if the header is not present
 	Default string `yaml:"default"`
+
+	// Name is the name of the header to set
+	Name string `yaml:"name,omitempty"`
+	// Value is the static value of the header to set
+	Value string `yaml:"value,omitempty"`
+	// ValueFromEnv is the environment variable holding the value of the header to set
+	ValueFromEnv string `yaml:"value_from_env,omitempty"`
+	// ValueTemplate is the value of the header to set with variables, e.g. "{{ claims.sub }}"
+	ValueTemplate string `yaml:"value_template,omitempty"`
+}
+
+type ResponseHeaderRuleAlgorithm string
+
+const (
+	// ResponseHeaderRuleAlgorithmFirstWrite keeps the header of the first subgraph response
+	ResponseHeaderRuleAlgorithmFirstWrite ResponseHeaderRuleAlgorithm = "first_write"
+	// ResponseHeaderRuleAlgorithmLastWrite keeps the header of the last subgraph response
+	ResponseHeaderRuleAlgorithmLastWrite ResponseHeaderRuleAlgorithm = "last_write"
+	// ResponseHeaderRuleAlgorithmAppend adds the header values of all subgraph responses
+	ResponseHeaderRuleAlgorithmAppend ResponseHeaderRuleAlgorithm = "append"
+	// ResponseHeaderRuleAlgorithmMostRestrictiveCacheControl merges the Cache-Control headers of all subgraph
+	// responses to the most restrictive policy
+	ResponseHeaderRuleAlgorithmMostRestrictiveCacheControl ResponseHeaderRuleAlgorithm = "most_restrictive_cache_control"
+)
+
+type ResponseHeaderRule struct {
+	// Operation describes the header operation to perform e.g. "propagate"
+	Operation HeaderRuleOperation `yaml:"op"`
+	// Matching is the regex to match the header name against
+	Matching string `yaml:"matching,omitempty"`
+	// Named is the exact header name to match
+	Named string `yaml:"named,omitempty"`
+	// Rename renames the header's key to the provided value
+	Rename string `yaml:"rename,omitempty"`
+	// Default is the default value to set if no subgraph response has the header. With the
+	// most_restrictive_cache_control algorithm it is the policy of each subgraph response without the header.
+	Default string `yaml:"default,omitempty"`
+	// Algorithm is the algorithm to merge the header of multiple subgraph responses
+	Algorithm ResponseHeaderRuleAlgorithm `yaml:"algorithm,omitempty"`
 }
 
 type EngineDebugConfiguration struct {
@@ -190,8 +273,24 @@ type EngineExecutionConfiguration struct {
 	EpollKqueueConnBufferSize              int                      `default:"128" envconfig:"ENGINE_EPOLL_KQUEUE_CONN_BUFFER_SIZE" yaml:"epoll_kqueue_conn_buffer_size,omitempty"`
 	WebSocketReadTimeout                   time.Duration            `default:"5s" envconfig:"ENGINE_WEBSOCKET_READ_TIMEOUT" yaml:"websocket_read_timeout,omitempty"`
 	ExecutionPlanCacheSize                 int64                    `default:"10000" envconfig:"ENGINE_EXECUTION_PLAN_CACHE_SIZE" yaml:"execution_plan_cache_size,omitempty"`
+	SubscriptionDeduplication              bool                     `default:"true" envconfig:"ENGINE_SUBSCRIPTION_DEDUPLICATION" yaml:"subscription_deduplication"`
+	SubscriptionClientBufferSize           int                      `default:"64" envconfig:"ENGINE_SUBSCRIPTION_CLIENT_BUFFER_SIZE" yaml:"subscription_client_buffer_size,omitempty"`
+	SubscriptionSlowConsumerPolicy         SlowConsumerPolicy       `default:"drop_oldest" envconfig:"ENGINE_SUBSCRIPTION_SLOW_CONSUMER_POLICY" yaml:"subscription_slow_consumer_policy,omitempty"`
+	SubscriptionMultipartHeartbeatInterval time.Duration            `default:"5s" envconfig:"ENGINE_SUBSCRIPTION_MULTIPART_HEARTBEAT_INTERVAL" yaml:"subscription_multipart_heartbeat_interval,omitempty"`
 }
 
+// SlowConsumerPolicy decides what happens to an update when the buffer of a client subscription is full
+type SlowConsumerPolicy string
+
+const (
+	// SlowConsumerPolicyDropOldest discards the oldest buffered update
+	SlowConsumerPolicyDropOldest SlowConsumerPolicy = "drop_oldest"
+	// SlowConsumerPolicyDropNewest discards the new update
+	SlowConsumerPolicyDropNewest SlowConsumerPolicy = "drop_newest"
+	// SlowConsumerPolicyClose completes the client subscription
+	SlowConsumerPolicyClose SlowConsumerPolicy = "close"
+)
+
 type SecurityConfiguration struct {
 	BlockMutations              bool `yaml:"block_mutations" default:"false" envconfig:"SECURITY_BLOCK_MUTATIONS"`
 	BlockSubscriptions          bool `yaml:"block_subscriptions" default:"false" envconfig:"SECURITY_BLOCK_SUBSCRIPTIONS"`
@@ -202,26 +301,156 @@ type OverrideRoutingURLConfiguration struct {
 	Subgraphs map[string]string `yaml:"subgraphs"`
 }
 
+type SubgraphPoolsConfiguration struct {
+	Subgraphs map[string]SubgraphPool `yaml:"subgraphs"`
+}
+
+type SubgraphPool struct {
+	URLs []string `yaml:"urls"`
+	// LoadBalancing is one of round_robin, least_requests or consistent_hash
+	LoadBalancing string `yaml:"load_balancing,omitempty"`
+	// HashHeader is the request header used as key for consistent_hash
+	HashHeader       string                       `yaml:"hash_header,omitempty"`
+	HealthCheck      SubgraphPoolHealthCheck      `yaml:"health_check,omitempty"`
+	OutlierDetection SubgraphPoolOutlierDetection `yaml:"outlier_detection,omitempty"`
+}
+
+type SubgraphPoolHealthCheck struct {
+	Enabled            bool          `yaml:"enabled"`
+	Path               string        `yaml:"path,omitempty"`
+	Interval           time.Duration `yaml:"interval,omitempty"`
+	Timeout            time.Duration `yaml:"timeout,omitempty"`
+	HealthyThreshold   int           `yaml:"healthy_threshold,omitempty"`
+	UnhealthyThreshold int           `yaml:"unhealthy_threshold,omitempty"`
+}
+
+type SubgraphPoolOutlierDetection struct {
+	Enabled             bool          `yaml:"enabled"`
+	ConsecutiveFailures int           `yaml:"consecutive_failures,omitempty"`
+	EjectionDuration    time.Duration `yaml:"ejection_duration,omitempty"`
+}
+
+// AuthenticationTokenValidation are the checks of a token in addition to its signature and expiration
+type AuthenticationTokenValidation struct {
+	Audiences      []string      `yaml:"audiences,omitempty"`
+	Issuers        []string      `yaml:"issuers,omitempty"`
+	ClockSkew      time.Duration `yaml:"clock_skew,omitempty"`
+	RequiredClaims []string      `yaml:"required_claims,omitempty"`
+	Algorithms     []string      `yaml:"algorithms,omitempty"`
+	// ValidateIssuedAt rejects tokens with an iat claim in the future
+	ValidateIssuedAt bool `yaml:"validate_issued_at,omitempty"`
+}
+
 type AuthenticationProviderJWKS struct {
+	URL                 string                        `yaml:"url"`
+	HeaderNames         []string                      `yaml:"header_names"`
+	HeaderValuePrefixes []string                      `yaml:"header_value_prefixes"`
+	RefreshInterval     time.Duration                 `yaml:"refresh_interval" default:"1m"`
+	Validation          AuthenticationTokenValidation `yaml:"validation,omitempty"`
+}
+
+// AuthenticationProviderJWT validates tokens with a static HMAC secret or public key
+type AuthenticationProviderJWT struct {
+	Secret              string   `yaml:"secret,omitempty"`
+	SecretFile          string   `yaml:"secret_file,omitempty"`
+	PublicKey           string   `yaml:"public_key,omitempty"`
+	PublicKeyFile       string   `yaml:"public_key_file,omitempty"`
+	HeaderNames         []string `yaml:"header_names"`
+	HeaderValuePrefixes []string `yaml:"header_value_prefixes"`
+	// Validation.Algorithms defaults to all the algorithms of the key type
+	Validation AuthenticationTokenValidation `yaml:"validation,omitempty"`
+}
+
+// AuthenticationProviderIntrospection validates opaque tokens with an OAuth2 token introspection endpoint (RFC 7662)
+type AuthenticationProviderIntrospection struct {
 	URL                 string        `yaml:"url"`
+	ClientID            string        `yaml:"client_id,omitempty"`
+	ClientSecret        string        `yaml:"client_secret,omitempty"`
+	TokenTypeHint       string        `yaml:"token_type_hint,omitempty"`
 	HeaderNames         []string      `yaml:"header_names"`
 	HeaderValuePrefixes []string      `yaml:"header_value_prefixes"`
-	RefreshInterval     time.Duration `yaml:"refresh_interval" default:"1m"`
+	CacheTTL            time.Duration `yaml:"cache_ttl,omitempty"`
+	CacheSize           int64         `yaml:"cache_size,omitempty"`
+	Timeout             time.Duration `yaml:"timeout,omitempty"`
+}
+
+// AuthenticationProviderAPIKey validates opaque API keys against a file of hashed keys
+type AuthenticationProviderAPIKey struct {
+	KeyFile             string   `yaml:"key_file"`
+	HeaderNames         []string `yaml:"header_names"`
+	HeaderValuePrefixes []string `yaml:"header_value_prefixes"`
 }
 
 type AuthenticationProvider struct {
-	Name string                      `yaml:"name"`
-	JWKS *AuthenticationProviderJWKS `yaml:"jwks"`
+	Name          string                               `yaml:"name"`
+	JWKS          *AuthenticationProviderJWKS          `yaml:"jwks,omitempty"`
+	JWT           *AuthenticationProviderJWT           `yaml:"jwt,omitempty"`
+	Introspection *AuthenticationProviderIntrospection `yaml:"introspection,omitempty"`
+	APIKey        *AuthenticationProviderAPIKey        `yaml:"api_key,omitempty"`
 }
 
 type AuthenticationConfiguration struct {
-	Providers []AuthenticationProvider `yaml:"providers"`
+	Providers     []AuthenticationProvider   `yaml:"providers"`
+	ForwardClaims ForwardClaimsConfiguration `yaml:"forward_claims,omitempty"`
+}
+
+type ForwardClaimsMode string
+
+const (
+	// ForwardClaimsModeHeaders forwards every claim as its own header
+	ForwardClaimsModeHeaders ForwardClaimsMode = "headers"
+	// ForwardClaimsModeJWT forwards the claims as a token signed by the router
+	ForwardClaimsModeJWT ForwardClaimsMode = "jwt"
+)
+
+// ForwardClaimsConfiguration forwards the claims of authenticated requests to the subgraphs
+type ForwardClaimsConfiguration struct {
+	Enabled bool              `yaml:"enabled" default:"false" envconfig:"FORWARD_CLAIMS_ENABLED"`
+	Mode    ForwardClaimsMode `yaml:"mode" default:"headers" envconfig:"FORWARD_CLAIMS_MODE"`
+	// Claims are the forwarded claims. Nested claims are addressed with dots, e.g. org.id
+	Claims []string `yaml:"claims,omitempty"`
+	// Subgraphs restricts the forwarding to these subgraphs. All subgraphs receive the claims when empty.
+	Subgraphs []string `yaml:"subgraphs,omitempty"`
+	// HeaderPrefix is the prefix of the claim headers, e.g. X-Claim-Org-Id for the claim org.id
+	HeaderPrefix string                  `yaml:"header_prefix" default:"X-Claim-"`
+	JWT          ForwardClaimsJWTOptions `yaml:"jwt"`
+}
+
+type ForwardClaimsJWTOptions struct {
+	HeaderName string `yaml:"header_name" default:"X-Router-Claims"`
+	// SigningKeyFile is a PEM encoded RSA or ECDSA private key. A key is generated on startup when empty.
+	SigningKeyFile string        `yaml:"signing_key_file,omitempty" envconfig:"FORWARD_CLAIMS_JWT_SIGNING_KEY_FILE"`
+	KeyID          string        `yaml:"key_id,omitempty"`
+	Issuer         string        `yaml:"issuer" default:"cosmo-router"`
+	Audience       string        `yaml:"audience,omitempty"`
+	TTL            time.Duration `yaml:"ttl" default:"5m"`
+	// JWKSPath is the path of the endpoint serving the public key to verify the tokens
+	JWKSPath string `yaml:"jwks_path" default:"/.well-known/jwks.json"`
 }
 
 type AuthorizationConfiguration struct {
 	RequireAuthentication bool `yaml:"require_authentication" default:"false" envconfig:"REQUIRE_AUTHENTICATION"`
 	// RejectOperationIfUnauthorized makes the router reject the whole GraphQL Operation if one field fails to authorize
 	RejectOperationIfUnauthorized bool `yaml:"reject_operation_if_unauthorized" default:"false" envconfig:"REJECT_OPERATION_IF_UNAUTHORIZED"`
+	// PolicyFile is the path of a file with claim based authorization rules for fields
+	PolicyFile string                             `yaml:"policy_file,omitempty" envconfig:"AUTHORIZATION_POLICY_FILE"`
+	External   ExternalAuthorizationConfiguration `yaml:"external"`
+}
+
+// ExternalAuthorizationConfiguration delegates authorization decisions to an external policy service
+type ExternalAuthorizationConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"EXTERNAL_AUTHORIZATION_ENABLED"`
+	// Protocol is either http or grpc
+	Protocol string        `yaml:"protocol" default:"http" envconfig:"EXTERNAL_AUTHORIZATION_PROTOCOL"`
+	URL      string        `yaml:"url,omitempty" envconfig:"EXTERNAL_AUTHORIZATION_URL"`
+	Timeout  time.Duration `yaml:"timeout" default:"1s" envconfig:"EXTERNAL_AUTHORIZATION_TIMEOUT"`
+	// FailOpen allows fields when the service fails, fields are denied otherwise
+	FailOpen bool `yaml:"fail_open" default:"false" envconfig:"EXTERNAL_AUTHORIZATION_FAIL_OPEN"`
+	// Fields are the coordinates in the format Type.field delegated to the service. All fields with
+	// an authorization rule are delegated when empty.
+	Fields    []string      `yaml:"fields,omitempty"`
+	CacheTTL  time.Duration `yaml:"cache_ttl" default:"1m"`
+	CacheSize int64         `yaml:"cache_size" default:"10000"`
 }
 
 type RateLimitConfiguration struct {
@@ -262,16 +491,82 @@ type UsernamePasswordBasedAuthentication struct {
 type Authentication struct {
 	UsernamePasswordBasedAuthentication `yaml:",inline"`
 	TokenBasedAuthentication            `yaml:",inline"`
+	// Mechanism is the SASL mechanism of the KAFKA provider: PLAIN (default), SCRAM-SHA-256 or SCRAM-SHA-512
+	Mechanism string `yaml:"mechanism,omitempty"`
+	// NKeySeedFile and CredentialsFile authenticate with the NATS provider
+	NKeySeedFile    string `yaml:"nkey_seed_file,omitempty"`
+	CredentialsFile string `yaml:"credentials_file,omitempty"`
 }
 
 type EventSource struct {
 	Provider       string          `yaml:"provider,omitempty"`
 	URL            string          `yaml:"url,omitempty"`
 	Authentication *Authentication `yaml:"authentication,omitempty"`
+	// Brokers are the bootstrap brokers of the KAFKA provider
+	Brokers []string `yaml:"brokers,omitempty"`
+	// ConsumerGroup is shared by all subscriptions of a KAFKA source, every record is delivered to one
+	// of them only. Without a group, every subscription receives all records produced after it started.
+	// For the REDIS provider, it switches from Pub/Sub channels to Streams read through the group,
+	// subscriptions with a cursor read the streams after the cursor instead.
+	ConsumerGroup string `yaml:"consumer_group,omitempty"`
+	// TLS enables TLS for the connections to the brokers of the KAFKA provider and the server of the NATS provider
+	TLS *TLSClientCertConfiguration `yaml:"tls,omitempty"`
+	// Reconnect configures the reconnects of the NATS provider
+	Reconnect *NatsReconnectConfiguration `yaml:"reconnect,omitempty"`
+	// JetStream configures the consumers of subscriptions with a stream configuration of the NATS provider
+	JetStream *NatsJetStreamConfiguration `yaml:"jetstream,omitempty"`
+}
+
+type NatsReconnectConfiguration struct {
+	// MaxReconnects is the number of attempts before the connection is closed, 60 if not set and forever if negative
+	MaxReconnects int `yaml:"max_reconnects,omitempty"`
+	// InitialWait doubles after every failed attempt up to MaxWait
+	InitialWait time.Duration `yaml:"initial_wait,omitempty"`
+	MaxWait     time.Duration `yaml:"max_wait,omitempty"`
+	Jitter      time.Duration `yaml:"jitter,omitempty"`
+	// RetryOnFailedConnect starts the router while the server is not available
+	RetryOnFailedConnect bool `yaml:"retry_on_failed_connect,omitempty"`
+}
+
+type NatsJetStreamConfiguration struct {
+	// AckPolicy is one of explicit (default), all and none
+	AckPolicy string `yaml:"ack_policy,omitempty"`
+	// MaxDeliver is the maximum number of delivery attempts of a message, unlimited if not set
+	MaxDeliver     int `yaml:"max_deliver,omitempty"`
+	FetchBatchSize int `yaml:"fetch_batch_size,omitempty"`
+	// EphemeralConsumers creates a consumer for every subscription instead of using the durable consumer
+	EphemeralConsumers bool          `yaml:"ephemeral_consumers,omitempty"`
+	InactiveThreshold  time.Duration `yaml:"inactive_threshold,omitempty"`
 }
 
 type EventsConfiguration struct {
 	Sources map[string]EventSource `yaml:"sources,omitempty"`
+	// Subscriptions transform and filter the events of subscription fields
+	Subscriptions []EventSubscriptionConfiguration `yaml:"subscriptions,omitempty"`
+}
+
+type EventSubscriptionConfiguration struct {
+	// Field is the coordinate of the subscription field in the format Type.field
+	Field     string                       `yaml:"field"`
+	Transform *EventTransformConfiguration `yaml:"transform,omitempty"`
+	// Filter are the conditions an event must meet after the transformation
+	Filter []EventFilterCondition `yaml:"filter,omitempty"`
+}
+
+// EventTransformConfiguration maps events with either Fields and TypeName or Template
+type EventTransformConfiguration struct {
+	// Fields are the JSON paths of the values in the event by field name
+	Fields   map[string]string `yaml:"fields,omitempty"`
+	TypeName string            `yaml:"type_name,omitempty"`
+	// Template is a Go template with the event as .Data and the arguments as .Args
+	Template string `yaml:"template,omitempty"`
+}
+
+type EventFilterCondition struct {
+	Path string `yaml:"path"`
+	// Argument of the field the value must equal, the condition is met if the argument is not set
+	Argument string   `yaml:"argument,omitempty"`
+	Values   []string `yaml:"values,omitempty"`
 }
 
 type Cluster struct {
@@ -303,6 +598,53 @@ type WebSocketConfiguration struct {
 	ForwardUpgradeQueryParams bool `yaml:"forward_upgrade_query_params" default:"true" envconfig:"WEBSOCKETS_FORWARD_UPGRADE_QUERY_PARAMS"`
 	// ForwardInitialPayload true if the Router should forward the initial payload of a Subscription Request to the Subgraph
 	ForwardInitialPayload bool `yaml:"forward_initial_payload" default:"true" envconfig:"WEBSOCKETS_FORWARD_INITIAL_PAYLOAD"`
+	// Authentication configures the authentication of WebSocket connections
+	Authentication WebSocketAuthenticationConfiguration `yaml:"authentication,omitempty"`
+	// InitializationTimeout is the time a client has to send connection_init after the upgrade
+	InitializationTimeout time.Duration `yaml:"initialization_timeout,omitempty" default:"10s" envconfig:"WEBSOCKETS_INITIALIZATION_TIMEOUT"`
+	// PingInterval is the interval of the keep-alive messages sent to the clients, 0 disables them
+	PingInterval time.Duration `yaml:"ping_interval,omitempty" default:"0s" envconfig:"WEBSOCKETS_PING_INTERVAL"`
+	// PongTimeout is the time a graphql-transport-ws client has to answer a ping
+	PongTimeout time.Duration `yaml:"pong_timeout,omitempty" default:"30s" envconfig:"WEBSOCKETS_PONG_TIMEOUT"`
+	// MaxConnectionLifetime closes connections after this duration, 0 keeps them open
+	MaxConnectionLifetime time.Duration `yaml:"max_connection_lifetime,omitempty" default:"0s" envconfig:"WEBSOCKETS_MAX_CONNECTION_LIFETIME"`
+	// Limits caps the connections and the subscriptions of WebSocket clients
+	Limits WebSocketLimitsConfiguration `yaml:"limits,omitempty"`
+}
+
+type WebSocketLimitsConfiguration struct {
+	// MaxConnections is the maximum number of open connections of the Router, 0 is unlimited
+	MaxConnections int `yaml:"max_connections,omitempty" default:"0" envconfig:"WEBSOCKETS_LIMITS_MAX_CONNECTIONS"`
+	// MaxConnectionsPerClient is the maximum number of open connections of a single client, 0 is unlimited
+	MaxConnectionsPerClient int `yaml:"max_connections_per_client,omitempty" default:"0" envconfig:"WEBSOCKETS_LIMITS_MAX_CONNECTIONS_PER_CLIENT"`
+	// ClientIdentifier decides how clients are told apart for MaxConnectionsPerClient
+	ClientIdentifier WebSocketClientIdentifier `yaml:"client_identifier,omitempty" default:"ip" envconfig:"WEBSOCKETS_LIMITS_CLIENT_IDENTIFIER"`
+	// MaxSubscriptionsPerConnection is the maximum number of active subscriptions of a connection, 0 is unlimited
+	MaxSubscriptionsPerConnection int `yaml:"max_subscriptions_per_connection,omitempty" default:"0" envconfig:"WEBSOCKETS_LIMITS_MAX_SUBSCRIPTIONS_PER_CONNECTION"`
+}
+
+// WebSocketClientIdentifier identifies the client of a WebSocket connection
+type WebSocketClientIdentifier string
+
+const (
+	// WebSocketClientIdentifierIP identifies clients by their IP address
+	WebSocketClientIdentifierIP WebSocketClientIdentifier = "ip"
+	// WebSocketClientIdentifierClientName identifies clients by the graphql-client-name header and
+	// falls back to the IP address when the header is missing
+	WebSocketClientIdentifierClientName WebSocketClientIdentifier = "client_name"
+)
+
+type WebSocketAuthenticationConfiguration struct {
+	FromInitialPayload InitialPayloadAuthenticationConfiguration `yaml:"from_initial_payload,omitempty"`
+}
+
+type InitialPayloadAuthenticationConfiguration struct {
+	// Enabled true if the Router should authenticate WebSocket connections with a token of the connection_init payload
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"WEBSOCKETS_AUTHENTICATION_FROM_INITIAL_PAYLOAD_ENABLED"`
+	// Key is the key of the token in the initial payload
+	Key string `yaml:"key" default:"Authorization" envconfig:"WEBSOCKETS_AUTHENTICATION_FROM_INITIAL_PAYLOAD_KEY"`
+	// HeaderName is the header the token is passed to the authenticators in, so it must match their header names and prefixes
+	HeaderName string `yaml:"header_name" default:"Authorization" envconfig:"WEBSOCKETS_AUTHENTICATION_FROM_INITIAL_PAYLOAD_HEADER_NAME"`
 }
 
 type AnonymizeIpConfiguration struct {
@@ -315,16 +657,49 @@ type TLSClientAuthConfiguration struct {
 	Required bool   `yaml:"required" default:"false" envconfig:"TLS_CLIENT_AUTH_REQUIRED"`
 }
 
+type TLSCertificateConfiguration struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
 type TLSServerConfiguration struct {
 	Enabled  bool   `yaml:"enabled" default:"false" envconfig:"TLS_SERVER_ENABLED"`
 	CertFile string `yaml:"cert_file,omitempty" envconfig:"TLS_SERVER_CERT_FILE"`
 	KeyFile  string `yaml:"key_file,omitempty" envconfig:"TLS_SERVER_KEY_FILE"`
+	// Certificates are additional certificates selected by SNI
+	Certificates []TLSCertificateConfiguration `yaml:"certificates,omitempty"`
 
 	ClientAuth TLSClientAuthConfiguration `yaml:"client_auth,omitempty"`
 }
 
+type TLSClientCertConfiguration struct {
+	CAFile             string `yaml:"ca_file,omitempty"`
+	CertFile           string `yaml:"cert_file,omitempty"`
+	KeyFile            string `yaml:"key_file,omitempty"`
+	ServerName         string `yaml:"server_name,omitempty"`
+	MinVersion         string `yaml:"min_version,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+type TLSClientConfiguration struct {
+	// All is applied to all subgraph connections
+	All TLSClientCertConfiguration `yaml:"all,omitempty"`
+	// Subgraphs overrides the options of All per subgraph
+	Subgraphs map[string]TLSClientCertConfiguration `yaml:"subgraphs,omitempty"`
+}
+
+type H2CConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"H2C_ENABLED"`
+}
+
+// HTTP3Configuration serves HTTP/3 over QUIC on the UDP port of the listen address. It requires TLS.
+type HTTP3Configuration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"HTTP3_ENABLED"`
+}
+
 type TLSConfiguration struct {
 	Server TLSServerConfiguration `yaml:"server"`
+	Client TLSClientConfiguration `yaml:"client,omitempty"`
 }
 
 type SubgraphErrorPropagationMode string
@@ -346,14 +721,16 @@ type SubgraphErrorPropagationConfiguration struct {
 type Config struct {
 	Version string `yaml:"version,omitempty" ignored:"true"`
 
-	InstanceID     string           `yaml:"instance_id,omitempty" envconfig:"INSTANCE_ID"`
-	Graph          Graph            `yaml:"graph,omitempty"`
-	Telemetry      Telemetry        `yaml:"telemetry,omitempty"`
-	GraphqlMetrics GraphqlMetrics   `yaml:"graphql_metrics,omitempty"`
-	CORS           CORS             `yaml:"cors,omitempty"`
-	Cluster        Cluster          `yaml:"cluster,omitempty"`
-	Compliance     ComplianceConfig `yaml:"compliance,omitempty"`
-	TLS            TLSConfiguration `yaml:"tls,omitempty"`
+	InstanceID     string             `yaml:"instance_id,omitempty" envconfig:"INSTANCE_ID"`
+	Graph          Graph              `yaml:"graph,omitempty"`
+	Telemetry      Telemetry          `yaml:"telemetry,omitempty"`
+	GraphqlMetrics GraphqlMetrics     `yaml:"graphql_metrics,omitempty"`
+	CORS           CORS               `yaml:"cors,omitempty"`
+	Cluster        Cluster            `yaml:"cluster,omitempty"`
+	Compliance     ComplianceConfig   `yaml:"compliance,omitempty"`
+	TLS            TLSConfiguration   `yaml:"tls,omitempty"`
+	H2C            H2CConfiguration   `yaml:"h2c,omitempty"`
+	HTTP3          HTTP3Configuration `yaml:"http3,omitempty"`
 
 	Modules        map[string]interface{} `yaml:"modules,omitempty"`
 	Headers        HeaderRules            `yaml:"headers,omitempty"`
@@ -386,6 +763,8 @@ type Config struct {
 
 	OverrideRoutingURL OverrideRoutingURLConfiguration `yaml:"override_routing_url"`
 
+	SubgraphPools SubgraphPoolsConfiguration `yaml:"subgraph_pools,omitempty"`
+
 	SecurityConfiguration SecurityConfiguration `yaml:"security,omitempty"`
 
 	EngineExecutionConfiguration EngineExecutionConfiguration `yaml:"engine"`
@@ -196,3 +196,133 @@ func TestValidAuthenticatedNatsProviderWithUsernamePassword(t *testing.T) {
 	_, err := LoadConfig("./fixtures/events/valid_authenticated_nats_provider_with_username_password.yaml", "")
 	require.NoError(t, err)
 }
+
+func TestValidNatsProviderJetStream(t *testing.T) {
+	cfg, err := LoadConfig("./fixtures/events/valid_nats_provider_jetstream.yaml", "")
+	require.NoError(t, err)
+	require.Equal(t, &NatsJetStreamConfiguration{
+		AckPolicy:          "all",
+		MaxDeliver:         3,
+		FetchBatchSize:     50,
+		EphemeralConsumers: true,
+		InactiveThreshold:  10 * time.Second,
+	}, cfg.Config.Events.Sources["default"].JetStream)
+}
+
+func TestInvalidNatsProviderJetStreamAckPolicy(t *testing.T) {
+	_, err := LoadConfig("./fixtures/events/invalid_nats_provider_jetstream_ack_policy.yaml", "")
+	require.ErrorContains(t, err, "jetstream/ack_policy")
+}
+
+func TestValidNatsProviderResilience(t *testing.T) {
+	cfg, err := LoadConfig("./fixtures/events/valid_nats_provider_resilience.yaml", "")
+	require.NoError(t, err)
+	source := cfg.Config.Events.Sources["default"]
+	require.Equal(t, "/etc/router/nats.creds", source.Authentication.CredentialsFile)
+	require.Equal(t, "certs/nats-ca.pem", source.TLS.CAFile)
+	require.Equal(t, &NatsReconnectConfiguration{
+		MaxReconnects:        -1,
+		InitialWait:          500 * time.Millisecond,
+		MaxWait:              10 * time.Second,
+		Jitter:               250 * time.Millisecond,
+		RetryOnFailedConnect: true,
+	}, source.Reconnect)
+	require.Equal(t, "/etc/router/nats.nk", cfg.Config.Events.Sources["nkey"].Authentication.NKeySeedFile)
+}
+
+func TestInvalidNatsProviderNKeyAndCredentials(t *testing.T) {
+	_, err := LoadConfig("./fixtures/events/invalid_nats_provider_nkey_and_credentials.yaml", "")
+	// Note: If none of the oneOf array matches, the first in the array is compared
+	require.ErrorContains(t, err, "missing properties: 'token'")
+}
+
+func TestValidKafkaProvider(t *testing.T) {
+	cfg, err := LoadConfig("./fixtures/events/valid_kafka_provider.yaml", "")
+	require.NoError(t, err)
+	source := cfg.Config.Events.Sources["default"]
+	require.Equal(t, []string{"localhost:9092"}, source.Brokers)
+	require.Equal(t, "router", source.ConsumerGroup)
+	require.True(t, source.TLS.InsecureSkipVerify)
+	require.Equal(t, "SCRAM-SHA-256", source.Authentication.Mechanism)
+}
+
+func TestInvalidKafkaProviderNoBrokers(t *testing.T) {
+	_, err := LoadConfig("./fixtures/events/invalid_kafka_provider_no_brokers.yaml", "")
+	// Note: If none of the oneOf array matches, the first in the array is compared
+	require.ErrorContains(t, err, "missing properties: 'url'")
+}
+
+func TestInvalidKafkaProviderMechanism(t *testing.T) {
+	_, err := LoadConfig("./fixtures/events/invalid_kafka_provider_mechanism.yaml", "")
+	// Note: If none of the oneOf array matches, the first in the array is compared
+	require.ErrorContains(t, err, "missing properties: 'url'")
+}
+
+func TestValidRedisProvider(t *testing.T) {
+	cfg, err := LoadConfig("./fixtures/events/valid_redis_provider.yaml", "")
+	require.NoError(t, err)
+	source := cfg.Config.Events.Sources["default"]
+	require.Equal(t, "redis://localhost:6379", source.URL)
+	require.Equal(t, "router", source.ConsumerGroup)
+}
+
+func TestInvalidRedisProviderBrokers(t *testing.T) {
+	_, err := LoadConfig("./fixtures/events/invalid_redis_provider_brokers.yaml", "")
+	// Note: If none of the oneOf array matches, the first in the array is compared
+	require.ErrorContains(t, err, "value must be \"NATS\"")
+}
+
+func TestValidInMemoryProvider(t *testing.T) {
+	cfg, err := LoadConfig("./fixtures/events/valid_inmemory_provider.yaml", "")
+	require.NoError(t, err)
+	require.Equal(t, "INMEMORY", cfg.Config.Events.Sources["default"].Provider)
+}
+
+func TestValidEventSubscriptions(t *testing.T) {
+	cfg, err := LoadConfig("./fixtures/events/valid_event_subscriptions.yaml", "")
+	require.NoError(t, err)
+	subscriptions := cfg.Config.Events.Subscriptions
+	require.Len(t, subscriptions, 2)
+	require.Equal(t, EventSubscriptionConfiguration{
+		Field: "Subscription.employeeUpdated",
+		Transform: &EventTransformConfiguration{
+			Fields:   map[string]string{"id": "employee.id"},
+			TypeName: "Employee",
+		},
+		Filter: []EventFilterCondition{{Path: "id", Argument: "employeeID"}},
+	}, subscriptions[0])
+	require.Equal(t, []string{"DE", "US"}, subscriptions[1].Filter[0].Values)
+}
+
+func TestInvalidEventSubscriptionsFilter(t *testing.T) {
+	_, err := LoadConfig("./fixtures/events/invalid_event_subscriptions_filter.yaml", "")
+	require.ErrorContains(t, err, "events/subscriptions/0/filter/0")
+}
+
+func TestInvalidSlowConsumerPolicy(t *testing.T) {
+	_, err := LoadConfig("./fixtures/invalid_slow_consumer_policy.yaml", "")
+	require.ErrorContains(t, err, "engine/subscription_slow_consumer_policy")
+}
+
+func TestInvalidWebSocketClientIdentifier(t *testing.T) {
+	_, err := LoadConfig("./fixtures/invalid_websocket_client_identifier.yaml", "")
+	require.ErrorContains(t, err, "websocket/limits/client_identifier")
+}
+
+func TestUnixSocketAddresses(t *testing.T) {
+	cfg, err := LoadConfig("./fixtures/unix_sockets.yaml", "")
+	require.NoError(t, err)
+
+	require.Equal(t, "unix:///var/run/cosmo/router.sock", cfg.Config.ListenAddr)
+	require.Equal(t, "unix:///var/run/cosmo/employees.sock:/graphql", cfg.Config.OverrideRoutingURL.Subgraphs["employees"])
+}
+
+func TestInvalidJWTProviderMultipleKeys(t *testing.T) {
+	_, err := LoadConfig("./fixtures/authentication/invalid_jwt_multiple_keys.yaml", "")
+	require.ErrorContains(t, err, "valid against schemas at indexes 0 and 3")
+}
+
+func TestInvalidMultipleAuthenticationProviders(t *testing.T) {
+	_, err := LoadConfig("./fixtures/authentication/invalid_multiple_providers.yaml", "")
+	require.ErrorContains(t, err, "valid against schemas at indexes 0 and 3")
+}
@@ -215,6 +215,8 @@ func ValidateConfig(yamlData []byte, schema string) error {
 	c.Formats["file-path"] = isFilePath
 	c.Formats["x-uri"] = isURI
 	c.Formats["hostname-port"] = isHostnamePort
+	c.Formats["listen-addr"] = isListenAddr
+	c.Formats["subgraph-url"] = isSubgraphURL
 
 	c.RegisterExtension("duration", goDurationSchema, durationCompiler{})
 	c.RegisterExtension("bytes", humanBytesSchema, humanBytesCompiler{})
@@ -412,6 +414,34 @@ func isURI(a any) bool {
 	return err == nil
 }
 
+// isListenAddr validates a socket address or the path of a Unix domain socket, e.g. unix:///var/run/router.sock
+func isListenAddr(a any) bool {
+	val, ok := a.(string)
+	if !ok {
+		return false
+	}
+
+	if path, ok := strings.CutPrefix(val, "unix://"); ok {
+		return path != ""
+	}
+
+	return isHostnamePort(val)
+}
+
+// isSubgraphURL validates an HTTP URL or the URL of a subgraph on a Unix domain socket, e.g. unix:///var/run/employees.sock:/graphql
+func isSubgraphURL(a any) bool {
+	val, ok := a.(string)
+	if !ok {
+		return false
+	}
+
+	if path, ok := strings.CutPrefix(val, "unix://"); ok {
+		return path != "" && path != ":"
+	}
+
+	return isHttpURL(val)
+}
+
 // isHostnamePort validates a <dns>:<port> combination for fields typically used for socket address.
 func isHostnamePort(a any) bool {
 	val, ok := a.(string)
@@ -1,7 +1,9 @@
 package health
 
 import (
+	"fmt"
 	"net/http"
+	"sync"
 	"sync/atomic"
 
 	"go.uber.org/zap"
@@ -21,11 +23,32 @@ type Checker interface {
 	SetReady(isReady bool)
 }
 
-var _ Checker = (*Checks)(nil)
+// ReadinessCheck returns an error if a dependency of the router is not available
+type ReadinessCheck func() error
+
+// ReadinessCheckRegistry is implemented by checkers that take the state of dependencies,
+// e.g. the connections to event sources, into account.
+type ReadinessCheckRegistry interface {
+	// AddReadinessCheck adds check to the readiness of the server until remove is called.
+	AddReadinessCheck(name string, check ReadinessCheck) (remove func())
+}
+
+var (
+	_ Checker                = (*Checks)(nil)
+	_ ReadinessCheckRegistry = (*Checks)(nil)
+)
 
 type Checks struct {
 	options *Options
 	isReady atomic.Bool
+
+	mu     sync.RWMutex
+	checks map[*namedCheck]struct{}
+}
+
+type namedCheck struct {
+	name  string
+	check ReadinessCheck
 }
 
 type Options struct {
@@ -35,6 +58,7 @@ type Options struct {
 func New(opts *Options) *Checks {
 	return &Checks{
 		options: opts,
+		checks:  make(map[*namedCheck]struct{}),
 	}
 }
 
@@ -48,7 +72,7 @@ func (c *Checks) Liveness() http.HandlerFunc {
 }
 
 // Readiness returns a handler that returns 200 OK if the server is ready to accept traffic
-// and 503 Service Unavailable if the server is not ready to serve traffic.
+// and 503 Service Unavailable if the server or one of its readiness checks is not ready to serve traffic.
 func (c *Checks) Readiness() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -57,6 +81,14 @@ func (c *Checks) Readiness() http.HandlerFunc {
 			return
 		}
 
+		if err := c.check(); err != nil {
+			if c.options != nil && c.options.Logger != nil {
+				c.options.Logger.Debug("Readiness check failed", zap.Error(err))
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		_, _ = w.Write([]byte("OK"))
@@ -67,3 +99,28 @@ func (c *Checks) Readiness() http.HandlerFunc {
 func (c *Checks) SetReady(isReady bool) {
 	c.isReady.Swap(isReady)
 }
+
+// AddReadinessCheck adds check to the readiness of the server until remove is called.
+func (c *Checks) AddReadinessCheck(name string, check ReadinessCheck) (remove func()) {
+	nc := &namedCheck{name: name, check: check}
+	c.mu.Lock()
+	c.checks[nc] = struct{}{}
+	c.mu.Unlock()
+	return func() {
+		c.mu.Lock()
+		delete(c.checks, nc)
+		c.mu.Unlock()
+	}
+}
+
+func (c *Checks) check() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for nc := range c.checks {
+		if err := nc.check(); err != nil {
+			return fmt.Errorf("%s: %w", nc.name, err)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package health
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/wundergraph/cosmo/router/internal/test"
 	"go.uber.org/zap"
@@ -41,3 +42,35 @@ func TestReadinessCheckHandler(t *testing.T) {
 	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
 	assert.Equal(t, "OK", rec.Body.String())
 }
+
+func TestReadinessChecks(t *testing.T) {
+	handler := New(&Options{
+		Logger: zap.NewNop(),
+	})
+	handler.SetReady(true)
+
+	var connected bool
+	remove := handler.AddReadinessCheck("nats", func() error {
+		if !connected {
+			return errors.New("not connected")
+		}
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	handler.Readiness()(rec, test.NewRequest(http.MethodGet, "/health/ready"))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	connected = true
+
+	rec = httptest.NewRecorder()
+	handler.Readiness()(rec, test.NewRequest(http.MethodGet, "/health/ready"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	connected = false
+	remove()
+
+	rec = httptest.NewRecorder()
+	handler.Readiness()(rec, test.NewRequest(http.MethodGet, "/health/ready"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
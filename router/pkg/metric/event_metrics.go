@@ -0,0 +1,137 @@
+package metric
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric"
+)
+
+const (
+	cosmoRouterEventsMeterName    = "cosmo.router.events"
+	cosmoRouterEventsMeterVersion = "0.0.1"
+
+	EventMessagesReceived  = "router.events.messages.received"  // Messages received by subscriptions
+	EventMessagesPublished = "router.events.messages.published" // Messages published by mutations
+	EventMessagesDropped   = "router.events.messages.dropped"   // Messages dropped because subscriptions fell behind
+	EventReconnects        = "router.events.reconnects"         // Reconnects to the broker
+
+	AttributeEventProvider   = attribute.Key("wg.event.provider")
+	AttributeEventSourceName = attribute.Key("wg.event.source.name")
+)
+
+type eventInstruments struct {
+	received   otelmetric.Int64Counter
+	published  otelmetric.Int64Counter
+	dropped    otelmetric.Int64Counter
+	reconnects otelmetric.Int64Counter
+}
+
+// EventMetrics reports the traffic and the connection state of the event sources.
+type EventMetrics struct {
+	baseAttributes []attribute.KeyValue
+	instruments    []eventInstruments
+}
+
+// NewEventMetrics creates the event counters on all meter providers.
+func NewEventMetrics(baseAttributes []attribute.KeyValue, meterProviders ...*metric.MeterProvider) (*EventMetrics, error) {
+	m := &EventMetrics{
+		baseAttributes: baseAttributes,
+	}
+
+	for _, mp := range meterProviders {
+		if mp == nil {
+			continue
+		}
+
+		meter := mp.Meter(cosmoRouterEventsMeterName,
+			otelmetric.WithInstrumentationVersion(cosmoRouterEventsMeterVersion),
+		)
+
+		var (
+			i   eventInstruments
+			err error
+		)
+
+		i.received, err = meter.Int64Counter(
+			EventMessagesReceived,
+			otelmetric.WithDescription("Total number of messages received from the event source"),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		i.published, err = meter.Int64Counter(
+			EventMessagesPublished,
+			otelmetric.WithDescription("Total number of messages published to the event source"),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		i.dropped, err = meter.Int64Counter(
+			EventMessagesDropped,
+			otelmetric.WithDescription("Total number of messages dropped because a subscription could not keep up"),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		i.reconnects, err = meter.Int64Counter(
+			EventReconnects,
+			otelmetric.WithDescription("Total number of reconnects to the event source"),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		m.instruments = append(m.instruments, i)
+	}
+
+	return m, nil
+}
+
+// Source returns the metrics of the event source sourceName.
+func (m *EventMetrics) Source(provider, sourceName string) *EventSourceMetrics {
+	attrs := make([]attribute.KeyValue, 0, len(m.baseAttributes)+2)
+	attrs = append(attrs, m.baseAttributes...)
+	attrs = append(attrs,
+		AttributeEventProvider.String(provider),
+		AttributeEventSourceName.String(sourceName),
+	)
+	return &EventSourceMetrics{
+		instruments: m.instruments,
+		attributes:  otelmetric.WithAttributes(attrs...),
+	}
+}
+
+// EventSourceMetrics reports the metrics of a single event source.
+type EventSourceMetrics struct {
+	instruments []eventInstruments
+	attributes  otelmetric.MeasurementOption
+}
+
+func (s *EventSourceMetrics) MessageReceived() {
+	for _, i := range s.instruments {
+		i.received.Add(context.Background(), 1, s.attributes)
+	}
+}
+
+func (s *EventSourceMetrics) MessagePublished() {
+	for _, i := range s.instruments {
+		i.published.Add(context.Background(), 1, s.attributes)
+	}
+}
+
+func (s *EventSourceMetrics) MessagesDropped(count int64) {
+	for _, i := range s.instruments {
+		i.dropped.Add(context.Background(), count, s.attributes)
+	}
+}
+
+func (s *EventSourceMetrics) Reconnected() {
+	for _, i := range s.instruments {
+		i.reconnects.Add(context.Background(), 1, s.attributes)
+	}
+}
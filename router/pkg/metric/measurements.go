@@ -41,6 +41,36 @@ func createMeasures(meter otelmetric.Meter) (*Measurements, error) {
 
 	h.counters[RequestError] = requestError
 
+	requestRetry, err := meter.Int64Counter(
+		RequestRetryCounter,
+		RequestRetryCounterOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request retry counter: %w", err)
+	}
+
+	h.counters[RequestRetryCounter] = requestRetry
+
+	requestHedge, err := meter.Int64Counter(
+		RequestHedgeCounter,
+		RequestHedgeCounterOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request hedge counter: %w", err)
+	}
+
+	h.counters[RequestHedgeCounter] = requestHedge
+
+	authenticationFailure, err := meter.Int64Counter(
+		AuthenticationFailureCounter,
+		AuthenticationFailureCounterOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create authentication failure counter: %w", err)
+	}
+
+	h.counters[AuthenticationFailureCounter] = authenticationFailure
+
 	serverLatencyMeasure, err := meter.Float64Histogram(
 		ServerLatencyHistogram,
 		ServerLatencyHistogramOptions...,
@@ -81,5 +111,15 @@ func createMeasures(meter otelmetric.Meter) (*Measurements, error) {
 
 	h.upDownCounters[InFlightRequestsUpDownCounter] = inFlightRequestsGauge
 
+	subgraphEndpointHealthy, err := meter.Int64UpDownCounter(
+		SubgraphEndpointHealthy,
+		SubgraphEndpointHealthyOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subgraph endpoint health gauge: %w", err)
+	}
+
+	h.upDownCounters[SubgraphEndpointHealthy] = subgraphEndpointHealthy
+
 	return h, nil
 }
@@ -20,6 +20,10 @@ const (
 	ResponseContentLengthCounter  = "router.http.response.content_length"       // Outgoing response bytes total
 	InFlightRequestsUpDownCounter = "router.http.requests.in_flight"            // Number of requests in flight
 	RequestError                  = "router.http.requests.error"                // Total request error count
+	RequestRetryCounter           = "router.http.requests.retry"                // Total subgraph request retry count
+	RequestHedgeCounter           = "router.http.requests.hedged"               // Total hedged subgraph request count
+	SubgraphEndpointHealthy       = "router.subgraph.endpoint.healthy"          // Number of healthy subgraph endpoints
+	AuthenticationFailureCounter  = "router.authentication.failures"            // Total authentication failure count by provider and reason
 
 	unitBytes        = "bytes"
 	unitMilliseconds = "ms"
@@ -51,6 +55,22 @@ var (
 		otelmetric.WithUnit("bytes"),
 		otelmetric.WithDescription(ResponseContentLengthCounterDescription),
 	}
+	RequestRetryCounterDescription = "Total number of retried subgraph requests"
+	RequestRetryCounterOptions     = []otelmetric.Int64CounterOption{
+		otelmetric.WithDescription(RequestRetryCounterDescription),
+	}
+	RequestHedgeCounterDescription = "Total number of hedged subgraph requests"
+	RequestHedgeCounterOptions     = []otelmetric.Int64CounterOption{
+		otelmetric.WithDescription(RequestHedgeCounterDescription),
+	}
+	SubgraphEndpointHealthyDescription = "Number of healthy subgraph endpoints"
+	SubgraphEndpointHealthyOptions     = []otelmetric.Int64UpDownCounterOption{
+		otelmetric.WithDescription(SubgraphEndpointHealthyDescription),
+	}
+	AuthenticationFailureCounterDescription = "Total number of rejected authentication attempts"
+	AuthenticationFailureCounterOptions     = []otelmetric.Int64CounterOption{
+		otelmetric.WithDescription(AuthenticationFailureCounterDescription),
+	}
 	InFlightRequestsUpDownCounterDescription = "Number of requests in flight"
 	InFlightRequestsUpDownCounterOptions     = []otelmetric.Int64UpDownCounterOption{
 		otelmetric.WithDescription(InFlightRequestsUpDownCounterDescription),
@@ -82,6 +102,10 @@ type (
 		MeasureResponseSize(ctx context.Context, size int64, attr ...attribute.KeyValue)
 		MeasureLatency(ctx context.Context, requestStartTime time.Time, attr ...attribute.KeyValue)
 		MeasureRequestError(ctx context.Context, attr ...attribute.KeyValue)
+		MeasureRequestRetry(ctx context.Context, attr ...attribute.KeyValue)
+		MeasureRequestHedge(ctx context.Context, attr ...attribute.KeyValue)
+		MeasureSubgraphEndpointHealth(ctx context.Context, delta int64, attr ...attribute.KeyValue)
+		MeasureAuthenticationFailure(ctx context.Context, attr ...attribute.KeyValue)
 		Flush(ctx context.Context) error
 	}
 )
@@ -159,6 +183,26 @@ func (h *Metrics) MeasureRequestError(ctx context.Context, attr ...attribute.Key
 	h.promRequestMetrics.MeasureRequestError(ctx, attr...)
 }
 
+func (h *Metrics) MeasureRequestRetry(ctx context.Context, attr ...attribute.KeyValue) {
+	h.otlpRequestMetrics.MeasureRequestRetry(ctx, attr...)
+	h.promRequestMetrics.MeasureRequestRetry(ctx, attr...)
+}
+
+func (h *Metrics) MeasureRequestHedge(ctx context.Context, attr ...attribute.KeyValue) {
+	h.otlpRequestMetrics.MeasureRequestHedge(ctx, attr...)
+	h.promRequestMetrics.MeasureRequestHedge(ctx, attr...)
+}
+
+func (h *Metrics) MeasureSubgraphEndpointHealth(ctx context.Context, delta int64, attr ...attribute.KeyValue) {
+	h.otlpRequestMetrics.MeasureSubgraphEndpointHealth(ctx, delta, attr...)
+	h.promRequestMetrics.MeasureSubgraphEndpointHealth(ctx, delta, attr...)
+}
+
+func (h *Metrics) MeasureAuthenticationFailure(ctx context.Context, attr ...attribute.KeyValue) {
+	h.otlpRequestMetrics.MeasureAuthenticationFailure(ctx, attr...)
+	h.promRequestMetrics.MeasureAuthenticationFailure(ctx, attr...)
+}
+
 // Flush flushes the metrics to the backend synchronously.
 func (h *Metrics) Flush(ctx context.Context) error {
 
@@ -13,6 +13,15 @@ type NoopMetrics struct{}
 
 func (n NoopMetrics) MeasureRequestError(ctx context.Context, attr ...attribute.KeyValue) {}
 
+func (n NoopMetrics) MeasureRequestRetry(ctx context.Context, attr ...attribute.KeyValue) {}
+
+func (n NoopMetrics) MeasureRequestHedge(ctx context.Context, attr ...attribute.KeyValue) {}
+
+func (n NoopMetrics) MeasureSubgraphEndpointHealth(ctx context.Context, delta int64, attr ...attribute.KeyValue) {
+}
+
+func (n NoopMetrics) MeasureAuthenticationFailure(ctx context.Context, attr ...attribute.KeyValue) {}
+
 func NewNoopMetrics() Store {
 	return &NoopMetrics{}
 }
@@ -133,6 +133,58 @@ func (h *OtlpMetricStore) MeasureRequestError(ctx context.Context, attr ...attri
 	}
 }
 
+func (h *OtlpMetricStore) MeasureRequestRetry(ctx context.Context, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.counters[RequestRetryCounter]; ok {
+		c.Add(ctx, 1, baseAttributes)
+	}
+}
+
+func (h *OtlpMetricStore) MeasureRequestHedge(ctx context.Context, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.counters[RequestHedgeCounter]; ok {
+		c.Add(ctx, 1, baseAttributes)
+	}
+}
+
+func (h *OtlpMetricStore) MeasureSubgraphEndpointHealth(ctx context.Context, delta int64, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.upDownCounters[SubgraphEndpointHealthy]; ok {
+		c.Add(ctx, delta, baseAttributes)
+	}
+}
+
+func (h *OtlpMetricStore) MeasureAuthenticationFailure(ctx context.Context, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.counters[AuthenticationFailureCounter]; ok {
+		c.Add(ctx, 1, baseAttributes)
+	}
+}
+
 func (h *OtlpMetricStore) Flush(ctx context.Context) error {
 	return h.meterProvider.ForceFlush(ctx)
 }
@@ -133,6 +133,58 @@ func (h *PromMetricStore) MeasureRequestError(ctx context.Context, attr ...attri
 	}
 }
 
+func (h *PromMetricStore) MeasureRequestRetry(ctx context.Context, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.counters[RequestRetryCounter]; ok {
+		c.Add(ctx, 1, baseAttributes)
+	}
+}
+
+func (h *PromMetricStore) MeasureRequestHedge(ctx context.Context, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.counters[RequestHedgeCounter]; ok {
+		c.Add(ctx, 1, baseAttributes)
+	}
+}
+
+func (h *PromMetricStore) MeasureSubgraphEndpointHealth(ctx context.Context, delta int64, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.upDownCounters[SubgraphEndpointHealthy]; ok {
+		c.Add(ctx, delta, baseAttributes)
+	}
+}
+
+func (h *PromMetricStore) MeasureAuthenticationFailure(ctx context.Context, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.counters[AuthenticationFailureCounter]; ok {
+		c.Add(ctx, 1, baseAttributes)
+	}
+}
+
 func (h *PromMetricStore) Flush(ctx context.Context) error {
 	return h.meterProvider.ForceFlush(ctx)
 }
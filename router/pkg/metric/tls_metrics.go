@@ -0,0 +1,83 @@
+package metric
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric"
+)
+
+const (
+	cosmoRouterTLSMeterName    = "cosmo.router.tls"
+	cosmoRouterTLSMeterVersion = "0.0.1"
+
+	TLSCertificateExpiration = "router.tls.certificate.expiration_timestamp" // Unix time the served certificate expires
+
+	AttributeTLSCertificateSubject = attribute.Key("wg.tls.certificate.subject")
+	AttributeTLSCertificateSerial  = attribute.Key("wg.tls.certificate.serial")
+)
+
+// TLSMetrics reports the expiry of the served TLS certificates, e.g. to alert before they lapse.
+type TLSMetrics struct {
+	registrations []otelmetric.Registration
+}
+
+// NewTLSMetrics registers the certificate expiry gauge on all meter providers. certificates is called
+// on every collection and returns the currently served certificates.
+func NewTLSMetrics(certificates func() []*x509.Certificate, baseAttributes []attribute.KeyValue, meterProviders ...*metric.MeterProvider) (*TLSMetrics, error) {
+	m := &TLSMetrics{}
+
+	for _, mp := range meterProviders {
+		if mp == nil {
+			continue
+		}
+
+		meter := mp.Meter(cosmoRouterTLSMeterName,
+			otelmetric.WithInstrumentationVersion(cosmoRouterTLSMeterVersion),
+		)
+
+		expiration, err := meter.Int64ObservableGauge(
+			TLSCertificateExpiration,
+			otelmetric.WithUnit("s"),
+			otelmetric.WithDescription("Unix time in seconds when the served TLS certificate expires"),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		reg, err := meter.RegisterCallback(func(ctx context.Context, o otelmetric.Observer) error {
+			for _, cert := range certificates() {
+				attrs := make([]attribute.KeyValue, 0, len(baseAttributes)+2)
+				attrs = append(attrs, baseAttributes...)
+				attrs = append(attrs,
+					AttributeTLSCertificateSubject.String(cert.Subject.CommonName),
+					AttributeTLSCertificateSerial.String(cert.SerialNumber.Text(16)),
+				)
+				o.ObserveInt64(expiration, cert.NotAfter.Unix(), otelmetric.WithAttributes(attrs...))
+			}
+			return nil
+		}, expiration)
+		if err != nil {
+			return nil, err
+		}
+
+		m.registrations = append(m.registrations, reg)
+	}
+
+	return m, nil
+}
+
+func (m *TLSMetrics) Stop() error {
+	var err error
+
+	for _, reg := range m.registrations {
+		if regErr := reg.Unregister(); regErr != nil {
+			err = errors.Join(err, regErr)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,114 @@
+package metric
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric"
+)
+
+const (
+	cosmoRouterWebSocketMeterName    = "cosmo.router.websocket"
+	cosmoRouterWebSocketMeterVersion = "0.0.1"
+
+	WebSocketConnections   = "router.websocket.connections"   // Open WebSocket connections
+	WebSocketSubscriptions = "router.websocket.subscriptions" // Active subscriptions of WebSocket connections
+	WebSocketRejections    = "router.websocket.rejections"    // Connections and subscriptions rejected by the limits
+
+	AttributeWebSocketRejectionReason = attribute.Key("wg.websocket.rejection.reason")
+)
+
+// WebSocketCounts are the current counts of the WebSocket connections
+type WebSocketCounts struct {
+	Connections   int64
+	Subscriptions int64
+}
+
+// WebSocketMetrics reports the open WebSocket connections and subscriptions and the rejections of the limits.
+type WebSocketMetrics struct {
+	baseAttributes []attribute.KeyValue
+	rejections     []otelmetric.Int64Counter
+	registrations  []otelmetric.Registration
+}
+
+// NewWebSocketMetrics registers the WebSocket instruments on all meter providers. counts is called
+// on every collection and returns the current counts.
+func NewWebSocketMetrics(counts func() WebSocketCounts, baseAttributes []attribute.KeyValue, meterProviders ...*metric.MeterProvider) (*WebSocketMetrics, error) {
+	m := &WebSocketMetrics{
+		baseAttributes: baseAttributes,
+	}
+
+	for _, mp := range meterProviders {
+		if mp == nil {
+			continue
+		}
+
+		meter := mp.Meter(cosmoRouterWebSocketMeterName,
+			otelmetric.WithInstrumentationVersion(cosmoRouterWebSocketMeterVersion),
+		)
+
+		connections, err := meter.Int64ObservableGauge(
+			WebSocketConnections,
+			otelmetric.WithDescription("Number of open WebSocket connections"),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		subscriptions, err := meter.Int64ObservableGauge(
+			WebSocketSubscriptions,
+			otelmetric.WithDescription("Number of active subscriptions of WebSocket connections"),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		rejections, err := meter.Int64Counter(
+			WebSocketRejections,
+			otelmetric.WithDescription("Total number of WebSocket connections and subscriptions rejected by the limits"),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		attributes := otelmetric.WithAttributes(baseAttributes...)
+		reg, err := meter.RegisterCallback(func(ctx context.Context, o otelmetric.Observer) error {
+			c := counts()
+			o.ObserveInt64(connections, c.Connections, attributes)
+			o.ObserveInt64(subscriptions, c.Subscriptions, attributes)
+			return nil
+		}, connections, subscriptions)
+		if err != nil {
+			return nil, err
+		}
+
+		m.rejections = append(m.rejections, rejections)
+		m.registrations = append(m.registrations, reg)
+	}
+
+	return m, nil
+}
+
+// Rejected counts a connection or subscription rejected for reason.
+func (m *WebSocketMetrics) Rejected(reason string) {
+	attrs := make([]attribute.KeyValue, 0, len(m.baseAttributes)+1)
+	attrs = append(attrs, m.baseAttributes...)
+	attrs = append(attrs, AttributeWebSocketRejectionReason.String(reason))
+	for _, rejections := range m.rejections {
+		rejections.Add(context.Background(), 1, otelmetric.WithAttributes(attrs...))
+	}
+}
+
+func (m *WebSocketMetrics) Stop() error {
+	var err error
+
+	for _, reg := range m.registrations {
+		if regErr := reg.Unregister(); regErr != nil {
+			err = errors.Join(err, regErr)
+		}
+	}
+
+	return err
+}
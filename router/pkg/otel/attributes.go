@@ -27,6 +27,14 @@ const (
 	WgRouterClusterName           = attribute.Key("wg.router.cluster.name")
 	WgSubgraphErrorExtendedCode   = attribute.Key("wg.subgraph.error.extended_code")
 	WgSubgraphErrorMessage        = attribute.Key("wg.subgraph.error.message")
+	WgSubgraphRetryCount          = attribute.Key("wg.subgraph.retry.count")
+	WgSubgraphHedgeWon            = attribute.Key("wg.subgraph.hedge.won")
+	WgSubgraphEndpoint            = attribute.Key("wg.subgraph.endpoint")
+	WgAuthenticationProvider      = attribute.Key("wg.authentication.provider")
+	WgAuthenticationFailureReason = attribute.Key("wg.authentication.failure_reason")
+	WgAuthorizationCoordinate     = attribute.Key("wg.authorization.coordinate")
+	WgAuthorizationAllowed        = attribute.Key("wg.authorization.allowed")
+	WgAuthorizationCacheHit       = attribute.Key("wg.authorization.cache_hit")
 )
 
 var (
@@ -0,0 +1,270 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nuid"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/pubsub_datasource"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+var (
+	_ pubsub_datasource.Connector = (*inMemoryConnector)(nil)
+	_ pubsub_datasource.PubSub    = (*inMemoryPubSub)(nil)
+)
+
+var (
+	ErrBadSubject   = errors.New("invalid subject")
+	ErrNoResponders = errors.New("no responders available for request")
+)
+
+const inboxPrefix = "_INBOX."
+
+// Msg is a message published on a Bus
+type Msg struct {
+	Subject string
+	// Reply is the subject responses to a request are published to, empty for plain messages
+	Reply string
+	Data  []byte
+
+	bus *Bus
+}
+
+// Respond publishes data to the reply subject of the message
+func (m *Msg) Respond(data []byte) error {
+	if m.Reply == "" {
+		return errors.New("message has no reply subject")
+	}
+	return m.bus.Publish(m.Reply, data)
+}
+
+type busSubscription struct {
+	tokens  []string
+	handler func(msg *Msg)
+}
+
+// Bus is an in-process message bus with the subject semantics of NATS. Subjects consist of tokens
+// separated by dots. Subscriptions match a single token with "*" and one or more trailing tokens with ">".
+// Messages are delivered synchronously, Publish returns after all handlers returned.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[*busSubscription]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subs: make(map[*busSubscription]struct{}),
+	}
+}
+
+// Subscribe calls handler for every message published to a subject matching subject until
+// the returned function is called
+func (b *Bus) Subscribe(subject string, handler func(msg *Msg)) (unsubscribe func(), err error) {
+	tokens, err := parseSubject(subject, true)
+	if err != nil {
+		return nil, err
+	}
+	sub := &busSubscription{tokens: tokens, handler: handler}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		delete(b.subs, sub)
+		b.mu.Unlock()
+	}, nil
+}
+
+// Publish delivers data to the subscriptions matching subject
+func (b *Bus) Publish(subject string, data []byte) error {
+	_, err := b.publish(subject, "", data)
+	return err
+}
+
+// Request publishes data to subject and returns the first response
+func (b *Bus) Request(ctx context.Context, subject string, data []byte) ([]byte, error) {
+	inbox := inboxPrefix + nuid.Next()
+	responses := make(chan []byte, 1)
+	unsubscribe, err := b.Subscribe(inbox, func(msg *Msg) {
+		select {
+		case responses <- msg.Data:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer unsubscribe()
+
+	delivered, err := b.publish(subject, inbox, data)
+	if err != nil {
+		return nil, err
+	}
+	if delivered == 0 {
+		return nil, ErrNoResponders
+	}
+	select {
+	case response := <-responses:
+		return response, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// publish delivers the message and returns the number of subscriptions it was delivered to
+func (b *Bus) publish(subject, reply string, data []byte) (int, error) {
+	tokens, err := parseSubject(subject, false)
+	if err != nil {
+		return 0, err
+	}
+	// Handlers are called without the lock held, so they can publish and subscribe themselves
+	b.mu.RLock()
+	matches := make([]*busSubscription, 0, len(b.subs))
+	for sub := range b.subs {
+		if subjectMatches(sub.tokens, tokens) {
+			matches = append(matches, sub)
+		}
+	}
+	b.mu.RUnlock()
+
+	data = append([]byte(nil), data...)
+	for _, sub := range matches {
+		sub.handler(&Msg{Subject: subject, Reply: reply, Data: data, bus: b})
+	}
+	return len(matches), nil
+}
+
+// parseSubject returns the tokens of subject. Wildcards are only valid in subscriptions.
+func parseSubject(subject string, wildcards bool) ([]string, error) {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return nil, fmt.Errorf(`%w "%s"`, ErrBadSubject, subject)
+	}
+	tokens := strings.Split(subject, ".")
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return nil, fmt.Errorf(`%w "%s"`, ErrBadSubject, subject)
+		case token == "*" || token == ">":
+			if !wildcards || (token == ">" && i != len(tokens)-1) {
+				return nil, fmt.Errorf(`%w "%s"`, ErrBadSubject, subject)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+func subjectMatches(pattern, subject []string) bool {
+	for i, token := range pattern {
+		if token == ">" {
+			return len(subject) > i
+		}
+		if i >= len(subject) || (token != "*" && token != subject[i]) {
+			return false
+		}
+	}
+	return len(pattern) == len(subject)
+}
+
+type EDFSInMemoryError struct {
+	Err error
+}
+
+func (e *EDFSInMemoryError) Error() string { return e.Err.Error() }
+
+func (e *EDFSInMemoryError) Unwrap() error { return e.Err }
+
+func newEDFSInMemoryError(err error) *EDFSInMemoryError {
+	return &EDFSInMemoryError{
+		Err: err,
+	}
+}
+
+type inMemoryConnector struct {
+	bus *Bus
+}
+
+// NewInMemoryConnector creates a connector for bus. It needs no external services, events are only
+// exchanged between the subscriptions and publishers of the bus in the same process.
+func NewInMemoryConnector(bus *Bus) pubsub_datasource.Connector {
+	return &inMemoryConnector{bus: bus}
+}
+
+func (c *inMemoryConnector) New(ctx context.Context) pubsub_datasource.PubSub {
+	return &inMemoryPubSub{
+		ctx: ctx,
+		bus: c.bus,
+	}
+}
+
+type inMemoryPubSub struct {
+	ctx context.Context
+	bus *Bus
+}
+
+func (p *inMemoryPubSub) ID() string {
+	return "inmemory"
+}
+
+func (p *inMemoryPubSub) ensureBus() error {
+	if p.bus == nil {
+		return newEDFSInMemoryError(errors.New("the in-memory event bus is not configured"))
+	}
+	return nil
+}
+
+func (p *inMemoryPubSub) Subscribe(ctx context.Context, subjects []string, updater resolve.SubscriptionUpdater, streamConfiguration *pubsub_datasource.StreamConfiguration) error {
+	if err := p.ensureBus(); err != nil {
+		return err
+	}
+	if streamConfiguration != nil {
+		return newEDFSInMemoryError(errors.New("stream configurations are not supported by the in-memory provider"))
+	}
+	unsubscribes := make([]func(), 0, len(subjects))
+	unsubscribeAll := func() {
+		for _, unsubscribe := range unsubscribes {
+			unsubscribe()
+		}
+	}
+	for _, subject := range subjects {
+		unsubscribe, err := p.bus.Subscribe(subject, func(msg *Msg) {
+			updater.Update(msg.Data)
+		})
+		if err != nil {
+			unsubscribeAll()
+			return newEDFSInMemoryError(fmt.Errorf(`error subscribing to subject "%s": %w`, subject, err))
+		}
+		unsubscribes = append(unsubscribes, unsubscribe)
+	}
+	go func() {
+		<-ctx.Done()
+		unsubscribeAll()
+	}()
+	return nil
+}
+
+func (p *inMemoryPubSub) Publish(_ context.Context, subject string, data []byte) error {
+	if err := p.ensureBus(); err != nil {
+		return err
+	}
+	if err := p.bus.Publish(subject, data); err != nil {
+		return newEDFSInMemoryError(fmt.Errorf(`error publishing to subject "%s": %w`, subject, err))
+	}
+	return nil
+}
+
+func (p *inMemoryPubSub) Request(ctx context.Context, subject string, data []byte, w io.Writer) error {
+	if err := p.ensureBus(); err != nil {
+		return err
+	}
+	response, err := p.bus.Request(ctx, subject, data)
+	if err != nil {
+		return newEDFSInMemoryError(fmt.Errorf(`error requesting subject "%s": %w`, subject, err))
+	}
+	_, err = w.Write(response)
+	return err
+}
@@ -0,0 +1,109 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/pubsub_datasource"
+)
+
+func TestSubjectMatches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		subject string
+		match   bool
+	}{
+		{"employeeUpdated.1", "employeeUpdated.1", true},
+		{"employeeUpdated.1", "employeeUpdated.2", false},
+		{"employeeUpdated", "employeeUpdated.1", false},
+		{"employeeUpdated.*", "employeeUpdated.1", true},
+		{"employeeUpdated.*", "employeeUpdated", false},
+		{"employeeUpdated.*", "employeeUpdated.1.details", false},
+		{"*.1", "employeeUpdated.1", true},
+		{"employeeUpdated.>", "employeeUpdated.1", true},
+		{"employeeUpdated.>", "employeeUpdated.1.details", true},
+		{"employeeUpdated.>", "employeeUpdated", false},
+		{">", "employeeUpdated.1", true},
+		{"*.*.details", "employeeUpdated.1.details", true},
+		{"employee*.1", "employeeUpdated.1", false},
+	}
+	for _, tt := range tests {
+		pattern, err := parseSubject(tt.pattern, true)
+		require.NoError(t, err)
+		subject, err := parseSubject(tt.subject, false)
+		require.NoError(t, err)
+		require.Equal(t, tt.match, subjectMatches(pattern, subject), "%s %s", tt.pattern, tt.subject)
+	}
+
+	for _, subject := range []string{"", "employeeUpdated.", ".1", "employee..1", "employee updated", "employeeUpdated.>.1"} {
+		_, err := parseSubject(subject, true)
+		require.ErrorIs(t, err, ErrBadSubject, subject)
+	}
+	_, err := parseSubject("employeeUpdated.*", false)
+	require.ErrorIs(t, err, ErrBadSubject)
+}
+
+func TestInMemoryPubSub(t *testing.T) {
+	t.Parallel()
+
+	ps := NewInMemoryConnector(NewBus()).New(context.Background())
+	require.Equal(t, "inmemory", ps.ID())
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	first, second := &testUpdater{}, &testUpdater{}
+	require.NoError(t, ps.Subscribe(ctx, []string{"employeeUpdated.1", "employeeUpdated.2"}, first, nil))
+	require.NoError(t, ps.Subscribe(ctx, []string{"employeeUpdated.*"}, second, nil))
+
+	require.NoError(t, ps.Publish(ctx, "employeeUpdated.1", []byte(`{"id":1}`)))
+	require.NoError(t, ps.Publish(ctx, "employeeUpdated.3", []byte(`{"id":3}`)))
+	require.Equal(t, []string{`{"id":1}`}, first.Updates())
+	require.Equal(t, []string{`{"id":1}`, `{"id":3}`}, second.Updates())
+
+	var inMemoryErr *EDFSInMemoryError
+	require.ErrorAs(t, ps.Publish(ctx, "employeeUpdated.*", nil), &inMemoryErr)
+	require.ErrorAs(t, ps.Subscribe(ctx, []string{"employeeUpdated.1", "employeeUpdated..2"}, first, nil), &inMemoryErr)
+	err := ps.Subscribe(ctx, []string{"employeeUpdated.1"}, first, &pubsub_datasource.StreamConfiguration{Consumer: "consumer", StreamName: "stream"})
+	require.ErrorAs(t, err, &inMemoryErr)
+
+	// Subscriptions end with their context
+	cancel()
+	require.Eventually(t, func() bool {
+		require.NoError(t, ps.Publish(context.Background(), "employeeUpdated.1", []byte(`{"id":1}`)))
+		return len(first.Updates()) == 1 && len(second.Updates()) == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestInMemoryPubSubRequest(t *testing.T) {
+	t.Parallel()
+
+	bus := NewBus()
+	ps := NewInMemoryConnector(bus).New(context.Background())
+
+	var buf bytes.Buffer
+	err := ps.Request(context.Background(), "getEmployee.1", nil, &buf)
+	require.ErrorIs(t, err, ErrNoResponders)
+
+	unsubscribe, err := bus.Subscribe("getEmployee.>", func(msg *Msg) {
+		require.NoError(t, msg.Respond(append([]byte(`{"id":1,"request":`), append(msg.Data, '}')...)))
+	})
+	require.NoError(t, err)
+	defer unsubscribe()
+
+	require.NoError(t, ps.Request(context.Background(), "getEmployee.1", []byte(`true`), &buf))
+	require.Equal(t, `{"id":1,"request":true}`, buf.String())
+
+	// Requests without a response time out with their context
+	silent, err := bus.Subscribe("getHobbies.1", func(*Msg) {})
+	require.NoError(t, err)
+	defer silent()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = ps.Request(ctx, "getHobbies.1", nil, &buf)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,137 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/pubsub_datasource"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+var (
+	_ pubsub_datasource.Connector = (*kafkaConnector)(nil)
+	_ pubsub_datasource.PubSub    = (*kafkaPubSub)(nil)
+)
+
+// kafkaRetryInterval is the wait before polling again after fetch errors
+const kafkaRetryInterval = time.Second
+
+type EDFSKafkaError struct {
+	Err error
+}
+
+func (e *EDFSKafkaError) Error() string { return e.Err.Error() }
+
+func (e *EDFSKafkaError) Unwrap() error { return e.Err }
+
+func newEDFSKafkaError(err error) *EDFSKafkaError {
+	return &EDFSKafkaError{
+		Err: err,
+	}
+}
+
+type kafkaConnector struct {
+	client        *kgo.Client
+	opts          []kgo.Opt
+	consumerGroup string
+}
+
+// NewKafkaConnector creates a connector for the topics of the brokers of opts. Records are produced with
+// client, every subscription consumes with a client of its own created with opts. When consumerGroup is
+// set, all subscriptions share the group and every record is delivered to one of them only. Otherwise,
+// every subscription receives all records produced after it started.
+func NewKafkaConnector(client *kgo.Client, opts []kgo.Opt, consumerGroup string) pubsub_datasource.Connector {
+	return &kafkaConnector{client: client, opts: opts, consumerGroup: consumerGroup}
+}
+
+func (c *kafkaConnector) New(ctx context.Context) pubsub_datasource.PubSub {
+	return &kafkaPubSub{
+		ctx:           ctx,
+		client:        c.client,
+		opts:          c.opts,
+		consumerGroup: c.consumerGroup,
+	}
+}
+
+type kafkaPubSub struct {
+	ctx           context.Context
+	client        *kgo.Client
+	opts          []kgo.Opt
+	consumerGroup string
+}
+
+func (p *kafkaPubSub) ID() string {
+	return "kafka"
+}
+
+func (p *kafkaPubSub) ensureClient() error {
+	if p.client == nil {
+		return newEDFSKafkaError(errors.New("Kafka is not configured"))
+	}
+	return nil
+}
+
+func (p *kafkaPubSub) Subscribe(ctx context.Context, topics []string, updater resolve.SubscriptionUpdater, streamConfiguration *pubsub_datasource.StreamConfiguration) error {
+	if err := p.ensureClient(); err != nil {
+		return err
+	}
+	if streamConfiguration != nil {
+		return newEDFSKafkaError(errors.New("stream configurations are not supported by the Kafka provider"))
+	}
+	opts := append(slices.Clone(p.opts),
+		kgo.ConsumeTopics(topics...),
+		// Records produced before the subscription started are skipped, also by groups without committed offsets
+		kgo.ConsumeResetOffset(kgo.NewOffset().AfterMilli(time.Now().UnixMilli())),
+	)
+	if p.consumerGroup != "" {
+		opts = append(opts, kgo.ConsumerGroup(p.consumerGroup))
+	}
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return newEDFSKafkaError(fmt.Errorf(`error subscribing to Kafka topics %q: %w`, topics, err))
+	}
+	go consumeKafka(ctx, client, updater)
+	return nil
+}
+
+// consumeKafka passes the records of client to updater until ctx is done. Closing the client commits
+// the offsets and leaves the group.
+func consumeKafka(ctx context.Context, client *kgo.Client, updater resolve.SubscriptionUpdater) {
+	defer client.Close()
+	for {
+		fetches := client.PollFetches(ctx)
+		if ctx.Err() != nil || fetches.IsClientClosed() {
+			return
+		}
+		fetches.EachRecord(func(record *kgo.Record) {
+			updater.Update(record.Value)
+		})
+		// The client retries failed requests itself, errors are reported after it gave up
+		if len(fetches.Errors()) > 0 {
+			select {
+			case <-time.After(kafkaRetryInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (p *kafkaPubSub) Publish(ctx context.Context, topic string, data []byte) error {
+	if err := p.ensureClient(); err != nil {
+		return err
+	}
+	if err := p.client.ProduceSync(ctx, &kgo.Record{Topic: topic, Value: data}).FirstErr(); err != nil {
+		return newEDFSKafkaError(fmt.Errorf(`error publishing to Kafka topic "%s": %w`, topic, err))
+	}
+	return nil
+}
+
+func (p *kafkaPubSub) Request(_ context.Context, _ string, _ []byte, _ io.Writer) error {
+	return newEDFSKafkaError(errors.New("request is not supported by the Kafka provider"))
+}
@@ -0,0 +1,104 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/pubsub_datasource"
+)
+
+type testUpdater struct {
+	mu      sync.Mutex
+	updates []string
+}
+
+func (u *testUpdater) Update(data []byte) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.updates = append(u.updates, string(data))
+}
+
+func (u *testUpdater) Done() {}
+
+func (u *testUpdater) Updates() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.updates...)
+}
+
+func newKafkaPubSub(t *testing.T, consumerGroup string) pubsub_datasource.PubSub {
+	t.Helper()
+	cluster, err := kfake.NewCluster(
+		kfake.NumBrokers(1),
+		kfake.AllowAutoTopicCreation(),
+		kfake.EnableSASL(),
+		kfake.Superuser("SCRAM-SHA-512", "router", "secret"),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cluster.Close)
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.AllowAutoTopicCreation(),
+		kgo.SASL(scram.Auth{User: "router", Pass: "secret"}.AsSha512Mechanism()),
+	}
+	client, err := kgo.NewClient(opts...)
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	return NewKafkaConnector(client, opts, consumerGroup).New(context.Background())
+}
+
+func TestKafkaPubSub(t *testing.T) {
+	t.Parallel()
+
+	ps := newKafkaPubSub(t, "")
+	require.Equal(t, "kafka", ps.ID())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first, second := &testUpdater{}, &testUpdater{}
+	require.NoError(t, ps.Subscribe(ctx, []string{"employeeUpdated.1", "employeeUpdated.2"}, first, nil))
+	require.NoError(t, ps.Subscribe(ctx, []string{"employeeUpdated.1"}, second, nil))
+
+	require.NoError(t, ps.Publish(ctx, "employeeUpdated.1", []byte(`{"id":1}`)))
+	require.NoError(t, ps.Publish(ctx, "employeeUpdated.2", []byte(`{"id":2}`)))
+
+	require.Eventually(t, func() bool {
+		return len(first.Updates()) == 2 && len(second.Updates()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.ElementsMatch(t, []string{`{"id":1}`, `{"id":2}`}, first.Updates())
+	require.Equal(t, []string{`{"id":1}`}, second.Updates())
+
+	err := ps.Request(ctx, "employeeUpdated.1", nil, &bytes.Buffer{})
+	var kafkaErr *EDFSKafkaError
+	require.ErrorAs(t, err, &kafkaErr)
+
+	err = ps.Subscribe(ctx, []string{"employeeUpdated.1"}, first, &pubsub_datasource.StreamConfiguration{Consumer: "consumer", StreamName: "stream"})
+	require.ErrorAs(t, err, &kafkaErr)
+}
+
+func TestKafkaPubSubConsumerGroup(t *testing.T) {
+	t.Parallel()
+
+	ps := newKafkaPubSub(t, "router")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updater := &testUpdater{}
+	require.NoError(t, ps.Subscribe(ctx, []string{"employeeUpdated"}, updater, nil))
+	require.NoError(t, ps.Publish(ctx, "employeeUpdated", []byte(`{"id":1}`)))
+
+	require.Eventually(t, func() bool {
+		return len(updater.Updates()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{`{"id":1}`}, updater.Updates())
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/nats-io/nats.go"
-	"github.com/nats-io/nats.go/jetstream"
-	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
 	"io"
+	"time"
 
+	"github.com/cloudflare/backoff"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nats-io/nuid"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/pubsub_datasource"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"go.uber.org/zap"
 )
 
 var (
@@ -31,24 +35,117 @@ func newEDFSNatsError(err error) *EDFSNatsError {
 	}
 }
 
+const (
+	// natsMessageBufferSize is the number of messages buffered per subscription. The client drops messages
+	// when the buffer is full.
+	natsMessageBufferSize             = 1024
+	defaultJetStreamBatchSize         = 10
+	defaultJetStreamInactiveThreshold = 30 * time.Second
+	// jetStreamFetchMaxWait bounds blocking fetches, so subscriptions notice the end of their context
+	jetStreamFetchMaxWait = time.Second
+	// jetStreamDeleteTimeout bounds the deletion of ephemeral consumers when a subscription ends
+	jetStreamDeleteTimeout = 5 * time.Second
+	// jetStreamRetryInterval and jetStreamMaxRetryInterval bound the backoff between failed fetches
+	jetStreamRetryInterval    = 100 * time.Millisecond
+	jetStreamMaxRetryInterval = 10 * time.Second
+)
+
+// JetStreamConsumerOptions configure the consumers of subscriptions with a stream configuration
+type JetStreamConsumerOptions struct {
+	// AckPolicy of the consumers. Messages are acknowledged when they are handed off to the subscription,
+	// before they are written to the clients.
+	AckPolicy jetstream.AckPolicy
+	// MaxDeliver is the maximum number of delivery attempts of a message, unlimited if not set
+	MaxDeliver int
+	// BatchSize is the maximum number of messages requested at once, 10 if not set
+	BatchSize int
+	// Ephemeral creates a consumer for every subscription instead of the durable consumer of the stream
+	// configuration. It receives the messages published after the subscription started and is deleted
+	// when the subscription ends.
+	Ephemeral bool
+	// InactiveThreshold after which the server deletes ephemeral consumers of lost subscriptions, 30s if not set
+	InactiveThreshold time.Duration
+}
+
+// NATSMetrics records the messages of a NATS source
+type NATSMetrics interface {
+	MessageReceived()
+	MessagePublished()
+	MessagesDropped(count int64)
+}
+
+type noopNATSMetrics struct{}
+
+func (noopNATSMetrics) MessageReceived()      {}
+func (noopNATSMetrics) MessagePublished()     {}
+func (noopNATSMetrics) MessagesDropped(int64) {}
+
+type NATSOption func(c *natsConnector)
+
+// WithNATSLogger sets the logger for errors of subscriptions
+func WithNATSLogger(logger *zap.Logger) NATSOption {
+	return func(c *natsConnector) {
+		c.logger = logger
+	}
+}
+
+// WithNATSMetrics sets the recorder for received, published and dropped messages
+func WithNATSMetrics(metrics NATSMetrics) NATSOption {
+	return func(c *natsConnector) {
+		c.metrics = metrics
+	}
+}
+
+// WithJetStreamConsumerOptions configures the consumers of subscriptions with a stream configuration
+func WithJetStreamConsumerOptions(opts JetStreamConsumerOptions) NATSOption {
+	return func(c *natsConnector) {
+		c.jetStream = opts
+	}
+}
+
 type natsConnector struct {
-	conn *nats.Conn
+	conn      *nats.Conn
+	logger    *zap.Logger
+	metrics   NATSMetrics
+	jetStream JetStreamConsumerOptions
 }
 
-func NewNATSConnector(conn *nats.Conn) pubsub_datasource.Connector {
-	return &natsConnector{conn: conn}
+func NewNATSConnector(conn *nats.Conn, opts ...NATSOption) pubsub_datasource.Connector {
+	c := &natsConnector{conn: conn}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.logger == nil {
+		c.logger = zap.NewNop()
+	}
+	if c.metrics == nil {
+		c.metrics = noopNATSMetrics{}
+	}
+	if c.jetStream.BatchSize <= 0 {
+		c.jetStream.BatchSize = defaultJetStreamBatchSize
+	}
+	if c.jetStream.InactiveThreshold <= 0 {
+		c.jetStream.InactiveThreshold = defaultJetStreamInactiveThreshold
+	}
+	return c
 }
 
 func (c *natsConnector) New(ctx context.Context) pubsub_datasource.PubSub {
 	return &natsPubSub{
-		ctx:  ctx,
-		conn: c.conn,
+		ctx:       ctx,
+		conn:      c.conn,
+		logger:    c.logger,
+		metrics:   c.metrics,
+		jetStream: c.jetStream,
 	}
 }
 
 type natsPubSub struct {
-	ctx  context.Context
-	conn *nats.Conn
+	ctx       context.Context
+	conn      *nats.Conn
+	logger    *zap.Logger
+	metrics   NATSMetrics
+	jetStream JetStreamConsumerOptions
 }
 
 func (p *natsPubSub) ID() string {
@@ -67,59 +164,31 @@ func (p *natsPubSub) Subscribe(ctx context.Context, subjects []string, updater r
 		return newEDFSNatsError(fmt.Errorf(`failed to ensure nats connection: %w`, err))
 	}
 	if streamConfiguration != nil {
-		js, err := jetstream.New(p.conn)
-		if err != nil {
-			return newEDFSNatsError(fmt.Errorf(`failed to create jetstream: %w`, err))
-		}
-
-		consumer, err := js.CreateOrUpdateConsumer(ctx, streamConfiguration.StreamName, jetstream.ConsumerConfig{
-			Durable:        streamConfiguration.Consumer, // Durable consumers are not removed automatically regardless of the InactiveThreshold
-			FilterSubjects: subjects,
-		})
-		if err != nil {
-			return newEDFSNatsError(fmt.Errorf(`failed to create or update consumer "%s": %w`, streamConfiguration.Consumer, err))
-		}
-		if consumer == nil {
-			return newEDFSNatsError(fmt.Errorf(`consumer "%s" is nil; it is likely the nats stream "%s" does not exist`, streamConfiguration.Consumer, streamConfiguration.StreamName))
-		}
-		go func() {
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-				}
-				msgBatch, consumerFetchErr := consumer.FetchNoWait(1)
-				if consumerFetchErr != nil {
-					return
-				}
-				for msg := range msgBatch.Messages() {
-					ackErr := msg.Ack()
-					if ackErr != nil {
-						return
-					}
-					updater.Update(msg.Data())
-				}
-			}
-		}()
-		return nil
+		return p.subscribeJetStream(ctx, subjects, updater, streamConfiguration)
 	}
 
-	msgChan := make(chan *nats.Msg)
-	subscriptions := make([]*nats.Subscription, len(subjects))
-	for i, subject := range subjects {
+	msgChan := make(chan *nats.Msg, natsMessageBufferSize)
+	subscriptions := make([]*nats.Subscription, 0, len(subjects))
+	for _, subject := range subjects {
 		subscription, err := p.conn.ChanSubscribe(subject, msgChan)
 		if err != nil {
+			for _, subscription := range subscriptions {
+				_ = subscription.Unsubscribe()
+			}
 			return newEDFSNatsError(fmt.Errorf(`error subscribing to NATS subject "%s": %w`, subject, err))
 		}
-		subscriptions[i] = subscription
+		subscriptions = append(subscriptions, subscription)
 	}
 	go func() {
+		dropped := make([]int, len(subscriptions))
 		for {
 			select {
 			case msg := <-msgChan:
+				p.metrics.MessageReceived()
+				p.recordDropped(subscriptions, dropped)
 				updater.Update(msg.Data)
 			case <-ctx.Done():
+				p.recordDropped(subscriptions, dropped)
 				for _, subscription := range subscriptions {
 					_ = subscription.Unsubscribe()
 				}
@@ -130,11 +199,146 @@ func (p *natsPubSub) Subscribe(ctx context.Context, subjects []string, updater r
 	return nil
 }
 
+// recordDropped records the messages the client dropped since the last call. dropped holds the
+// previous count of each subscription.
+func (p *natsPubSub) recordDropped(subscriptions []*nats.Subscription, dropped []int) {
+	for i, subscription := range subscriptions {
+		count, err := subscription.Dropped()
+		if err != nil || count <= dropped[i] {
+			continue
+		}
+		p.logger.Warn("NATS subscription dropped messages, the subscriber is too slow",
+			zap.String("subject", subscription.Subject),
+			zap.Int("dropped", count-dropped[i]),
+		)
+		p.metrics.MessagesDropped(int64(count - dropped[i]))
+		dropped[i] = count
+	}
+}
+
+func (p *natsPubSub) subscribeJetStream(ctx context.Context, subjects []string, updater resolve.SubscriptionUpdater, streamConfiguration *pubsub_datasource.StreamConfiguration) error {
+	js, err := jetstream.New(p.conn)
+	if err != nil {
+		return newEDFSNatsError(fmt.Errorf(`failed to create jetstream: %w`, err))
+	}
+
+	consumerConfig := jetstream.ConsumerConfig{
+		Durable:        streamConfiguration.Consumer,
+		FilterSubjects: subjects,
+		AckPolicy:      p.jetStream.AckPolicy,
+		MaxDeliver:     p.jetStream.MaxDeliver,
+	}
+	if p.jetStream.Ephemeral {
+		consumerConfig.Durable = ""
+		consumerConfig.Name = streamConfiguration.Consumer + "-" + nuid.Next()
+		consumerConfig.DeliverPolicy = jetstream.DeliverNewPolicy
+		consumerConfig.InactiveThreshold = p.jetStream.InactiveThreshold
+	}
+	consumerName := consumerConfig.Durable
+	if consumerName == "" {
+		consumerName = consumerConfig.Name
+	}
+
+	consumer, err := js.CreateOrUpdateConsumer(ctx, streamConfiguration.StreamName, consumerConfig)
+	if err != nil {
+		return newEDFSNatsError(fmt.Errorf(`failed to create or update consumer "%s": %w`, consumerName, err))
+	}
+	if consumer == nil {
+		return newEDFSNatsError(fmt.Errorf(`consumer "%s" is nil; it is likely the nats stream "%s" does not exist`, consumerName, streamConfiguration.StreamName))
+	}
+
+	logger := p.logger.With(zap.String("stream", streamConfiguration.StreamName), zap.String("consumer", consumerName))
+	go func() {
+		if p.jetStream.Ephemeral {
+			defer func() {
+				deleteCtx, cancel := context.WithTimeout(context.Background(), jetStreamDeleteTimeout)
+				defer cancel()
+				if err := js.DeleteConsumer(deleteCtx, streamConfiguration.StreamName, consumerName); err != nil && !errors.Is(err, jetstream.ErrConsumerNotFound) {
+					logger.Warn("Failed to delete ephemeral JetStream consumer", zap.Error(err))
+				}
+			}()
+		}
+		p.runJetStream(ctx, consumer, updater, logger)
+	}()
+	return nil
+}
+
+// ErrorUpdater receives the error that ends a subscription, so it can be sent to the clients before
+// the subscription is completed
+type ErrorUpdater interface {
+	resolve.SubscriptionUpdater
+	UpdateError(err error)
+}
+
+// runJetStream consumes the messages of consumer until ctx is done. When the consumer fails, the error is
+// sent to updaters implementing ErrorUpdater and the subscription is completed, so the client can
+// subscribe again.
+func (p *natsPubSub) runJetStream(ctx context.Context, consumer jetstream.Consumer, updater resolve.SubscriptionUpdater, logger *zap.Logger) {
+	err := p.consumeJetStream(ctx, consumer, updater, logger)
+	if err == nil {
+		return
+	}
+	logger.Error("JetStream subscription failed", zap.Error(err))
+	if u, ok := updater.(ErrorUpdater); ok {
+		u.UpdateError(newEDFSNatsError(err))
+	}
+	updater.Done()
+}
+
+// consumeJetStream passes the messages of consumer to updater until ctx is done. Failed fetches, e.g. while
+// the connection reconnects, are retried with backoff as long as the consumer exists. Messages are
+// acknowledged when they are handed off to updater, which only queues them for the clients. Messages
+// that are not written to the clients yet when the router stops are lost.
+func (p *natsPubSub) consumeJetStream(ctx context.Context, consumer jetstream.Consumer, updater resolve.SubscriptionUpdater, logger *zap.Logger) error {
+	ack := p.jetStream.AckPolicy != jetstream.AckNonePolicy
+	b := backoff.New(jetStreamMaxRetryInterval, jetStreamRetryInterval)
+	for ctx.Err() == nil {
+		batch, err := consumer.Fetch(p.jetStream.BatchSize, jetstream.FetchMaxWait(jetStreamFetchMaxWait))
+		if err == nil {
+			for msg := range batch.Messages() {
+				if ctx.Err() != nil {
+					// The subscription ended, the message is delivered again
+					if ack {
+						_ = msg.Nak()
+					}
+					continue
+				}
+				p.metrics.MessageReceived()
+				updater.Update(msg.Data())
+				if ack {
+					if err := msg.Ack(); err != nil {
+						logger.Warn("Failed to acknowledge JetStream message", zap.Error(err))
+					}
+				}
+			}
+			err = batch.Error()
+		}
+		if err == nil {
+			b.Reset()
+			continue
+		}
+		if errors.Is(err, jetstream.ErrConsumerDeleted) || errors.Is(err, jetstream.ErrConsumerNotFound) {
+			return fmt.Errorf("failed to fetch messages: %w", err)
+		}
+		wait := b.Duration()
+		logger.Warn("Failed to fetch JetStream messages, retrying", zap.Error(err), zap.Duration("wait", wait))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+		}
+	}
+	return nil
+}
+
 func (p *natsPubSub) Publish(_ context.Context, subject string, data []byte) error {
 	if err := p.ensureConn(); err != nil {
 		return err
 	}
-	return p.conn.Publish(subject, data)
+	if err := p.conn.Publish(subject, data); err != nil {
+		return err
+	}
+	p.metrics.MessagePublished()
+	return nil
 }
 
 func (p *natsPubSub) Request(ctx context.Context, subject string, data []byte, w io.Writer) error {
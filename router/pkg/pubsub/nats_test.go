@@ -0,0 +1,165 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testConsumer returns the results of fetches in order and blocks for the fetch wait afterwards
+type testConsumer struct {
+	jetstream.Consumer
+	mu      sync.Mutex
+	results []testFetchResult
+}
+
+type testFetchResult struct {
+	messages []jetstream.Msg
+	err      error
+}
+
+func (c *testConsumer) Fetch(_ int, _ ...jetstream.FetchOpt) (jetstream.MessageBatch, error) {
+	c.mu.Lock()
+	var result testFetchResult
+	if len(c.results) > 0 {
+		result = c.results[0]
+		c.results = c.results[1:]
+	}
+	c.mu.Unlock()
+	if result.messages == nil && result.err == nil {
+		time.Sleep(jetStreamFetchMaxWait)
+	}
+	messages := make(chan jetstream.Msg, len(result.messages))
+	for _, msg := range result.messages {
+		messages <- msg
+	}
+	close(messages)
+	return &testBatch{messages: messages, err: result.err}, nil
+}
+
+type testBatch struct {
+	messages chan jetstream.Msg
+	err      error
+}
+
+func (b *testBatch) Messages() <-chan jetstream.Msg {
+	return b.messages
+}
+
+func (b *testBatch) Error() error {
+	return b.err
+}
+
+type testMsg struct {
+	jetstream.Msg
+	data  []byte
+	acked chan struct{}
+}
+
+func (m *testMsg) Data() []byte {
+	return m.data
+}
+
+func (m *testMsg) Ack() error {
+	close(m.acked)
+	return nil
+}
+
+func newTestMsg(data string) *testMsg {
+	return &testMsg{data: []byte(data), acked: make(chan struct{})}
+}
+
+func newJetStreamPubSub() *natsPubSub {
+	return &natsPubSub{
+		logger:    zap.NewNop(),
+		metrics:   noopNATSMetrics{},
+		jetStream: JetStreamConsumerOptions{AckPolicy: jetstream.AckExplicitPolicy, BatchSize: defaultJetStreamBatchSize},
+	}
+}
+
+func TestConsumeJetStreamRetriesFailedFetches(t *testing.T) {
+	t.Parallel()
+
+	first, second := newTestMsg(`{"id":1}`), newTestMsg(`{"id":2}`)
+	consumer := &testConsumer{results: []testFetchResult{
+		{messages: []jetstream.Msg{first}, err: errors.New("nats: connection closed")},
+		{err: errors.New("nats: timeout")},
+		{messages: []jetstream.Msg{second}},
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updater := &testUpdater{}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- newJetStreamPubSub().consumeJetStream(ctx, consumer, updater, zap.NewNop())
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(updater.Updates()) == 2
+	}, time.Second*5, time.Millisecond*10)
+	require.Equal(t, []string{`{"id":1}`, `{"id":2}`}, updater.Updates())
+	<-first.acked
+	<-second.acked
+
+	cancel()
+	require.NoError(t, <-errs)
+}
+
+func TestConsumeJetStreamEndsWhenTheConsumerIsDeleted(t *testing.T) {
+	t.Parallel()
+
+	consumer := &testConsumer{results: []testFetchResult{
+		{err: errors.New("nats: timeout")},
+		{err: jetstream.ErrConsumerDeleted},
+	}}
+
+	err := newJetStreamPubSub().consumeJetStream(context.Background(), consumer, &testUpdater{}, zap.NewNop())
+	require.ErrorIs(t, err, jetstream.ErrConsumerDeleted)
+}
+
+// testErrorUpdater records the error and the completion of a subscription
+type testErrorUpdater struct {
+	testUpdater
+	err  error
+	done bool
+}
+
+func (u *testErrorUpdater) UpdateError(err error) {
+	u.err = err
+}
+
+func (u *testErrorUpdater) Done() {
+	u.done = true
+}
+
+func TestRunJetStreamSendsTheErrorBeforeCompleting(t *testing.T) {
+	t.Parallel()
+
+	consumer := &testConsumer{results: []testFetchResult{
+		{err: jetstream.ErrConsumerDeleted},
+	}}
+
+	updater := &testErrorUpdater{}
+	newJetStreamPubSub().runJetStream(context.Background(), consumer, updater, zap.NewNop())
+
+	var natsErr *EDFSNatsError
+	require.ErrorAs(t, updater.err, &natsErr)
+	require.ErrorIs(t, updater.err, jetstream.ErrConsumerDeleted)
+	require.True(t, updater.done)
+
+	// Subscriptions that end with their context are completed by the resolver
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	updater = &testErrorUpdater{}
+	newJetStreamPubSub().runJetStream(ctx, &testConsumer{}, updater, zap.NewNop())
+	require.NoError(t, updater.err)
+	require.False(t, updater.done)
+}
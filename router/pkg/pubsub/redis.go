@@ -0,0 +1,385 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/pubsub_datasource"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+var (
+	_ pubsub_datasource.Connector = (*redisConnector)(nil)
+	_ pubsub_datasource.PubSub    = (*redisPubSub)(nil)
+)
+
+const (
+	// redisStreamField is the field of stream entries holding the event data
+	redisStreamField = "data"
+	// redisBlockTimeout bounds blocking stream reads, so subscriptions notice the end of their context
+	redisBlockTimeout = time.Second
+	// redisRetryInterval is the wait before reading a stream again after an error
+	redisRetryInterval = time.Second
+)
+
+// LatestCursor starts a subscription with the events published after it started
+const LatestCursor = "$"
+
+type cursorKey struct{}
+
+// WithCursor returns a context carrying the cursor of a client, the ID of the last stream entry it
+// received or LatestCursor. Subscriptions to Redis streams started with the context read the entries
+// after the cursor and pass the ID of every entry to updaters implementing CursorUpdater.
+func WithCursor(ctx context.Context, cursor string) context.Context {
+	return context.WithValue(ctx, cursorKey{}, cursor)
+}
+
+// CursorFromContext returns the cursor set with WithCursor or an empty string
+func CursorFromContext(ctx context.Context) string {
+	cursor, _ := ctx.Value(cursorKey{}).(string)
+	return cursor
+}
+
+// CursorUpdater receives the events of subscriptions with a cursor together with the ID of their
+// stream entry, the cursor to resume after the event.
+type CursorUpdater interface {
+	resolve.SubscriptionUpdater
+	UpdateCursor(data []byte, cursor string)
+}
+
+// validCursor reports whether cursor is LatestCursor or a stream entry ID, e.g. 1526919030474-55
+func validCursor(cursor string) bool {
+	if cursor == LatestCursor {
+		return true
+	}
+	ms, seq, hasSeq := strings.Cut(cursor, "-")
+	if _, err := strconv.ParseUint(ms, 10, 64); err != nil {
+		return false
+	}
+	if hasSeq {
+		if _, err := strconv.ParseUint(seq, 10, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+type EDFSRedisError struct {
+	Err error
+}
+
+func (e *EDFSRedisError) Error() string { return e.Err.Error() }
+
+func (e *EDFSRedisError) Unwrap() error { return e.Err }
+
+func newEDFSRedisError(err error) *EDFSRedisError {
+	return &EDFSRedisError{
+		Err: err,
+	}
+}
+
+type redisConnector struct {
+	client        *redis.Client
+	consumerGroup string
+}
+
+// NewRedisConnector creates a connector for client. Without a consumerGroup, events are published to
+// Pub/Sub channels and delivered to the subscriptions connected at that time only. With a consumerGroup,
+// events are appended to streams and read through the group, every entry is delivered to one of its
+// subscriptions. Subscriptions with a cursor (see WithCursor) read the streams after the cursor instead
+// of the group, so clients resume after the last entry they received when they reconnect.
+func NewRedisConnector(client *redis.Client, consumerGroup string) pubsub_datasource.Connector {
+	return &redisConnector{client: client, consumerGroup: consumerGroup}
+}
+
+func (c *redisConnector) New(ctx context.Context) pubsub_datasource.PubSub {
+	return &redisPubSub{
+		ctx:           ctx,
+		client:        c.client,
+		consumerGroup: c.consumerGroup,
+	}
+}
+
+type redisPubSub struct {
+	ctx           context.Context
+	client        *redis.Client
+	consumerGroup string
+}
+
+func (p *redisPubSub) ID() string {
+	return "redis"
+}
+
+func (p *redisPubSub) ensureClient() error {
+	if p.client == nil {
+		return newEDFSRedisError(errors.New("Redis is not configured"))
+	}
+	return nil
+}
+
+func (p *redisPubSub) Subscribe(ctx context.Context, keys []string, updater resolve.SubscriptionUpdater, streamConfiguration *pubsub_datasource.StreamConfiguration) error {
+	if err := p.ensureClient(); err != nil {
+		return err
+	}
+	if streamConfiguration != nil {
+		return newEDFSRedisError(errors.New("stream configurations are not supported by the Redis provider"))
+	}
+	if p.consumerGroup == "" {
+		return p.subscribeChannels(ctx, keys, updater)
+	}
+	return p.subscribeStreams(ctx, keys, updater)
+}
+
+func (p *redisPubSub) subscribeChannels(ctx context.Context, channels []string, updater resolve.SubscriptionUpdater) error {
+	sub := p.client.Subscribe(ctx, channels...)
+	// Wait for the confirmation of every channel, events published afterward are not missed
+	for range channels {
+		if _, err := sub.Receive(ctx); err != nil {
+			_ = sub.Close()
+			return newEDFSRedisError(fmt.Errorf(`error subscribing to Redis channels %q: %w`, channels, err))
+		}
+	}
+	messages := sub.Channel()
+	go func() {
+		defer sub.Close()
+		for {
+			select {
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				updater.Update([]byte(msg.Payload))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// streamReader reads the entries of streams through a consumer group
+type streamReader struct {
+	client   *redis.Client
+	streams  []string
+	group    string
+	consumer string
+	updater  resolve.SubscriptionUpdater
+}
+
+func (p *redisPubSub) subscribeStreams(ctx context.Context, streams []string, updater resolve.SubscriptionUpdater) error {
+	if cursor := CursorFromContext(ctx); cursor != "" {
+		return p.subscribeCursor(ctx, streams, cursor, updater)
+	}
+	r := &streamReader{
+		client:   p.client,
+		streams:  streams,
+		group:    p.consumerGroup,
+		consumer: uuid.NewString(),
+		updater:  updater,
+	}
+	if err := r.createGroups(ctx); err != nil {
+		return newEDFSRedisError(fmt.Errorf(`error subscribing to Redis streams %q: %w`, streams, err))
+	}
+	go r.run(ctx)
+	return nil
+}
+
+// createGroups creates the consumer group on every stream. New groups start at the end of the streams.
+func (r *streamReader) createGroups(ctx context.Context) error {
+	for _, stream := range r.streams {
+		err := r.client.XGroupCreateMkStream(ctx, stream, r.group, "$").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf(`failed to create consumer group "%s" on stream "%s": %w`, r.group, stream, err)
+		}
+	}
+	return nil
+}
+
+func (r *streamReader) run(ctx context.Context) {
+	defer func() {
+		// Remove the consumer so it does not pile up in the group
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), redisBlockTimeout)
+		defer cancel()
+		for _, stream := range r.streams {
+			_ = r.client.XGroupDelConsumer(cleanupCtx, stream, r.group, r.consumer).Err()
+		}
+	}()
+
+	// Entries delivered to the consumer before but not acknowledged are read first
+	pending := true
+	for ctx.Err() == nil {
+		streams, err := r.read(ctx, pending)
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				pending = false
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			// The streams or the group might have been deleted in the meantime
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				_ = r.createGroups(ctx)
+			}
+			select {
+			case <-time.After(redisRetryInterval):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			// Entries read after the end of the subscription stay pending and are delivered again
+			return
+		}
+		delivered := 0
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				delivered++
+				if data, ok := msg.Values[redisStreamField].(string); ok {
+					r.updater.Update([]byte(data))
+				}
+				_ = r.client.XAck(ctx, stream.Stream, r.group, msg.ID).Err()
+			}
+		}
+		if pending && delivered == 0 {
+			pending = false
+		}
+	}
+}
+
+// read returns the next entries of the streams. When pending is true, the entries delivered to the
+// consumer but not acknowledged are returned instead.
+func (r *streamReader) read(ctx context.Context, pending bool) ([]redis.XStream, error) {
+	id, block := ">", redisBlockTimeout
+	if pending {
+		id, block = "0", -1
+	}
+	streams := make([]string, 0, len(r.streams)*2)
+	streams = append(streams, r.streams...)
+	for range r.streams {
+		streams = append(streams, id)
+	}
+	return r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    r.group,
+		Consumer: r.consumer,
+		Streams:  streams,
+		Count:    100,
+		Block:    block,
+	}).Result()
+}
+
+// cursorReader reads the entries of streams after the cursor of a client
+type cursorReader struct {
+	client  *redis.Client
+	streams []string
+	// ids are the IDs of the last entries read by stream
+	ids     map[string]string
+	updater resolve.SubscriptionUpdater
+}
+
+func (p *redisPubSub) subscribeCursor(ctx context.Context, streams []string, cursor string, updater resolve.SubscriptionUpdater) error {
+	if !validCursor(cursor) {
+		return newEDFSRedisError(fmt.Errorf(`invalid cursor "%s", expected a stream entry ID or "%s"`, cursor, LatestCursor))
+	}
+	r := &cursorReader{
+		client:  p.client,
+		streams: streams,
+		ids:     make(map[string]string, len(streams)),
+		updater: updater,
+	}
+	for _, stream := range streams {
+		r.ids[stream] = cursor
+		if cursor != LatestCursor {
+			continue
+		}
+		// Resolve the latest entry now, so events published before the first read are not missed
+		latest, err := p.client.XRevRangeN(ctx, stream, "+", "-", 1).Result()
+		if err != nil {
+			return newEDFSRedisError(fmt.Errorf(`error subscribing to Redis stream "%s": %w`, stream, err))
+		}
+		r.ids[stream] = "0-0"
+		if len(latest) > 0 {
+			r.ids[stream] = latest[0].ID
+		}
+	}
+	go r.run(ctx)
+	return nil
+}
+
+func (r *cursorReader) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		streams := make([]string, 0, len(r.streams)*2)
+		streams = append(streams, r.streams...)
+		for _, stream := range r.streams {
+			streams = append(streams, r.ids[stream])
+		}
+		result, err := r.client.XRead(ctx, &redis.XReadArgs{
+			Streams: streams,
+			Count:   100,
+			Block:   redisBlockTimeout,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			select {
+			case <-time.After(redisRetryInterval):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		for _, stream := range result {
+			for _, msg := range stream.Messages {
+				if ctx.Err() != nil {
+					return
+				}
+				if data, ok := msg.Values[redisStreamField].(string); ok {
+					updateCursor(r.updater, []byte(data), msg.ID)
+				}
+				r.ids[stream.Stream] = msg.ID
+			}
+		}
+	}
+}
+
+// updateCursor passes the ID of the stream entry with the event to updaters implementing CursorUpdater
+func updateCursor(updater resolve.SubscriptionUpdater, data []byte, cursor string) {
+	if u, ok := updater.(CursorUpdater); ok {
+		u.UpdateCursor(data, cursor)
+		return
+	}
+	updater.Update(data)
+}
+
+func (p *redisPubSub) Publish(ctx context.Context, key string, data []byte) error {
+	if err := p.ensureClient(); err != nil {
+		return err
+	}
+	if p.consumerGroup == "" {
+		if err := p.client.Publish(ctx, key, data).Err(); err != nil {
+			return newEDFSRedisError(fmt.Errorf(`error publishing to Redis channel "%s": %w`, key, err))
+		}
+		return nil
+	}
+	err := p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		Values: []any{redisStreamField, data},
+	}).Err()
+	if err != nil {
+		return newEDFSRedisError(fmt.Errorf(`error publishing to Redis stream "%s": %w`, key, err))
+	}
+	return nil
+}
+
+func (p *redisPubSub) Request(_ context.Context, _ string, _ []byte, _ io.Writer) error {
+	return newEDFSRedisError(errors.New("request is not supported by the Redis provider"))
+}
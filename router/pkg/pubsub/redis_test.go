@@ -0,0 +1,145 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/pubsub_datasource"
+)
+
+func newRedisPubSub(t *testing.T, consumerGroup string) pubsub_datasource.PubSub {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return NewRedisConnector(client, consumerGroup).New(context.Background())
+}
+
+func TestRedisPubSubChannels(t *testing.T) {
+	t.Parallel()
+
+	ps := newRedisPubSub(t, "")
+	require.Equal(t, "redis", ps.ID())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first, second := &testUpdater{}, &testUpdater{}
+	require.NoError(t, ps.Subscribe(ctx, []string{"employeeUpdated.1", "employeeUpdated.2"}, first, nil))
+	require.NoError(t, ps.Subscribe(ctx, []string{"employeeUpdated.1"}, second, nil))
+
+	require.NoError(t, ps.Publish(ctx, "employeeUpdated.1", []byte(`{"id":1}`)))
+	require.NoError(t, ps.Publish(ctx, "employeeUpdated.2", []byte(`{"id":2}`)))
+
+	require.Eventually(t, func() bool {
+		return len(first.Updates()) == 2 && len(second.Updates()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.ElementsMatch(t, []string{`{"id":1}`, `{"id":2}`}, first.Updates())
+	require.Equal(t, []string{`{"id":1}`}, second.Updates())
+
+	err := ps.Request(ctx, "employeeUpdated.1", nil, &bytes.Buffer{})
+	var redisErr *EDFSRedisError
+	require.ErrorAs(t, err, &redisErr)
+
+	err = ps.Subscribe(ctx, []string{"employeeUpdated.1"}, first, &pubsub_datasource.StreamConfiguration{Consumer: "consumer", StreamName: "stream"})
+	require.ErrorAs(t, err, &redisErr)
+}
+
+func TestRedisPubSubStreams(t *testing.T) {
+	t.Parallel()
+
+	ps := newRedisPubSub(t, "router")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first, second := &testUpdater{}, &testUpdater{}
+	require.NoError(t, ps.Subscribe(ctx, []string{"employeeUpdated"}, first, nil))
+	require.NoError(t, ps.Subscribe(ctx, []string{"employeeUpdated"}, second, nil))
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, ps.Publish(ctx, "employeeUpdated", []byte(strconv.Itoa(i))))
+	}
+
+	// Every entry is delivered to one of the subscriptions of the group
+	require.Eventually(t, func() bool {
+		return len(first.Updates())+len(second.Updates()) == 10
+	}, 5*time.Second, 10*time.Millisecond)
+	require.ElementsMatch(t, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}, append(first.Updates(), second.Updates()...))
+}
+
+// cursorUpdater records the cursors of the updates besides the updates
+type cursorUpdater struct {
+	testUpdater
+	cursors []string
+}
+
+func (u *cursorUpdater) UpdateCursor(data []byte, cursor string) {
+	u.mu.Lock()
+	u.cursors = append(u.cursors, cursor)
+	u.mu.Unlock()
+	u.Update(data)
+}
+
+func (u *cursorUpdater) Cursors() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.cursors...)
+}
+
+func TestRedisPubSubStreamsCursor(t *testing.T) {
+	t.Parallel()
+
+	ps := newRedisPubSub(t, "router")
+
+	ctx, cancel := context.WithCancel(WithCursor(context.Background(), LatestCursor))
+	updater := &cursorUpdater{}
+	require.NoError(t, ps.Subscribe(ctx, []string{"employeeUpdated"}, updater, nil))
+	require.NoError(t, ps.Publish(ctx, "employeeUpdated", []byte(`{"id":1}`)))
+	require.Eventually(t, func() bool {
+		return len(updater.Updates()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	cursor := updater.Cursors()[0]
+	require.True(t, validCursor(cursor))
+
+	// Events published while the client is disconnected are delivered when it resumes with the cursor
+	cancel()
+	require.NoError(t, ps.Publish(context.Background(), "employeeUpdated", []byte(`{"id":2}`)))
+	require.NoError(t, ps.Publish(context.Background(), "employeeUpdated", []byte(`{"id":3}`)))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	resumed := &cursorUpdater{}
+	require.NoError(t, ps.Subscribe(WithCursor(ctx, cursor), []string{"employeeUpdated"}, resumed, nil))
+
+	// The latest cursor starts with the events published after the subscription started
+	latest := &cursorUpdater{}
+	require.NoError(t, ps.Subscribe(WithCursor(ctx, LatestCursor), []string{"employeeUpdated"}, latest, nil))
+	require.NoError(t, ps.Publish(ctx, "employeeUpdated", []byte(`{"id":4}`)))
+
+	// Subscriptions with a cursor do not share a group, each of them receives all entries
+	all := &cursorUpdater{}
+	require.NoError(t, ps.Subscribe(WithCursor(ctx, "0"), []string{"employeeUpdated"}, all, nil))
+
+	require.Eventually(t, func() bool {
+		return len(resumed.Updates()) == 3 && len(latest.Updates()) == 1 && len(all.Updates()) == 4
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{`{"id":2}`, `{"id":3}`, `{"id":4}`}, resumed.Updates())
+	require.Equal(t, []string{`{"id":4}`}, latest.Updates())
+	require.Equal(t, []string{`{"id":1}`, `{"id":2}`, `{"id":3}`, `{"id":4}`}, all.Updates())
+	require.Equal(t, []string{`{"id":1}`}, updater.Updates())
+	require.Equal(t, append([]string{cursor}, resumed.Cursors()...), all.Cursors())
+	require.Equal(t, resumed.Cursors()[2:], latest.Cursors())
+
+	err := ps.Subscribe(WithCursor(ctx, "client"), []string{"employeeUpdated"}, &cursorUpdater{}, nil)
+	var redisErr *EDFSRedisError
+	require.ErrorAs(t, err, &redisErr)
+}